@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"commandripple/internal/commands"
+	"commandripple/internal/commands/config"
+	"commandripple/internal/commands/finder"
+
+	"github.com/chzyer/readline"
+	"github.com/mattn/go-runewidth"
+)
+
+// keyBindings adds the shell's own keys to the line editor: Ctrl-R and
+// Ctrl-T, or the keys set in the config, open the fuzzy finder for the
+// history and for files below the current directory, and Right (Ctrl-F) or
+// Alt-F accept the autosuggestion shown after the cursor, whole or word by
+// word. Keys are seen by the input filter before the line editor gets them;
+// the new line is handed to the listener, which is the only hook that may
+// replace the buffer and the cursor position.
+type keyBindings struct {
+	router     *finder.Router
+	historyKey rune
+	fileKey    rune
+
+	mutex   sync.Mutex
+	line    []rune
+	pos     int
+	pending []rune // Buffer to apply on the next listener call
+	hasEdit bool
+
+	prompt      string // Shown before the line, to fit the suggestion on screen
+	rightPrompt string // Shown at the right edge of the line
+	accepted    bool   // The line was entered, so no suggestion is drawn
+	suggestFor  string // The line the suggestion was looked up for
+	suggestion  string
+}
+
+func newKeyBindings() *keyBindings {
+	b := &keyBindings{router: finder.NewRouter(readline.Stdin)}
+	b.configure()
+	return b
+}
+
+// configure takes the finder keys from the settings, which were checked
+// when they were loaded
+func (b *keyBindings) configure() {
+	settings := commands.Default.Settings()
+	historyKey, _ := config.ParseKey(settings.String("keybindings.history_search"))
+	fileKey, _ := config.ParseKey(settings.String("keybindings.file_search"))
+	b.mutex.Lock()
+	b.historyKey, b.fileKey = historyKey, fileKey
+	b.mutex.Unlock()
+}
+
+// reset forgets the line of the previous prompt
+func (b *keyBindings) reset(prompt, rightPrompt string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.line, b.pos = nil, 0
+	b.hasEdit = false
+	b.prompt, b.rightPrompt = prompt, rightPrompt
+	b.accepted = false
+	b.suggestFor, b.suggestion = "", ""
+}
+
+// FilterInputRune implements readline's FuncFilterInputRune
+func (b *keyBindings) FilterInputRune(r rune) (rune, bool) {
+	b.mutex.Lock()
+	historyKey, fileKey := b.historyKey, b.fileKey
+	b.mutex.Unlock()
+
+	switch r {
+	case historyKey, fileKey:
+		b.runFinder(r == historyKey)
+	case readline.CharForward, readline.MetaForward:
+		if !b.acceptSuggestion(r == readline.MetaForward) {
+			return r, true
+		}
+	case readline.CharEnter, readline.CharCtrlJ, readline.CharInterrupt:
+		// The line is redrawn once more when it is entered
+		b.mutex.Lock()
+		b.accepted = true
+		b.mutex.Unlock()
+		return r, true
+	default:
+		return r, true
+	}
+
+	// Ctrl-G does nothing outside of search and completion, so the line
+	// editor just passes it on to the listener, which redraws the line
+	return readline.CharBell, true
+}
+
+// runFinder lets the user pick a history entry or files and prepares the
+// line with the selection
+func (b *keyBindings) runFinder(fromHistory bool) {
+	b.mutex.Lock()
+	line := append([]rune(nil), b.line...)
+	pos := b.pos
+	b.mutex.Unlock()
+
+	input := b.router.Activate()
+	var newLine []rune
+	var newPos int
+	if fromHistory {
+		command, err := commands.Default.PickHistory(input, string(line))
+		if err == nil {
+			newLine = []rune(command)
+			newPos = len(newLine)
+		}
+	} else {
+		// The word before the cursor becomes the query and is replaced
+		start := pos
+		for start > 0 && line[start-1] != ' ' {
+			start--
+		}
+		paths, err := commands.Default.PickFiles(input, string(line[start:pos]))
+		if err == nil {
+			insert := []rune(strings.Join(paths, " "))
+			newLine = append(append(append([]rune(nil), line[:start]...), insert...), line[pos:]...)
+			newPos = start + len(insert)
+		}
+	}
+	input.Close()
+
+	b.mutex.Lock()
+	if newLine != nil {
+		b.pending, b.pos = newLine, newPos
+	} else {
+		b.pending = line
+	}
+	b.hasEdit = true
+	b.mutex.Unlock()
+}
+
+// acceptSuggestion appends the suggestion, or its next word, to the line
+// and reports whether there was one. Away from the end of the line the keys
+// move the cursor as usual.
+func (b *keyBindings) acceptSuggestion(oneWord bool) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.pos != len(b.line) {
+		return false
+	}
+	suggestion := []rune(b.suggestionLocked(b.line))
+	if len(suggestion) == 0 {
+		return false
+	}
+
+	if oneWord {
+		end := 0
+		for end < len(suggestion) && suggestion[end] == ' ' {
+			end++
+		}
+		for end < len(suggestion) && suggestion[end] != ' ' {
+			end++
+		}
+		suggestion = suggestion[:end]
+	}
+	b.pending = append(append([]rune(nil), b.line...), suggestion...)
+	b.pos = len(b.pending)
+	b.hasEdit = true
+	return true
+}
+
+// suggestionLocked returns the autosuggestion for a line, looking it up
+// only when the line changed since the painter and the filter both need it
+func (b *keyBindings) suggestionLocked(line []rune) string {
+	if b.accepted {
+		return ""
+	}
+	if s := string(line); s != b.suggestFor {
+		b.suggestFor = s
+		b.suggestion = commands.Default.SuggestCommand(s)
+	}
+	return b.suggestion
+}
+
+// OnChange implements readline.Listener
+func (b *keyBindings) OnChange(line []rune, pos int, key rune) ([]rune, int, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.hasEdit && key == readline.CharBell {
+		b.hasEdit = false
+		b.line = b.pending
+		b.pending = nil
+		return append([]rune(nil), b.line...), b.pos, true
+	}
+	b.line = append([]rune(nil), line...)
+	b.pos = pos
+	return nil, 0, false
+}
+
+// Paint implements readline.Painter: the line is highlighted and, with the
+// cursor at its end, followed by the suggestion in dim text. The right
+// prompt is drawn at the edge of the screen while the line leaves room for
+// it. Both are cut to the rest of the screen row and the cursor is moved
+// back over them, since the line editor places the cursor as if they were
+// not there.
+func (b *keyBindings) Paint(line []rune, pos int) []rune {
+	painted := commands.Default.HighlightLine(string(line))
+	if n := len(line); n > 0 && line[n-1] == '\n' {
+		// The entered line is drawn once more with its newline
+		return []rune(painted)
+	}
+
+	b.mutex.Lock()
+	suggestion := ""
+	if pos == len(line) {
+		suggestion = b.suggestionLocked(line)
+	}
+	prompt, rightPrompt := b.prompt, b.rightPrompt
+	b.mutex.Unlock()
+
+	width := readline.GetScreenWidth()
+	if width <= 0 {
+		return []rune(painted)
+	}
+	column := visibleWidth(prompt) + runewidth.StringWidth(string(line))
+	end := width - 1 // Columns the suggestion may use
+
+	right := ""
+	if rightPrompt != "" {
+		rightWidth := visibleWidth(rightPrompt)
+		if column+rightWidth+2 <= width {
+			end = width - rightWidth - 2
+			// Saved and restored cursor, absolute column
+			right = fmt.Sprintf("\0337\033[%dG%s%s\0338", width-rightWidth, rightPrompt, commands.Reset)
+		}
+	}
+
+	ghost := ""
+	if suggestion != "" && end > column%width {
+		ghost = runewidth.Truncate(suggestion, end-column%width, "")
+	}
+	if ghostWidth := runewidth.StringWidth(ghost); ghostWidth > 0 {
+		painted += fmt.Sprintf("%s%s%s\033[%dD", commands.SuggestionColor(), ghost, commands.Reset, ghostWidth)
+	}
+	return []rune(painted + right)
+}
+
+// visibleWidth returns the width of text without its escape sequences
+func visibleWidth(text string) int {
+	width := 0
+	inEscape := false
+	for _, r := range text {
+		switch {
+		case inEscape:
+			inEscape = r < '@' || r > '~' || r == '['
+		case r == '\033':
+			inEscape = true
+		default:
+			width += runewidth.RuneWidth(r)
+		}
+	}
+	return width
+}
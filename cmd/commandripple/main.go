@@ -1,58 +1,127 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
-	"path/filepath"
 	"strings"
 	"syscall"
+	"time"
 
 	"commandripple/internal/commands"
+	"commandripple/internal/commands/history"
+	"commandripple/internal/commands/svc"
+	"commandripple/internal/commands/theme"
+	"commandripple/internal/commands/ulimit"
 
 	"github.com/chzyer/readline"
-)
-
-var (
-	history     []string
-	historyFile = filepath.Join(os.TempDir(), "commandripple_history")
+	"github.com/mattn/go-runewidth"
 )
 
 func main() {
+	// The shell re-executes itself to supervise services started with svc
+	if code, ok := svc.RunInternal(os.Args[1:]); ok {
+		os.Exit(code)
+	}
+	// and to set resource limits before running a command
+	if code, ok := ulimit.RunInternal(os.Args[1:]); ok {
+		os.Exit(code)
+	}
+
+	norc := flag.Bool("norc", false, "do not read ~/.commandripplerc and config.toml")
+	rcfile := flag.String("rcfile", "", "read `file` instead of ~/.commandripplerc")
+	flag.Parse()
+
+	if !*norc {
+		if err := commands.Default.LoadConfig(""); err != nil {
+			reportError(err)
+		}
+	}
+
+	// Take control of the terminal so jobs can be stopped and resumed
+	commands.InitJobControl()
+
 	// Setup signal handling
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
 	go func() {
 		for sig := range sigChan {
-			if sig == syscall.SIGINT {
+			switch sig {
+			case syscall.SIGINT:
 				fmt.Println("\nCTRL-C detected. Use 'exit' to quit the shell.")
+			case syscall.SIGHUP:
+				// The terminal went away, pass the hangup on to the jobs
+				commands.Default.HangupJobs()
+				os.Exit(128 + int(syscall.SIGHUP))
 			}
 		}
 	}()
 
-	// Initialize readline
+	// Initialize readline, with Ctrl-R and Ctrl-T opening the fuzzy finder
+	// and the line colored and completed from history as it is typed
+	bindings := newKeyBindings()
 	rl, err := readline.NewEx(&readline.Config{
-		Prompt:          getPrompt(),
-		HistoryFile:     historyFile,
-		AutoComplete:    newCompleter(),
-		InterruptPrompt: "^C",
-		EOFPrompt:       "exit",
+		AutoComplete:           newCompleter(),
+		InterruptPrompt:        "^C",
+		EOFPrompt:              "exit",
+		DisableAutoSaveHistory: true,
+		Stdin:                  bindings.router,
+		FuncFilterInputRune:    bindings.FilterInputRune,
+		Listener:               bindings,
+		Painter:                bindings,
+		VimMode:                commands.Default.Settings().String("keybindings.mode") == "vi",
 	})
 	if err != nil {
 		panic(err)
 	}
 	defer rl.Close()
 
+	// Seed the line editor with the persistent history
+	if _, err := commands.Default.InitHistory(); err != nil {
+		reportError(err)
+	}
+	seedHistory(rl)
+
+	// Settings changed with the config builtin apply to the line editor too
+	commands.Default.OnConfigChange(func() {
+		bindings.configure()
+		rl.SetVimMode(commands.Default.Settings().String("keybindings.mode") == "vi")
+		seedHistory(rl)
+	})
+
+	// Interactive shells run the commands in the rc file first
+	if !*norc && readline.DefaultIsTerminal() {
+		sourceRC(*rcfile)
+	}
+
 	for {
+		commands.Default.NotifyJobs()
+		commands.Default.RefreshPlugins()
+		head, prompt := splitPrompt(commands.Default.Prompt())
+		fmt.Print(head)
+		rl.SetPrompt(prompt)
+		bindings.reset(prompt, commands.Default.RightPrompt())
 		line, err := rl.Readline()
+		if err == io.EOF && !commands.Default.ConfirmExit() {
+			continue
+		}
 		if err != nil { // io.EOF, readline.ErrInterrupt
 			break
 		}
+		collapsePrompt(head, prompt, line)
 
+		// Like HISTCONTROL=ignorespace, a leading space keeps a line out of
+		// the history
+		record := !strings.HasPrefix(line, " ")
 		line = strings.TrimSpace(line)
 
 		if line == "exit" {
+			if !commands.Default.ConfirmExit() {
+				continue
+			}
 			break
 		}
 
@@ -60,71 +129,88 @@ func main() {
 			continue
 		}
 
-		// Add command to history
-		readline.AddHistory(line)
+		// Expand !! and friends, showing what will actually run
+		expanded, changed, err := commands.Default.ExpandHistory(line)
+		if err != nil {
+			reportError(err)
+			continue
+		}
+		if changed {
+			line = expanded
+			fmt.Println(line)
+		}
 
-		if err := executePipeline(line); err != nil {
-			fmt.Fprintf(os.Stderr, "CommandRipple: %v\n", err)
+		// Add command to history
+		if record {
+			rl.SaveHistory(line)
 		}
+		entry := history.NewEntry(line)
 
-		// Update prompt after each command execution
-		rl.SetPrompt(getPrompt())
+		err = executePipeline(line)
+		commands.Default.CommandFinished(err, time.Since(entry.Time))
+		if err != nil {
+			reportError(err)
+		}
+		if record {
+			commands.Default.RecordHistory(entry, err)
+		}
 	}
-}
 
-func getPrompt() string {
-	pwd, err := os.Getwd()
-	if err != nil {
-		pwd = "unknown"
-	}
-	// Shorten the path if it's too long
-	if len(pwd) > 30 {
-		pwd = "..." + pwd[len(pwd)-27:]
-	}
-	return fmt.Sprintf("\033[1;34m%s\033[0m CommandRipple> ", pwd)
+	commands.Default.HangupJobs()
 }
 
-func executePipeline(commandLine string) error {
-	commandsList := strings.Split(commandLine, "|")
-	numCommands := len(commandsList)
+// reportError prints an error in the error color of the theme
+func reportError(err error) {
+	fmt.Fprintln(os.Stderr, theme.Paint(os.Stderr, theme.Error, fmt.Sprintf("CommandRipple: %v", err)))
+}
 
-	if numCommands == 1 {
-		// If there's no pipe, execute the command normally
-		return executeCommand(commandLine)
+// seedHistory fills the line editor's history with the persistent one
+func seedHistory(rl *readline.Instance) {
+	store := commands.Default.HistoryStore()
+	if store == nil {
+		return
 	}
+	rl.ResetHistory()
+	for _, entry := range store.Entries() {
+		rl.SaveHistory(entry.Command)
+	}
+}
 
-	var commandsChain []commands.Command
-
-	// Create a list of commands to execute
-	for _, cmd := range commandsList {
-		trimmedCmd := strings.TrimSpace(cmd)
-		if trimmedCmd == "" {
-			continue
+// sourceRC runs the commands of the rc file, ~/.commandripplerc unless
+// another one is given, which then has to exist
+func sourceRC(path string) {
+	if path == "" {
+		path = commands.RCPath()
+		if _, err := os.Stat(path); err != nil {
+			return
 		}
-
-		cmdName := strings.Fields(trimmedCmd)[0]
-		cmdArgs := strings.Fields(trimmedCmd)[1:]
-
-		commandsChain = append(commandsChain, commands.Command{
-			Name: cmdName,
-			Args: cmdArgs,
-		})
 	}
-
-	// Execute the command pipeline
-	return commands.ExecutePipeline(commandsChain)
+	if err := commands.Default.Source([]string{path}); err != nil {
+		reportError(err)
+	}
 }
 
-func executeCommand(commandLine string) error {
-	parts := strings.Fields(commandLine)
-	cmdName := parts[0]
-	cmdArgs := parts[1:]
+// splitPrompt splits a prompt into the lines printed before the line
+// editor starts and its last line, since the editor redraws a single line
+func splitPrompt(prompt string) (head, last string) {
+	i := strings.LastIndex(prompt, "\n")
+	return prompt[:i+1], prompt[i+1:]
+}
 
-	if commands.IsBuiltinCommand(cmdName) {
-		return commands.ExecuteBuiltin(cmdName, cmdArgs)
-	} else {
-		return commands.ExecuteExternal(cmdName, cmdArgs)
+// collapsePrompt redraws an entered line after the transient prompt, if one
+// is set, in place of the full prompt
+func collapsePrompt(head, prompt, line string) {
+	transient, ok := commands.Default.TransientPrompt()
+	width := readline.GetScreenWidth()
+	if !ok || width <= 0 {
+		return
 	}
+	rows := strings.Count(head, "\n") + (visibleWidth(prompt)+runewidth.StringWidth(line))/width + 1
+	fmt.Printf("\033[%dA\r\033[J%s%s\n", rows, transient, commands.Default.HighlightLine(line))
+}
+
+func executePipeline(commandLine string) error {
+	return commands.Default.ExecuteCommandLine(commandLine)
 }
 
 // completer implements readline.AutoCompleter interface
@@ -134,106 +220,17 @@ func newCompleter() *completer {
 	return &completer{}
 }
 
+// Do returns the rest of every word completing the one under the cursor.
+// Completed words get a trailing space, directories keep their separator so
+// that completion can continue inside them.
 func (c *completer) Do(line []rune, pos int) (newLine [][]rune, length int) {
-	lineStr := string(line[:pos])
-	parts := strings.Fields(lineStr)
-
-	if len(parts) == 0 {
-		return c.completeCommands(lineStr)
-	}
-
-	cmdName := parts[0]
-	if len(parts) == 1 {
-		return c.completeCommands(lineStr)
-	}
-
-	// Check if we're completing a path
-	if strings.Contains(parts[len(parts)-1], string(os.PathSeparator)) || strings.Contains(parts[len(parts)-1], ".") {
-		return c.completeFilesDirs(lineStr)
-	}
-
-	// Command-specific argument completion
-	switch cmdName {
-	case "cd", "ls", "rm", "mv", "cp":
-		return c.completeFilesDirs(lineStr)
-	case "chmod":
-		if len(parts) == 2 {
-			return c.completeChmodArgs(lineStr)
+	candidates, current := commands.Default.Completions(string(line[:pos]))
+	for _, candidate := range candidates {
+		suffix := candidate[len(current):]
+		if !strings.HasSuffix(candidate, string(os.PathSeparator)) {
+			suffix += " "
 		}
-		return c.completeFilesDirs(lineStr)
-	// Add more command-specific completions here
-	default:
-		return c.completeFilesDirs(lineStr)
-	}
-}
-
-func (c *completer) completeCommands(lineStr string) (newLine [][]rune, length int) {
-	commands := []string{
-		"exit", "cd", "pwd", "echo", "clear", "mkdir", "mkdirp",
-		"rmdir", "rm", "rmrf", "cp", "mv", "head", "tail", "grep",
-		"find", "wc", "chmod", "chmodr", "env", "export", "history",
-		"alias", "unalias", "date", "uptime", "kill", "ps", "whoami",
-		"basename", "dirname", "sort", "uniq", "cut", "tee", "log", "calc",
-		"truncate", "du", "df", "ln", "tr", "help", "ping", "ls", "cal", "touch",
-		"stat", "dfi", "which", "killall", "source", "jobs", "fg", "bg", "compress",
-		"decompress", "tree", "watch", "free", "uname", "remote_execute", "file_transfer",
-	}
-
-	return c.filterCompletions(lineStr, commands)
-}
-
-func (c *completer) completeFilesDirs(lineStr string) (newLine [][]rune, length int) {
-	parts := strings.Fields(lineStr)
-	lastPart := parts[len(parts)-1]
-	dir := filepath.Dir(lastPart)
-	prefix := filepath.Base(lastPart)
-
-	entries, err := os.ReadDir(dir)
-	if err != nil {
-		return nil, 0
-	}
-
-	var matches []string
-
-	for _, entry := range entries {
-		name := entry.Name()
-		if strings.HasPrefix(name, prefix) {
-			if entry.IsDir() {
-				name += string(os.PathSeparator)
-			}
-			matches = append(matches, filepath.Join(dir, name))
-		}
-	}
-
-	return c.filterCompletions(lastPart, matches)
-}
-
-func (c *completer) completeChmodArgs(lineStr string) (newLine [][]rune, length int) {
-	chmodArgs := []string{"644", "755", "777", "600", "400"}
-	return c.filterCompletions(lineStr, chmodArgs)
-}
-
-func (c *completer) filterCompletions(lineStr string, completions []string) (newLine [][]rune, length int) {
-	var matches []string
-	for _, comp := range completions {
-		if strings.HasPrefix(comp, lineStr) {
-			matches = append(matches, comp)
-		}
-	}
-
-	if len(matches) == 0 {
-		return
-	}
-
-	if len(matches) == 1 {
-		newLine = [][]rune{[]rune(matches[0][len(lineStr):])}
-		length = len(matches[0])
-		return
-	}
-
-	for _, match := range matches {
-		newLine = append(newLine, []rune(match))
+		newLine = append(newLine, []rune(suffix))
 	}
-	length = len(lineStr)
-	return
+	return newLine, len([]rune(current))
 }
@@ -3,10 +3,10 @@ module commandripple
 go 1.23.0
 
 require (
-	github.com/chzyer/readline v1.5.1 // indirect
-	github.com/mattn/go-runewidth v0.0.9 // indirect
-	github.com/olekukonko/tablewriter v0.0.5 // indirect
-	github.com/sergi/go-diff v1.3.1 // indirect
-	golang.org/x/crypto v0.26.0 // indirect
-	golang.org/x/sys v0.23.0 // indirect
+	github.com/chzyer/readline v1.5.1
+	github.com/mattn/go-runewidth v0.0.9
+	github.com/olekukonko/tablewriter v0.0.5
+	github.com/sergi/go-diff v1.3.1
+	golang.org/x/crypto v0.26.0
+	golang.org/x/sys v0.23.0
 )
@@ -2,14 +2,14 @@ package commands
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"commandripple/internal/commands/clear"
@@ -17,222 +17,253 @@ import (
 	"commandripple/internal/commands/ls"
 	"commandripple/internal/commands/processes"
 	"commandripple/internal/commands/stat"
+	"commandripple/internal/commands/svc"
+	"commandripple/internal/commands/theme"
 	"commandripple/internal/commands/uname"
 	"commandripple/internal/commands/which"
-
-	"github.com/olekukonko/tablewriter"
 )
 
-var (
-	history     []string
-	aliases     = make(map[string]string)
-	startTime   = time.Now()
-	bgJobs      = make(map[int]*JobInfo) // Store background jobs
-	bgJobsMutex sync.Mutex               // To handle concurrent access to bgJobs
-	jobCounter  int                      // Unique identifier for jobs
-)
+// Version is the version of the shell, set with -ldflags when it is built
+var Version = "dev"
 
-type JobInfo struct {
-	Cmd       *exec.Cmd
-	StartTime time.Time
-	Status    string
+// builtinNames lists every built-in command
+var builtinNames = []string{
+	"exit", "cd", "pwd", "echo", "clear", "mkdir", "mkdirp", "rmdir", "rm", "rmrf",
+	"cp", "mv", "head", "tail", "grep", "find", "wc", "chmod", "chmodr", "env",
+	"export", "history", "alias", "unalias", "date", "uptime", "kill", "ps", "whoami", "basename",
+	"dirname", "sort", "uniq", "cut", "tee", "log", "calc", "truncate", "du", "df",
+	"ln", "tr", "help", "ping", "ls", "lsc", "cal", "touch", "stat", "dfi",
+	"which", "killall", "source", "jobs", "joblog", "wait", "fg", "bg", "disown", "nohup",
+	"svc", "at", "every", "cron", "schedule", "task", "pick", "z", "j", "tree",
+	"watch", "compress", "decompress", "diff", "free", "uname", "file_transfer", "remote_execute", "ulimit", "limit",
+	"timeout", "retry", "xargs", "parallel", "complete", "gitinfo", "plugin",
+	"config", "unset", "true", "false",
 }
 
+var builtinSet = func() map[string]bool {
+	set := make(map[string]bool, len(builtinNames))
+	for _, name := range builtinNames {
+		set[name] = true
+	}
+	return set
+}()
+
 // IsBuiltinCommand checks if the command is a built-in command.
 func IsBuiltinCommand(cmd string) bool {
-	switch cmd {
-	case "exit", "cd", "pwd", "echo", "clear", "mkdir", "mkdirp", "rmdir", "rm", "rmrf", "cp", "mv", "head", "tail", "grep", "find", "wc", "chmod", "chmodr", "env", "export", "history", "alias", "unalias", "date", "uptime", "kill", "ps", "whoami", "basename", "dirname", "sort", "uniq", "cut", "tee", "log", "calc", "truncate", "du", "df", "ln", "tr", "help", "ping", "ls", "lsc", "cal", "touch", "stat", "dfi", "which", "killall", "source", "jobs", "fg", "bg", "tree", "watch", "compress", "decompress", "diff", "free", "uname", "file_transfer", "remote_execute":
-		return true
-	default:
-		return false
-	}
+	return builtinSet[cmd]
 }
 
-// ExecuteBuiltin executes the built-in commands.
-func ExecuteBuiltin(cmd string, args []string) error {
-	history = append(history, cmd+" "+strings.Join(args, " "))
+// BuiltinNames returns the names of the built-in commands
+func BuiltinNames() []string {
+	return append([]string(nil), builtinNames...)
+}
 
+// ExecuteBuiltin executes the built-in commands.
+func (s *Session) ExecuteBuiltin(cmd string, args []string) error {
 	switch cmd {
 	case "exit":
-		fmt.Println("Exiting CommandRipple...")
-		os.Exit(0)
+		return s.ExitShell(args)
 	case "cd":
-		return ChangeDirectory(args)
+		return s.ChangeDirectory(args)
 	case "pwd":
-		return PrintWorkingDirectory()
+		return s.PrintWorkingDirectory()
 	case "echo":
-		return Echo(args)
+		return s.Echo(args)
 	case "clear":
-		return clear.ClearScreen()
+		return clear.ClearScreen(s.Stdout)
 	case "mkdir":
-		return MakeDirectory(args)
+		return s.MakeDirectory(args)
 	case "rmdir":
-		return RemoveDirectory(args)
+		return s.RemoveDirectory(args)
 	case "cat":
-		return Cat(args)
+		return s.Cat(args)
 	case "rm":
-		return RemoveFile(args)
+		return s.RemoveFile(args)
 	case "cp":
-		return CopyFile(args)
+		return s.CopyFile(args)
 	case "mv":
-		return MoveFile(args)
+		return s.MoveFile(args)
 	case "head":
-		return Head(args)
+		return s.Head(args)
 	case "tail":
-		return Tail(args)
+		return s.Tail(args)
 	case "grep":
-		return Grep(args)
+		return s.Grep(args)
 	case "find":
-		return Find(args)
+		return s.Find(args)
 	case "wc":
-		return WordCount(args)
+		return s.WordCount(args)
 	case "chmod":
-		return Chmod(args)
+		return s.Chmod(args)
 	case "env":
-		return PrintEnv()
+		return s.Env(args)
 	case "export":
-		return ExportEnv(args)
+		return s.ExportEnv(args)
+	case "unset":
+		return s.UnsetEnv(args)
+	case "true":
+		return nil
+	case "false":
+		return &ExitStatus{Code: 1}
 	case "history":
-		return ShowHistory()
+		return s.ShowHistory(args)
 	case "alias":
-		return CreateAlias(args)
+		return s.CreateAlias(args)
 	case "unalias":
-		return RemoveAlias(args)
+		return s.RemoveAlias(args)
 	case "date":
-		return ShowDate()
+		return s.ShowDate()
 	case "uptime":
-		return ShowUptime()
+		return s.ShowUptime()
 	case "kill":
-		return processes.KillProcess(args)
+		return s.KillJobs(args)
 	case "ps":
-		return processes.ListProcesses()
+		return processes.ListProcesses(s.Stdout)
 	case "basename":
-		return Basename(args)
+		return s.Basename(args)
 	case "dirname":
-		return Dirname(args)
+		return s.Dirname(args)
 	case "sort":
-		return SortFile(args)
+		return s.SortFile(args)
 	case "uniq":
-		return Uniq(args)
+		return s.Uniq(args)
 	case "cut":
-		return Cut(args)
+		return s.Cut(args)
 	case "tee":
-		return Tee(args)
+		return s.Tee(args)
 	case "log":
-		return LogMessage(args)
+		return s.LogMessage(args)
 	case "calc":
-		return Calc(args)
+		return s.Calc(args)
 	case "truncate":
-		return Truncate(args)
+		return s.Truncate(args)
 	case "du":
-		return Du(args)
+		return s.Du(args)
 	case "df":
-		return Df(args)
+		return s.Df(args)
 	case "ln":
-		return Ln(args)
+		return s.Ln(args)
 	case "tr":
-		return Tr(args)
+		return s.Tr(args)
 	case "mkdirp":
-		return MkdirP(args)
+		return s.MkdirP(args)
 	case "rmrf":
-		return RmRf(args)
+		return s.RmRf(args)
 	case "ping":
-		return Ping(args)
+		return s.Ping(args)
 	case "ls":
-		return ls.Ls(args)
+		return ls.Ls(s.Stdout, args)
 	case "lsc":
-		return ls.LsColor(args)
+		return ls.LsColor(s.Stdout, args)
 	case "cal":
-		return Cal(args)
+		return s.Cal(args)
 	case "chmodr":
-		return ChmodRecursive(args)
+		return s.ChmodRecursive(args)
 	case "touch":
 		if len(args) > 0 && args[0] == "-t" {
-			return TouchWithTimestamp(args)
+			return s.TouchWithTimestamp(args)
 		}
-		return Touch(args)
+		return s.Touch(args)
 	case "whoami":
-		return Whoami(args)
+		return s.Whoami(args)
 	case "stat":
-		return stat.Stat(args)
+		return stat.Stat(s.Stdout, args)
 	case "dfi":
-		return DfInodes(args)
+		return s.DfInodes(args)
 	case "which":
-		return which.Which(args)
+		if len(args) > 0 {
+			if p, ok := s.resolvePlugin(args[0]); ok {
+				fmt.Fprintln(s.Stdout, p.Path)
+				return nil
+			}
+		}
+		return which.Which(s.Stdout, args, s.Dir(), s.Getenv("PATH"))
 	case "killall":
-		return processes.KillAll(args)
+		return processes.KillAll(s.Stdout, args)
 	case "source":
-		return Source(args)
+		return s.Source(args)
 	case "jobs":
-		return ListJobs()
+		return s.ListJobs()
+	case "joblog":
+		return s.JobLog(args)
+	case "wait":
+		return s.Wait(args)
+	case "disown":
+		return s.Disown(args)
+	case "nohup":
+		return s.Nohup(args)
+	case "svc":
+		return svc.Svc(s.Stdout, s.Dir(), args)
+	case "at":
+		return s.At(args)
+	case "every":
+		return s.Every(args)
+	case "cron":
+		return s.Cron(args)
+	case "schedule":
+		return s.Schedule(args)
+	case "task":
+		return s.Task(args)
+	case "pick":
+		return s.Pick(args)
+	case "complete":
+		return s.Complete(args)
+	case "z", "j":
+		return s.Z(args)
 	case "fg":
-		return BringToForeground(args)
+		return s.BringToForeground(args)
 	case "bg":
-		return SendToBackground(args)
+		return s.SendToBackground(args)
 	case "tree":
-		return Tree(args)
+		return s.Tree(args)
 	case "watch":
-		return Watch(args)
+		return s.Watch(args)
 	case "compress":
-		return Compress(args)
+		return s.Compress(args)
 	case "decompress":
-		return Decompress(args)
+		return s.Decompress(args)
 	case "diff":
-		return Diff(args)
+		return s.Diff(args)
 	case "free":
-		return free.Free(args)
+		return free.Free(s.Stdout, args)
 	case "uname":
-		return uname.Uname(args)
+		return uname.Uname(s.Stdout, args)
 	case "file_transfer":
-		return FileTransfer(args)
+		return s.FileTransfer(args)
 	case "remote_execute":
-		return RemoteExecute(args)
+		return s.RemoteExecute(args)
+	case "ulimit":
+		return s.ulimits.Ulimit(s.Stdout, args)
+	case "limit":
+		return s.Limit(args)
+	case "timeout":
+		return s.Timeout(args)
+	case "retry":
+		return s.Retry(args)
+	case "xargs":
+		return s.Xargs(args)
+	case "parallel":
+		return s.Parallel(args)
+	case "gitinfo":
+		return s.GitInfo(args)
+	case "plugin":
+		return s.Plugin(args)
+	case "config":
+		return s.Config(args)
 	case "help":
-		PrintHelp()
+		s.PrintHelp()
 	default:
 		return fmt.Errorf("unknown builtin command: %s", cmd)
 	}
 	return nil
 }
 
-// `jobs` command implementation
-func ListJobs() error {
-	bgJobsMutex.Lock()
-	defer bgJobsMutex.Unlock()
-
-	if len(bgJobs) == 0 {
-		fmt.Println("No background jobs running.")
-		return nil
-	}
-
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"ID", "Command", "Status", "Started", "Runtime"})
-
-	for id, jobInfo := range bgJobs {
-		command := strings.Join(jobInfo.Cmd.Args, " ")
-		status := jobInfo.Status
-		started := jobInfo.StartTime.Format("2006-01-02 15:04:05")
-		runtime := time.Since(jobInfo.StartTime).Round(time.Second).String()
-
-		table.Append([]string{
-			fmt.Sprintf("%d", id),
-			command,
-			status,
-			started,
-			runtime,
-		})
-	}
-
-	table.Render()
-	return nil
-}
-
 // `source` command implementation
-func Source(args []string) error {
+func (s *Session) Source(args []string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("'source' requires a filename")
 	}
-	file, err := os.Open(args[0])
+	file, err := os.Open(s.path(args[0]))
 	if err != nil {
 		return err
 	}
@@ -244,121 +275,22 @@ func Source(args []string) error {
 		if commandLine == "" {
 			continue
 		}
-		if err := executeCommand(commandLine); err != nil {
-			fmt.Fprintf(os.Stderr, "CommandRipple: %v\n", err)
-		}
-	}
-
-	return scanner.Err()
-}
-
-// `fg` command implementation
-func BringToForeground(args []string) error {
-	if len(args) < 1 {
-		return fmt.Errorf("'fg' requires a job ID")
-	}
-
-	jobID, err := strconv.Atoi(args[0])
-	if err != nil {
-		return fmt.Errorf("invalid job ID: %s", args[0])
-	}
-
-	bgJobsMutex.Lock()
-	jobInfo, exists := bgJobs[jobID]
-	if !exists {
-		bgJobsMutex.Unlock()
-		return fmt.Errorf("no such job: %d", jobID)
-	}
-
-	if jobInfo.Status == "Completed" {
-		bgJobsMutex.Unlock()
-		return fmt.Errorf("job %d has already completed", jobID)
-	}
-
-	delete(bgJobs, jobID)
-	bgJobsMutex.Unlock()
-
-	// Bring the job to the foreground
-	jobInfo.Cmd.Stdout = os.Stdout
-	jobInfo.Cmd.Stderr = os.Stderr
-	jobInfo.Cmd.Stdin = os.Stdin
-
-	fmt.Printf("Bringing job %d to foreground: %s\n", jobID, strings.Join(jobInfo.Cmd.Args, " "))
-
-	err = jobInfo.Cmd.Wait()
-	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			return fmt.Errorf("job exited with status %d", exitErr.ExitCode())
+		err := s.executeCommand(commandLine)
+		var exit *exitError
+		if errors.As(err, &exit) {
+			// exit in a script sourced by an embedded shell ends its run
+			return err
 		}
-		return fmt.Errorf("error waiting for job: %v", err)
-	}
-
-	return nil
-}
-
-// Helper function to create a new background job
-func StartBackgroundJob(cmd *exec.Cmd) (int, error) {
-	bgJobsMutex.Lock()
-	defer bgJobsMutex.Unlock()
-
-	jobCounter++
-	id := jobCounter
-
-	bgJobs[id] = &JobInfo{
-		Cmd:       cmd,
-		StartTime: time.Now(),
-		Status:    "Running",
-	}
-
-	go func() {
-		cmd.Wait()
-		bgJobsMutex.Lock()
-		if job, exists := bgJobs[id]; exists {
-			job.Status = "Completed"
+		if err != nil {
+			fmt.Fprintf(s.Stderr, "CommandRipple: %v\n", err)
 		}
-		bgJobsMutex.Unlock()
-	}()
-
-	return id, nil
-}
-
-// `bg` command implementation
-func SendToBackground(args []string) error {
-	if len(args) < 1 {
-		return fmt.Errorf("'bg' requires a command")
 	}
 
-	cmdName := args[0]
-	cmdArgs := args[1:]
-
-	command := exec.Command(cmdName, cmdArgs...)
-
-	// Start the command
-	err := command.Start()
-	if err != nil {
-		return fmt.Errorf("failed to start command: %v", err)
-	}
-
-	// Add the job to bgJobs
-	jobID, err := StartBackgroundJob(command)
-	if err != nil {
-		return fmt.Errorf("failed to add job to background: %v", err)
-	}
-
-	fmt.Printf("[%d] %s\n", jobID, strings.Join(command.Args, " "))
-	return nil
-}
-
-// Command History
-func ShowHistory() error {
-	for i, cmd := range history {
-		fmt.Printf("%d %s\n", i+1, cmd)
-	}
-	return nil
+	return scanner.Err()
 }
 
 // Alias Management
-func CreateAlias(args []string) error {
+func (s *Session) CreateAlias(args []string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("'alias' requires an argument in the format name=command")
 	}
@@ -369,88 +301,96 @@ func CreateAlias(args []string) error {
 		}
 		name := parts[0]
 		command := parts[1]
-		aliases[name] = command
+		s.Alias(name, command)
 	}
 	return nil
 }
 
-func RemoveAlias(args []string) error {
+func (s *Session) RemoveAlias(args []string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("'unalias' requires an argument")
 	}
 	for _, name := range args {
-		delete(aliases, name)
+		s.Alias(name, "")
 	}
 	return nil
 }
 
 // Date and Uptime
-func ShowDate() error {
-	fmt.Println(time.Now().Format(time.RFC1123))
+func (s *Session) ShowDate() error {
+	fmt.Fprintln(s.Stdout, time.Now().Format(time.RFC1123))
 	return nil
 }
 
-func ShowUptime() error {
-	uptime := fmt.Sprintf("Uptime: %s", time.Since(startTime).String())
-	PrintColor(Green, uptime)
+func (s *Session) ShowUptime() error {
+	uptime := fmt.Sprintf("Uptime: %s", time.Since(s.startTime).String())
+	s.printColor(theme.Success, uptime)
 	return nil
 }
 
-func PrintWorkingDirectory() error {
-	if dir, err := os.Getwd(); err != nil {
-		return err
-	} else {
-		fmt.Println(dir)
-		return nil
+func (s *Session) PrintWorkingDirectory() error {
+	dir := s.Dir()
+	if dir == "" {
+		return fmt.Errorf("cannot determine the current directory")
 	}
+	fmt.Fprintln(s.Stdout, dir)
+	return nil
 }
 
-func Echo(args []string) error {
-	PrintColor(Cyan, strings.Join(args, " "))
+func (s *Session) Echo(args []string) error {
+	s.printColor(theme.Accent, strings.Join(args, " "))
 	return nil
 }
 
-func MakeDirectory(args []string) error {
+func (s *Session) MakeDirectory(args []string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("'mkdir' requires an argument")
 	}
-	return os.Mkdir(args[0], os.ModePerm)
+	return os.Mkdir(s.path(args[0]), os.ModePerm)
 }
 
-func RemoveDirectory(args []string) error {
+func (s *Session) RemoveDirectory(args []string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("'rmdir' requires an argument")
 	}
-	return os.Remove(args[0])
+	return os.Remove(s.path(args[0]))
 }
 
-func PrintEnv() error {
-	for _, env := range os.Environ() {
-		fmt.Println(env)
-	}
-	return nil
-}
-
-func ExportEnv(args []string) error {
+func (s *Session) ExportEnv(args []string) error {
 	if len(args) < 1 {
-		return fmt.Errorf("'export' requires an argument in the format NAME=VALUE")
+		return printEnv(s.Stdout, s.Environ(), "")
 	}
 	for _, arg := range args {
-		parts := strings.SplitN(arg, "=", 2)
-		if len(parts) != 2 {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			// export NAME keeps the value it has
+			value = s.Getenv(name)
+		}
+		if name == "" {
 			return fmt.Errorf("'export' argument must be in the format NAME=VALUE")
 		}
-		name := parts[0]
-		value := parts[1]
-		if err := os.Setenv(name, value); err != nil {
+		if err := s.Setenv(name, value); err != nil {
 			return fmt.Errorf("failed to set environment variable: %v", err)
 		}
 	}
 	return nil
 }
 
+// Remove environment variables
+func (s *Session) UnsetEnv(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("'unset' requires a variable name")
+	}
+	for _, name := range args {
+		if err := s.Unsetenv(name); err != nil {
+			return fmt.Errorf("failed to unset environment variable: %v", err)
+		}
+	}
+	return nil
+}
+
 // Truncate or extend the size of a file
-func Truncate(args []string) error {
+func (s *Session) Truncate(args []string) error {
 	if len(args) < 3 || args[1] != "-s" {
 		return fmt.Errorf("usage: truncate [file] -s [size]")
 	}
@@ -458,27 +398,24 @@ func Truncate(args []string) error {
 	if err != nil {
 		return fmt.Errorf("invalid size: %s", args[2])
 	}
-	return os.Truncate(args[0], size)
+	return os.Truncate(s.path(args[0]), size)
 }
 
 // Report file system disk space usage
-func Df(args []string) error {
-	cmd := exec.Command("df", "-h")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+func (s *Session) Df(args []string) error {
+	return s.runProgram("df", "-h")
 }
 
 // Create a symbolic link between files
-func Ln(args []string) error {
+func (s *Session) Ln(args []string) error {
 	if len(args) != 2 {
 		return fmt.Errorf("usage: ln [target] [link]")
 	}
-	return os.Symlink(args[0], args[1])
+	return os.Symlink(args[0], s.path(args[1]))
 }
 
 // Translate or delete characters in a string
-func Tr(args []string) error {
+func (s *Session) Tr(args []string) error {
 	if len(args) != 2 {
 		return fmt.Errorf("usage: tr [set1] [set2]")
 	}
@@ -500,8 +437,7 @@ func Tr(args []string) error {
 	// Create a new Replacer with the pairs
 	replacer := strings.NewReplacer(replacements...)
 
-	input := bufio.NewReader(os.Stdin)
-	output := os.Stdout
+	input := bufio.NewReader(s.Stdin)
 
 	for {
 		line, err := input.ReadString('\n')
@@ -514,19 +450,19 @@ func Tr(args []string) error {
 
 		// Apply the replacement to the line
 		transformedLine := replacer.Replace(line)
-		output.WriteString(transformedLine)
+		io.WriteString(s.Stdout, transformedLine)
 	}
 
 	return nil
 }
 
 // Recursively remove a directory and its contents
-func RmRf(args []string) error {
+func (s *Session) RmRf(args []string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("usage: rm -rf [directory]")
 	}
 	for _, dir := range args {
-		err := os.RemoveAll(dir)
+		err := os.RemoveAll(s.path(dir))
 		if err != nil {
 			return err
 		}
@@ -535,12 +471,12 @@ func RmRf(args []string) error {
 }
 
 // Create a directory and its parent directories if they do not exist
-func MkdirP(args []string) error {
+func (s *Session) MkdirP(args []string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("usage: mkdir -p [directory]")
 	}
 	for _, dir := range args {
-		err := os.MkdirAll(dir, os.ModePerm)
+		err := os.MkdirAll(s.path(dir), os.ModePerm)
 		if err != nil {
 			return err
 		}
@@ -549,26 +485,20 @@ func MkdirP(args []string) error {
 }
 
 // Send ICMP ECHO_REQUEST to network hosts
-func Ping(args []string) error {
+func (s *Session) Ping(args []string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("usage: ping [hostname]")
 	}
-	cmd := exec.Command("ping", args[0])
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	return s.runProgram("ping", args[0])
 }
 
 // Display a calendar
-func Cal(args []string) error {
-	cmd := exec.Command("cal")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+func (s *Session) Cal(args []string) error {
+	return s.runProgram("cal")
 }
 
 // Create or update a file with a specific timestamp
-func TouchWithTimestamp(args []string) error {
+func (s *Session) TouchWithTimestamp(args []string) error {
 	if len(args) < 3 || args[0] != "-t" {
 		return fmt.Errorf("usage: touch -t [timestamp] [file]")
 	}
@@ -580,7 +510,7 @@ func TouchWithTimestamp(args []string) error {
 		return fmt.Errorf("invalid timestamp format: %v", err)
 	}
 
-	err = os.Chtimes(file, parsedTime, parsedTime)
+	err = os.Chtimes(s.path(file), parsedTime, parsedTime)
 	if err != nil {
 		return err
 	}
@@ -588,7 +518,7 @@ func TouchWithTimestamp(args []string) error {
 }
 
 // Recursively change permissions of a directory
-func ChmodRecursive(args []string) error {
+func (s *Session) ChmodRecursive(args []string) error {
 	if len(args) != 3 || args[0] != "-R" {
 		return fmt.Errorf("usage: chmod -R [permissions] [directory]")
 	}
@@ -596,7 +526,7 @@ func ChmodRecursive(args []string) error {
 	if err != nil {
 		return fmt.Errorf("invalid permissions: %s", args[1])
 	}
-	dir := args[2]
+	dir := s.path(args[2])
 	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -606,173 +536,226 @@ func ChmodRecursive(args []string) error {
 }
 
 // Display the current username
-func Whoami(args []string) error {
-	user := os.Getenv("USERNAME") // On Unix-like systems, use "USER"
-	fmt.Println(user)
+func (s *Session) Whoami(args []string) error {
+	user := s.Getenv("USERNAME") // On Unix-like systems, use "USER"
+	fmt.Fprintln(s.Stdout, user)
 	return nil
 }
 
 // Report file system inode usage
-func DfInodes(args []string) error {
-	cmd := exec.Command("df", "-i")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+func (s *Session) DfInodes(args []string) error {
+	return s.runProgram("df", "-i")
 }
 
 // Command execution helper
-func executeCommand(commandLine string) error {
-	parts := strings.Fields(commandLine)
-	cmdName := parts[0]
-	cmdArgs := parts[1:]
-
-	if IsBuiltinCommand(cmdName) {
-		return ExecuteBuiltin(cmdName, cmdArgs)
-	} else {
-		return ExecuteExternal(cmdName, cmdArgs)
+func (s *Session) executeCommand(commandLine string) error {
+	// Loops such as xargs stop once the timeout they run under expired
+	if ctx := s.commandContext(); ctx.Err() != nil {
+		return context.Cause(ctx)
 	}
+	return s.ExecuteCommandLine(commandLine)
 }
 
 // Help function
-func PrintHelp() {
-	PrintColor(Cyan, "CommandRipple - A simple shell implemented in Go")
-	PrintColor(White, "Built-in commands:")
-	PrintColor(Green, "  cd [dir]          ")
-	fmt.Println("Change the current directory")
-	PrintColor(Green, "  pwd               ")
-	fmt.Println("Print the current working directory")
-	PrintColor(Green, "  echo [text]       ")
-	fmt.Println("Echo the input text back to the user")
-	PrintColor(Green, "  clear             ")
-	fmt.Println("Clear the terminal screen")
-	PrintColor(Green, "  mkdir [dir]       ")
-	fmt.Println("Create a new directory")
-	PrintColor(Green, "  mkdirp [dir]      ")
-	fmt.Println("Create directories and parent directories if needed")
-	PrintColor(Green, "  rmdir [dir]       ")
-	fmt.Println("Remove an empty directory")
-	PrintColor(Green, "  rm [file]         ")
-	fmt.Println("Remove a file")
-	PrintColor(Green, "  rmrf [dir]        ")
-	fmt.Println("Recursively remove a directory and its contents")
-	PrintColor(Green, "  cp [src] [dest]   ")
-	fmt.Println("Copy a file")
-	PrintColor(Green, "  mv [src] [dest]   ")
-	fmt.Println("Move or rename a file or directory")
-	PrintColor(Green, "  touch [file]      ")
-	fmt.Println("Create an empty file or update timestamp")
-	PrintColor(Green, "  touch -t [timestamp] [file] ")
-	fmt.Println("Create or update a file with a specific timestamp")
-	PrintColor(Green, "  chmod [permissions] [file] ")
-	fmt.Println("Change file permissions")
-	PrintColor(Green, "  chmodr [permissions] [dir] ")
-	fmt.Println("Recursively change permissions of a directory")
-	PrintColor(Green, "  cat [file]        ")
-	fmt.Println("Display the content of a file")
-	PrintColor(Green, "  head [file]       ")
-	fmt.Println("Display the first few lines of a file")
-	PrintColor(Green, "  tail [file]       ")
-	fmt.Println("Display the last few lines of a file")
-	PrintColor(Green, "  grep [pattern] [file] ")
-	fmt.Println("Search for a pattern in a file")
-	PrintColor(Green, "  find [dir] [name] ")
-	fmt.Println("Search for a file or directory by name")
-	PrintColor(Green, "  wc [file]         ")
-	fmt.Println("Count lines, words, and characters in a file")
-	PrintColor(Green, "  env               ")
-	fmt.Println("Print environment variables")
-	PrintColor(Green, "  export NAME=VALUE ")
-	fmt.Println("Set or modify environment variables")
-	PrintColor(Green, "  history           ")
-	fmt.Println("Display command history")
-	PrintColor(Green, "  alias name=command ")
-	fmt.Println("Create an alias for a command")
-	PrintColor(Green, "  unalias name      ")
-	fmt.Println("Remove an alias")
-	PrintColor(Green, "  date              ")
-	fmt.Println("Display the current date and time")
-	PrintColor(Green, "  uptime            ")
-	fmt.Println("Display how long the shell has been running")
-	PrintColor(Green, "  kill [PID]        ")
-	fmt.Println("Terminate a process by PID")
-	PrintColor(Green, "  killall [name]    ")
-	fmt.Println("Kill all processes by name")
-	PrintColor(Green, "  ps                ")
-	fmt.Println("List currently running processes")
-	PrintColor(Green, "  whoami            ")
-	fmt.Println("Display the current user's username")
-	PrintColor(Green, "  basename [path]   ")
-	fmt.Println("Strip directory and suffix from filenames")
-	PrintColor(Green, "  dirname [path]    ")
-	fmt.Println("Extract the directory path from a full path")
-	PrintColor(Green, "  sort [file]       ")
-	fmt.Println("Sort lines of a text file")
-	PrintColor(Green, "  uniq [file]       ")
-	fmt.Println("Remove duplicate lines from a file")
-	PrintColor(Green, "  cut [file] -d [delimiter] -f [field] ")
-	fmt.Println("Extract selected portions of each line")
-	PrintColor(Green, "  tee [file]        ")
-	fmt.Println("Read from standard input and write to standard output and files")
-	PrintColor(Green, "  log [message]     ")
-	fmt.Println("Append a log message to a log file")
-	PrintColor(Green, "  calc [expression] ")
-	fmt.Println("Evaluate a simple arithmetic expression")
-	PrintColor(Green, "  truncate [file] -s [size] ")
-	fmt.Println("Truncate or extend the size of a file")
-	PrintColor(Green, "  du [dir]          ")
-	fmt.Println("Estimate file space usage of a directory")
-	PrintColor(Green, "  df                ")
-	fmt.Println("Report file system disk space usage")
-	PrintColor(Green, "  dfi               ")
-	fmt.Println("Report file system inode usage")
-	PrintColor(Green, "  ln [target] [link] ")
-	fmt.Println("Create a symbolic link between files")
-	PrintColor(Green, "  tr [set1] [set2]  ")
-	fmt.Println("Translate or delete characters in a string")
-	PrintColor(Green, "  ping [hostname]   ")
-	fmt.Println("Send ICMP ECHO_REQUEST to network hosts")
-	PrintColor(Green, "  which [command]   ")
-	fmt.Println("Locate a command in the PATH")
-	PrintColor(Green, "  ls [dir]          ")
-	fmt.Println("List directory contents with detailed file information")
-	PrintColor(Green, "  lsc [dir]          ")
-	fmt.Println("List directory contents with detailed file information. color-coded output")
-	PrintColor(Green, "  stat [file]       ")
-	fmt.Println("Display file or file system status")
-	PrintColor(Green, "  cal               ")
-	fmt.Println("Display a calendar")
-	PrintColor(Green, "  source [file]     ")
-	fmt.Println("Execute commands from a file")
-	PrintColor(Green, "  jobs              ")
-	fmt.Println("List background jobs")
-	PrintColor(Green, "  fg [job]          ")
-	fmt.Println("Bring a background job to the foreground")
-	PrintColor(Green, "  bg [job]          ")
-	fmt.Println("Send a job to the background")
-	PrintColor(Green, "  tree [directory] [-a|--all]")
-	fmt.Println("Visualize directory structure as a colorful tree")
-	fmt.Println("    -a, --all    Show hidden files and directories")
-	fmt.Println("    If no directory is specified, the current directory is used")
-	fmt.Println("    Colors indicate directory levels and file types")
-	PrintColor(Green, "  watch              ")
-	fmt.Println("Runs a specified command periodically and displays its output")
-	PrintColor(Green, "  compress [file]   ")
-	fmt.Println("Compress a file using gzip")
-	PrintColor(Green, "  decompress [file] ")
-	fmt.Println("Decompress a file using gzip")
-	PrintColor(Green, "  diff [file1] [file2] ")
-	fmt.Println("Compare two files line by line")
-	PrintColor(Green, "  free              ")
-	fmt.Println("Display amount of free and used memory in the system")
-	PrintColor(Green, "  uname             ")
-	fmt.Println("Print system information")
-	PrintColor(Green, "  file_transfer     ")
-	fmt.Println("Transfer files between systems using ssh")
-	PrintColor(Green, "  remote_execute    ")
-	fmt.Println("Execute a command on a remote machine via SSH")
-	PrintColor(Green, "  help              ")
-	fmt.Println("Show this help message")
-	PrintColor(White, "\nPipes:")
-	PrintColor(Green, "  Use the '|' character to pipe the output of one command to the input of another.")
-	fmt.Println("Example: cat file.txt | grep 'search' | sort")
+func (s *Session) PrintHelp() {
+	s.printColor(theme.Accent, "CommandRipple - A simple shell implemented in Go")
+	s.printColor(theme.Heading, "Built-in commands:")
+	s.printColor(theme.Command, "  cd [dir]          ")
+	fmt.Fprintln(s.Stdout, "Change the current directory")
+	s.printColor(theme.Command, "  pwd               ")
+	fmt.Fprintln(s.Stdout, "Print the current working directory")
+	s.printColor(theme.Command, "  echo [text]       ")
+	fmt.Fprintln(s.Stdout, "Echo the input text back to the user")
+	s.printColor(theme.Command, "  clear             ")
+	fmt.Fprintln(s.Stdout, "Clear the terminal screen")
+	s.printColor(theme.Command, "  mkdir [dir]       ")
+	fmt.Fprintln(s.Stdout, "Create a new directory")
+	s.printColor(theme.Command, "  mkdirp [dir]      ")
+	fmt.Fprintln(s.Stdout, "Create directories and parent directories if needed")
+	s.printColor(theme.Command, "  rmdir [dir]       ")
+	fmt.Fprintln(s.Stdout, "Remove an empty directory")
+	s.printColor(theme.Command, "  rm [file]         ")
+	fmt.Fprintln(s.Stdout, "Remove a file")
+	s.printColor(theme.Command, "  rmrf [dir]        ")
+	fmt.Fprintln(s.Stdout, "Recursively remove a directory and its contents")
+	s.printColor(theme.Command, "  cp [src] [dest]   ")
+	fmt.Fprintln(s.Stdout, "Copy a file")
+	s.printColor(theme.Command, "  mv [src] [dest]   ")
+	fmt.Fprintln(s.Stdout, "Move or rename a file or directory")
+	s.printColor(theme.Command, "  touch [file]      ")
+	fmt.Fprintln(s.Stdout, "Create an empty file or update timestamp")
+	s.printColor(theme.Command, "  touch -t [timestamp] [file] ")
+	fmt.Fprintln(s.Stdout, "Create or update a file with a specific timestamp")
+	s.printColor(theme.Command, "  chmod [permissions] [file] ")
+	fmt.Fprintln(s.Stdout, "Change file permissions")
+	s.printColor(theme.Command, "  chmodr [permissions] [dir] ")
+	fmt.Fprintln(s.Stdout, "Recursively change permissions of a directory")
+	s.printColor(theme.Command, "  cat [file]        ")
+	fmt.Fprintln(s.Stdout, "Display the content of a file")
+	s.printColor(theme.Command, "  head [file]       ")
+	fmt.Fprintln(s.Stdout, "Display the first few lines of a file")
+	s.printColor(theme.Command, "  tail [file]       ")
+	fmt.Fprintln(s.Stdout, "Display the last few lines of a file")
+	s.printColor(theme.Command, "  grep [pattern] [file] ")
+	fmt.Fprintln(s.Stdout, "Search for a pattern in a file")
+	s.printColor(theme.Command, "  find [dir] [name] ")
+	fmt.Fprintln(s.Stdout, "Search for a file or directory by name")
+	s.printColor(theme.Command, "  wc [file]         ")
+	fmt.Fprintln(s.Stdout, "Count lines, words, and characters in a file")
+	s.printColor(theme.Command, "  env [-i] [-u NAME] [--filter PATTERN] [NAME=VALUE]... [command] ")
+	fmt.Fprintln(s.Stdout, "Print the sorted environment or run a command in a modified environment")
+	s.printColor(theme.Command, "  export NAME=VALUE ")
+	fmt.Fprintln(s.Stdout, "Set or modify environment variables, or list them without arguments")
+	s.printColor(theme.Command, "  unset NAME...     ")
+	fmt.Fprintln(s.Stdout, "Remove environment variables")
+	s.printColor(theme.Command, "  true | false      ")
+	fmt.Fprintln(s.Stdout, "Do nothing, successfully or with exit status 1")
+	s.printColor(theme.Command, "  history [N] [-v]  ")
+	fmt.Fprintln(s.Stdout, "Display command history (--since, --until, --dir, --here, --status, --failed, --session)")
+	s.printColor(theme.Command, "  history -c|-d N   ")
+	fmt.Fprintln(s.Stdout, "Clear the history or delete entry N")
+	s.printColor(theme.Command, "  history scrub [FILE...]")
+	fmt.Fprintln(s.Stdout, "Redact secrets in the stored history and the given log files")
+	s.printColor(theme.Command, "  alias name=command ")
+	fmt.Fprintln(s.Stdout, "Create an alias for a command")
+	s.printColor(theme.Command, "  unalias name      ")
+	fmt.Fprintln(s.Stdout, "Remove an alias")
+	s.printColor(theme.Command, "  date              ")
+	fmt.Fprintln(s.Stdout, "Display the current date and time")
+	s.printColor(theme.Command, "  uptime            ")
+	fmt.Fprintln(s.Stdout, "Display how long the shell has been running")
+	s.printColor(theme.Command, "  kill [-SIG] PID|%job")
+	fmt.Fprintln(s.Stdout, "Send a signal to a process or job (kill -l lists signals)")
+	s.printColor(theme.Command, "  killall [name]    ")
+	fmt.Fprintln(s.Stdout, "Kill all processes by name")
+	s.printColor(theme.Command, "  ps                ")
+	fmt.Fprintln(s.Stdout, "List currently running processes")
+	s.printColor(theme.Command, "  whoami            ")
+	fmt.Fprintln(s.Stdout, "Display the current user's username")
+	s.printColor(theme.Command, "  basename [path]   ")
+	fmt.Fprintln(s.Stdout, "Strip directory and suffix from filenames")
+	s.printColor(theme.Command, "  dirname [path]    ")
+	fmt.Fprintln(s.Stdout, "Extract the directory path from a full path")
+	s.printColor(theme.Command, "  sort [file]       ")
+	fmt.Fprintln(s.Stdout, "Sort lines of a text file")
+	s.printColor(theme.Command, "  uniq [file]       ")
+	fmt.Fprintln(s.Stdout, "Remove duplicate lines from a file")
+	s.printColor(theme.Command, "  cut [file] -d [delimiter] -f [field] ")
+	fmt.Fprintln(s.Stdout, "Extract selected portions of each line")
+	s.printColor(theme.Command, "  tee [file]        ")
+	fmt.Fprintln(s.Stdout, "Read from standard input and write to standard output and files")
+	s.printColor(theme.Command, "  log [message]     ")
+	fmt.Fprintln(s.Stdout, "Append a log message to a log file")
+	s.printColor(theme.Command, "  calc [expression] ")
+	fmt.Fprintln(s.Stdout, "Evaluate a simple arithmetic expression")
+	s.printColor(theme.Command, "  truncate [file] -s [size] ")
+	fmt.Fprintln(s.Stdout, "Truncate or extend the size of a file")
+	s.printColor(theme.Command, "  du [dir]          ")
+	fmt.Fprintln(s.Stdout, "Estimate file space usage of a directory")
+	s.printColor(theme.Command, "  df                ")
+	fmt.Fprintln(s.Stdout, "Report file system disk space usage")
+	s.printColor(theme.Command, "  dfi               ")
+	fmt.Fprintln(s.Stdout, "Report file system inode usage")
+	s.printColor(theme.Command, "  ln [target] [link] ")
+	fmt.Fprintln(s.Stdout, "Create a symbolic link between files")
+	s.printColor(theme.Command, "  tr [set1] [set2]  ")
+	fmt.Fprintln(s.Stdout, "Translate or delete characters in a string")
+	s.printColor(theme.Command, "  ping [hostname]   ")
+	fmt.Fprintln(s.Stdout, "Send ICMP ECHO_REQUEST to network hosts")
+	s.printColor(theme.Command, "  which [command]   ")
+	fmt.Fprintln(s.Stdout, "Locate a command in the PATH")
+	s.printColor(theme.Command, "  ls [dir]          ")
+	fmt.Fprintln(s.Stdout, "List directory contents with detailed file information")
+	s.printColor(theme.Command, "  lsc [dir]          ")
+	fmt.Fprintln(s.Stdout, "List directory contents with detailed file information. color-coded output")
+	s.printColor(theme.Command, "  stat [file]       ")
+	fmt.Fprintln(s.Stdout, "Display file or file system status")
+	s.printColor(theme.Command, "  cal               ")
+	fmt.Fprintln(s.Stdout, "Display a calendar")
+	s.printColor(theme.Command, "  source [file]     ")
+	fmt.Fprintln(s.Stdout, "Execute commands from a file")
+	s.printColor(theme.Command, "  jobs              ")
+	fmt.Fprintln(s.Stdout, "List background jobs")
+	s.printColor(theme.Command, "  fg [%job]         ")
+	fmt.Fprintln(s.Stdout, "Bring a job to the foreground, resuming it if stopped")
+	s.printColor(theme.Command, "  bg [%job|command] ")
+	fmt.Fprintln(s.Stdout, "Resume a stopped job in the background or start a command there")
+	s.printColor(theme.Command, "  bg --log FILE cmd ")
+	fmt.Fprintln(s.Stdout, "Start a background job and also append its output to FILE")
+	s.printColor(theme.Command, "  wait [-n] [%job|pid]")
+	fmt.Fprintln(s.Stdout, "Wait for background jobs and return the exit status of the last one")
+	s.printColor(theme.Command, "  disown [-h] [%job]")
+	fmt.Fprintln(s.Stdout, "Forget a job, or with -h keep it but do not hang it up on exit")
+	s.printColor(theme.Command, "  nohup command     ")
+	fmt.Fprintln(s.Stdout, "Run a command in the background, immune to hangups, terminal output to nohup.out")
+	s.printColor(theme.Command, "  svc start NAME -- cmd")
+	fmt.Fprintln(s.Stdout, "Run a supervised command that survives the shell (--restart on-failure|always)")
+	s.printColor(theme.Command, "  svc list|logs|stop|restart|rm [NAME]")
+	fmt.Fprintln(s.Stdout, "Manage supervised services from any shell session")
+	s.printColor(theme.Command, "  at TIME command   ")
+	fmt.Fprintln(s.Stdout, "Run a command once at HH:MM, after +DURATION or at a date")
+	s.printColor(theme.Command, "  every INTERVAL [--at HH:MM] command")
+	fmt.Fprintln(s.Stdout, "Run a command repeatedly as a background job")
+	s.printColor(theme.Command, "  cron \"EXPR\" command")
+	fmt.Fprintln(s.Stdout, "Run a command on a cron schedule, e.g. \"*/5 * * * *\" or @daily")
+	s.printColor(theme.Command, "  schedule [list|rm ID]")
+	fmt.Fprintln(s.Stdout, "List or cancel scheduled commands")
+	s.printColor(theme.Command, "  task [-j N] [task...]")
+	fmt.Fprintln(s.Stdout, "Run tasks from the nearest Ripplefile with their dependencies (--list)")
+	s.printColor(theme.Command, "  pick [-m] [--prompt P] [--query Q] [--preview command...]")
+	fmt.Fprintln(s.Stdout, "Choose lines from stdin with the fuzzy finder and print the selection")
+	s.printColor(theme.Command, "  z [-l] [-i] [term...] / z -x [dir]")
+	fmt.Fprintln(s.Stdout, "Jump to the most frecent directory matching the terms (also j)")
+	s.printColor(theme.Command, "  complete [-o OPTS] [-W WORDS] [-f] [-G GLOB] [-d] [-F FUNC] [-C CMD] NAME...")
+	fmt.Fprintln(s.Stdout, "Define how the arguments of a command complete; -p lists, -r removes")
+	s.printColor(theme.Command, "  joblog [%job] [-f]")
+	fmt.Fprintln(s.Stdout, "Show the captured output of a background job, -f to follow it")
+	s.printColor(theme.Command, "  tree [directory] [-a|--all]")
+	fmt.Fprintln(s.Stdout, "Visualize directory structure as a colorful tree")
+	fmt.Fprintln(s.Stdout, "    -a, --all    Show hidden files and directories")
+	fmt.Fprintln(s.Stdout, "    If no directory is specified, the current directory is used")
+	fmt.Fprintln(s.Stdout, "    Colors indicate directory levels and file types")
+	s.printColor(theme.Command, "  watch              ")
+	fmt.Fprintln(s.Stdout, "Runs a specified command periodically and displays its output")
+	s.printColor(theme.Command, "  compress [file]   ")
+	fmt.Fprintln(s.Stdout, "Compress a file using gzip")
+	s.printColor(theme.Command, "  decompress [file] ")
+	fmt.Fprintln(s.Stdout, "Decompress a file using gzip")
+	s.printColor(theme.Command, "  diff [file1] [file2] ")
+	fmt.Fprintln(s.Stdout, "Compare two files line by line")
+	s.printColor(theme.Command, "  free              ")
+	fmt.Fprintln(s.Stdout, "Display amount of free and used memory in the system")
+	s.printColor(theme.Command, "  uname             ")
+	fmt.Fprintln(s.Stdout, "Print system information")
+	s.printColor(theme.Command, "  file_transfer     ")
+	fmt.Fprintln(s.Stdout, "Transfer files between systems using ssh")
+	s.printColor(theme.Command, "  remote_execute    ")
+	fmt.Fprintln(s.Stdout, "Execute a command on a remote machine via SSH")
+	s.printColor(theme.Command, "  ulimit [-a] [-c|-f|-n|-t|-u|-v [value]] ")
+	fmt.Fprintln(s.Stdout, "Show or set resource limits for spawned commands")
+	s.printColor(theme.Command, "  limit [--cpu T] [--mem S] [--files N] [--procs N] -- command ")
+	fmt.Fprintln(s.Stdout, "Run an external command with additional resource limits")
+	s.printColor(theme.Command, "  timeout [-s SIGNAL] [-k KILL_AFTER] DURATION command ")
+	fmt.Fprintln(s.Stdout, "Run a command and kill it if it is still running after DURATION (exit 124)")
+	s.printColor(theme.Command, "  retry [--attempts N] [--backoff exp|linear|const] [--max-delay D] [--on-exit CODES] -- command ")
+	fmt.Fprintln(s.Stdout, "Run a command again with jittered backoff until it succeeds")
+	s.printColor(theme.Command, "  xargs [-0] [-d DELIM] [-n N] [-L N] [-I REPLACE] [-r] [command] ")
+	fmt.Fprintln(s.Stdout, "Build and run commands from standard input")
+	s.printColor(theme.Command, "  parallel [-j N] [-k] [--tag] [--joblog FILE] [--halt now|soon,fail=N] command [::: args] ")
+	fmt.Fprintln(s.Stdout, "Run a command for every input line with a bounded pool of jobs")
+	s.printColor(theme.Command, "  gitinfo [-s] [directory]")
+	fmt.Fprintln(s.Stdout, "Show the branch, upstream, stash and changes of a git repository without running git")
+	s.printColor(theme.Command, "  config [list|get KEY|set KEY VALUE|edit|reload|path]")
+	fmt.Fprintln(s.Stdout, "Show or change the settings in config.toml")
+	s.printColor(theme.Command, "  plugin [list|info NAME|reload]")
+	fmt.Fprintln(s.Stdout, "Show the commandripple-NAME plugins found in the plugins directory and on PATH")
+	s.printColor(theme.Command, "  help              ")
+	fmt.Fprintln(s.Stdout, "Show this help message")
+	s.printColor(theme.Heading, "\nEnvironment assignments:")
+	s.printColor(theme.Command, "  Prefix a command with NAME=VALUE to set variables for that command only.")
+	fmt.Fprintln(s.Stdout, "Example: GOOS=linux go build")
+	s.printColor(theme.Heading, "\nPipes:")
+	s.printColor(theme.Command, "  Use the '|' character to pipe the output of one command to the input of another.")
+	fmt.Fprintln(s.Stdout, "Example: cat file.txt | grep 'search' | sort")
+	s.printPluginHelp()
 }
@@ -4,34 +4,37 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"commandripple/internal/commands/theme"
 )
 
-func ChangeDirectory(args []string) error {
-	if len(args) < 1 {
-		return fmt.Errorf("'cd' requires an argument")
+// `cd` command implementation: changes to a directory, to the home
+// directory without an argument and back to the previous one with `cd -`
+func (s *Session) ChangeDirectory(args []string) error {
+	targetDir := "~"
+	if len(args) > 0 {
+		targetDir = args[0]
 	}
-
-	targetDir := args[0]
+	previousDir := s.Dir()
 
 	switch targetDir {
 	case "~":
-		homeDir, err := os.UserHomeDir()
+		homeDir, err := s.homeDir()
 		if err != nil {
 			return fmt.Errorf("failed to get home directory: %v", err)
 		}
 		targetDir = homeDir
 	case "..":
-		currentDir, err := os.Getwd()
-		if err != nil {
-			return fmt.Errorf("failed to get current directory: %v", err)
-		}
-		targetDir = filepath.Dir(currentDir)
+		targetDir = filepath.Dir(s.Dir())
 	case "-":
-		// TODO: Implement changing to previous directory
-		return fmt.Errorf("changing to previous directory is not implemented yet")
+		oldDir, ok := s.LookupEnv("OLDPWD")
+		if !ok || oldDir == "" {
+			return fmt.Errorf("no previous directory, OLDPWD is not set")
+		}
+		targetDir = oldDir
 	}
 
-	err := os.Chdir(targetDir)
+	err := s.SetDir(targetDir)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return fmt.Errorf("directory does not exist: %s", targetDir)
@@ -41,20 +44,20 @@ func ChangeDirectory(args []string) error {
 		return fmt.Errorf("failed to change directory: %v", err)
 	}
 
-	newDir, err := os.Getwd()
-	if err != nil {
-		return fmt.Errorf("failed to get current directory: %v", err)
-	}
-
+	newDir := s.Dir()
 	absPath, err := filepath.Abs(newDir)
 	if err != nil {
 		return fmt.Errorf("failed to get absolute path: %v", err)
 	}
 
-	fmt.Printf("Changed to directory: %s%s%s\n", ColorBlue, absPath, ColorReset)
+	fmt.Fprintf(s.Stdout, "Changed to directory: %s\n", theme.Paint(s.Stdout, theme.Directory, absPath))
 
 	// Optional: Update shell prompt or environment variable with new directory
-	os.Setenv("PWD", absPath)
+	s.Setenv("OLDPWD", previousDir)
+	s.Setenv("PWD", absPath)
+	if s.interactive {
+		s.RecordDirectory(absPath)
+	}
 
 	return nil
 }
@@ -1,5 +1,7 @@
 package clear
 
-func ClearScreen() error {
-	return clear()
+import "io"
+
+func ClearScreen(w io.Writer) error {
+	return clear(w)
 }
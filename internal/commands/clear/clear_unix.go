@@ -4,13 +4,13 @@
 package clear
 
 import (
-	"os"
+	"io"
 	"os/exec"
 )
 
-func clear() error {
+func clear(w io.Writer) error {
 	cmd := exec.Command("clear")
 
-	cmd.Stdout = os.Stdout
+	cmd.Stdout = w
 	return cmd.Run()
 }
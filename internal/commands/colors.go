@@ -2,25 +2,19 @@ package commands
 
 import (
 	"fmt"
-)
 
-// Color codes
-const (
-	Reset   = "\033[0m"
-	Red     = "\033[31m"
-	Green   = "\033[32m"
-	Yellow  = "\033[33m"
-	Blue    = "\033[34m"
-	Magenta = "\033[35m"
-	Cyan    = "\033[36m"
-	White   = "\033[37m"
+	"commandripple/internal/commands/theme"
 )
 
-// Print in color
-func PrintColor(color, text string) {
-	fmt.Println(color + text + Reset)
+// Reset ends a color
+const Reset = theme.Reset
+
+// printColor prints a line in the color of a theme role, which is left out
+// when the standard output of the session is not a terminal
+func (s *Session) printColor(role, text string) {
+	fmt.Fprintln(s.Stdout, theme.Paint(s.Stdout, role, text))
 }
 
-func PrintColorInline(color, text string) {
-	fmt.Print(color + text + Reset)
+func (s *Session) printColorInline(role, text string) {
+	fmt.Fprint(s.Stdout, theme.Paint(s.Stdout, role, text))
 }
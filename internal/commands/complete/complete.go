@@ -0,0 +1,249 @@
+// Package complete implements programmable completion: specs registered per
+// command decide which words are offered for its arguments.
+package complete
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"commandripple/internal/commands/syntax"
+)
+
+// Context describes the command line being completed
+type Context struct {
+	Line    string   // The line up to the cursor
+	Words   []string // Words before the one being completed, the command first
+	Current string   // The partial word under the cursor
+}
+
+// Generator produces candidates for a context. Candidates are full words;
+// those not starting with the current word are dropped by the caller.
+type Generator func(ctx Context) []string
+
+// Spec describes the completions for the arguments of one command
+type Spec struct {
+	Options  []string // Offered when the current word starts with -
+	Words    []string // Fixed word list
+	Files    bool     // Files and directories
+	Glob     string   // Only files matching this pattern, directories are kept
+	Dirs     bool     // Directories only
+	Function string   // Name of a registered generator
+	Command  []string // Command whose output lines are candidates
+}
+
+var (
+	mutex      sync.RWMutex
+	specs      = make(map[string]*Spec)
+	generators = make(map[string]Generator)
+)
+
+// Register sets the spec for a command, replacing any earlier one
+func Register(name string, spec *Spec) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	specs[name] = spec
+}
+
+// Remove deletes the spec of a command and reports whether there was one
+func Remove(name string) bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+	_, ok := specs[name]
+	delete(specs, name)
+	return ok
+}
+
+// Lookup returns the spec of a command
+func Lookup(name string) (*Spec, bool) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	spec, ok := specs[name]
+	return spec, ok
+}
+
+// Names returns the commands with a spec, sorted
+func Names() []string {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	names := make([]string, 0, len(specs))
+	for name := range specs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RegisterFunction makes a generator available to specs under a name
+func RegisterFunction(name string, fn Generator) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	generators[name] = fn
+}
+
+// Function returns the generator registered under a name
+func Function(name string) (Generator, bool) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	fn, ok := generators[name]
+	return fn, ok
+}
+
+// FunctionNames returns the names of the registered generators, sorted
+func FunctionNames() []string {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	names := make([]string, 0, len(generators))
+	for name := range generators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Parse splits the line up to the cursor into the words before the cursor
+// and the partial word under it. Words are read like the shell reads them,
+// but variables are kept as written so that they can be completed.
+func Parse(line string) Context {
+	keep := func(name string) (string, bool) { return "$" + name, true }
+	tokens, _ := syntax.Tokenize(line, keep)
+
+	ctx := Context{Line: line}
+	for i, token := range tokens {
+		word := token.Value
+		if token.Kind != syntax.Word {
+			word = token.Text
+		}
+		if i == len(tokens)-1 && token.Kind == syntax.Word && token.End == len(line) {
+			ctx.Current = word
+			break
+		}
+		ctx.Words = append(ctx.Words, word)
+	}
+	return ctx
+}
+
+// Candidates returns the words the spec offers in a context. run executes
+// the spec's command and returns its output.
+func (s *Spec) Candidates(ctx Context, run func(argv []string) string) []string {
+	var candidates []string
+	if strings.HasPrefix(ctx.Current, "-") && len(s.Options) > 0 {
+		return s.Options
+	}
+
+	candidates = append(candidates, s.Words...)
+	if s.Function != "" {
+		if fn, ok := Function(s.Function); ok {
+			candidates = append(candidates, fn(ctx)...)
+		}
+	}
+	if len(s.Command) > 0 && run != nil {
+		for _, line := range strings.Split(run(s.Command), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				candidates = append(candidates, line)
+			}
+		}
+	}
+	if s.Files || s.Glob != "" || s.Dirs {
+		candidates = append(candidates, Files(ctx.Current, s.Dirs, s.Glob)...)
+	}
+	return candidates
+}
+
+// String formats the spec as the complete command that registers it
+func (s *Spec) String(name string) string {
+	parts := []string{"complete"}
+	if len(s.Options) > 0 {
+		parts = append(parts, "-o", strings.Join(s.Options, ","))
+	}
+	if len(s.Words) > 0 {
+		parts = append(parts, "-W", strings.Join(s.Words, ","))
+	}
+	if s.Files {
+		parts = append(parts, "-f")
+	}
+	if s.Glob != "" {
+		parts = append(parts, "-G", s.Glob)
+	}
+	if s.Dirs {
+		parts = append(parts, "-d")
+	}
+	if s.Function != "" {
+		parts = append(parts, "-F", s.Function)
+	}
+	if len(s.Command) > 0 {
+		parts = append(parts, "-C", strings.Join(s.Command, ","))
+	}
+	return strings.Join(append(parts, name), " ")
+}
+
+// ShowHidden makes Files offer hidden entries without a leading dot
+var ShowHidden bool
+
+// Files returns the paths completing prefix. Directories end with a path
+// separator and are always offered so that the user can descend into them;
+// files can be limited to a glob pattern or left out entirely.
+func Files(prefix string, dirsOnly bool, glob string) []string {
+	dir, base := filepath.Split(prefix)
+	readDir := dir
+	if readDir == "" {
+		readDir = "."
+	}
+	if strings.HasPrefix(readDir, "~") {
+		if home, err := os.UserHomeDir(); err == nil {
+			readDir = home + readDir[1:]
+		}
+	}
+
+	entries, err := os.ReadDir(readDir)
+	if err != nil {
+		return nil
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, base) {
+			continue
+		}
+		// Hidden entries only when asked for with a leading dot
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".") && !ShowHidden {
+			continue
+		}
+		isDir := entry.IsDir()
+		if entry.Type()&os.ModeSymlink != 0 {
+			if info, err := os.Stat(filepath.Join(readDir, name)); err == nil {
+				isDir = info.IsDir()
+			}
+		}
+		switch {
+		case isDir:
+			name += string(os.PathSeparator)
+		case dirsOnly:
+			continue
+		case glob != "":
+			if ok, _ := filepath.Match(glob, name); !ok {
+				continue
+			}
+		}
+		paths = append(paths, dir+name)
+	}
+	return paths
+}
+
+// Filter keeps the candidates starting with prefix, sorted and without
+// duplicates
+func Filter(candidates []string, prefix string) []string {
+	seen := make(map[string]bool)
+	var matches []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, prefix) && !seen[c] {
+			seen[c] = true
+			matches = append(matches, c)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
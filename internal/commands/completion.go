@@ -0,0 +1,405 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"commandripple/internal/commands/complete"
+	"commandripple/internal/commands/config"
+	"commandripple/internal/commands/processes"
+)
+
+// completionCommandTimeout bounds commands run by `complete -C`
+const completionCommandTimeout = 2 * time.Second
+
+func init() {
+	complete.RegisterFunction("aliases", func(complete.Context) []string { return Default.aliasNames() })
+	complete.RegisterFunction("commands", func(complete.Context) []string { return Default.commandNames() })
+	complete.RegisterFunction("hosts", func(complete.Context) []string { return sshHosts() })
+	complete.RegisterFunction("jobs", func(complete.Context) []string { return Default.jobSpecs() })
+	complete.RegisterFunction("pids", func(complete.Context) []string { return processIDs() })
+	complete.RegisterFunction("signals", completeSignals)
+	complete.RegisterFunction("tasks", func(complete.Context) []string { return TaskNames() })
+	complete.RegisterFunction("variables", func(complete.Context) []string { return Default.variableNames() })
+	complete.RegisterFunction("config", func(ctx complete.Context) []string { return Default.completeConfig(ctx) })
+	complete.RegisterFunction("kill", func(ctx complete.Context) []string {
+		switch {
+		case strings.HasPrefix(ctx.Current, "%"):
+			return Default.jobSpecs()
+		case strings.HasPrefix(ctx.Current, "-"):
+			return completeSignals(ctx)
+		}
+		return processIDs()
+	})
+
+	for _, name := range []string{"fg", "bg", "wait", "disown", "joblog"} {
+		complete.Register(name, &complete.Spec{Function: "jobs"})
+	}
+	for _, name := range []string{"cd", "rmdir", "du", "tree"} {
+		complete.Register(name, &complete.Spec{Dirs: true})
+	}
+	complete.Register("kill", &complete.Spec{Function: "kill"})
+	complete.Register("config", &complete.Spec{Function: "config"})
+	complete.Register("alias", &complete.Spec{Function: "aliases"})
+	complete.Register("unalias", &complete.Spec{Function: "aliases"})
+	complete.Register("export", &complete.Spec{Function: "variables"})
+	complete.Register("unset", &complete.Spec{Function: "variables"})
+	complete.Register("which", &complete.Spec{Function: "commands"})
+	complete.Register("plugin", &complete.Spec{Words: []string{"list", "info", "reload"}})
+	complete.Register("gitinfo", &complete.Spec{Dirs: true, Options: []string{"-s", "--short"}})
+	complete.Register("complete", &complete.Spec{Function: "commands", Options: []string{"-o", "-W", "-f", "-G", "-d", "-F", "-C", "-p", "-r"}})
+	complete.Register("remote_execute", &complete.Spec{Function: "hosts"})
+	complete.Register("file_transfer", &complete.Spec{Function: "hosts", Files: true})
+	complete.Register("ping", &complete.Spec{Function: "hosts"})
+	complete.Register("task", &complete.Spec{Function: "tasks", Options: []string{"-f", "-j", "--force", "--list"}})
+	complete.Register("chmod", &complete.Spec{Words: []string{"644", "755", "777", "600", "400"}, Files: true})
+	complete.Register("svc", &complete.Spec{Words: []string{"start", "list", "logs", "stop", "restart", "rm"}})
+	complete.Register("schedule", &complete.Spec{Words: []string{"list", "rm"}})
+	complete.Register("history", &complete.Spec{Words: []string{"scrub"}, Options: []string{"-v", "-c", "-d", "--since", "--until", "--dir", "--here", "--status", "--failed", "--session"}})
+}
+
+// Completions returns the words completing the line up to the cursor and
+// the partial word they replace
+func (s *Session) Completions(line string) ([]string, string) {
+	ctx := complete.Parse(line)
+
+	// Variables complete anywhere, e.g. `echo $HO` or `cd $GO`
+	if i := strings.LastIndex(ctx.Current, "$"); i >= 0 {
+		var candidates []string
+		for _, name := range s.variableNames() {
+			candidates = append(candidates, ctx.Current[:i+1]+name)
+		}
+		return complete.Filter(candidates, ctx.Current), ctx.Current
+	}
+
+	// Only the words of the last pipeline stage, after any assignments,
+	// belong to the command being completed
+	words := ctx.Words
+	for i, word := range words {
+		if word == "|" {
+			words = ctx.Words[i+1:]
+		}
+	}
+	for len(words) > 0 && isAssignment(words[0]) {
+		words = words[1:]
+	}
+	ctx.Words = words
+
+	if len(words) == 0 {
+		if strings.ContainsRune(ctx.Current, os.PathSeparator) || strings.HasPrefix(ctx.Current, ".") {
+			return complete.Filter(complete.Files(ctx.Current, false, ""), ctx.Current), ctx.Current
+		}
+		return complete.Filter(s.commandNames(), ctx.Current), ctx.Current
+	}
+
+	spec, ok := complete.Lookup(words[0])
+	if !ok {
+		if p, ok := s.lookupPlugin(words[0]); ok {
+			return complete.Filter(s.pluginCandidates(p, ctx), ctx.Current), ctx.Current
+		}
+		spec = &complete.Spec{Files: true}
+	}
+	run := func(argv []string) string { return s.runCompletionCommand(argv, ctx) }
+	return complete.Filter(spec.Candidates(ctx, run), ctx.Current), ctx.Current
+}
+
+// `complete` command implementation: registers how the arguments of
+// commands are completed, e.g. `complete -W start,stop,status svcctl`,
+// `complete -G *.go -o -v,-run gotest` or `complete -C ./list-envs deploy`.
+// Without options or with -p the specs are printed, -r removes them.
+func (s *Session) Complete(args []string) error {
+	spec := &complete.Spec{}
+	var names []string
+	remove := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "-p":
+			continue
+		case "-r":
+			remove = true
+			continue
+		case "-f":
+			spec.Files = true
+			continue
+		case "-d":
+			spec.Dirs = true
+			continue
+		case "-o", "-W", "-G", "-F", "-C":
+		default:
+			if strings.HasPrefix(arg, "-") {
+				return fmt.Errorf("usage: complete [-o OPTIONS] [-W WORDS] [-f] [-G GLOB] [-d] [-F FUNCTION] [-C COMMAND] NAME... | complete -p | complete -r NAME...")
+			}
+			names = append(names, arg)
+			continue
+		}
+
+		if i+1 >= len(args) {
+			return fmt.Errorf("missing value for %s", arg)
+		}
+		i++
+		value := args[i]
+		switch arg {
+		case "-o":
+			spec.Options = splitWordList(value)
+		case "-W":
+			spec.Words = splitWordList(value)
+		case "-G":
+			spec.Glob = value
+		case "-F":
+			if _, ok := complete.Function(value); !ok {
+				return fmt.Errorf("complete: unknown function %s, available: %s", value, strings.Join(complete.FunctionNames(), ", "))
+			}
+			spec.Function = value
+		case "-C":
+			spec.Command = splitWordList(value)
+		}
+	}
+
+	if remove {
+		for _, name := range names {
+			if !complete.Remove(name) {
+				return fmt.Errorf("complete: no completion spec for %s", name)
+			}
+		}
+		return nil
+	}
+
+	if len(names) == 0 {
+		for _, name := range complete.Names() {
+			registered, _ := complete.Lookup(name)
+			fmt.Fprintln(s.Stdout, registered.String(name))
+		}
+		return nil
+	}
+	for _, name := range names {
+		complete.Register(name, spec)
+	}
+	return nil
+}
+
+// splitWordList splits a list given as one argument on commas and spaces
+func splitWordList(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' })
+}
+
+// runCompletionCommand runs the command of a spec with the line being
+// completed in COMP_LINE and the partial word in COMP_WORD
+func (s *Session) runCompletionCommand(argv []string, ctx complete.Context) string {
+	if IsBuiltinCommand(argv[0]) {
+		var buf bytes.Buffer
+		sub := *s
+		sub.Stdin, sub.Stdout, sub.Stderr = strings.NewReader(""), &buf, io.Discard
+		sub.ExecuteBuiltin(argv[0], argv[1:])
+		return buf.String()
+	}
+
+	timeout, cancel := context.WithTimeout(context.Background(), completionCommandTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(timeout, argv[0], argv[1:]...)
+	cmd.Env = append(s.Environ(), "COMP_LINE="+ctx.Line, "COMP_WORD="+ctx.Current)
+	output, _ := cmd.Output()
+	return string(output)
+}
+
+func (s *Session) aliasNames() []string {
+	aliases := s.aliasTable()
+	names := make([]string, 0, len(aliases))
+	for name := range aliases {
+		names = append(names, name)
+	}
+	return names
+}
+
+func (s *Session) variableNames() []string {
+	var names []string
+	for _, entry := range s.Environ() {
+		if name, _, ok := strings.Cut(entry, "="); ok && name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// completeConfig offers the subcommands of config, then setting names
+func (s *Session) completeConfig(ctx complete.Context) []string {
+	switch {
+	case len(ctx.Words) == 1:
+		return []string{"list", "get", "set", "edit", "reload", "path"}
+	case len(ctx.Words) == 2 && (ctx.Words[1] == "get" || ctx.Words[1] == "set"):
+		var keys []string
+		for _, setting := range config.Settings {
+			keys = append(keys, setting.Key)
+		}
+		return append(keys, s.settings.Keys()...)
+	}
+	return nil
+}
+
+// jobSpecs returns %N for every job in the job table
+func (s *Session) jobSpecs() []string {
+	s.jobs.mutex.Lock()
+	defer s.jobs.mutex.Unlock()
+	var specs []string
+	for _, j := range s.jobs.sorted() {
+		specs = append(specs, "%"+strconv.Itoa(j.ID))
+	}
+	return specs
+}
+
+func completeSignals(ctx complete.Context) []string {
+	var names []string
+	for _, name := range processes.SignalNames() {
+		if strings.HasPrefix(ctx.Current, "-") {
+			name = "-" + name
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// processIDs lists the running processes
+func processIDs() []string {
+	switch runtime.GOOS {
+	case "linux":
+		entries, err := os.ReadDir("/proc")
+		if err != nil {
+			return nil
+		}
+		var pids []string
+		for _, entry := range entries {
+			if _, err := strconv.Atoi(entry.Name()); err == nil {
+				pids = append(pids, entry.Name())
+			}
+		}
+		return pids
+	case "windows":
+		return nil
+	}
+	output, err := exec.Command("ps", "-axo", "pid=").Output()
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(string(output))
+}
+
+// sshHosts collects host names from ~/.ssh/config and ~/.ssh/known_hosts,
+// leaving out patterns and hashed entries
+func sshHosts() []string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+
+	var hosts []string
+	if f, err := os.Open(filepath.Join(home, ".ssh", "config")); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 2 || !strings.EqualFold(fields[0], "Host") {
+				continue
+			}
+			for _, host := range fields[1:] {
+				if !strings.ContainsAny(host, "*?!") {
+					hosts = append(hosts, host)
+				}
+			}
+		}
+		f.Close()
+	}
+
+	if f, err := os.Open(filepath.Join(home, ".ssh", "known_hosts")); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) == 0 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "|") || strings.HasPrefix(fields[0], "@") {
+				continue
+			}
+			for _, host := range strings.Split(fields[0], ",") {
+				// [host]:port for servers on other ports
+				if strings.HasPrefix(host, "[") {
+					if end := strings.Index(host, "]"); end > 0 {
+						host = host[1:end]
+					}
+				}
+				hosts = append(hosts, host)
+			}
+		}
+		f.Close()
+	}
+	return hosts
+}
+
+// commandNames returns the builtins, aliases and executables on the PATH
+func (s *Session) commandNames() []string {
+	names := append(BuiltinNames(), s.aliasNames()...)
+	names = append(names, s.pluginNames()...)
+	return append(names, pathExecutables()...)
+}
+
+var executableCache struct {
+	sync.Mutex
+	path    string
+	updated time.Time
+	names   []string
+}
+
+// pathExecutables lists the executables in the PATH directories. The list
+// is cached for a few seconds since it is needed on every Tab.
+func pathExecutables() []string {
+	path := os.Getenv("PATH")
+	executableCache.Lock()
+	defer executableCache.Unlock()
+	if executableCache.path == path && time.Since(executableCache.updated) < 10*time.Second {
+		return executableCache.names
+	}
+
+	var extensions []string
+	if runtime.GOOS == "windows" {
+		extensions = strings.Split(strings.ToLower(os.Getenv("PATHEXT")), ";")
+	}
+
+	var names []string
+	for _, dir := range filepath.SplitList(path) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			name := entry.Name()
+			if extensions != nil {
+				ext := strings.ToLower(filepath.Ext(name))
+				for _, e := range extensions {
+					if e != "" && ext == e {
+						names = append(names, strings.TrimSuffix(name, filepath.Ext(name)))
+						break
+					}
+				}
+				continue
+			}
+			info, err := os.Stat(filepath.Join(dir, name))
+			if err == nil && info.Mode().IsRegular() && info.Mode().Perm()&0111 != 0 {
+				names = append(names, name)
+			}
+		}
+	}
+
+	executableCache.path = path
+	executableCache.updated = time.Now()
+	executableCache.names = names
+	return names
+}
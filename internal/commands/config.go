@@ -0,0 +1,202 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"commandripple/internal/commands/complete"
+	"commandripple/internal/commands/config"
+	"commandripple/internal/commands/syntax"
+	"commandripple/internal/commands/theme"
+)
+
+// OnConfigChange registers fn to be called whenever the config is loaded
+// or changed, for settings that belong to the line editor
+func (s *Session) OnConfigChange(fn func()) {
+	s.configListeners = append(s.configListeners, fn)
+}
+
+// LoadConfig reads the config file at path, or the default one when path is
+// empty, and applies it. When the file is invalid the defaults stay in
+// effect and the error is returned.
+func (s *Session) LoadConfig(path string) error {
+	if path == "" {
+		var err error
+		if path, err = config.DefaultPath(); err != nil {
+			return err
+		}
+	}
+	loaded, err := config.Load(path)
+	if err != nil {
+		s.settings = config.Empty(path)
+		s.applyConfig()
+		return err
+	}
+	s.settings = loaded
+	s.applyConfig()
+	return nil
+}
+
+// Settings returns the loaded config
+func (s *Session) Settings() *config.Config {
+	return s.settings
+}
+
+func (s *Session) applyConfig() {
+	s.mutex.Lock()
+	for name, command := range s.configAliases {
+		if s.aliases[name] == command {
+			delete(s.aliases, name)
+		}
+	}
+	s.configAliases = s.settings.Aliases()
+	for name, command := range s.configAliases {
+		s.aliases[name] = command
+	}
+	s.mutex.Unlock()
+
+	// The rest belongs to the terminal, which only the interactive shell
+	// draws on
+	if !s.interactive {
+		return
+	}
+
+	// The settings were checked when they were loaded
+	for _, role := range theme.Roles {
+		theme.Set(role.Name, s.settings.String("colors."+role.Name))
+	}
+	complete.ShowHidden = s.settings.Bool("completion.show_hidden")
+
+	// A new history file is opened when the setting changed
+	if s.history != nil {
+		if _, err := s.InitHistory(); err != nil {
+			fmt.Fprintf(s.Stderr, "CommandRipple: %v\n", err)
+		}
+	}
+
+	for _, fn := range s.configListeners {
+		fn()
+	}
+}
+
+// RCPath returns the startup file run by interactive shells,
+// ~/.commandripplerc
+func RCPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".commandripplerc")
+}
+
+// expandHome replaces a leading ~ with the home directory
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") && !strings.HasPrefix(path, "~"+string(os.PathSeparator)) {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
+
+// `config` command implementation: shows and changes the settings in
+// config.toml. `config get KEY` prints a setting, `config set KEY VALUE`
+// writes it to the file and applies it, `config edit` opens the file in
+// $VISUAL or $EDITOR and `config reload` reads it again. Without arguments
+// every setting is listed.
+func (s *Session) Config(args []string) error {
+	usage := fmt.Errorf("usage: config [list|get KEY|set KEY VALUE|edit|reload|path]")
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	if s.settings.Path() == "" {
+		// Started without reading the config file
+		path, err := config.DefaultPath()
+		if err != nil {
+			return err
+		}
+		s.settings = config.Empty(path)
+	}
+
+	switch args[0] {
+	case "list":
+		for _, setting := range config.Settings {
+			s.printSetting(setting.Key)
+		}
+		for _, key := range s.settings.Keys() {
+			if strings.HasPrefix(key, config.AliasPrefix) {
+				s.printSetting(key)
+			}
+		}
+	case "get":
+		if len(args) != 2 {
+			return usage
+		}
+		if _, ok := config.Lookup(args[1]); !ok {
+			return fmt.Errorf("config: unknown setting %s", args[1])
+		}
+		if strings.HasPrefix(args[1], config.AliasPrefix) && !s.settings.IsSet(args[1]) {
+			return &ExitStatus{Code: 1, Reason: fmt.Sprintf("config: %s is not set", args[1])}
+		}
+		fmt.Fprintln(s.Stdout, s.settings.Get(args[1]))
+	case "set":
+		if len(args) < 3 {
+			return usage
+		}
+		if err := s.settings.Set(args[1], strings.Join(args[2:], " ")); err != nil {
+			return fmt.Errorf("config: %v", err)
+		}
+		return s.reloadConfig()
+	case "edit":
+		path := s.settings.Path()
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			if err := os.WriteFile(path, []byte(config.Template()), 0644); err != nil {
+				return err
+			}
+		}
+		if err := s.executeCommand(s.editor() + " " + syntax.Quote(path)); err != nil {
+			return err
+		}
+		return s.reloadConfig()
+	case "reload":
+		return s.reloadConfig()
+	case "path":
+		fmt.Fprintln(s.Stdout, s.settings.Path())
+	default:
+		return usage
+	}
+	return nil
+}
+
+func (s *Session) printSetting(key string) {
+	s.printColorInline(theme.Accent, key)
+	fmt.Fprintf(s.Stdout, " = %s\n", s.settings.Format(key))
+}
+
+func (s *Session) reloadConfig() error {
+	if err := s.LoadConfig(s.settings.Path()); err != nil {
+		return fmt.Errorf("config: %v", err)
+	}
+	return nil
+}
+
+// editor returns the command line of the user's editor
+func (s *Session) editor() string {
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if value := s.Getenv(name); value != "" {
+			return value
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return "notepad"
+	}
+	return "vi"
+}
@@ -0,0 +1,317 @@
+// Package config reads the shell's settings from config.toml. Settings
+// have a type and a default; a file only holds the ones that were changed.
+// Aliases are the exception, every key under [aliases] defines one.
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"commandripple/internal/commands/theme"
+)
+
+// Kinds of settings
+const (
+	String = iota
+	Int
+	Bool
+)
+
+// Setting is a known key with its type, default and description
+type Setting struct {
+	Key     string
+	Kind    int
+	Default any
+	Doc     string
+	check   func(any) error
+}
+
+// Settings lists every known key in the order they are shown
+var Settings = withColors([]Setting{
+	{Key: "prompt.template", Kind: String, Default: "", Doc: "Prompt template when PROMPT and PS1 are not set"},
+	{Key: "prompt.right", Kind: String, Default: "", Doc: "Right prompt when RPROMPT is not set"},
+	{Key: "prompt.transient", Kind: String, Default: "", Doc: "Transient prompt when TRANSIENT_PROMPT is not set"},
+	{Key: "colors.highlight", Kind: Bool, Default: true, Doc: "Color the command line as it is typed"},
+	{Key: "history.size", Kind: Int, Default: int64(0), Doc: "Entries kept in the history file, 0 for no limit", check: checkSize},
+	{Key: "history.path", Kind: String, Default: "", Doc: "History file instead of the one in the data directory"},
+	{Key: "completion.show_hidden", Kind: Bool, Default: false, Doc: "Complete hidden files without a leading dot"},
+	{Key: "completion.autosuggest", Kind: Bool, Default: true, Doc: "Suggest commands from the history while typing"},
+	{Key: "keybindings.mode", Kind: String, Default: "emacs", Doc: "Line editing keys, emacs or vi", check: checkMode},
+	{Key: "keybindings.history_search", Kind: String, Default: "ctrl-r", Doc: "Key opening the history finder", check: checkKey},
+	{Key: "keybindings.file_search", Kind: String, Default: "ctrl-t", Doc: "Key opening the file finder", check: checkKey},
+	{Key: "plugins.dir", Kind: String, Default: "", Doc: "Directory searched for commandripple-NAME plugins before PATH, plugins next to config.toml when empty"},
+})
+
+// withColors adds a colors.ROLE setting for every role of the theme after
+// colors.highlight
+func withColors(settings []Setting) []Setting {
+	var list []Setting
+	for _, s := range settings {
+		list = append(list, s)
+		if s.Key != "colors.highlight" {
+			continue
+		}
+		for _, role := range theme.Roles {
+			list = append(list, Setting{Key: "colors." + role.Name, Kind: String, Default: role.Default, Doc: role.Doc, check: checkColor})
+		}
+	}
+	return list
+}
+
+// AliasPrefix starts the keys that define aliases
+const AliasPrefix = "aliases."
+
+// Lookup returns the setting of a key
+func Lookup(key string) (Setting, bool) {
+	for _, s := range Settings {
+		if s.Key == key {
+			return s, true
+		}
+	}
+	if strings.HasPrefix(key, AliasPrefix) && len(key) > len(AliasPrefix) {
+		return Setting{Key: key, Kind: String, Default: ""}, true
+	}
+	return Setting{}, false
+}
+
+// Config is a loaded config file
+type Config struct {
+	path string
+	doc  *Document
+}
+
+// DefaultPath returns $XDG_CONFIG_HOME/commandripple/config.toml,
+// ~/.config/commandripple/config.toml or %AppData%\commandripple\config.toml
+// on Windows
+func DefaultPath() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "commandripple", "config.toml"), nil
+	}
+	if runtime.GOOS == "windows" {
+		if dir := os.Getenv("AppData"); dir != "" {
+			return filepath.Join(dir, "commandripple", "config.toml"), nil
+		}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the config directory: %v", err)
+	}
+	return filepath.Join(home, ".config", "commandripple", "config.toml"), nil
+}
+
+// Empty returns a config without a file, holding the defaults
+func Empty(path string) *Config {
+	return &Config{path: path, doc: &Document{values: map[string]any{}, spans: map[string]span{}, tables: map[string]int{}}}
+}
+
+// Load reads the config file at path. A missing file holds the defaults.
+// Unknown keys and values of the wrong type are errors, reported with the
+// file name and line.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return Empty(path), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	doc, err := Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for _, key := range doc.Keys() {
+		value, _ := doc.Get(key)
+		if _, err := convert(key, value); err != nil {
+			return nil, fmt.Errorf("%s: line %d: %v", path, doc.spans[key].start+1, err)
+		}
+	}
+	return &Config{path: path, doc: doc}, nil
+}
+
+// Path returns the file the config was loaded from
+func (c *Config) Path() string {
+	return c.path
+}
+
+// Get returns the value of a setting, or its default
+func (c *Config) Get(key string) any {
+	if value, ok := c.doc.Get(key); ok {
+		if value, err := convert(key, value); err == nil {
+			return value
+		}
+	}
+	s, _ := Lookup(key)
+	return s.Default
+}
+
+// IsSet reports whether the file sets a key
+func (c *Config) IsSet(key string) bool {
+	_, ok := c.doc.Get(key)
+	return ok
+}
+
+// String returns a string setting
+func (c *Config) String(key string) string {
+	s, _ := c.Get(key).(string)
+	return s
+}
+
+// Int returns an integer setting
+func (c *Config) Int(key string) int {
+	i, _ := c.Get(key).(int64)
+	return int(i)
+}
+
+// Bool returns a boolean setting
+func (c *Config) Bool(key string) bool {
+	b, _ := c.Get(key).(bool)
+	return b
+}
+
+// Aliases returns the aliases defined under [aliases]
+func (c *Config) Aliases() map[string]string {
+	aliases := make(map[string]string)
+	for _, key := range c.doc.Keys() {
+		if name, ok := strings.CutPrefix(key, AliasPrefix); ok {
+			aliases[name] = c.String(key)
+		}
+	}
+	return aliases
+}
+
+// Keys returns the keys set in the file
+func (c *Config) Keys() []string {
+	return c.doc.Keys()
+}
+
+// Set parses value as the type of the setting and writes it to the file,
+// creating the file and its directory when needed
+func (c *Config) Set(key, value string) error {
+	s, ok := Lookup(key)
+	if !ok {
+		return fmt.Errorf("unknown setting %s", key)
+	}
+	var parsed any = value
+	switch s.Kind {
+	case Int:
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s must be a number", key)
+		}
+		parsed = i
+	case Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s must be true or false", key)
+		}
+		parsed = b
+	}
+	if _, err := convert(key, parsed); err != nil {
+		return err
+	}
+
+	// Changes made to the file since it was loaded are kept
+	if data, err := os.ReadFile(c.path); err == nil {
+		doc, err := Parse(string(data))
+		if err != nil {
+			return fmt.Errorf("%s: %v", c.path, err)
+		}
+		c.doc = doc
+	}
+	if err := c.doc.Set(key, parsed); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, []byte(c.doc.String()), 0644)
+}
+
+// Format returns a value as written in the file
+func (c *Config) Format(key string) string {
+	return Format(c.Get(key))
+}
+
+// convert checks that a value from the file has the type of its setting
+func convert(key string, value any) (any, error) {
+	s, ok := Lookup(key)
+	if !ok {
+		return nil, fmt.Errorf("unknown setting %s", key)
+	}
+	switch s.Kind {
+	case String:
+		if _, ok := value.(string); !ok {
+			return nil, fmt.Errorf("%s must be a string", key)
+		}
+	case Int:
+		if _, ok := value.(int64); !ok {
+			return nil, fmt.Errorf("%s must be an integer", key)
+		}
+	case Bool:
+		if _, ok := value.(bool); !ok {
+			return nil, fmt.Errorf("%s must be true or false", key)
+		}
+	}
+	if s.check != nil {
+		if err := s.check(value); err != nil {
+			return nil, fmt.Errorf("%s: %v", key, err)
+		}
+	}
+	return value, nil
+}
+
+func checkSize(value any) error {
+	if value.(int64) < 0 {
+		return fmt.Errorf("must not be negative")
+	}
+	return nil
+}
+
+func checkMode(value any) error {
+	if mode := value.(string); mode != "emacs" && mode != "vi" {
+		return fmt.Errorf("must be emacs or vi")
+	}
+	return nil
+}
+
+func checkKey(value any) error {
+	_, err := ParseKey(value.(string))
+	return err
+}
+
+func checkColor(value any) error {
+	return theme.Check(value.(string))
+}
+
+// ParseKey returns the control character of a key such as ctrl-r
+func ParseKey(key string) (rune, error) {
+	name := strings.ToLower(key)
+	for _, prefix := range []string{"ctrl-", "ctrl+", "c-"} {
+		if letter, ok := strings.CutPrefix(name, prefix); ok && len(letter) == 1 && letter[0] >= 'a' && letter[0] <= 'z' {
+			return rune(letter[0]-'a') + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported key %q, use ctrl-a to ctrl-z", key)
+}
+
+// Template returns a config file with every setting commented out at its
+// default, written by `config edit` when there is no file yet
+func Template() string {
+	var b strings.Builder
+	b.WriteString("# CommandRipple settings. Remove the # in front of a setting to change it.\n")
+	table := ""
+	for _, s := range Settings {
+		t, name, _ := strings.Cut(s.Key, ".")
+		if t != table {
+			fmt.Fprintf(&b, "\n[%s]\n", t)
+			table = t
+		}
+		fmt.Fprintf(&b, "# %s\n# %s = %s\n", s.Doc, name, Format(s.Default))
+	}
+	b.WriteString("\n[aliases]\n# ll = \"ls -l\"\n")
+	return b.String()
+}
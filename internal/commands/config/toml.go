@@ -0,0 +1,446 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Document is a TOML file read into flat keys, such as prompt.template,
+// that remembers where each key was written so that it can be changed in
+// place without losing comments or layout. Only the parts of TOML a
+// configuration needs are supported: tables, dotted keys, strings,
+// integers, floats, booleans and arrays.
+type Document struct {
+	lines  []string
+	values map[string]any
+	spans  map[string]span // Lines of each key
+	tables map[string]int  // Line of each [table] header
+}
+
+type span struct {
+	start, end int    // end is exclusive
+	key        string // As written, before the =
+	table      string // Header the key is written under
+	comment    string // After the value, kept when it changes
+}
+
+// ParseError is a syntax error at a line of a document
+type ParseError struct {
+	Line int
+	Msg  string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// Parse reads a document
+func Parse(data string) (*Document, error) {
+	d := &Document{
+		values: make(map[string]any),
+		spans:  make(map[string]span),
+		tables: make(map[string]int),
+	}
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	if data != "" {
+		d.lines = strings.Split(strings.TrimSuffix(data, "\n"), "\n")
+	}
+
+	table := ""
+	for i := 0; i < len(d.lines); i++ {
+		fail := func(format string, args ...any) error {
+			return &ParseError{Line: i + 1, Msg: fmt.Sprintf(format, args...)}
+		}
+		line := strings.TrimSpace(d.lines[i])
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[[") {
+			return nil, fail("arrays of tables are not supported")
+		}
+		if line[0] == '[' {
+			end := strings.IndexByte(line, ']')
+			if end < 0 || !isComment(line[end+1:]) {
+				return nil, fail("invalid table header")
+			}
+			parts, rest, err := parseKey(line[1:end])
+			if err != nil || strings.TrimSpace(rest) != "" {
+				return nil, fail("invalid table name")
+			}
+			table = strings.Join(parts, ".")
+			if _, ok := d.tables[table]; ok {
+				return nil, fail("table %s is defined twice", table)
+			}
+			d.tables[table] = i
+			continue
+		}
+
+		parts, rest, err := parseKey(line)
+		if err != nil {
+			return nil, fail("%v", err)
+		}
+		rest = strings.TrimLeft(rest, " \t")
+		if !strings.HasPrefix(rest, "=") {
+			return nil, fail("expected = after the key")
+		}
+		keyText := strings.TrimSpace(line[:len(line)-len(rest)])
+		rest = strings.TrimLeft(rest[1:], " \t")
+
+		// Arrays may go on over several lines
+		start := i
+		for !balanced(rest) && i+1 < len(d.lines) {
+			i++
+			rest += "\n" + d.lines[i]
+		}
+		value, after, err := parseValue(rest)
+		if err != nil {
+			return nil, fail("%v", err)
+		}
+		if !isComment(after) {
+			return nil, fail("unexpected text after the value")
+		}
+
+		key := strings.Join(parts, ".")
+		if table != "" {
+			key = table + "." + key
+		}
+		if _, ok := d.values[key]; ok {
+			return nil, fail("%s is set twice", key)
+		}
+		d.values[key] = value
+		d.spans[key] = span{start: start, end: i + 1, key: keyText, table: table, comment: strings.TrimSpace(after)}
+	}
+	return d, nil
+}
+
+// Get returns the value of a key: a string, int64, float64, bool or []any
+func (d *Document) Get(key string) (any, bool) {
+	value, ok := d.values[key]
+	return value, ok
+}
+
+// Keys returns every key, sorted
+func (d *Document) Keys() []string {
+	keys := make([]string, 0, len(d.values))
+	for key := range d.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Set changes a key where it is written, or adds it after the last key of
+// its table, which is created when needed. The table is the key up to its
+// last dot.
+func (d *Document) Set(key string, value any) error {
+	text := Format(value)
+	lines := append([]string(nil), d.lines...)
+	if s, ok := d.spans[key]; ok {
+		line := s.key + " = " + text
+		if s.comment != "" {
+			line += " " + s.comment
+		}
+		lines = splice(lines, s.start, s.end, line)
+	} else {
+		table, name := "", key
+		if i := strings.LastIndexByte(key, '.'); i >= 0 {
+			table, name = key[:i], key[i+1:]
+		}
+		line := quoteKey(name) + " = " + text
+		header, ok := d.tables[table]
+		switch {
+		case ok || table == "":
+			at := 0
+			if ok {
+				at = header + 1
+			}
+			for _, s := range d.spans {
+				if s.table == table && s.end > at {
+					at = s.end
+				}
+			}
+			lines = splice(lines, at, at, line)
+		case len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) != "":
+			lines = append(lines, "", "["+table+"]", line)
+		default:
+			lines = append(lines, "["+table+"]", line)
+		}
+	}
+
+	updated, err := Parse(strings.Join(lines, "\n"))
+	if err != nil {
+		return err
+	}
+	*d = *updated
+	return nil
+}
+
+func splice(lines []string, start, end int, line string) []string {
+	return append(lines[:start], append([]string{line}, lines[end:]...)...)
+}
+
+// String returns the document as it would be written to a file
+func (d *Document) String() string {
+	if len(d.lines) == 0 {
+		return ""
+	}
+	return strings.Join(d.lines, "\n") + "\n"
+}
+
+// parseKey reads a dotted key of bare and quoted parts and returns the
+// text after it
+func parseKey(s string) ([]string, string, error) {
+	var parts []string
+	for {
+		s = strings.TrimLeft(s, " \t")
+		var part string
+		switch {
+		case strings.HasPrefix(s, `"`) || strings.HasPrefix(s, "'"):
+			value, rest, err := parseString(s)
+			if err != nil {
+				return nil, "", err
+			}
+			part, s = value, rest
+		default:
+			n := 0
+			for n < len(s) && isBare(s[n]) {
+				n++
+			}
+			if n == 0 {
+				return nil, "", fmt.Errorf("expected a key")
+			}
+			part, s = s[:n], s[n:]
+		}
+		parts = append(parts, part)
+		trimmed := strings.TrimLeft(s, " \t")
+		if !strings.HasPrefix(trimmed, ".") {
+			return parts, s, nil
+		}
+		s = trimmed[1:]
+	}
+}
+
+func isBare(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+func quoteKey(name string) string {
+	for i := 0; i < len(name); i++ {
+		if !isBare(name[i]) {
+			return Format(name)
+		}
+	}
+	if name == "" {
+		return `""`
+	}
+	return name
+}
+
+func isComment(s string) bool {
+	s = strings.TrimSpace(s)
+	return s == "" || s[0] == '#'
+}
+
+// balanced reports whether the brackets of an array value are closed,
+// ignoring those in strings and comments
+func balanced(s string) bool {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"', '\'':
+			quote := s[i]
+			for i++; i < len(s) && s[i] != quote && s[i] != '\n'; i++ {
+				if quote == '"' && s[i] == '\\' {
+					i++
+				}
+			}
+		case '#':
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		case '[':
+			depth++
+		case ']':
+			depth--
+		}
+	}
+	return depth <= 0
+}
+
+// parseValue reads a value and returns the text after it
+func parseValue(s string) (any, string, error) {
+	s = strings.TrimLeft(s, " \t")
+	switch {
+	case s == "":
+		return nil, "", fmt.Errorf("missing value")
+	case strings.HasPrefix(s, `"""`) || strings.HasPrefix(s, "'''"):
+		return nil, "", fmt.Errorf("multi-line strings are not supported")
+	case s[0] == '"' || s[0] == '\'':
+		return parseString(s)
+	case s[0] == '[':
+		return parseArray(s[1:])
+	case s[0] == '{':
+		return nil, "", fmt.Errorf("inline tables are not supported")
+	}
+
+	n := 0
+	for n < len(s) && !strings.ContainsRune(" \t\n#,]", rune(s[n])) {
+		n++
+	}
+	word, rest := s[:n], s[n:]
+	switch word {
+	case "true":
+		return true, rest, nil
+	case "false":
+		return false, rest, nil
+	}
+	clean := strings.ReplaceAll(word, "_", "")
+	if i, err := strconv.ParseInt(clean, 0, 64); err == nil {
+		return i, rest, nil
+	}
+	if f, err := strconv.ParseFloat(clean, 64); err == nil {
+		return f, rest, nil
+	}
+	return nil, "", fmt.Errorf("invalid value %q", word)
+}
+
+func parseArray(s string) (any, string, error) {
+	values := []any{}
+	for {
+		s = skipSpace(s)
+		if strings.HasPrefix(s, "]") {
+			return values, s[1:], nil
+		}
+		value, rest, err := parseValue(s)
+		if err != nil {
+			return nil, "", err
+		}
+		values = append(values, value)
+		s = skipSpace(rest)
+		switch {
+		case strings.HasPrefix(s, ","):
+			s = s[1:]
+		case strings.HasPrefix(s, "]"):
+			return values, s[1:], nil
+		default:
+			return nil, "", fmt.Errorf("expected , or ] in array")
+		}
+	}
+}
+
+// skipSpace skips blanks, newlines and comments inside arrays
+func skipSpace(s string) string {
+	for {
+		s = strings.TrimLeft(s, " \t\n")
+		if !strings.HasPrefix(s, "#") {
+			return s
+		}
+		if i := strings.IndexByte(s, '\n'); i >= 0 {
+			s = s[i:]
+		} else {
+			return ""
+		}
+	}
+}
+
+// parseString reads a basic "string" with escapes or a literal 'string'
+func parseString(s string) (string, string, error) {
+	quote := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == quote:
+			return b.String(), s[i+1:], nil
+		case c == '\n':
+			return "", "", fmt.Errorf("unterminated string")
+		case c == '\\' && quote == '"':
+			if i+1 >= len(s) {
+				return "", "", fmt.Errorf("unterminated string")
+			}
+			i++
+			switch s[i] {
+			case 'b':
+				b.WriteByte('\b')
+			case 't':
+				b.WriteByte('\t')
+			case 'n':
+				b.WriteByte('\n')
+			case 'f':
+				b.WriteByte('\f')
+			case 'r':
+				b.WriteByte('\r')
+			case 'e':
+				b.WriteByte('\033')
+			case '"', '\\':
+				b.WriteByte(s[i])
+			case 'u', 'U':
+				n := 4
+				if s[i] == 'U' {
+					n = 8
+				}
+				if i+n >= len(s) {
+					return "", "", fmt.Errorf("invalid escape")
+				}
+				code, err := strconv.ParseUint(s[i+1:i+1+n], 16, 32)
+				if err != nil || !utf8.ValidRune(rune(code)) {
+					return "", "", fmt.Errorf("invalid escape")
+				}
+				b.WriteRune(rune(code))
+				i += n
+			default:
+				return "", "", fmt.Errorf("invalid escape \\%c", s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", "", fmt.Errorf("unterminated string")
+}
+
+// Format writes a value as TOML
+func Format(value any) string {
+	switch v := value.(type) {
+	case string:
+		var b strings.Builder
+		b.WriteByte('"')
+		for _, r := range v {
+			switch {
+			case r == '"' || r == '\\':
+				b.WriteByte('\\')
+				b.WriteRune(r)
+			case r == '\n':
+				b.WriteString(`\n`)
+			case r == '\t':
+				b.WriteString(`\t`)
+			case r == '\033':
+				b.WriteString(`\e`)
+			case r < 0x20 || r == 0x7f:
+				fmt.Fprintf(&b, `\u%04x`, r)
+			default:
+				b.WriteRune(r)
+			}
+		}
+		b.WriteByte('"')
+		return b.String()
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = Format(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case float64:
+		// A float keeps its point, or it would be read back as an integer
+		text := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(text, ".eEnN") {
+			text += ".0"
+		}
+		return text
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,168 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		data string
+		want map[string]any
+	}{
+		{"", map[string]any{}},
+		{"# only a comment\n\n", map[string]any{}},
+		{`name = "value"`, map[string]any{"name": "value"}},
+		{"name = 'C:\\path'", map[string]any{"name": `C:\path`}},
+		{`s = "tab\there \"q\" \\ \e[1m \u00e9 \U0001F600"`, map[string]any{"s": "tab\there \"q\" \\ \033[1m é 😀"}},
+		{"n = 42\nm = -7\nh = 0x1f\nbig = 1_000", map[string]any{"n": int64(42), "m": int64(-7), "h": int64(31), "big": int64(1000)}},
+		{"f = 1.5\ng = -2e3", map[string]any{"f": 1.5, "g": -2000.0}},
+		{"yes = true\nno = false", map[string]any{"yes": true, "no": false}},
+		{"a = [1, 2, 3]\nb = []", map[string]any{"a": []any{int64(1), int64(2), int64(3)}, "b": []any{}}},
+		{"a = [\n  \"x\", # first\n  \"y\",\n]", map[string]any{"a": []any{"x", "y"}}},
+		{"a = [[1], [\"]\"]]", map[string]any{"a": []any{[]any{int64(1)}, []any{"]"}}}},
+		{"[prompt]\ntemplate = \"> \" # short\n", map[string]any{"prompt.template": "> "}},
+		{"prompt.right = 'x'\n[colors]\nerror = \"red\"", map[string]any{"prompt.right": "x", "colors.error": "red"}},
+		{"[aliases]\n\"git st\" = 'git status'\nll = \"ls -l\"", map[string]any{"aliases.git st": "git status", "aliases.ll": "ls -l"}},
+		{"[ a . b ]\nc = 1", map[string]any{"a.b.c": int64(1)}},
+		{"key = 1\r\nother = 2\r\n", map[string]any{"key": int64(1), "other": int64(2)}},
+	}
+	for _, tt := range tests {
+		doc, err := Parse(tt.data)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", tt.data, err)
+			continue
+		}
+		got := make(map[string]any)
+		for _, key := range doc.Keys() {
+			got[key], _ = doc.Get(key)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %#v, want %#v", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		data string
+		line int
+	}{
+		{"name", 1},
+		{"name =", 1},
+		{"= 1", 1},
+		{"a = 1\nb = nope", 2},
+		{"a = 1 2", 1},
+		{`a = "unterminated`, 1},
+		{`a = "bad \q escape"`, 1},
+		{`a = "\u12"`, 1},
+		{`a = """multi"""`, 1},
+		{"a = {x = 1}", 1},
+		{"a = [1 2]", 1},
+		{"a = [1,\n2", 2},
+		{"a = 1\na = 2", 2},
+		{"[t]\na = 1\n[t]", 3},
+		{"[t\na = 1", 1},
+		{"[t] x", 1},
+		{"[]", 1},
+		{"[[servers]]", 1},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.data)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("Parse(%q) = %v, want a ParseError", tt.data, err)
+			continue
+		}
+		if parseErr.Line != tt.line {
+			t.Errorf("Parse(%q) failed at line %d, want %d: %v", tt.data, parseErr.Line, tt.line, err)
+		}
+	}
+}
+
+func TestFormatRoundTrip(t *testing.T) {
+	values := []any{
+		"",
+		"plain",
+		`quote " and backslash \`,
+		"new\nline\ttab\rreturn",
+		"\033[1;31mred\033[0m",
+		"\x00\x7f",
+		"unicode é 😀",
+		int64(0),
+		int64(-123456789),
+		1.5,
+		1.0,
+		-0.25,
+		1e300,
+		true,
+		false,
+		[]any{},
+		[]any{"a", int64(1), 2.0, false, []any{"nested"}},
+	}
+	for _, value := range values {
+		text := Format(value)
+		doc, err := Parse("key = " + text)
+		if err != nil {
+			t.Errorf("Format(%#v) = %s, which does not parse: %v", value, text, err)
+			continue
+		}
+		if got, _ := doc.Get("key"); !reflect.DeepEqual(got, value) {
+			t.Errorf("Format(%#v) = %s, which parses as %#v", value, text, got)
+		}
+	}
+}
+
+func TestSetRoundTrip(t *testing.T) {
+	data := `# Settings
+[prompt]
+template = "> " # short
+
+[aliases]
+ll = "ls -l"
+`
+	doc, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.String() != data {
+		t.Errorf("String() = %q, want the file unchanged: %q", doc.String(), data)
+	}
+
+	changes := map[string]any{
+		"prompt.template":       "$ ",
+		"prompt.right":          "%t",
+		"aliases.git st":        "git status",
+		"history.size":          int64(500),
+		"colors.highlight":      false,
+		"completion.extensions": []any{"go", "md"},
+		"top":                   1.0,
+	}
+	for key, value := range changes {
+		if err := doc.Set(key, value); err != nil {
+			t.Fatalf("Set(%q) failed: %v", key, err)
+		}
+	}
+	text := doc.String()
+	for _, kept := range []string{"# Settings\n", `template = "$ " # short`, `ll = "ls -l"`} {
+		if !strings.Contains(text, kept) {
+			t.Errorf("%q is missing from the written file:\n%s", kept, text)
+		}
+	}
+
+	reread, err := Parse(text)
+	if err != nil {
+		t.Fatalf("the written file does not parse: %v\n%s", err, text)
+	}
+	changes["aliases.ll"] = "ls -l"
+	if got, want := len(reread.Keys()), len(changes); got != want {
+		t.Errorf("the written file has %d keys, want %d:\n%s", got, want, text)
+	}
+	for key, want := range changes {
+		if got, _ := reread.Get(key); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %#v after writing, want %#v", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseNext(t *testing.T) {
+	// A Wednesday
+	start := time.Date(2024, time.January, 10, 10, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"* * * * *", "2024-01-10 10:08"},
+		{"*/15 * * * *", "2024-01-10 10:15"},
+		{"0 9 * * *", "2024-01-11 09:00"},
+		{"0-30/10 * * * *", "2024-01-10 10:10"},
+		{"5/20 * * * *", "2024-01-10 10:25"},
+		{"0 12 * * MON-FRI", "2024-01-10 12:00"},
+		{"0 0 * * sat,sun", "2024-01-13 00:00"},
+		{"0 0 * * 7", "2024-01-14 00:00"},
+		{"0 0 1 FEB *", "2024-02-01 00:00"},
+		{"0 0 29 2 *", "2024-02-29 00:00"},
+		{"0 0 31 * *", "2024-01-31 00:00"},
+		// With both days restricted either one matches
+		{"0 0 20 * MON", "2024-01-15 00:00"},
+		{"@hourly", "2024-01-10 11:00"},
+		{"@daily", "2024-01-11 00:00"},
+		{"@weekly", "2024-01-14 00:00"},
+		{"@monthly", "2024-02-01 00:00"},
+		{"@yearly", "2025-01-01 00:00"},
+		{"  @Daily  ", "2024-01-11 00:00"},
+	}
+	for _, tt := range tests {
+		schedule, err := Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", tt.expr, err)
+			continue
+		}
+		if got := schedule.Next(start).Format("2006-01-02 15:04"); got != tt.want {
+			t.Errorf("Parse(%q).Next() = %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"-1 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"30-10 * * * *",
+		"1-x * * * *",
+		"* * * FOO *",
+		"* * * * MON-",
+		"1,,2 * * * *",
+		"@sometimes",
+	}
+	for _, expr := range tests {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) succeeded", expr)
+		}
+	}
+}
+
+func TestNextWithoutMatch(t *testing.T) {
+	schedule, err := Parse("0 0 31 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next := schedule.Next(time.Now()); !next.IsZero() {
+		t.Errorf("Next() = %v, want the zero time for February 31", next)
+	}
+}
@@ -5,20 +5,22 @@ import (
 	"os"
 	"strings"
 
+	"commandripple/internal/commands/theme"
+
 	"github.com/sergi/go-diff/diffmatchpatch"
 )
 
-func Diff(args []string) error {
+func (s *Session) Diff(args []string) error {
 	if len(args) != 2 {
 		return fmt.Errorf("usage: diff [file1] [file2]")
 	}
 
-	file1, err := os.ReadFile(args[0])
+	file1, err := os.ReadFile(s.path(args[0]))
 	if err != nil {
 		return fmt.Errorf("error reading file1: %v", err)
 	}
 
-	file2, err := os.ReadFile(args[1])
+	file2, err := os.ReadFile(s.path(args[1]))
 	if err != nil {
 		return fmt.Errorf("error reading file2: %v", err)
 	}
@@ -27,7 +29,7 @@ func Diff(args []string) error {
 
 	diffs := dmp.DiffMain(string(file1), string(file2), false)
 
-	fmt.Printf("Differences between %s and %s:\n\n", args[0], args[1])
+	fmt.Fprintf(s.Stdout, "Differences between %s and %s:\n\n", args[0], args[1])
 
 	lineNum1, lineNum2 := 1, 1
 	for _, diff := range diffs {
@@ -40,7 +42,7 @@ func Diff(args []string) error {
 			lines := strings.Split(diff.Text, "\n")
 			for _, line := range lines {
 				if line != "" {
-					fmt.Printf("\033[32m+ %d: %s\033[0m\n", lineNum2, line)
+					fmt.Fprintln(s.Stdout, theme.Paint(s.Stdout, theme.DiffAdd, fmt.Sprintf("+ %d: %s", lineNum2, line)))
 					lineNum2++
 				}
 			}
@@ -48,7 +50,7 @@ func Diff(args []string) error {
 			lines := strings.Split(diff.Text, "\n")
 			for _, line := range lines {
 				if line != "" {
-					fmt.Printf("\033[31m- %d: %s\033[0m\n", lineNum1, line)
+					fmt.Fprintln(s.Stdout, theme.Paint(s.Stdout, theme.DiffRemove, fmt.Sprintf("- %d: %s", lineNum1, line)))
 					lineNum1++
 				}
 			}
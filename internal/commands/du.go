@@ -7,16 +7,16 @@ import (
 )
 
 // Du estimates file space usage of a directory
-func Du(args []string) error {
+func (s *Session) Du(args []string) error {
 	dir := "."
 	if len(args) > 0 {
 		dir = args[0]
 	}
 
 	var totalSize int64
-	err := filepath.Walk(dir, func(path string, info fs.FileInfo, err error) error {
+	err := filepath.Walk(s.path(dir), func(path string, info fs.FileInfo, err error) error {
 		if err != nil {
-			fmt.Printf("Error accessing %s: %v\n", path, err)
+			fmt.Fprintf(s.Stdout, "Error accessing %s: %v\n", path, err)
 			return nil // Continue walking
 		}
 		if !info.IsDir() {
@@ -31,7 +31,7 @@ func Du(args []string) error {
 
 	// Convert bytes to human-readable format
 	sizeStr := formatSize(totalSize)
-	fmt.Printf("%s\t%s\n", sizeStr, dir)
+	fmt.Fprintf(s.Stdout, "%s\t%s\n", sizeStr, dir)
 
 	return nil
 }
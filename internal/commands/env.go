@@ -0,0 +1,156 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Env prints the environment or runs a command in a modified environment,
+// e.g. `env -i PATH=/bin -u HOME make`
+func (s *Session) Env(args []string) error {
+	env := s.Environ()
+	var filter string
+
+	i := 0
+	for ; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-i" || arg == "--ignore-environment":
+			env = []string{}
+		case arg == "-u" || arg == "--unset":
+			if i+1 >= len(args) {
+				return fmt.Errorf("'env -u' requires a variable name")
+			}
+			i++
+			env = unsetEnv(env, args[i])
+		case arg == "--filter":
+			if i+1 >= len(args) {
+				return fmt.Errorf("'env --filter' requires a pattern")
+			}
+			i++
+			filter = args[i]
+		case isAssignment(arg):
+			env = mergeEnv(env, []string{arg})
+		case arg == "--":
+			i++
+			return s.runWithEnv(env, args[i:])
+		default:
+			return s.runWithEnv(env, args[i:])
+		}
+	}
+
+	return printEnv(s.Stdout, env, filter)
+}
+
+func (s *Session) runWithEnv(env []string, args []string) error {
+	if len(args) == 0 {
+		return printEnv(s.Stdout, env, "")
+	}
+
+	sub := *s
+	sub.env = env
+	return sub.runCommand(Command{Name: args[0], Args: args[1:]})
+}
+
+// printEnv prints the variables sorted by name, optionally keeping only the
+// names matching a glob pattern
+func printEnv(w io.Writer, env []string, filter string) error {
+	sorted := append([]string(nil), env...)
+	sort.Strings(sorted)
+
+	for _, entry := range sorted {
+		if filter != "" {
+			name, _, _ := strings.Cut(entry, "=")
+			matched, err := filepath.Match(filter, name)
+			if err != nil {
+				return fmt.Errorf("invalid filter pattern: %v", err)
+			}
+			if !matched {
+				continue
+			}
+		}
+		fmt.Fprintln(w, entry)
+	}
+	return nil
+}
+
+// isAssignment reports whether a word has the form NAME=value
+func isAssignment(word string) bool {
+	name, _, found := strings.Cut(word, "=")
+	if !found || name == "" {
+		return false
+	}
+	for i, c := range name {
+		if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
+			continue
+		}
+		if i > 0 && c >= '0' && c <= '9' {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// mergeEnv returns env with the assignments added, replacing existing
+// variables of the same name
+func mergeEnv(env []string, assignments []string) []string {
+	merged := env
+	for _, assignment := range assignments {
+		name, _, _ := strings.Cut(assignment, "=")
+		merged = append(unsetEnv(merged, name), assignment)
+	}
+	return merged
+}
+
+func unsetEnv(env []string, name string) []string {
+	result := make([]string, 0, len(env))
+	for _, entry := range env {
+		if !strings.HasPrefix(entry, name+"=") {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// scopeEnv applies a command's assignments until the returned function is
+// called, returning the session to run it in. Spawned processes receive
+// them through its environment. Builtins of the interactive shell also see
+// them in the process's environment, which they read.
+func (s *Session) scopeEnv(cmd Command) (*Session, func()) {
+	if len(cmd.Env) == 0 {
+		return s, func() {}
+	}
+
+	sub := *s
+	sub.env = mergeEnv(s.Environ(), cmd.Env)
+
+	saved := make(map[string]*string)
+	if s.interactive && IsBuiltinCommand(cmd.Name) {
+		for _, assignment := range cmd.Env {
+			name, value, _ := strings.Cut(assignment, "=")
+			if _, done := saved[name]; !done {
+				if old, ok := os.LookupEnv(name); ok {
+					saved[name] = &old
+				} else {
+					saved[name] = nil
+				}
+			}
+			os.Setenv(name, value)
+		}
+	}
+
+	return &sub, func() {
+		for name, old := range saved {
+			if old == nil {
+				os.Unsetenv(name)
+			} else {
+				os.Setenv(name, *old)
+			}
+		}
+	}
+}
@@ -0,0 +1,62 @@
+// Package exitstatus derives the exit status of a command from the error it
+// returned, for the shell and for the processes it re-executes itself as.
+package exitstatus
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"syscall"
+)
+
+// ExitStatus is returned by commands that finish with a specific exit code
+type ExitStatus struct {
+	Code   int
+	Reason string
+	Signal syscall.Signal // Signal that killed the process, if any
+}
+
+func (e *ExitStatus) Error() string {
+	if e.Reason != "" {
+		return e.Reason
+	}
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// Code returns the exit status of a command from the error it returned.
+// Processes killed by a signal report 128 plus the signal number.
+func Code(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var status *ExitStatus
+	if errors.As(err, &status) {
+		return status.Code
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			return 128 + int(ws.Signal())
+		}
+		return exitErr.ExitCode()
+	}
+	return 1
+}
+
+// Signal returns the number of the signal that killed a process, or 0
+func Signal(err error) int {
+	var status *ExitStatus
+	if errors.As(err, &status) {
+		return int(status.Signal)
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			return int(ws.Signal())
+		}
+	}
+	return 0
+}
@@ -11,7 +11,7 @@ import (
 )
 
 // FileTransfer transfers a file to or from a remote machine using SSH
-func FileTransfer(args []string) error {
+func (s *Session) FileTransfer(args []string) error {
 	if len(args) < 5 {
 		return fmt.Errorf("usage: file_transfer [user] [host] [port] [source] [destination]")
 	}
@@ -24,28 +24,22 @@ func FileTransfer(args []string) error {
 	// Determine if we're uploading or downloading
 	isUpload := !strings.HasPrefix(source, fmt.Sprintf("%s@%s:", user, host))
 
-	// Setup SSH client configuration
-	config, err := getSSHConfig(user)
-	if err != nil {
-		return fmt.Errorf("failed to configure SSH client: %v", err)
-	}
-
 	// Connect to the SSH server
-	client, err := ssh.Dial("tcp", fmt.Sprintf("%s:%s", host, port), config)
+	client, err := s.dialSSH(user, host, port)
 	if err != nil {
 		return fmt.Errorf("failed to connect to SSH server: %v", err)
 	}
 	defer client.Close()
 
 	if isUpload {
-		return uploadFile(client, source, destination)
+		return s.uploadFile(client, source, destination)
 	}
-	return downloadFile(client, source, destination)
+	return s.downloadFile(client, source, destination)
 }
 
-func uploadFile(client *ssh.Client, source, destination string) error {
+func (s *Session) uploadFile(client *ssh.Client, source, destination string) error {
 	// Open the source file
-	srcFile, err := os.Open(source)
+	srcFile, err := os.Open(s.path(source))
 	if err != nil {
 		return fmt.Errorf("failed to open source file: %v", err)
 	}
@@ -78,11 +72,11 @@ func uploadFile(client *ssh.Client, source, destination string) error {
 		return fmt.Errorf("failed to run remote scp command: %v", err)
 	}
 
-	fmt.Printf("File uploaded successfully to %s\n", destination)
+	fmt.Fprintf(s.Stdout, "File uploaded successfully to %s\n", destination)
 	return nil
 }
 
-func downloadFile(client *ssh.Client, source, destination string) error {
+func (s *Session) downloadFile(client *ssh.Client, source, destination string) error {
 	// Create an SSH session
 	session, err := client.NewSession()
 	if err != nil {
@@ -107,7 +101,7 @@ func downloadFile(client *ssh.Client, source, destination string) error {
 	}
 
 	// Open the destination file
-	dstFile, err := os.Create(destination)
+	dstFile, err := os.Create(s.path(destination))
 	if err != nil {
 		return fmt.Errorf("failed to create destination file: %v", err)
 	}
@@ -130,6 +124,6 @@ func downloadFile(client *ssh.Client, source, destination string) error {
 		return fmt.Errorf("failed to copy file content: %v", err)
 	}
 
-	fmt.Printf("File downloaded successfully to %s\n", destination)
+	fmt.Fprintf(s.Stdout, "File downloaded successfully to %s\n", destination)
 	return nil
 }
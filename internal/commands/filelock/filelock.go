@@ -0,0 +1,36 @@
+// Package filelock takes advisory locks on files, shared by the processes
+// of every shell session. A lock lasts until its file is closed, or until
+// the process holding it exits.
+package filelock
+
+import (
+	"errors"
+	"os"
+)
+
+// ErrLocked is returned by TryLock when another process holds the lock
+var ErrLocked = errors.New("file is locked")
+
+// Lock opens path, creating it when needed, and waits for an exclusive lock
+// on it. Closing the file releases the lock.
+func Lock(path string) (*os.File, error) {
+	return open(path, true)
+}
+
+// TryLock is Lock without waiting, it returns ErrLocked when the lock is
+// held elsewhere
+func TryLock(path string) (*os.File, error) {
+	return open(path, false)
+}
+
+func open(path string, wait bool) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := lock(f, wait); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
@@ -14,56 +14,56 @@ import (
 
 // File operation command implementations
 
-func Cat(args []string) error {
+func (s *Session) Cat(args []string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("'cat' requires an argument")
 	}
 	for _, file := range args {
-		if err := printFileContent(file); err != nil {
+		if err := s.printFileContent(file); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func Touch(args []string) error {
+func (s *Session) Touch(args []string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("'touch' requires an argument")
 	}
 	for _, file := range args {
-		if err := touchFile(file); err != nil {
+		if err := s.touchFile(file); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func RemoveFile(args []string) error {
+func (s *Session) RemoveFile(args []string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("'rm' requires an argument")
 	}
 	for _, file := range args {
-		if err := os.Remove(file); err != nil {
+		if err := os.Remove(s.path(file)); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func CopyFile(args []string) error {
+func (s *Session) CopyFile(args []string) error {
 	if len(args) < 2 {
 		return fmt.Errorf("'cp' requires two arguments")
 	}
 	src := args[0]
 	dest := args[1]
 
-	sourceFile, err := os.Open(src)
+	sourceFile, err := os.Open(s.path(src))
 	if err != nil {
 		return err
 	}
 	defer sourceFile.Close()
 
-	destFile, err := os.Create(dest)
+	destFile, err := os.Create(s.path(dest))
 	if err != nil {
 		return err
 	}
@@ -73,16 +73,16 @@ func CopyFile(args []string) error {
 	return err
 }
 
-func MoveFile(args []string) error {
+func (s *Session) MoveFile(args []string) error {
 	if len(args) < 2 {
 		return fmt.Errorf("'mv' requires two arguments")
 	}
-	return os.Rename(args[0], args[1])
+	return os.Rename(s.path(args[0]), s.path(args[1]))
 }
 
 // New file operation commands
 
-func Head(args []string) error {
+func (s *Session) Head(args []string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("'head' requires an argument")
 	}
@@ -91,10 +91,10 @@ func Head(args []string) error {
 	if len(args) > 1 {
 		fmt.Sscanf(args[1], "%d", &lines)
 	}
-	return printHead(file, lines)
+	return s.printHead(file, lines)
 }
 
-func Tail(args []string) error {
+func (s *Session) Tail(args []string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("'tail' requires an argument")
 	}
@@ -103,49 +103,49 @@ func Tail(args []string) error {
 	if len(args) > 1 {
 		fmt.Sscanf(args[1], "%d", &lines)
 	}
-	return printTail(file, lines)
+	return s.printTail(file, lines)
 }
 
-func Grep(args []string) error {
+func (s *Session) Grep(args []string) error {
 	if len(args) < 2 {
 		return fmt.Errorf("'grep' requires a pattern and a file")
 	}
 	pattern := args[0]
 	file := args[1]
-	return grepPattern(file, pattern)
+	return s.grepPattern(file, pattern)
 }
 
-func Find(args []string) error {
+func (s *Session) Find(args []string) error {
 	if len(args) < 2 {
 		return fmt.Errorf("'find' requires a directory and a name")
 	}
 	directory := args[0]
 	name := args[1]
-	return findFile(directory, name)
+	return s.findFile(directory, name)
 }
 
-func WordCount(args []string) error {
+func (s *Session) WordCount(args []string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("'wc' requires a file")
 	}
 	file := args[0]
-	return printWordCount(file)
+	return s.printWordCount(file)
 }
 
-func Chmod(args []string) error {
+func (s *Session) Chmod(args []string) error {
 	if len(args) < 2 {
 		return fmt.Errorf("'chmod' requires permissions and a file")
 	}
 	// Windows doesn't fully support Unix-style permissions,
 	// so this is a placeholder to demonstrate the structure.
-	fmt.Println("chmod is not fully supported on Windows.")
+	fmt.Fprintln(s.Stdout, "chmod is not fully supported on Windows.")
 	return nil
 }
 
 // Helper functions for the new commands
 
-func printHead(filename string, lines int) error {
-	file, err := os.Open(filename)
+func (s *Session) printHead(filename string, lines int) error {
+	file, err := os.Open(s.path(filename))
 	if err != nil {
 		return err
 	}
@@ -153,13 +153,13 @@ func printHead(filename string, lines int) error {
 
 	scanner := bufio.NewScanner(file)
 	for i := 0; i < lines && scanner.Scan(); i++ {
-		fmt.Println(scanner.Text())
+		fmt.Fprintln(s.Stdout, scanner.Text())
 	}
 	return scanner.Err()
 }
 
-func printTail(filename string, lines int) error {
-	file, err := os.Open(filename)
+func (s *Session) printTail(filename string, lines int) error {
+	file, err := os.Open(s.path(filename))
 	if err != nil {
 		return err
 	}
@@ -174,13 +174,13 @@ func printTail(filename string, lines int) error {
 		}
 	}
 	for _, line := range buffer {
-		fmt.Println(line)
+		fmt.Fprintln(s.Stdout, line)
 	}
 	return scanner.Err()
 }
 
-func grepPattern(filename, pattern string) error {
-	file, err := os.Open(filename)
+func (s *Session) grepPattern(filename, pattern string) error {
+	file, err := os.Open(s.path(filename))
 	if err != nil {
 		return err
 	}
@@ -190,26 +190,29 @@ func grepPattern(filename, pattern string) error {
 	for scanner.Scan() {
 		line := scanner.Text()
 		if strings.Contains(line, pattern) {
-			fmt.Println(line)
+			fmt.Fprintln(s.Stdout, line)
 		}
 	}
 	return scanner.Err()
 }
 
-func findFile(directory, name string) error {
-	return filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
+func (s *Session) findFile(directory, name string) error {
+	root := s.path(directory)
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 		if strings.Contains(info.Name(), name) {
-			fmt.Println(path)
+			// Shown below the directory as it was given
+			rel, _ := filepath.Rel(root, path)
+			fmt.Fprintln(s.Stdout, filepath.Join(directory, rel))
 		}
 		return nil
 	})
 }
 
-func printWordCount(filename string) error {
-	file, err := os.Open(filename)
+func (s *Session) printWordCount(filename string) error {
+	file, err := os.Open(s.path(filename))
 	if err != nil {
 		return err
 	}
@@ -233,30 +236,30 @@ func printWordCount(filename string) error {
 		lines++
 	}
 
-	fmt.Printf(" %d %d %d %s\n", lines, words, characters, filename)
+	fmt.Fprintf(s.Stdout, " %d %d %d %s\n", lines, words, characters, filename)
 	return nil
 }
 
-func printFileContent(filename string) error {
-	file, err := os.Open(filename)
+func (s *Session) printFileContent(filename string) error {
+	file, err := os.Open(s.path(filename))
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
-	_, err = io.Copy(os.Stdout, file)
+	_, err = io.Copy(s.Stdout, file)
 	return err
 }
 
-func touchFile(filename string) error {
-	file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0644)
+func (s *Session) touchFile(filename string) error {
+	file, err := os.OpenFile(s.path(filename), os.O_RDWR|os.O_CREATE, 0644)
 	if err != nil {
 		return err
 	}
 	return file.Close()
 }
 
-func Calc(args []string) error {
+func (s *Session) Calc(args []string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("'calc' requires an expression")
 	}
@@ -265,35 +268,35 @@ func Calc(args []string) error {
 	if err != nil {
 		return fmt.Errorf("failed to evaluate expression: %v", err)
 	}
-	fmt.Println(result)
+	fmt.Fprintln(s.Stdout, result)
 	return nil
 }
 
-func Basename(args []string) error {
+func (s *Session) Basename(args []string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("'basename' requires a path")
 	}
 	path := args[0]
-	fmt.Println(filepath.Base(path))
+	fmt.Fprintln(s.Stdout, filepath.Base(path))
 	return nil
 }
 
-func Dirname(args []string) error {
+func (s *Session) Dirname(args []string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("'dirname' requires a path")
 	}
 	path := args[0]
-	fmt.Println(filepath.Dir(path))
+	fmt.Fprintln(s.Stdout, filepath.Dir(path))
 	return nil
 }
 
-func SortFile(args []string) error {
+func (s *Session) SortFile(args []string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("'sort' requires a file")
 	}
 	file := args[0]
 
-	lines, err := readLines(file)
+	lines, err := s.readLines(file)
 	if err != nil {
 		return err
 	}
@@ -301,18 +304,18 @@ func SortFile(args []string) error {
 	sort.Strings(lines)
 
 	for _, line := range lines {
-		fmt.Println(line)
+		fmt.Fprintln(s.Stdout, line)
 	}
 	return nil
 }
 
-func Uniq(args []string) error {
+func (s *Session) Uniq(args []string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("'uniq' requires a file")
 	}
 	file := args[0]
 
-	lines, err := readLines(file)
+	lines, err := s.readLines(file)
 	if err != nil {
 		return err
 	}
@@ -320,12 +323,12 @@ func Uniq(args []string) error {
 	uniqLines := uniq(lines)
 
 	for _, line := range uniqLines {
-		fmt.Println(line)
+		fmt.Fprintln(s.Stdout, line)
 	}
 	return nil
 }
 
-func Cut(args []string) error {
+func (s *Session) Cut(args []string) error {
 	if len(args) < 3 {
 		return fmt.Errorf("'cut' requires a file, delimiter, and field number")
 	}
@@ -334,7 +337,7 @@ func Cut(args []string) error {
 	field := 1
 	fmt.Sscanf(args[2], "%d", &field)
 
-	lines, err := readLines(file)
+	lines, err := s.readLines(file)
 	if err != nil {
 		return err
 	}
@@ -342,22 +345,22 @@ func Cut(args []string) error {
 	for _, line := range lines {
 		fields := strings.Split(line, delimiter)
 		if len(fields) >= field {
-			fmt.Println(fields[field-1])
+			fmt.Fprintln(s.Stdout, fields[field-1])
 		}
 	}
 	return nil
 }
 
-func Tee(args []string) error {
+func (s *Session) Tee(args []string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("'tee' requires at least one file")
 	}
 
 	files := args
-	writers := []io.Writer{os.Stdout}
+	writers := []io.Writer{s.Stdout}
 
 	for _, file := range files {
-		f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+		f, err := os.OpenFile(s.path(file), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
 		if err != nil {
 			return err
 		}
@@ -366,17 +369,17 @@ func Tee(args []string) error {
 	}
 
 	multiWriter := io.MultiWriter(writers...)
-	_, err := io.Copy(multiWriter, os.Stdin)
+	_, err := io.Copy(multiWriter, s.Stdin)
 	return err
 }
 
-func LogMessage(args []string) error {
+func (s *Session) LogMessage(args []string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("'log' requires a message")
 	}
-	message := strings.Join(args, " ")
+	message := RedactSecrets(strings.Join(args, " "))
 	logFile := "commandripple.log"
-	f, err := os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	f, err := os.OpenFile(s.path(logFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		return err
 	}
@@ -385,14 +388,14 @@ func LogMessage(args []string) error {
 	if err != nil {
 		return err
 	}
-	fmt.Println("Log entry added.")
+	fmt.Fprintln(s.Stdout, "Log entry added.")
 	return nil
 }
 
 // Utility functions
 
-func readLines(filename string) ([]string, error) {
-	file, err := os.Open(filename)
+func (s *Session) readLines(filename string) ([]string, error) {
+	file, err := os.Open(s.path(filename))
 	if err != nil {
 		return nil, err
 	}
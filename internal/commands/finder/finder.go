@@ -0,0 +1,376 @@
+// Package finder implements a full screen fuzzy finder in the style of fzf.
+// It is used for the Ctrl-R and Ctrl-T bindings of the prompt and by the
+// pick builtin.
+package finder
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"commandripple/internal/commands/theme"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// ErrCancelled is returned when the finder is closed without a selection
+var ErrCancelled = errors.New("selection cancelled")
+
+// Options configure a finder session
+type Options struct {
+	Prompt string // Shown before the query, "> " by default
+	Query  string // Initial query
+	Multi  bool   // Allow selecting several candidates with Tab
+	// Preview returns the text shown next to the candidate under the
+	// cursor, nil disables the preview pane
+	Preview func(item string) string
+}
+
+const (
+	// minPreviewWidth is the terminal width below which the preview pane
+	// is not shown
+	minPreviewWidth = 60
+)
+
+type state struct {
+	items    []string
+	opts     Options
+	query    []rune
+	cursor   int // Position of the editing cursor in the query
+	matches  []Match
+	current  int // Index into matches of the highlighted candidate
+	offset   int // First match shown
+	selected map[int]bool
+	preview  map[int][]string
+	scroll   int // First line of the preview shown
+}
+
+// Run shows the candidates in the alternate screen of the terminal written
+// to by out and lets the user narrow them down with a fuzzy query read from
+// in, which must already be in raw mode. It returns the selected
+// candidates in input order, or ErrCancelled.
+//
+// Keys: Up/Down (Ctrl-P/Ctrl-N) move, PgUp/PgDn page, Tab/Shift-Tab toggle
+// a selection with Multi, Ctrl-A toggles all, Shift-Up/Shift-Down scroll
+// the preview, Enter accepts and Esc, Ctrl-C or Ctrl-G cancel.
+func Run(items []string, in io.Reader, out *os.File, opts Options) ([]string, error) {
+	if opts.Prompt == "" {
+		opts.Prompt = "> "
+	}
+	s := &state{
+		items:    items,
+		opts:     opts,
+		query:    []rune(opts.Query),
+		selected: make(map[int]bool),
+		preview:  make(map[int][]string),
+	}
+	s.cursor = len(s.query)
+	s.filter()
+
+	fmt.Fprint(out, "\033[?1049h")
+	defer fmt.Fprint(out, "\033[?1049l")
+
+	keys := &keyReader{chunks: readChunks(in)}
+	for {
+		s.draw(out)
+
+		k, err := keys.next()
+		if err != nil {
+			return nil, ErrCancelled
+		}
+		done, err := s.handle(k, out)
+		if done {
+			return s.result(), err
+		}
+	}
+}
+
+func (s *state) filter() {
+	s.matches = Filter(s.items, string(s.query))
+	s.current = 0
+	s.offset = 0
+	s.scroll = 0
+}
+
+// handle applies a key press and reports whether the finder is done
+func (s *state) handle(k key, out *os.File) (bool, error) {
+	_, rows := s.listSize(out)
+	queryChanged := false
+
+	switch k.kind {
+	case keyEnter:
+		if len(s.matches) == 0 && len(s.selected) == 0 {
+			return false, nil
+		}
+		return true, nil
+	case keyCancel:
+		return true, ErrCancelled
+	case keyRune:
+		s.query = append(s.query[:s.cursor], append([]rune{k.r}, s.query[s.cursor:]...)...)
+		s.cursor++
+		queryChanged = true
+	case keyBackspace:
+		if s.cursor > 0 {
+			s.query = append(s.query[:s.cursor-1], s.query[s.cursor:]...)
+			s.cursor--
+			queryChanged = true
+		}
+	case keyClearLine:
+		s.query = s.query[s.cursor:]
+		s.cursor = 0
+		queryChanged = true
+	case keyDeleteWord:
+		start := s.cursor
+		for start > 0 && s.query[start-1] == ' ' {
+			start--
+		}
+		for start > 0 && s.query[start-1] != ' ' {
+			start--
+		}
+		s.query = append(s.query[:start], s.query[s.cursor:]...)
+		s.cursor = start
+		queryChanged = true
+	case keyLeft:
+		if s.cursor > 0 {
+			s.cursor--
+		}
+	case keyRight:
+		if s.cursor < len(s.query) {
+			s.cursor++
+		}
+	case keyHome:
+		s.cursor = 0
+	case keyEnd:
+		s.cursor = len(s.query)
+	case keyUp:
+		s.move(-1)
+	case keyDown:
+		s.move(1)
+	case keyPageUp:
+		s.move(-rows)
+	case keyPageDown:
+		s.move(rows)
+	case keyTab, keyShiftTab:
+		if s.opts.Multi && len(s.matches) > 0 {
+			index := s.matches[s.current].Index
+			if s.selected[index] {
+				delete(s.selected, index)
+			} else {
+				s.selected[index] = true
+			}
+			if k.kind == keyTab {
+				s.move(1)
+			} else {
+				s.move(-1)
+			}
+		}
+	case keyToggleAll:
+		if s.opts.Multi {
+			for _, m := range s.matches {
+				if s.selected[m.Index] {
+					delete(s.selected, m.Index)
+				} else {
+					s.selected[m.Index] = true
+				}
+			}
+		}
+	case keyPreviewUp:
+		if s.scroll > 0 {
+			s.scroll--
+		}
+	case keyPreviewDown:
+		s.scroll++
+	}
+
+	if queryChanged {
+		s.filter()
+	}
+	return false, nil
+}
+
+func (s *state) move(delta int) {
+	if len(s.matches) == 0 {
+		return
+	}
+	s.current += delta
+	if s.current < 0 {
+		s.current = 0
+	}
+	if s.current >= len(s.matches) {
+		s.current = len(s.matches) - 1
+	}
+	s.scroll = 0
+}
+
+// result returns the selected candidates in input order, or the one under
+// the cursor when nothing was selected
+func (s *state) result() []string {
+	if len(s.selected) == 0 {
+		if len(s.matches) == 0 {
+			return nil
+		}
+		return []string{s.matches[s.current].Text}
+	}
+	var result []string
+	for i, item := range s.items {
+		if s.selected[i] {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// listSize returns the width and height available to the candidate list
+func (s *state) listSize(out *os.File) (int, int) {
+	width, height := size(out)
+	if s.opts.Preview != nil && width >= minPreviewWidth {
+		width = width / 2
+	}
+	rows := height - 2
+	if rows < 1 {
+		rows = 1
+	}
+	return width, rows
+}
+
+func (s *state) draw(out *os.File) {
+	listWidth, rows := s.listSize(out)
+	totalWidth, _ := size(out)
+	showPreview := s.opts.Preview != nil && totalWidth >= minPreviewWidth
+
+	// Keep the current candidate on screen
+	if s.current < s.offset {
+		s.offset = s.current
+	}
+	if s.current >= s.offset+rows {
+		s.offset = s.current - rows + 1
+	}
+
+	var preview []string
+	if showPreview && len(s.matches) > 0 {
+		preview = s.previewLines(s.matches[s.current])
+		if s.scroll > len(preview)-1 {
+			s.scroll = max(len(preview)-1, 0)
+		}
+		preview = preview[s.scroll:]
+	}
+
+	var frame bytes.Buffer
+	frame.WriteString("\033[H")
+
+	promptLine := s.opts.Prompt + string(s.query)
+	frame.WriteString(truncate(promptLine, totalWidth))
+	frame.WriteString("\033[K\r\n")
+
+	info := fmt.Sprintf("  %d/%d", len(s.matches), len(s.items))
+	if len(s.selected) > 0 {
+		info += fmt.Sprintf(" (%d)", len(s.selected))
+	}
+	info += " " + strings.Repeat("─", max(totalWidth-runewidth.StringWidth(info)-1, 0))
+	frame.WriteString(theme.Interactive(theme.FinderInfo) + truncate(info, totalWidth) + theme.Reset + "\033[K")
+
+	for row := 0; row < rows; row++ {
+		frame.WriteString("\r\n")
+		i := s.offset + row
+		used := 0
+		if i < len(s.matches) {
+			used = s.drawMatch(&frame, s.matches[i], i == s.current, listWidth)
+		}
+		if showPreview {
+			frame.WriteString(strings.Repeat(" ", max(listWidth-used, 0)))
+			frame.WriteString(theme.Interactive(theme.FinderInfo) + "│" + theme.Reset + " ")
+			if row < len(preview) {
+				frame.WriteString(truncate(preview[row], totalWidth-listWidth-2))
+			}
+		}
+		frame.WriteString("\033[K")
+	}
+
+	// Leave the terminal cursor at the editing position of the query
+	column := runewidth.StringWidth(s.opts.Prompt) + runewidth.StringWidth(string(s.query[:s.cursor])) + 1
+	fmt.Fprintf(&frame, "\033[1;%dH", min(column, totalWidth))
+	out.Write(frame.Bytes())
+}
+
+// drawMatch writes one candidate with its matched characters highlighted
+// and returns the number of columns used
+func (s *state) drawMatch(frame *bytes.Buffer, m Match, current bool, width int) int {
+	marker := "  "
+	switch {
+	case current && s.selected[m.Index]:
+		marker = theme.Interactive(theme.FinderCursor) + ">" + theme.Interactive(theme.FinderSelected) + "*" + theme.Reset
+	case current:
+		marker = theme.Interactive(theme.FinderCursor) + "> " + theme.Reset
+	case s.selected[m.Index]:
+		marker = " " + theme.Interactive(theme.FinderSelected) + "*" + theme.Reset
+	}
+	frame.WriteString(marker)
+
+	base := theme.Reset
+	if current {
+		base = theme.Interactive(theme.FinderCurrent)
+	}
+	frame.WriteString(base)
+
+	used := 2
+	next := 0
+	for i, r := range []rune(m.Text) {
+		r = printable(r)
+		w := runewidth.RuneWidth(r)
+		if used+w > width {
+			break
+		}
+		highlight := next < len(m.Positions) && m.Positions[next] == i
+		if highlight {
+			next++
+			frame.WriteString(theme.Interactive(theme.FinderMatch))
+		}
+		frame.WriteRune(r)
+		if highlight {
+			frame.WriteString(theme.Reset + base)
+		}
+		used += w
+	}
+	frame.WriteString(theme.Reset)
+	return used
+}
+
+func (s *state) previewLines(m Match) []string {
+	lines, ok := s.preview[m.Index]
+	if !ok {
+		text := strings.ReplaceAll(s.opts.Preview(m.Text), "\t", "    ")
+		lines = strings.Split(strings.TrimRight(text, "\n"), "\n")
+		s.preview[m.Index] = lines
+	}
+	return lines
+}
+
+// truncate cuts text to width columns, replacing control characters so
+// that they cannot move the cursor
+func truncate(text string, width int) string {
+	var b strings.Builder
+	used := 0
+	for _, r := range text {
+		r = printable(r)
+		w := runewidth.RuneWidth(r)
+		if used+w > width {
+			break
+		}
+		b.WriteRune(r)
+		used += w
+	}
+	return b.String()
+}
+
+func printable(r rune) rune {
+	if r == '\t' {
+		return ' '
+	}
+	if r < 0x20 || r == 0x7f {
+		return '?'
+	}
+	return r
+}
@@ -0,0 +1,194 @@
+package finder
+
+import (
+	"io"
+	"time"
+	"unicode/utf8"
+)
+
+type keyKind int
+
+const (
+	keyRune keyKind = iota
+	keyEnter
+	keyCancel
+	keyBackspace
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyPageUp
+	keyPageDown
+	keyHome
+	keyEnd
+	keyTab
+	keyShiftTab
+	keyClearLine
+	keyDeleteWord
+	keyToggleAll
+	keyPreviewUp
+	keyPreviewDown
+	keyIgnore
+)
+
+type key struct {
+	kind keyKind
+	r    rune
+}
+
+// escapeTimeout is how long a lone Esc waits for the rest of a sequence
+const escapeTimeout = 30 * time.Millisecond
+
+// keyReader decodes key presses from the chunks read off the terminal
+type keyReader struct {
+	chunks <-chan []byte
+	buf    []byte
+	eof    bool
+}
+
+// readChunks copies the input to a channel so that reads can time out
+func readChunks(input io.Reader) <-chan []byte {
+	chunks := make(chan []byte, 16)
+	go func() {
+		defer close(chunks)
+		buf := make([]byte, 256)
+		for {
+			n, err := input.Read(buf)
+			if n > 0 {
+				chunks <- append([]byte(nil), buf[:n]...)
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return chunks
+}
+
+// fill waits for more input, giving up after timeout when it is positive
+func (k *keyReader) fill(timeout time.Duration) bool {
+	if k.eof {
+		return false
+	}
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case chunk, ok := <-k.chunks:
+		if !ok {
+			k.eof = true
+			return false
+		}
+		k.buf = append(k.buf, chunk...)
+		return true
+	case <-expired:
+		return false
+	}
+}
+
+func (k *keyReader) next() (key, error) {
+	for len(k.buf) == 0 {
+		if !k.fill(0) {
+			return key{}, io.EOF
+		}
+	}
+
+	c := k.buf[0]
+	if c == 0x1b {
+		return k.escape(), nil
+	}
+	if c < 0x20 || c == 0x7f {
+		k.buf = k.buf[1:]
+		return controlKey(c), nil
+	}
+
+	for !utf8.FullRune(k.buf) && k.fill(escapeTimeout) {
+	}
+	r, size := utf8.DecodeRune(k.buf)
+	k.buf = k.buf[size:]
+	return key{kind: keyRune, r: r}, nil
+}
+
+func controlKey(c byte) key {
+	switch c {
+	case '\r', '\n':
+		return key{kind: keyEnter}
+	case 0x03, 0x07: // Ctrl-C, Ctrl-G
+		return key{kind: keyCancel}
+	case 0x08, 0x7f:
+		return key{kind: keyBackspace}
+	case '\t':
+		return key{kind: keyTab}
+	case 0x10, 0x0b: // Ctrl-P, Ctrl-K
+		return key{kind: keyUp}
+	case 0x0e: // Ctrl-N
+		return key{kind: keyDown}
+	case 0x15: // Ctrl-U
+		return key{kind: keyClearLine}
+	case 0x17: // Ctrl-W
+		return key{kind: keyDeleteWord}
+	case 0x01: // Ctrl-A
+		return key{kind: keyToggleAll}
+	}
+	return key{kind: keyIgnore}
+}
+
+// escape decodes Esc, Alt-key and the CSI and SS3 sequences sent for
+// cursor and editing keys
+func (k *keyReader) escape() key {
+	if len(k.buf) == 1 && !k.fill(escapeTimeout) {
+		k.buf = k.buf[1:]
+		return key{kind: keyCancel}
+	}
+	if k.buf[1] != '[' && k.buf[1] != 'O' {
+		// Alt-key, which has no binding of its own
+		k.buf = k.buf[2:]
+		return key{kind: keyIgnore}
+	}
+
+	// Parameters and intermediates run up to a final byte in 0x40-0x7e
+	end := 2
+	for {
+		for end >= len(k.buf) {
+			if !k.fill(escapeTimeout) {
+				k.buf = k.buf[:0]
+				return key{kind: keyIgnore}
+			}
+		}
+		if c := k.buf[end]; c >= 0x40 && c <= 0x7e {
+			break
+		}
+		end++
+	}
+	seq := string(k.buf[2 : end+1])
+	k.buf = k.buf[end+1:]
+
+	switch seq {
+	case "A":
+		return key{kind: keyUp}
+	case "B":
+		return key{kind: keyDown}
+	case "C":
+		return key{kind: keyRight}
+	case "D":
+		return key{kind: keyLeft}
+	case "H", "1~", "7~":
+		return key{kind: keyHome}
+	case "F", "4~", "8~":
+		return key{kind: keyEnd}
+	case "5~":
+		return key{kind: keyPageUp}
+	case "6~":
+		return key{kind: keyPageDown}
+	case "Z":
+		return key{kind: keyShiftTab}
+	case "1;2A":
+		return key{kind: keyPreviewUp}
+	case "1;2B":
+		return key{kind: keyPreviewDown}
+	}
+	return key{kind: keyIgnore}
+}
@@ -0,0 +1,169 @@
+package finder
+
+import (
+	"io"
+	"sync"
+)
+
+// Router shares the terminal input between the line editor and the finder.
+// The line editor keeps reading ahead while a key binding runs, so while
+// the finder is active the input read from the terminal is handed to the
+// finder instead. The terminal is only read while one of them is waiting
+// for input, which leaves it to the commands the shell runs otherwise.
+type Router struct {
+	src     io.Reader
+	mutex   sync.Mutex
+	cond    *sync.Cond
+	waiting int    // Readers blocked in Read
+	pending []byte // Input read for the line editor but not consumed yet
+	err     error
+	active  *routedReader
+	closed  bool
+}
+
+// NewRouter starts routing the input read from src
+func NewRouter(src io.Reader) *Router {
+	r := &Router{src: src}
+	r.cond = sync.NewCond(&r.mutex)
+	go r.loop()
+	return r
+}
+
+func (r *Router) loop() {
+	buf := make([]byte, 1024)
+	for {
+		r.mutex.Lock()
+		for !r.closed && r.active == nil && (r.waiting == 0 || len(r.pending) > 0) {
+			r.cond.Wait()
+		}
+		if r.closed {
+			r.mutex.Unlock()
+			return
+		}
+		r.mutex.Unlock()
+
+		n, err := r.src.Read(buf)
+
+		r.mutex.Lock()
+		if n > 0 {
+			if r.active != nil {
+				r.active.deliver(buf[:n])
+			} else {
+				r.pending = append(r.pending, buf[:n]...)
+			}
+		}
+		if err != nil {
+			r.err = err
+			r.closed = true
+			if r.active != nil {
+				r.active.closeLocked()
+			}
+		}
+		r.cond.Broadcast()
+		r.mutex.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Read returns input for the line editor, blocking while the finder is
+// active
+func (r *Router) Read(p []byte) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.waiting++
+	r.cond.Broadcast()
+	for (r.active != nil || len(r.pending) == 0) && !r.closed {
+		r.cond.Wait()
+	}
+	r.waiting--
+
+	if len(r.pending) > 0 {
+		n := copy(p, r.pending)
+		r.pending = r.pending[n:]
+		return n, nil
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	return 0, io.EOF
+}
+
+// Close stops routing, the goroutine reading src exits after its current
+// read
+func (r *Router) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.closed = true
+	r.cond.Broadcast()
+	return nil
+}
+
+// Activate hands the input to the returned reader until it is closed.
+// Input the line editor has read ahead but not consumed yet goes to the
+// finder first.
+func (r *Router) Activate() io.ReadCloser {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	reader := &routedReader{router: r}
+	if len(r.pending) > 0 {
+		reader.deliver(r.pending)
+		r.pending = nil
+	}
+	r.active = reader
+	r.cond.Broadcast()
+	return reader
+}
+
+type routedReader struct {
+	router *Router
+	queue  []byte // Input not read yet, guarded by the router's mutex
+	closed bool
+}
+
+// deliver queues input for the reader. The queue grows as needed, so no
+// input is lost when the reader falls behind. Callers must hold the
+// router's mutex.
+func (rr *routedReader) deliver(data []byte) {
+	rr.queue = append(rr.queue, data...)
+	rr.router.cond.Broadcast()
+}
+
+func (rr *routedReader) Read(p []byte) (int, error) {
+	r := rr.router
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for len(rr.queue) == 0 && !rr.closed {
+		r.cond.Wait()
+	}
+	if rr.closed {
+		return 0, io.EOF
+	}
+	n := copy(p, rr.queue)
+	rr.queue = rr.queue[n:]
+	return n, nil
+}
+
+// Close returns the input to the line editor
+func (rr *routedReader) Close() error {
+	rr.router.mutex.Lock()
+	defer rr.router.mutex.Unlock()
+	rr.closeLocked()
+	return nil
+}
+
+func (rr *routedReader) closeLocked() {
+	if rr.closed {
+		return
+	}
+	rr.closed = true
+	rr.queue = nil
+	r := rr.router
+	if r.active == rr {
+		r.active = nil
+	}
+	r.cond.Broadcast()
+}
@@ -0,0 +1,263 @@
+package finder
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Scoring constants, modelled on fzf: every matched character earns
+// scoreMatch, matches at word boundaries and runs of consecutive matches earn
+// bonuses and gaps between matched characters cost points
+const (
+	scoreMatch        = 16
+	scoreGapStart     = -3
+	scoreGapExtension = -1
+	bonusBoundary     = 8
+	bonusCamel        = 7
+	bonusConsecutive  = 4
+	bonusFirstChar    = 2
+)
+
+// Match is a candidate that matched the query
+type Match struct {
+	Index     int    // Position of the candidate in the input
+	Text      string // The candidate itself
+	Score     int
+	Positions []int // Rune offsets of the matched characters, ascending
+}
+
+// term is one space separated part of a query. Terms use fzf's extended
+// syntax: 'exact, ^prefix, suffix$ and !negated.
+type term struct {
+	text   []rune
+	exact  bool
+	prefix bool
+	suffix bool
+	negate bool
+	fold   bool // Smart case: case-insensitive unless the term has capitals
+}
+
+func parseQuery(query string) []term {
+	var terms []term
+	for _, word := range strings.Fields(query) {
+		t := term{}
+		if strings.HasPrefix(word, "!") {
+			t.negate = true
+			t.exact = true
+			word = word[1:]
+		}
+		if strings.HasPrefix(word, "'") {
+			t.exact = true
+			word = word[1:]
+		}
+		if strings.HasPrefix(word, "^") {
+			t.prefix = true
+			word = word[1:]
+		}
+		if strings.HasSuffix(word, "$") && len(word) > 1 {
+			t.suffix = true
+			word = word[:len(word)-1]
+		}
+		if word == "" {
+			continue
+		}
+		t.fold = strings.ToLower(word) == word
+		t.text = []rune(word)
+		if t.fold {
+			t.text = []rune(strings.ToLower(word))
+		}
+		terms = append(terms, t)
+	}
+	return terms
+}
+
+// Filter returns the candidates matching every term of the query, best
+// first. Candidates with equal scores keep the shorter one first and then
+// their input order, so an empty query leaves the input unchanged.
+func Filter(items []string, query string) []Match {
+	terms := parseQuery(query)
+	matches := make([]Match, 0, len(items))
+	for i, item := range items {
+		if m, ok := score(item, terms); ok {
+			m.Index = i
+			matches = append(matches, m)
+		}
+	}
+	if len(terms) == 0 {
+		return matches
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return len(matches[i].Text) < len(matches[j].Text)
+	})
+	return matches
+}
+
+func score(item string, terms []term) (Match, bool) {
+	m := Match{Text: item}
+	if len(terms) == 0 {
+		return m, true
+	}
+
+	text := []rune(item)
+	lower := []rune(strings.ToLower(item))
+	if len(lower) != len(text) {
+		// Lowering changed the length, fall back to per-rune folding
+		lower = make([]rune, len(text))
+		for i, r := range text {
+			lower[i] = unicode.ToLower(r)
+		}
+	}
+
+	for _, t := range terms {
+		subject := text
+		if t.fold {
+			subject = lower
+		}
+
+		var positions []int
+		var ok bool
+		switch {
+		case t.prefix || t.suffix || t.exact:
+			positions, ok = matchExact(subject, t)
+		default:
+			positions, ok = matchFuzzy(subject, t.text)
+		}
+		if t.negate {
+			if ok {
+				return Match{}, false
+			}
+			continue
+		}
+		if !ok {
+			return Match{}, false
+		}
+		m.Score += scorePositions(text, positions)
+		m.Positions = append(m.Positions, positions...)
+	}
+
+	sort.Ints(m.Positions)
+	return m, true
+}
+
+// matchFuzzy finds the pattern as a subsequence of the text. Like fzf it
+// first finds the leftmost end of a match and then scans backwards from
+// there for the shortest window, which keeps the matched characters close.
+func matchFuzzy(text, pattern []rune) ([]int, bool) {
+	pi := 0
+	end := -1
+	for i, r := range text {
+		if r == pattern[pi] {
+			pi++
+			if pi == len(pattern) {
+				end = i
+				break
+			}
+		}
+	}
+	if end < 0 {
+		return nil, false
+	}
+
+	positions := make([]int, len(pattern))
+	pi = len(pattern) - 1
+	for i := end; i >= 0 && pi >= 0; i-- {
+		if text[i] == pattern[pi] {
+			positions[pi] = i
+			pi--
+		}
+	}
+	return positions, true
+}
+
+func matchExact(text []rune, t term) ([]int, bool) {
+	n := len(t.text)
+	if n > len(text) {
+		return nil, false
+	}
+
+	var start int
+	switch {
+	case t.prefix && t.suffix:
+		if len(text) != n || !runesEqual(text, t.text) {
+			return nil, false
+		}
+	case t.prefix:
+		if !runesEqual(text[:n], t.text) {
+			return nil, false
+		}
+	case t.suffix:
+		start = len(text) - n
+		if !runesEqual(text[start:], t.text) {
+			return nil, false
+		}
+	default:
+		start = -1
+		for i := 0; i+n <= len(text); i++ {
+			if runesEqual(text[i:i+n], t.text) {
+				start = i
+				break
+			}
+		}
+		if start < 0 {
+			return nil, false
+		}
+	}
+
+	positions := make([]int, n)
+	for i := range positions {
+		positions[i] = start + i
+	}
+	return positions, true
+}
+
+func runesEqual(a, b []rune) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func scorePositions(text []rune, positions []int) int {
+	total := 0
+	for i, pos := range positions {
+		total += scoreMatch + bonusAt(text, pos)
+		if i == 0 {
+			if pos == 0 {
+				total += bonusFirstChar
+			}
+			continue
+		}
+		gap := pos - positions[i-1] - 1
+		if gap == 0 {
+			total += bonusConsecutive
+		} else {
+			total += scoreGapStart + scoreGapExtension*(gap-1)
+		}
+	}
+	return total
+}
+
+// bonusAt rewards characters that start a word, path component or
+// camelCase hump
+func bonusAt(text []rune, pos int) int {
+	if pos == 0 {
+		return bonusBoundary
+	}
+	prev, cur := text[pos-1], text[pos]
+	switch {
+	case strings.ContainsRune("/\\_-. :=", prev) && !strings.ContainsRune("/\\_-. :=", cur):
+		return bonusBoundary
+	case unicode.IsLower(prev) && unicode.IsUpper(cur):
+		return bonusCamel
+	case !unicode.IsLetter(prev) && !unicode.IsDigit(prev) && (unicode.IsLetter(cur) || unicode.IsDigit(cur)):
+		return bonusBoundary / 2
+	}
+	return 0
+}
@@ -0,0 +1,41 @@
+package finder
+
+import (
+	"os"
+
+	"github.com/chzyer/readline"
+)
+
+// fileDescriptor returns the descriptor of f without calling f.Fd, which
+// switches the file to blocking mode. A blocked read would then survive
+// Close and swallow the next input meant for the shell.
+func fileDescriptor(f *os.File) int {
+	fd := -1
+	if conn, err := f.SyscallConn(); err == nil {
+		conn.Control(func(sysfd uintptr) {
+			fd = int(sysfd)
+		})
+	}
+	return fd
+}
+
+// MakeRaw puts the terminal f into raw mode and returns a function
+// restoring its previous state
+func MakeRaw(f *os.File) (restore func(), err error) {
+	fd := fileDescriptor(f)
+	state, err := readline.MakeRaw(fd)
+	if err != nil {
+		return nil, err
+	}
+	return func() { readline.Restore(fd, state) }, nil
+}
+
+// size returns the width and height of the terminal f, 80x24 when it cannot
+// be determined
+func size(f *os.File) (int, int) {
+	width, height, err := readline.GetSize(fileDescriptor(f))
+	if err != nil || width <= 0 || height <= 0 {
+		return 80, 24
+	}
+	return width, height
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+// +build !windows
+
+package finder
+
+import "os"
+
+// OpenTTY opens the controlling terminal for reading keys and drawing,
+// which lets the finder work while stdin and stdout are redirected
+func OpenTTY() (in *os.File, out *os.File, err error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tty, tty, nil
+}
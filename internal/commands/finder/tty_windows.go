@@ -0,0 +1,21 @@
+//go:build windows
+// +build windows
+
+package finder
+
+import "os"
+
+// OpenTTY opens the console for reading keys and drawing, which lets the
+// finder work while stdin and stdout are redirected
+func OpenTTY() (in *os.File, out *os.File, err error) {
+	in, err = os.OpenFile("CONIN$", os.O_RDWR, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	out, err = os.OpenFile("CONOUT$", os.O_RDWR, 0)
+	if err != nil {
+		in.Close()
+		return nil, nil, err
+	}
+	return in, out, nil
+}
@@ -0,0 +1,232 @@
+// Package frecency keeps the directories the shell changed into, ranked by
+// how often and how recently they were visited, for the z builtin.
+package frecency
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRank is the total rank above which every rank is aged, so that
+// directories that are no longer visited drop out of the database
+const maxRank = 10000
+
+// Dir is a visited directory
+type Dir struct {
+	Path       string    `json:"path"`
+	Rank       float64   `json:"rank"` // Number of visits, aged over time
+	LastAccess time.Time `json:"last_access"`
+}
+
+// Score weighs the rank by how recently the directory was visited
+func (d Dir) Score(now time.Time) float64 {
+	age := now.Sub(d.LastAccess)
+	switch {
+	case age < time.Hour:
+		return d.Rank * 4
+	case age < 24*time.Hour:
+		return d.Rank * 2
+	case age < 7*24*time.Hour:
+		return d.Rank / 2
+	}
+	return d.Rank / 4
+}
+
+// Store is the database shared by every shell session. Each change reloads
+// the file first so that visits recorded by other sessions are kept.
+type Store struct {
+	path  string
+	mutex sync.Mutex
+}
+
+// DefaultPath returns the database in the user's data directory:
+// $XDG_DATA_HOME/commandripple/dirs.json, ~/.local/share/commandripple or
+// %LocalAppData%\commandripple on Windows
+func DefaultPath() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "commandripple", "dirs.json"), nil
+	}
+	if runtime.GOOS == "windows" {
+		if dir := os.Getenv("LocalAppData"); dir != "" {
+			return filepath.Join(dir, "commandripple", "dirs.json"), nil
+		}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the data directory: %v", err)
+	}
+	return filepath.Join(home, ".local", "share", "commandripple", "dirs.json"), nil
+}
+
+// Open returns the database at path, creating its directory when needed
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %v", err)
+	}
+	return &Store{path: path}, nil
+}
+
+// Add records a visit to dir
+func (s *Store) Add(dir string, now time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	dirs, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	found := false
+	total := 0.0
+	for i := range dirs {
+		if dirs[i].Path == dir {
+			dirs[i].Rank++
+			dirs[i].LastAccess = now
+			found = true
+		}
+		total += dirs[i].Rank
+	}
+	if !found {
+		dirs = append(dirs, Dir{Path: dir, Rank: 1, LastAccess: now})
+		total++
+	}
+
+	if total > maxRank {
+		aged := dirs[:0]
+		for _, d := range dirs {
+			d.Rank *= 0.9
+			if d.Rank >= 1 {
+				aged = append(aged, d)
+			}
+		}
+		dirs = aged
+	}
+	return s.save(dirs)
+}
+
+// Remove forgets dir and reports whether it was in the database
+func (s *Store) Remove(dir string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	dirs, err := s.load()
+	if err != nil {
+		return false, err
+	}
+	kept := dirs[:0]
+	for _, d := range dirs {
+		if d.Path != dir {
+			kept = append(kept, d)
+		}
+	}
+	if len(kept) == len(dirs) {
+		return false, nil
+	}
+	return true, s.save(kept)
+}
+
+// Query returns the directories matching every term, best score first.
+// Directories that no longer exist are pruned from the database.
+func (s *Store) Query(terms []string, now time.Time) ([]Dir, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	dirs, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []Dir
+	existing := dirs[:0]
+	pruned := false
+	for _, d := range dirs {
+		if info, err := os.Stat(d.Path); err != nil || !info.IsDir() {
+			pruned = true
+			continue
+		}
+		existing = append(existing, d)
+		if Matches(d.Path, terms) {
+			matches = append(matches, d)
+		}
+	}
+	if pruned {
+		if err := s.save(existing); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score(now) > matches[j].Score(now)
+	})
+	return matches, nil
+}
+
+// Matches reports whether the terms appear in path in order, with the last
+// one in the final path component unless it contains a separator. Matching
+// ignores case unless a term has capitals.
+func Matches(path string, terms []string) bool {
+	if len(terms) == 0 {
+		return true
+	}
+
+	fold := true
+	for _, term := range terms {
+		if strings.ToLower(term) != term {
+			fold = false
+		}
+	}
+	if fold {
+		path = strings.ToLower(path)
+	}
+
+	offset := 0
+	for _, term := range terms {
+		i := strings.Index(path[offset:], term)
+		if i < 0 {
+			return false
+		}
+		offset += i + len(term)
+	}
+
+	last := terms[len(terms)-1]
+	if strings.ContainsAny(last, `/\`) {
+		return true
+	}
+	return strings.Contains(filepath.Base(path), last)
+}
+
+func (s *Store) load() ([]Dir, error) {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory database: %v", err)
+	}
+	var dirs []Dir
+	if err := json.Unmarshal(data, &dirs); err != nil {
+		return nil, fmt.Errorf("failed to read directory database: %v", err)
+	}
+	return dirs, nil
+}
+
+// save writes the database through a temporary file so that a failure never
+// leaves it truncated
+func (s *Store) save(dirs []Dir) error {
+	data, err := json.MarshalIndent(dirs, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to save directory database: %v", err)
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -2,6 +2,7 @@ package free
 
 import (
 	"fmt"
+	"io"
 )
 
 type MemoryInfo struct {
@@ -11,22 +12,22 @@ type MemoryInfo struct {
 	Available uint64
 }
 
-func Free(args []string) error {
+func Free(w io.Writer, args []string) error {
 	memInfo, err := getMemoryInfo()
 	if err != nil {
 		return fmt.Errorf("error getting memory info: %v", err)
 	}
 
-	printMemoryInfo(memInfo)
+	printMemoryInfo(w, memInfo)
 	return nil
 }
 
-func printMemoryInfo(info MemoryInfo) {
-	fmt.Println("Memory Information:")
-	fmt.Printf("Total:     %s\n", formatBytes(info.Total))
-	fmt.Printf("Used:      %s\n", formatBytes(info.Used))
-	fmt.Printf("Free:      %s\n", formatBytes(info.Free))
-	fmt.Printf("Available: %s\n", formatBytes(info.Available))
+func printMemoryInfo(w io.Writer, info MemoryInfo) {
+	fmt.Fprintln(w, "Memory Information:")
+	fmt.Fprintf(w, "Total:     %s\n", formatBytes(info.Total))
+	fmt.Fprintf(w, "Used:      %s\n", formatBytes(info.Used))
+	fmt.Fprintf(w, "Free:      %s\n", formatBytes(info.Free))
+	fmt.Fprintf(w, "Available: %s\n", formatBytes(info.Available))
 }
 
 func formatBytes(bytes uint64) string {
@@ -0,0 +1,84 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"commandripple/internal/commands/gitstatus"
+	"commandripple/internal/commands/theme"
+)
+
+// GitInfo shows the state of the repository holding the current directory,
+// or dir, read from .git without running git. With -s it prints the short
+// form used by the {git} prompt segment.
+func (s *Session) GitInfo(args []string) error {
+	short := false
+	dir := "."
+	for _, arg := range args {
+		switch {
+		case arg == "-s" || arg == "--short":
+			short = true
+		case strings.HasPrefix(arg, "-"):
+			return fmt.Errorf("usage: gitinfo [-s] [directory]")
+		default:
+			dir = arg
+		}
+	}
+
+	status, err := gitstatus.Get(s.path(dir))
+	if err != nil {
+		return fmt.Errorf("gitinfo: %v", err)
+	}
+	if status == nil {
+		return &ExitStatus{Code: 1, Reason: fmt.Sprintf("gitinfo: not a git repository: %s", dir)}
+	}
+	if short {
+		fmt.Fprintln(s.Stdout, status.Short())
+		return nil
+	}
+
+	row := func(name, value string) {
+		s.printColorInline(theme.Accent, fmt.Sprintf("%-11s", name))
+		fmt.Fprintln(s.Stdout, value)
+	}
+	row("repository", status.Root)
+	switch {
+	case status.Branch == "":
+		row("branch", fmt.Sprintf("(detached at %.7s)", status.Commit))
+	case status.Commit == "":
+		row("branch", status.Branch+" (no commits yet)")
+	default:
+		row("branch", fmt.Sprintf("%s at %.7s", status.Branch, status.Commit))
+	}
+	if status.Upstream != "" {
+		row("upstream", fmt.Sprintf("%s, %d ahead, %d behind", status.Upstream, status.Ahead, status.Behind))
+	}
+	if status.Stashes > 0 {
+		entries := "entries"
+		if status.Stashes == 1 {
+			entries = "entry"
+		}
+		row("stash", fmt.Sprintf("%d %s", status.Stashes, entries))
+	}
+
+	var changes []string
+	for _, c := range []struct {
+		count int
+		name  string
+	}{
+		{status.Staged, "staged"},
+		{status.Modified, "modified"},
+		{status.Deleted, "deleted"},
+		{status.Conflicts, "conflicted"},
+	} {
+		if c.count > 0 {
+			changes = append(changes, fmt.Sprintf("%d %s", c.count, c.name))
+		}
+	}
+	if len(changes) == 0 {
+		row("changes", "none")
+	} else {
+		row("changes", strings.Join(changes, ", "))
+	}
+	return nil
+}
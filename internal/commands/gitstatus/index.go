@@ -0,0 +1,248 @@
+package gitstatus
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// File modes stored in the index and in trees
+const (
+	modeDir     = 0o040000
+	modeFile    = 0o100644
+	modeExec    = 0o100755
+	modeSymlink = 0o120000
+	modeGitlink = 0o160000
+)
+
+// Flags of index entries
+const (
+	flagExtended     = 0x4000
+	flagStage        = 0x3000
+	flagSkipWorktree = 0x4000 // In the extended flags
+	flagIntentToAdd  = 0x2000 // In the extended flags
+)
+
+// indexEntry is a file staged in the index
+type indexEntry struct {
+	path         string // Slash separated
+	mtime        time.Time
+	size         uint32 // Truncated to 32 bits, like git
+	mode         uint32
+	hash         string
+	stage        int // Non-zero for the sides of a conflict
+	skipWorktree bool
+	intentToAdd  bool
+}
+
+// index is a parsed .git/index
+type index struct {
+	mtime   time.Time // Files changed in the same tick may differ unnoticed
+	entries []indexEntry
+}
+
+// readIndex parses an index of version 2, 3 or 4. A missing index, as in a
+// new repository, is empty.
+func readIndex(path string) (*index, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return &index{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 12 || string(data[:4]) != "DIRC" {
+		return nil, fmt.Errorf("invalid index %s", path)
+	}
+	version := binary.BigEndian.Uint32(data[4:])
+	if version < 2 || version > 4 {
+		return nil, fmt.Errorf("unsupported index version %d", version)
+	}
+	count := int(binary.BigEndian.Uint32(data[8:]))
+
+	idx := &index{mtime: info.ModTime(), entries: make([]indexEntry, 0, count)}
+	errCorrupt := fmt.Errorf("corrupt index %s", path)
+	rest := data[12:]
+	previous := ""
+	for i := 0; i < count; i++ {
+		start := len(data) - len(rest)
+		if len(rest) < 62 {
+			return nil, errCorrupt
+		}
+		u32 := func(at int) uint32 { return binary.BigEndian.Uint32(rest[at:]) }
+		entry := indexEntry{
+			mtime: time.Unix(int64(u32(8)), int64(u32(12))),
+			mode:  u32(24),
+			size:  u32(36),
+			hash:  hex.EncodeToString(rest[40:60]),
+		}
+		flags := binary.BigEndian.Uint16(rest[60:])
+		entry.stage = int(flags&flagStage) >> 12
+		rest = rest[62:]
+		if version >= 3 && flags&flagExtended != 0 {
+			if len(rest) < 2 {
+				return nil, errCorrupt
+			}
+			extended := binary.BigEndian.Uint16(rest)
+			entry.skipWorktree = extended&flagSkipWorktree != 0
+			entry.intentToAdd = extended&flagIntentToAdd != 0
+			rest = rest[2:]
+		}
+
+		if version == 4 {
+			// The number of bytes to drop from the previous path, then the
+			// rest of this one
+			strip, n := offsetVarint(rest)
+			if n == 0 || strip > len(previous) {
+				return nil, errCorrupt
+			}
+			rest = rest[n:]
+			nul := bytes.IndexByte(rest, 0)
+			if nul < 0 {
+				return nil, errCorrupt
+			}
+			entry.path = previous[:len(previous)-strip] + string(rest[:nul])
+			rest = rest[nul+1:]
+		} else {
+			nul := bytes.IndexByte(rest, 0)
+			if nul < 0 {
+				return nil, errCorrupt
+			}
+			entry.path = string(rest[:nul])
+			// Entries are padded with NULs to a multiple of 8 bytes
+			end := start + (len(data)-start-len(rest)+nul+8)&^7
+			if end > len(data) {
+				return nil, errCorrupt
+			}
+			rest = data[end:]
+		}
+		previous = entry.path
+		idx.entries = append(idx.entries, entry)
+	}
+
+	// Extensions follow the entries, before the trailing checksum
+	for len(rest) > 20+8 {
+		signature := string(rest[:4])
+		size := int(binary.BigEndian.Uint32(rest[4:]))
+		if signature == "link" {
+			return nil, fmt.Errorf("split index is not supported")
+		}
+		if 8+size > len(rest) {
+			break
+		}
+		rest = rest[8+size:]
+	}
+	return idx, nil
+}
+
+// offsetVarint decodes the variable length integer used for path prefixes
+// in version 4 indexes, returning 0 bytes read when it is truncated
+func offsetVarint(data []byte) (int, int) {
+	if len(data) == 0 {
+		return 0, 0
+	}
+	c := data[0]
+	value := int(c & 0x7f)
+	for n := 1; c&0x80 != 0; n++ {
+		if n >= len(data) {
+			return 0, 0
+		}
+		c = data[n]
+		value = (value+1)<<7 | int(c&0x7f)
+		if c&0x80 == 0 {
+			return value, n + 1
+		}
+	}
+	return value, 1
+}
+
+// fileState remembers whether a file differed from its index entry, for as
+// long as neither changes
+type fileState struct {
+	mtime   time.Time
+	size    int64
+	hash    string
+	changed bool
+}
+
+// worktreeChange tells whether the file of an entry was changed or deleted
+// in the work tree. Files whose size and mtime match the index are taken as
+// unchanged unless they were written in the same tick as the index, the
+// others are hashed, with results kept in states.
+func worktreeChange(root string, idx *index, entry *indexEntry, states map[string]fileState) (changed, deleted bool) {
+	path := filepath.Join(root, filepath.FromSlash(entry.path))
+	info, err := os.Lstat(path)
+	if err != nil {
+		return false, true
+	}
+	if entry.mode == modeGitlink {
+		// Submodules have their own status
+		return false, false
+	}
+
+	var mode uint32
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		mode = modeSymlink
+	case info.Mode().IsRegular():
+		mode = modeFile
+		if info.Mode()&0o111 != 0 && runtime.GOOS != "windows" {
+			mode = modeExec
+		}
+	default:
+		return true, false
+	}
+	if mode != entry.mode && !(runtime.GOOS == "windows" && entry.mode == modeExec && mode == modeFile) {
+		return true, false
+	}
+	if uint32(info.Size()) != entry.size {
+		return true, false
+	}
+	mtime := info.ModTime()
+	if mtime.Equal(entry.mtime) && mtime.Before(idx.mtime) {
+		return false, false
+	}
+
+	if state, ok := states[entry.path]; ok && state.mtime.Equal(mtime) && state.size == info.Size() && state.hash == entry.hash {
+		return state.changed, false
+	}
+	hash, err := hashFile(path, mode, info.Size())
+	changed = err != nil || hash != entry.hash
+	states[entry.path] = fileState{mtime: mtime, size: info.Size(), hash: entry.hash, changed: changed}
+	return changed, false
+}
+
+// hashFile returns the blob id of a file, or of a symlink's target
+func hashFile(path string, mode uint32, size int64) (string, error) {
+	h := sha1.New()
+	if mode == modeSymlink {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "blob %d\x00%s", len(target), filepath.ToSlash(target))
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	fmt.Fprintf(h, "blob %d\x00", size)
+	if n, err := io.Copy(h, f); err != nil || n != size {
+		return "", fmt.Errorf("%s changed while reading", path)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
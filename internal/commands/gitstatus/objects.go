@@ -0,0 +1,542 @@
+package gitstatus
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// errNotFound is returned for objects in neither loose files nor packs
+var errNotFound = errors.New("object not found")
+
+// Object types as numbered in packs
+const (
+	objCommit   = 1
+	objTree     = 2
+	objBlob     = 3
+	objTag      = 4
+	objOfsDelta = 6
+	objRefDelta = 7
+)
+
+var typeNames = map[string]int{"commit": objCommit, "tree": objTree, "blob": objBlob, "tag": objTag}
+
+// objectStore reads objects from the objects directory of a repository and
+// its alternates
+type objectStore struct {
+	dirs  []string
+	packs []*pack
+}
+
+// openObjects loads the pack indexes of an objects directory and of the
+// directories listed in its info/alternates
+func openObjects(dir string) *objectStore {
+	s := &objectStore{}
+	s.addDir(dir, 0)
+	return s
+}
+
+func (s *objectStore) addDir(dir string, depth int) {
+	s.dirs = append(s.dirs, dir)
+	indexes, _ := filepath.Glob(filepath.Join(dir, "pack", "*.idx"))
+	for _, path := range indexes {
+		if p, err := openPack(path); err == nil {
+			s.packs = append(s.packs, p)
+		}
+	}
+
+	if depth > 4 {
+		return
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "info", "alternates"))
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			s.addDir(relativeTo(dir, line), depth+1)
+		}
+	}
+}
+
+func (s *objectStore) close() {
+	for _, p := range s.packs {
+		p.close()
+	}
+}
+
+// read returns the type and content of an object
+func (s *objectStore) read(hash string) (int, []byte, error) {
+	id, err := hex.DecodeString(hash)
+	if err != nil || len(id) != 20 {
+		return 0, nil, fmt.Errorf("invalid object name %s", hash)
+	}
+	for _, p := range s.packs {
+		if offset, ok := p.find(id); ok {
+			return p.readAt(s, offset, 0)
+		}
+	}
+	for _, dir := range s.dirs {
+		kind, data, err := readLoose(filepath.Join(dir, hash[:2], hash[2:]))
+		if err == nil {
+			return kind, data, nil
+		}
+		if !os.IsNotExist(err) {
+			return 0, nil, err
+		}
+	}
+	return 0, nil, errNotFound
+}
+
+func readLoose(path string) (int, []byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer f.Close()
+
+	z, err := zlib.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer z.Close()
+	data, err := io.ReadAll(z)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// "<type> <size>\0<content>"
+	header, content, ok := bytes.Cut(data, []byte{0})
+	name, _, _ := strings.Cut(string(header), " ")
+	kind, known := typeNames[name]
+	if !ok || !known {
+		return 0, nil, fmt.Errorf("corrupt object %s", path)
+	}
+	return kind, content, nil
+}
+
+// pack is a pack file with its version 2 index held in memory
+type pack struct {
+	path    string
+	fanout  [256]uint32
+	hashes  []byte   // 20 bytes per object, sorted
+	offsets []uint32 // Offsets, or indexes into large with the top bit set
+	large   []uint64
+
+	mutex sync.Mutex
+	file  *os.File
+}
+
+func openPack(indexPath string) (*pack, error) {
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 8+256*4 || !bytes.Equal(data[:4], []byte{0xff, 't', 'O', 'c'}) || binary.BigEndian.Uint32(data[4:]) != 2 {
+		return nil, fmt.Errorf("unsupported pack index %s", indexPath)
+	}
+
+	p := &pack{path: strings.TrimSuffix(indexPath, ".idx") + ".pack"}
+	for i := range p.fanout {
+		p.fanout[i] = binary.BigEndian.Uint32(data[8+i*4:])
+	}
+	n := int(p.fanout[255])
+	hashStart := 8 + 256*4
+	offsetStart := hashStart + n*20 + n*4 // CRCs come between
+	largeStart := offsetStart + n*4
+	if len(data) < largeStart {
+		return nil, fmt.Errorf("truncated pack index %s", indexPath)
+	}
+	p.hashes = data[hashStart : hashStart+n*20]
+	p.offsets = make([]uint32, n)
+	for i := range p.offsets {
+		p.offsets[i] = binary.BigEndian.Uint32(data[offsetStart+i*4:])
+	}
+	for i := largeStart; i+8 <= len(data)-40; i += 8 {
+		p.large = append(p.large, binary.BigEndian.Uint64(data[i:]))
+	}
+	return p, nil
+}
+
+// find returns the offset of an object in the pack
+func (p *pack) find(id []byte) (int64, bool) {
+	lo := 0
+	if id[0] > 0 {
+		lo = int(p.fanout[id[0]-1])
+	}
+	hi := int(p.fanout[id[0]])
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(p.hashes[(lo+i)*20:(lo+i+1)*20], id) >= 0
+	})
+	if i >= hi || !bytes.Equal(p.hashes[i*20:(i+1)*20], id) {
+		return 0, false
+	}
+	offset := p.offsets[i]
+	if offset&0x80000000 != 0 {
+		index := int(offset &^ 0x80000000)
+		if index >= len(p.large) {
+			return 0, false
+		}
+		return int64(p.large[index]), true
+	}
+	return int64(offset), true
+}
+
+func (p *pack) open() (*os.File, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.file == nil {
+		f, err := os.Open(p.path)
+		if err != nil {
+			return nil, err
+		}
+		p.file = f
+	}
+	return p.file, nil
+}
+
+func (p *pack) close() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.file != nil {
+		p.file.Close()
+		p.file = nil
+	}
+}
+
+// readAt reads the object at offset, applying deltas to their base
+func (p *pack) readAt(s *objectStore, offset int64, depth int) (int, []byte, error) {
+	if depth > 50 {
+		return 0, nil, fmt.Errorf("delta chain too long in %s", p.path)
+	}
+	f, err := p.open()
+	if err != nil {
+		return 0, nil, err
+	}
+	r := bufio.NewReader(io.NewSectionReader(f, offset, 1<<62))
+
+	// Type and inflated size, 7 bits at a time after the first 4
+	c, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	kind := int(c>>4) & 7
+	size := int64(c & 0x0f)
+	for shift := 4; c&0x80 != 0; shift += 7 {
+		if c, err = r.ReadByte(); err != nil {
+			return 0, nil, err
+		}
+		size |= int64(c&0x7f) << shift
+	}
+
+	var baseKind int
+	var base []byte
+	switch kind {
+	case objOfsDelta:
+		// Distance back to the base, in git's offset encoding
+		if c, err = r.ReadByte(); err != nil {
+			return 0, nil, err
+		}
+		distance := int64(c & 0x7f)
+		for c&0x80 != 0 {
+			if c, err = r.ReadByte(); err != nil {
+				return 0, nil, err
+			}
+			distance = (distance+1)<<7 | int64(c&0x7f)
+		}
+		baseKind, base, err = p.readAt(s, offset-distance, depth+1)
+	case objRefDelta:
+		id := make([]byte, 20)
+		if _, err = io.ReadFull(r, id); err != nil {
+			return 0, nil, err
+		}
+		baseKind, base, err = s.read(hex.EncodeToString(id))
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	z, err := zlib.NewReader(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer z.Close()
+	data := make([]byte, size)
+	if _, err := io.ReadFull(z, data); err != nil {
+		return 0, nil, err
+	}
+
+	if kind != objOfsDelta && kind != objRefDelta {
+		return kind, data, nil
+	}
+	result, err := applyDelta(base, data)
+	return baseKind, result, err
+}
+
+// applyDelta rebuilds an object from its base and a delta of copy and
+// insert instructions
+func applyDelta(base, delta []byte) ([]byte, error) {
+	errCorrupt := errors.New("corrupt delta")
+	varint := func() (int, bool) {
+		value, shift := 0, 0
+		for len(delta) > 0 {
+			c := delta[0]
+			delta = delta[1:]
+			value |= int(c&0x7f) << shift
+			shift += 7
+			if c&0x80 == 0 {
+				return value, true
+			}
+		}
+		return 0, false
+	}
+
+	baseSize, ok1 := varint()
+	resultSize, ok2 := varint()
+	if !ok1 || !ok2 || baseSize != len(base) {
+		return nil, errCorrupt
+	}
+
+	result := make([]byte, 0, resultSize)
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+		if op&0x80 == 0 {
+			// Insert the next op bytes
+			n := int(op)
+			if n == 0 || n > len(delta) {
+				return nil, errCorrupt
+			}
+			result = append(result, delta[:n]...)
+			delta = delta[n:]
+			continue
+		}
+
+		// Copy from the base; the low bits say which offset and size bytes
+		// follow
+		var offset, size int
+		for i := 0; i < 4; i++ {
+			if op&(1<<i) != 0 {
+				if len(delta) == 0 {
+					return nil, errCorrupt
+				}
+				offset |= int(delta[0]) << (8 * i)
+				delta = delta[1:]
+			}
+		}
+		for i := 0; i < 3; i++ {
+			if op&(0x10<<i) != 0 {
+				if len(delta) == 0 {
+					return nil, errCorrupt
+				}
+				size |= int(delta[0]) << (8 * i)
+				delta = delta[1:]
+			}
+		}
+		if size == 0 {
+			size = 0x10000
+		}
+		if offset+size > len(base) {
+			return nil, errCorrupt
+		}
+		result = append(result, base[offset:offset+size]...)
+	}
+
+	if len(result) != resultSize {
+		return nil, errCorrupt
+	}
+	return result, nil
+}
+
+// commit holds the fields of a commit needed here
+type commit struct {
+	tree    string
+	parents []string
+	time    int64 // Committer time, for walking newest first
+}
+
+func (s *objectStore) readCommit(hash string) (*commit, error) {
+	kind, data, err := s.read(hash)
+	if err != nil {
+		return nil, err
+	}
+	if kind != objCommit {
+		return nil, fmt.Errorf("%s is not a commit", hash)
+	}
+	c := &commit{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			break
+		}
+		field, value, _ := strings.Cut(line, " ")
+		switch field {
+		case "tree":
+			c.tree = value
+		case "parent":
+			c.parents = append(c.parents, value)
+		case "committer":
+			// "Name <email> 1700000000 +0100"
+			fields := strings.Fields(value)
+			if len(fields) >= 2 {
+				fmt.Sscan(fields[len(fields)-2], &c.time)
+			}
+		}
+	}
+	return c, nil
+}
+
+// treeEntry is a file of a tree, flattened to its path
+type treeEntry struct {
+	mode uint32
+	hash string
+}
+
+// flattenTree lists the files below a tree by their slash separated path
+func (s *objectStore) flattenTree(hash, prefix string, files map[string]treeEntry) error {
+	kind, data, err := s.read(hash)
+	if err != nil {
+		return err
+	}
+	if kind != objTree {
+		return fmt.Errorf("%s is not a tree", hash)
+	}
+
+	// "<octal mode> <name>\0<20 byte id>" entries
+	for len(data) > 0 {
+		space := bytes.IndexByte(data, ' ')
+		nul := bytes.IndexByte(data, 0)
+		if space < 0 || nul < space || nul+21 > len(data) {
+			return fmt.Errorf("corrupt tree %s", hash)
+		}
+		var mode uint32
+		fmt.Sscanf(string(data[:space]), "%o", &mode)
+		name := prefix + string(data[space+1:nul])
+		id := hex.EncodeToString(data[nul+1 : nul+21])
+		data = data[nul+21:]
+
+		if mode == 0o40000 {
+			if err := s.flattenTree(id, name+"/", files); err != nil {
+				return err
+			}
+			continue
+		}
+		files[name] = treeEntry{mode: mode, hash: id}
+	}
+	return nil
+}
+
+// maxWalk bounds the commits read to count ahead and behind, so that far
+// diverged branches cannot stall the prompt
+const maxWalk = 20000
+
+// aheadBehind counts the commits reachable from local but not upstream and
+// the other way around. Like git, it walks both histories newest first,
+// marking each commit with the sides it is reachable from, and stops once
+// every commit left to visit is reachable from both and older than all
+// commits seen from one side only.
+func (s *objectStore) aheadBehind(local, upstream string) (int, int, error) {
+	const (
+		fromLocal    = 1
+		fromUpstream = 2
+		fromBoth     = fromLocal | fromUpstream
+	)
+	if local == upstream {
+		return 0, 0, nil
+	}
+
+	flags := make(map[string]int)
+	commits := make(map[string]*commit)
+	visited := make(map[string]bool)
+	var queue []string // Sorted oldest first, so the newest is popped
+
+	// mark adds a flag to a commit and to the ancestors already visited,
+	// whose parents were queued with the flags they had then
+	mark := func(hash string, flag int) {
+		stack := []string{hash}
+		for len(stack) > 0 {
+			hash := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if flags[hash]|flag == flags[hash] {
+				continue
+			}
+			flags[hash] |= flag
+			if visited[hash] {
+				stack = append(stack, commits[hash].parents...)
+			}
+		}
+	}
+	push := func(hash string, flag int) error {
+		if _, known := flags[hash]; known {
+			mark(hash, flag)
+			return nil
+		}
+		flags[hash] = flag
+		c, err := s.readCommit(hash)
+		if err == errNotFound {
+			// The history of a shallow clone ends here
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		commits[hash] = c
+		// Commits of the same time are visited in the order they were found
+		i := sort.Search(len(queue), func(i int) bool { return commits[queue[i]].time >= c.time })
+		queue = append(queue[:i], append([]string{hash}, queue[i:]...)...)
+		return nil
+	}
+	done := func() bool {
+		for _, hash := range queue {
+			if flags[hash] != fromBoth {
+				return false
+			}
+		}
+		// A commit seen from one side only could still be reached from the
+		// other through the queue, unless it is newer
+		newest := commits[queue[len(queue)-1]].time
+		for hash, flag := range flags {
+			if c, ok := commits[hash]; ok && flag != fromBoth && c.time <= newest {
+				return false
+			}
+		}
+		return true
+	}
+
+	if err := push(local, fromLocal); err != nil {
+		return 0, 0, err
+	}
+	if err := push(upstream, fromUpstream); err != nil {
+		return 0, 0, err
+	}
+	for walked := 0; len(queue) > 0 && walked < maxWalk && !done(); walked++ {
+		hash := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		visited[hash] = true
+		for _, parent := range commits[hash].parents {
+			if err := push(parent, flags[hash]); err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+
+	ahead, behind := 0, 0
+	for _, flag := range flags {
+		switch flag {
+		case fromLocal:
+			ahead++
+		case fromUpstream:
+			behind++
+		}
+	}
+	return ahead, behind, nil
+}
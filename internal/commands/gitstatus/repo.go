@@ -0,0 +1,210 @@
+package gitstatus
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Repo locates the parts of a repository
+type Repo struct {
+	Root      string // Top of the work tree
+	GitDir    string // .git, or the directory of a linked worktree inside it
+	CommonDir string // Objects, refs and config shared by all worktrees
+}
+
+// Find returns the repository holding dir, or nil outside of one. A .git
+// file, as used by worktrees and submodules, points to the real directory.
+func Find(dir string) *Repo {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil
+	}
+	for {
+		path := filepath.Join(dir, ".git")
+		if info, err := os.Stat(path); err == nil {
+			repo := &Repo{Root: dir, GitDir: path}
+			if !info.IsDir() {
+				data, err := os.ReadFile(path)
+				if err != nil || !strings.HasPrefix(string(data), "gitdir:") {
+					return nil
+				}
+				repo.GitDir = relativeTo(dir, strings.TrimSpace(strings.TrimPrefix(string(data), "gitdir:")))
+			}
+			repo.CommonDir = repo.GitDir
+			if data, err := os.ReadFile(filepath.Join(repo.GitDir, "commondir")); err == nil {
+				repo.CommonDir = relativeTo(repo.GitDir, strings.TrimSpace(string(data)))
+			}
+			return repo
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+}
+
+func relativeTo(base, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(base, path)
+}
+
+// head returns the branch HEAD points to, empty when it is detached, and
+// the commit it resolves to, empty on a branch without commits
+func (r *Repo) head() (branch, commit string, err error) {
+	data, err := os.ReadFile(filepath.Join(r.GitDir, "HEAD"))
+	if err != nil {
+		return "", "", err
+	}
+	content := strings.TrimSpace(string(data))
+	if ref, ok := strings.CutPrefix(content, "ref: "); ok {
+		branch = strings.TrimPrefix(ref, "refs/heads/")
+		commit, _ = r.resolve(ref)
+		return branch, commit, nil
+	}
+	if !isHash(content) {
+		return "", "", fmt.Errorf("invalid HEAD in %s", r.GitDir)
+	}
+	return "", content, nil
+}
+
+// resolve returns the object a ref points to, following symbolic refs.
+// Loose refs take precedence over packed-refs.
+func (r *Repo) resolve(ref string) (string, error) {
+	for depth := 0; depth < 5; depth++ {
+		dir := r.CommonDir
+		if ref == "HEAD" || strings.HasPrefix(ref, "refs/worktree/") || strings.HasPrefix(ref, "refs/bisect/") {
+			dir = r.GitDir
+		}
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(ref)))
+		if err != nil {
+			return r.packedRef(ref)
+		}
+		content := strings.TrimSpace(string(data))
+		next, symbolic := strings.CutPrefix(content, "ref: ")
+		if !symbolic {
+			if !isHash(content) {
+				return "", fmt.Errorf("invalid ref %s", ref)
+			}
+			return content, nil
+		}
+		ref = next
+	}
+	return "", fmt.Errorf("too many levels of symbolic refs")
+}
+
+func (r *Repo) packedRef(ref string) (string, error) {
+	f, err := os.Open(filepath.Join(r.CommonDir, "packed-refs"))
+	if err != nil {
+		return "", fmt.Errorf("unknown ref %s", ref)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// Comments and the peeled objects of annotated tags
+		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "^") {
+			continue
+		}
+		if hash, name, ok := strings.Cut(line, " "); ok && name == ref {
+			return hash, nil
+		}
+	}
+	return "", fmt.Errorf("unknown ref %s", ref)
+}
+
+// upstream returns the remote-tracking ref a branch follows, such as
+// refs/remotes/origin/main, and its short name, from the repository config
+func (r *Repo) upstream(branch string) (ref, name string) {
+	section := r.configSection(fmt.Sprintf("[branch %q]", branch))
+	remote, merge := section["remote"], section["merge"]
+	if remote == "" || merge == "" {
+		return "", ""
+	}
+	merged := strings.TrimPrefix(merge, "refs/heads/")
+	if remote == "." {
+		return merge, merged
+	}
+	return "refs/remotes/" + remote + "/" + merged, remote + "/" + merged
+}
+
+// objectFormat returns the hash algorithm of the repository's object ids,
+// sha1 unless extensions.objectFormat says otherwise
+func (r *Repo) objectFormat() string {
+	if format := r.configSection("[extensions]")["objectformat"]; format != "" {
+		return strings.ToLower(format)
+	}
+	return "sha1"
+}
+
+// configSection returns the keys, in lower case, and values of a section
+// of the repository config, given as its header
+func (r *Repo) configSection(header string) map[string]string {
+	values := make(map[string]string)
+	f, err := os.Open(filepath.Join(r.CommonDir, "config"))
+	if err != nil {
+		return values
+	}
+	defer f.Close()
+
+	inSection := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			inSection = line == header
+			continue
+		}
+		if !inSection {
+			continue
+		}
+		key, value, _ := strings.Cut(line, "=")
+		values[strings.ToLower(strings.TrimSpace(key))] = strings.Trim(strings.TrimSpace(value), `"`)
+	}
+	return values
+}
+
+// stashCount returns the number of stash entries, one per line of the
+// stash reflog
+func (r *Repo) stashCount() int {
+	f, err := os.Open(filepath.Join(r.CommonDir, "logs", "refs", "stash"))
+	if err != nil {
+		if _, err := r.resolve("refs/stash"); err == nil {
+			return 1
+		}
+		return 0
+	}
+	defer f.Close()
+
+	count := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if scanner.Text() != "" {
+			count++
+		}
+	}
+	return count
+}
+
+// isHash reports whether s is a SHA-1 object id. Repositories using
+// SHA-256 are refused by Get before any id is read.
+func isHash(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,241 @@
+// Package gitstatus reads the state of a git repository straight from its
+// .git directory, without running git: the branch or detached commit, how
+// far it is ahead of and behind its upstream, the stash and whether files
+// were changed. What does not change between prompts, the parsed index,
+// the HEAD tree and the ahead and behind counts, is kept and only read
+// again when the files it came from change.
+package gitstatus
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Status is the state of a repository
+type Status struct {
+	Root      string
+	Branch    string // Empty when HEAD is detached
+	Commit    string // Empty on a branch without commits
+	Upstream  string // Such as origin/main, empty without one
+	Ahead     int    // Commits on the branch that are not on the upstream
+	Behind    int    // Commits on the upstream that are not on the branch
+	Stashes   int
+	Staged    int // Files that differ between HEAD and the index
+	Modified  int // Files that differ between the index and the work tree
+	Deleted   int // Files in the index that are missing from the work tree
+	Conflicts int // Files with unresolved merge conflicts
+}
+
+// Dirty reports whether there are changes that are not committed
+func (s *Status) Dirty() bool {
+	return s.Staged+s.Modified+s.Deleted+s.Conflicts > 0
+}
+
+// Short returns the status in a few characters for the prompt, such as
+// main*↑1↓2: the branch, or the short commit when HEAD is detached, then
+// + for staged changes, * for unstaged ones and ! for conflicts, and the
+// commits ahead of and behind the upstream.
+func (s *Status) Short() string {
+	var b strings.Builder
+	switch {
+	case s.Branch != "":
+		b.WriteString(s.Branch)
+	case len(s.Commit) >= 7:
+		b.WriteString(s.Commit[:7])
+	}
+	if s.Staged > 0 {
+		b.WriteString("+")
+	}
+	if s.Modified+s.Deleted > 0 {
+		b.WriteString("*")
+	}
+	if s.Conflicts > 0 {
+		b.WriteString("!")
+	}
+	if s.Ahead > 0 {
+		fmt.Fprintf(&b, "↑%d", s.Ahead)
+	}
+	if s.Behind > 0 {
+		fmt.Fprintf(&b, "↓%d", s.Behind)
+	}
+	return b.String()
+}
+
+// stamp identifies a version of a file or directory
+type stamp struct {
+	mtime time.Time
+	size  int64
+}
+
+func stampOf(path string) stamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return stamp{}
+	}
+	return stamp{info.ModTime(), info.Size()}
+}
+
+// repoCache holds what was read from a repository for the next status
+type repoCache struct {
+	objects      *objectStore
+	objectsStamp stamp // Of the pack directory, which changes on gc and fetch
+
+	index      *index
+	indexStamp stamp
+
+	treeHash string
+	tree     map[string]treeEntry
+
+	aheadBehindFor [2]string
+	ahead, behind  int
+	files          map[string]fileState
+}
+
+var (
+	cacheMutex sync.Mutex
+	caches     = make(map[string]*repoCache) // By git directory
+)
+
+// Get returns the status of the repository holding dir, or nil outside of
+// one
+func Get(dir string) (*Status, error) {
+	repo := Find(dir)
+	if repo == nil {
+		return nil, nil
+	}
+	// Object ids are read as 20 byte SHA-1 hashes throughout
+	if format := repo.objectFormat(); format != "sha1" {
+		return nil, fmt.Errorf("%s: object format %s is not supported", repo.Root, format)
+	}
+
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+	cache, ok := caches[repo.GitDir]
+	if !ok {
+		cache = &repoCache{files: make(map[string]fileState)}
+		caches[repo.GitDir] = cache
+	}
+
+	status := &Status{Root: repo.Root}
+	var err error
+	if status.Branch, status.Commit, err = repo.head(); err != nil {
+		return nil, err
+	}
+	status.Stashes = repo.stashCount()
+
+	objectsDir := filepath.Join(repo.CommonDir, "objects")
+	if s := stampOf(filepath.Join(objectsDir, "pack")); cache.objects == nil || s != cache.objectsStamp {
+		if cache.objects != nil {
+			cache.objects.close()
+		}
+		cache.objects, cache.objectsStamp = openObjects(objectsDir), s
+	}
+
+	if status.Branch != "" && status.Commit != "" {
+		if ref, name := repo.upstream(status.Branch); ref != "" {
+			if upstream, err := repo.resolve(ref); err == nil {
+				status.Upstream = name
+				key := [2]string{status.Commit, upstream}
+				if cache.aheadBehindFor != key {
+					ahead, behind, err := cache.objects.aheadBehind(status.Commit, upstream)
+					if err != nil {
+						return nil, err
+					}
+					cache.aheadBehindFor, cache.ahead, cache.behind = key, ahead, behind
+				}
+				status.Ahead, status.Behind = cache.ahead, cache.behind
+			}
+		}
+	}
+
+	if err := cache.changes(repo, status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// changes counts the staged, modified, deleted and conflicting files
+func (c *repoCache) changes(repo *Repo, status *Status) error {
+	indexPath := filepath.Join(repo.GitDir, "index")
+	if s := stampOf(indexPath); c.index == nil || s != c.indexStamp {
+		idx, err := readIndex(indexPath)
+		if err != nil {
+			return err
+		}
+		c.index, c.indexStamp = idx, s
+		c.files = make(map[string]fileState)
+	}
+
+	treeHash := ""
+	if status.Commit != "" {
+		head, err := c.objects.readCommit(status.Commit)
+		if err != nil {
+			return err
+		}
+		treeHash = head.tree
+	}
+	if c.tree == nil || treeHash != c.treeHash {
+		tree := make(map[string]treeEntry)
+		if treeHash != "" {
+			if err := c.objects.flattenTree(treeHash, "", tree); err != nil {
+				return err
+			}
+		}
+		c.tree, c.treeHash = tree, treeHash
+	}
+
+	inIndex := make(map[string]bool, len(c.index.entries))
+	var sparseDirs []string
+	conflicts := make(map[string]bool)
+	for i := range c.index.entries {
+		entry := &c.index.entries[i]
+		inIndex[entry.path] = true
+		switch {
+		case entry.stage != 0:
+			conflicts[entry.path] = true
+			continue
+		case entry.mode == modeDir:
+			// A directory of a sparse index stands for the files below it
+			sparseDirs = append(sparseDirs, entry.path)
+			continue
+		}
+
+		if committed, ok := c.tree[entry.path]; entry.intentToAdd {
+			status.Modified++
+			continue
+		} else if !ok || committed.hash != entry.hash || committed.mode != entry.mode {
+			status.Staged++
+		}
+		if entry.skipWorktree {
+			continue
+		}
+		changed, deleted := worktreeChange(repo.Root, c.index, entry, c.files)
+		if deleted {
+			status.Deleted++
+		} else if changed {
+			status.Modified++
+		}
+	}
+	status.Conflicts = len(conflicts)
+
+	// Files committed but removed from the index
+	for path := range c.tree {
+		if !inIndex[path] && !underAny(path, sparseDirs) {
+			status.Staged++
+		}
+	}
+	return nil
+}
+
+func underAny(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if strings.HasPrefix(path, strings.TrimSuffix(dir, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,295 @@
+package gitstatus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// The fixtures are made with git and every status is compared with what
+// git status --porcelain=v2 reports for the same repository.
+func TestStatusMatchesGit(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, dir string)
+		check func(t *testing.T, dir string)
+	}{
+		{"empty repository", func(t *testing.T, dir string) {
+			write(t, dir, "new.txt", "new\n")
+			git(t, dir, "add", "new.txt")
+		}, nil},
+		{"work tree and index changes", func(t *testing.T, dir string) {
+			for _, name := range []string{"a.txt", "b.txt", "c.txt", "dir/d.sh", "dir/sub/e.txt"} {
+				write(t, dir, name, name+"\n")
+			}
+			git(t, dir, "add", ".")
+			git(t, dir, "commit", "-qm", "initial")
+
+			write(t, dir, "a.txt", "changed\n")
+			write(t, dir, "dir/sub/e.txt", "staged\n")
+			git(t, dir, "add", "dir/sub/e.txt")
+			write(t, dir, "dir/sub/e.txt", "staged and changed again\n")
+			os.Remove(filepath.Join(dir, "b.txt"))
+			git(t, dir, "rm", "-q", "--cached", "c.txt")
+			if err := os.Chmod(filepath.Join(dir, "dir/d.sh"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			write(t, dir, "intent.txt", "later\n")
+			git(t, dir, "add", "-N", "intent.txt")
+			write(t, dir, "untracked.txt", "ignored by the counts\n")
+		}, nil},
+		{"index version 4", func(t *testing.T, dir string) {
+			git(t, dir, "config", "index.version", "4")
+			for _, name := range []string{
+				"src/commands/alpha.go", "src/commands/alphabet.go", "src/commands/beta.go",
+				"src/commands/nested/deeper/gamma.go", "src/common.go", "src/z.go", "top.txt",
+			} {
+				write(t, dir, name, name+"\n")
+			}
+			git(t, dir, "add", ".")
+			git(t, dir, "commit", "-qm", "initial")
+			write(t, dir, "src/commands/alphabet.go", "changed\n")
+			write(t, dir, "src/commands/nested/deeper/new.go", "new\n")
+			git(t, dir, "add", "src/commands/nested/deeper/new.go")
+			os.Remove(filepath.Join(dir, "src/common.go"))
+		}, func(t *testing.T, dir string) {
+			data, err := os.ReadFile(filepath.Join(dir, ".git", "index"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version := binary.BigEndian.Uint32(data[4:]); version != 4 {
+				t.Fatalf("the index has version %d, want 4", version)
+			}
+		}},
+		{"packed refs and delta objects", func(t *testing.T, dir string) {
+			var lines []string
+			for i := 0; i < 300; i++ {
+				lines = append(lines, fmt.Sprintf("line %d of a file that compresses well as deltas", i))
+			}
+			commit := func(i int) {
+				lines[i*7] = fmt.Sprintf("changed in commit %d", i)
+				write(t, dir, "big.txt", strings.Join(lines, "\n")+"\n")
+				git(t, dir, "commit", "-qam", fmt.Sprintf("commit %d", i))
+			}
+			write(t, dir, "big.txt", strings.Join(lines, "\n")+"\n")
+			write(t, dir, "small.txt", "small\n")
+			git(t, dir, "add", ".")
+			git(t, dir, "commit", "-qm", "initial")
+			for i := 1; i <= 8; i++ {
+				commit(i)
+			}
+			git(t, dir, "branch", "upstream", "HEAD~3")
+			git(t, dir, "checkout", "-q", "upstream")
+			for i := 20; i <= 22; i++ {
+				commit(i)
+			}
+			git(t, dir, "checkout", "-q", "main")
+			git(t, dir, "branch", "-q", "--set-upstream-to=upstream")
+			git(t, dir, "tag", "-a", "-m", "annotated", "v1", "HEAD~1")
+
+			write(t, dir, "small.txt", "stashed\n")
+			git(t, dir, "stash", "-q")
+			write(t, dir, "small.txt", "stashed again\n")
+			git(t, dir, "stash", "-q")
+
+			git(t, dir, "gc", "-q", "--aggressive", "--prune=now")
+			write(t, dir, "big.txt", "replaced\n")
+		}, func(t *testing.T, dir string) {
+			if _, err := os.Stat(filepath.Join(dir, ".git", "refs", "heads", "main")); err == nil {
+				t.Fatal("refs/heads/main is a loose ref after gc")
+			}
+			packed, err := os.ReadFile(filepath.Join(dir, ".git", "packed-refs"))
+			if err != nil || !strings.Contains(string(packed), "refs/heads/main") {
+				t.Fatalf("refs/heads/main is not in packed-refs: %v", err)
+			}
+			packs, _ := filepath.Glob(filepath.Join(dir, ".git", "objects", "pack", "*.idx"))
+			if len(packs) == 0 {
+				t.Fatal("gc wrote no pack")
+			}
+			if out := git(t, dir, append([]string{"verify-pack", "-v"}, packs...)...); !strings.Contains(out, "chain length") {
+				t.Fatal("the pack holds no delta objects")
+			}
+		}},
+		{"detached HEAD", func(t *testing.T, dir string) {
+			write(t, dir, "file.txt", "one\n")
+			git(t, dir, "add", ".")
+			git(t, dir, "commit", "-qm", "one")
+			write(t, dir, "file.txt", "two\n")
+			git(t, dir, "commit", "-qam", "two")
+			git(t, dir, "checkout", "-q", "--detach", "HEAD~1")
+			write(t, dir, "file.txt", "three\n")
+		}, nil},
+		{"merge conflict", func(t *testing.T, dir string) {
+			write(t, dir, "file.txt", "base\n")
+			write(t, dir, "other.txt", "base\n")
+			git(t, dir, "add", ".")
+			git(t, dir, "commit", "-qm", "base")
+			git(t, dir, "checkout", "-qb", "topic")
+			write(t, dir, "file.txt", "topic\n")
+			write(t, dir, "other.txt", "topic\n")
+			git(t, dir, "commit", "-qam", "topic")
+			git(t, dir, "checkout", "-q", "main")
+			write(t, dir, "file.txt", "main\n")
+			git(t, dir, "commit", "-qam", "main")
+			gitCommand(dir, "merge", "-q", "topic").Run() // Fails with the conflict
+		}, nil},
+	}
+
+	requireGit(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := initRepo(t)
+			tt.setup(t, dir)
+			if tt.check != nil {
+				tt.check(t, dir)
+			}
+
+			want := porcelainStatus(t, dir)
+			got, err := Get(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got == nil {
+				t.Fatal("Get found no repository")
+			}
+			got.Root = ""
+			if *got != want {
+				t.Errorf("Get = %+v\ngit reports %+v", *got, want)
+			}
+		})
+	}
+}
+
+func TestSHA256Repository(t *testing.T) {
+	requireGit(t)
+	dir := t.TempDir()
+	if out, err := gitCommand(dir, "init", "-q", "--object-format=sha256", "-b", "main").CombinedOutput(); err != nil {
+		t.Skipf("git cannot create SHA-256 repositories: %v: %s", err, out)
+	}
+	write(t, dir, "file.txt", "content\n")
+	git(t, dir, "add", ".")
+	git(t, dir, "commit", "-qm", "initial")
+
+	status, err := Get(dir)
+	if err == nil {
+		t.Fatalf("Get = %+v, want an error", status)
+	}
+	if !strings.Contains(err.Error(), "sha256") {
+		t.Errorf("Get failed with %q, want it to name the object format", err)
+	}
+}
+
+func TestIsHash(t *testing.T) {
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{"0123456789abcdef0123456789abcdef01234567", true},
+		{"0123456789ABCDEF0123456789abcdef01234567", false},
+		{"0123456789abcdef0123456789abcdef0123456", false},
+		{"0123456789abcdef0123456789abcdef012345678", false},
+		{strings.Repeat("a", 64), false},
+		{"ref: refs/heads/main", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isHash(tt.s); got != tt.want {
+			t.Errorf("isHash(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func requireGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+}
+
+func initRepo(t *testing.T) string {
+	dir := t.TempDir()
+	git(t, dir, "init", "-q", "-b", "main")
+	return dir
+}
+
+// gitCommand runs git without the user's config, as a fixed author
+func gitCommand(dir string, args ...string) *exec.Cmd {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_CONFIG_GLOBAL="+os.DevNull,
+		"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com",
+	)
+	return cmd
+}
+
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := gitCommand(dir, args...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return string(out)
+}
+
+func write(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// porcelainStatus returns the status git reports, counted the way Status
+// counts files
+func porcelainStatus(t *testing.T, dir string) Status {
+	var status Status
+	out := git(t, dir, "status", "--porcelain=v2", "--branch", "--show-stash", "--untracked-files=no")
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) < 2:
+		case fields[0] == "#" && len(fields) >= 3:
+			switch fields[1] {
+			case "branch.oid":
+				if fields[2] != "(initial)" {
+					status.Commit = fields[2]
+				}
+			case "branch.head":
+				if fields[2] != "(detached)" {
+					status.Branch = fields[2]
+				}
+			case "branch.upstream":
+				status.Upstream = fields[2]
+			case "branch.ab":
+				status.Ahead, _ = strconv.Atoi(strings.TrimPrefix(fields[2], "+"))
+				status.Behind, _ = strconv.Atoi(strings.TrimPrefix(fields[3], "-"))
+			case "stash":
+				status.Stashes, _ = strconv.Atoi(fields[2])
+			}
+		case fields[0] == "1" || fields[0] == "2":
+			staged, unstaged := fields[1][0], fields[1][1]
+			if staged != '.' {
+				status.Staged++
+			}
+			switch unstaged {
+			case 'D':
+				status.Deleted++
+			case 'M', 'T', 'A':
+				status.Modified++
+			}
+		case fields[0] == "u":
+			status.Conflicts++
+		}
+	}
+	return status
+}
@@ -0,0 +1,181 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"commandripple/internal/commands/theme"
+)
+
+// ConfirmExit reports whether the shell may exit. When jobs are still
+// running or stopped it warns about them the first time and returns false.
+func (s *Session) ConfirmExit() bool {
+	s.jobs.mutex.Lock()
+	running, stopped := 0, 0
+	for _, jobInfo := range s.jobs.jobs {
+		switch jobInfo.Status {
+		case JobRunning:
+			running++
+		case JobStopped:
+			stopped++
+		}
+	}
+	warned := s.jobs.exitWarned
+	s.jobs.exitWarned = true
+	s.jobs.mutex.Unlock()
+
+	if running+stopped == 0 || warned {
+		return true
+	}
+	if stopped > 0 {
+		fmt.Fprintf(s.Stdout, "There are %d stopped job(s).\n", stopped)
+	}
+	if running > 0 {
+		fmt.Fprintf(s.Stdout, "There are %d running job(s).\n", running)
+	}
+	fmt.Fprintln(s.Stdout, "Use 'exit' again to quit, remaining jobs will be sent SIGHUP.")
+	return false
+}
+
+// HangupJobs sends SIGHUP to every job that was not disowned with -h or
+// started with nohup, continuing stopped jobs so that they receive it
+func (s *Session) HangupJobs() {
+	s.jobs.mutex.Lock()
+	jobs := s.jobs.sorted()
+	s.jobs.mutex.Unlock()
+
+	for _, jobInfo := range jobs {
+		s.jobs.mutex.Lock()
+		skip := jobInfo.noHangup || jobInfo.Status == JobDone
+		stopped := jobInfo.Status == JobStopped
+		s.jobs.mutex.Unlock()
+		if skip {
+			continue
+		}
+
+		signalJob(jobInfo, syscall.SIGHUP)
+		if stopped {
+			signalJob(jobInfo, sigContinue)
+		}
+	}
+}
+
+// `exit` command implementation, e.g. `exit` or `exit 2`. Sessions other
+// than Default end the script they run, by default with the status of the
+// last command.
+func (s *Session) ExitShell(args []string) error {
+	code := 0
+	if !s.interactive {
+		code = s.Status()
+	}
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid exit status: %s", args[0])
+		}
+		code = n
+	}
+	if !s.interactive {
+		return &exitError{code: code}
+	}
+
+	if !s.ConfirmExit() {
+		return nil
+	}
+	s.HangupJobs()
+	fmt.Fprintln(s.Stdout, "Exiting CommandRipple...")
+	os.Exit(code)
+	return nil
+}
+
+// `disown` command implementation: removes jobs from the job table, or with
+// -h keeps them but does not send them SIGHUP when the shell exits,
+// e.g. `disown %1` or `disown -h %+`
+func (s *Session) Disown(args []string) error {
+	keep := false
+	if len(args) > 0 && args[0] == "-h" {
+		keep = true
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		args = []string{"%+"}
+	}
+
+	s.jobs.mutex.Lock()
+	defer s.jobs.mutex.Unlock()
+
+	for _, spec := range args {
+		jobInfo, err := s.jobs.lookup(spec)
+		if err != nil {
+			return err
+		}
+		if keep {
+			jobInfo.noHangup = true
+			continue
+		}
+		if jobInfo.Status == JobStopped {
+			fmt.Fprintln(s.Stderr, theme.Paint(s.Stderr, theme.Warning, fmt.Sprintf("warning: job %d is stopped and will stay stopped", jobInfo.ID)))
+		}
+		s.jobs.remove(jobInfo)
+	}
+	return nil
+}
+
+// `nohup` command implementation: starts a command as a background job
+// that ignores SIGHUP and is not sent it when the shell exits. Output that
+// would go to the terminal is appended to nohup.out, or $HOME/nohup.out
+// when the current directory is not writable, which the job writes to
+// itself so that it can outlive the shell.
+func (s *Session) Nohup(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: nohup command [args]")
+	}
+
+	stdout, stderr := s.Stdout, s.Stderr
+	logPath := ""
+	if isTerminal(s.Stdout) {
+		logPath = "nohup.out"
+		logFile, err := os.OpenFile(s.path(logPath), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			home, homeErr := s.homeDir()
+			if homeErr != nil {
+				return fmt.Errorf("failed to open nohup.out: %v", err)
+			}
+			logPath = filepath.Join(home, "nohup.out")
+			logFile, err = os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+			if err != nil {
+				return fmt.Errorf("failed to open nohup.out: %v", err)
+			}
+		}
+		defer logFile.Close()
+		stdout = logFile
+	}
+	if isTerminal(s.Stderr) {
+		stderr = stdout
+	}
+
+	command := nohupCommand(s, args)
+	command.Stdin = nil
+	command.Stdout = stdout
+	command.Stderr = stderr
+
+	job := s.newJob(strings.Join(args, " "))
+	job.noHangup = true
+	if err := job.start(command); err != nil {
+		return fmt.Errorf("failed to start command: %v", err)
+	}
+
+	s.jobs.mutex.Lock()
+	s.jobs.register(job)
+	s.jobs.mutex.Unlock()
+
+	if logPath != "" {
+		fmt.Fprintf(s.Stderr, "nohup: appending output to '%s'\n", logPath)
+	}
+	fmt.Fprintf(s.Stdout, "[%d] %d\n", job.ID, job.Pgid)
+	return nil
+}
@@ -1,15 +1,49 @@
 package commands
 
 import (
-	"os"
 	"os/exec"
+
+	"commandripple/internal/commands/syntax"
+	"commandripple/internal/commands/ulimit"
 )
 
-// ExecuteExternal executes external commands using cmd.exe /c on Windows.
-func ExecuteExternal(cmdName string, args []string) error {
-	cmd := exec.Command("cmd", "/c", cmdName)
-	cmd.Args = append(cmd.Args, args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+// ExecuteExternal executes external commands, using cmd.exe /c on Windows.
+func (s *Session) ExecuteExternal(cmdName string, args []string) error {
+	cmd := s.externalOrPlugin(cmdName, args)
+	cmd.Stdin = s.Stdin
+	cmd.Stdout = s.Stdout
+	cmd.Stderr = s.Stderr
+
+	job := s.newJob(syntax.Join(append([]string{cmdName}, args...)))
+	if err := job.start(cmd); err != nil {
+		return err
+	}
+	return s.waitForeground(job, false)
+}
+
+// runProgram runs a program for a builtin such as ping as a foreground job,
+// so that it gets the process group and limits of the command line
+func (s *Session) runProgram(name string, args ...string) error {
+	job := s.newJob(syntax.Join(append([]string{name}, args...)))
+	if err := job.start(s.command(name, args...)); err != nil {
+		return err
+	}
+	return s.waitForeground(job, false)
+}
+
+// startProcess starts a command in the directory and scoped environment of
+// the session with the configured resource limits, which are set before the
+// program runs
+func (s *Session) startProcess(cmd *exec.Cmd) error {
+	s.prepare(cmd)
+
+	if s.group != nil {
+		setProcessGroup(cmd)
+	}
+
+	start := cmd.Start
+	if s.group != nil {
+		start = func() error { return s.group.start(cmd) }
+	}
+	return ulimit.Start(cmd, s.ulimits.Current().Merge(s.limits), start)
 }
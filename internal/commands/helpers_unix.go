@@ -0,0 +1,19 @@
+//go:build !windows
+// +build !windows
+
+package commands
+
+import (
+	"os/exec"
+)
+
+func externalCommand(s *Session, cmdName string, args []string) *exec.Cmd {
+	return s.command(cmdName, args...)
+}
+
+// nohupCommand runs a command through nohup(1), which ignores SIGHUP before
+// it executes the command in its place. A Go program cannot change the
+// signals of a child between fork and exec.
+func nohupCommand(s *Session, args []string) *exec.Cmd {
+	return s.command("nohup", args...)
+}
@@ -0,0 +1,17 @@
+//go:build windows
+// +build windows
+
+package commands
+
+import (
+	"os/exec"
+)
+
+func externalCommand(s *Session, cmdName string, args []string) *exec.Cmd {
+	return s.command("cmd", append([]string{"/c", cmdName}, args...)...)
+}
+
+// nohupCommand runs a command as is, there are no hangups on Windows
+func nohupCommand(s *Session, args []string) *exec.Cmd {
+	return externalCommand(s, args[0], args[1:])
+}
@@ -0,0 +1,146 @@
+package commands
+
+import (
+	"os"
+	"strings"
+
+	"commandripple/internal/commands/syntax"
+	"commandripple/internal/commands/theme"
+	"commandripple/internal/commands/which"
+)
+
+const underline = "\033[4m"
+
+// SuggestionColor returns the color of autosuggestions
+func SuggestionColor() string {
+	return theme.Interactive(theme.Suggestion)
+}
+
+// HighlightLine colors a command line as it is typed. The line is split by
+// the tokenizer that runs it and commands are looked up like `which` does,
+// so a red command name is one that would not be found. Arguments that
+// look like paths but do not exist are underlined.
+func (s *Session) HighlightLine(line string) string {
+	if !s.settings.Bool("colors.highlight") {
+		return line
+	}
+	tokens, _ := syntax.Tokenize(line, s.LookupEnv)
+	colors := make([]string, len(line))
+	underlined := make([]bool, len(line))
+	paint := func(start, end int, color string) {
+		for i := start; i < end; i++ {
+			colors[i] = color
+		}
+	}
+
+	commandNext := true
+	for i, token := range tokens {
+		switch token.Kind {
+		case syntax.Pipe:
+			paint(token.Start, token.End, theme.Interactive(theme.Pipe))
+			commandNext = true
+			continue
+		case syntax.Redirect:
+			paint(token.Start, token.End, theme.Interactive(theme.Redirect))
+			continue
+		case syntax.Unsupported:
+			// The line will not run, but the command after it is still
+			// highlighted as one
+			paint(token.Start, token.End, theme.Interactive(theme.Unknown))
+			commandNext = true
+			continue
+		}
+
+		afterRedirect := i > 0 && tokens[i-1].Kind == syntax.Redirect
+		switch {
+		case afterRedirect:
+			// Only input files have to exist
+			if tokens[i-1].Text == "<" && !s.pathExists(token.Value) {
+				mark(underlined, token.Start, token.End)
+			}
+		case commandNext && isAssignment(token.Text):
+			name, _, _ := strings.Cut(token.Text, "=")
+			paint(token.Start, token.Start+len(name), theme.Interactive(theme.Variable))
+		case commandNext:
+			commandNext = false
+			if s.commandExists(token.Value) {
+				paint(token.Start, token.End, theme.Interactive(theme.Command))
+			} else {
+				paint(token.Start, token.End, theme.Interactive(theme.Unknown))
+			}
+		case looksLikePath(token.Text) && !s.pathExists(token.Value):
+			mark(underlined, token.Start, token.End)
+		}
+
+		// Variables inside double quotes come before the quotes that hold
+		// them, so strings are painted first
+		for _, part := range token.Parts {
+			switch part.Kind {
+			case syntax.SingleQuoted, syntax.DoubleQuoted:
+				paint(part.Start, part.End, theme.Interactive(theme.String))
+			case syntax.Escape:
+				paint(part.Start, part.End, theme.Interactive(theme.Escape))
+			}
+		}
+		for _, part := range token.Parts {
+			if part.Kind == syntax.Variable {
+				paint(part.Start, part.End, theme.Interactive(theme.Variable))
+			}
+		}
+	}
+
+	var b strings.Builder
+	current, currentUnderline := "", false
+	for i := 0; i < len(line); i++ {
+		if colors[i] != current || underlined[i] != currentUnderline {
+			if current != "" || currentUnderline {
+				b.WriteString(Reset)
+			}
+			if underlined[i] {
+				b.WriteString(underline)
+			}
+			b.WriteString(colors[i])
+			current, currentUnderline = colors[i], underlined[i]
+		}
+		b.WriteByte(line[i])
+	}
+	if current != "" || currentUnderline {
+		b.WriteString(Reset)
+	}
+	return b.String()
+}
+
+func mark(underlined []bool, start, end int) {
+	for i := start; i < end; i++ {
+		underlined[i] = true
+	}
+}
+
+// commandExists reports whether a command name would run
+func (s *Session) commandExists(name string) bool {
+	if IsBuiltinCommand(name) {
+		return true
+	}
+	if _, ok := s.lookupAlias(name); ok {
+		return true
+	}
+	if _, ok := s.lookupPlugin(name); ok {
+		return true
+	}
+	_, err := which.Lookup(name, s.Dir(), s.Getenv("PATH"))
+	return err == nil
+}
+
+// looksLikePath reports whether an argument is meant to name a file,
+// leaving out options and URLs
+func looksLikePath(word string) bool {
+	if strings.HasPrefix(word, "-") || strings.Contains(word, "://") {
+		return false
+	}
+	return strings.ContainsRune(word, os.PathSeparator) || strings.ContainsRune(word, '/') || strings.HasPrefix(word, ".")
+}
+
+func (s *Session) pathExists(path string) bool {
+	_, err := os.Stat(s.path(path))
+	return err == nil
+}
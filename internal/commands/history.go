@@ -0,0 +1,253 @@
+package commands
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"commandripple/internal/commands/history"
+	"commandripple/internal/commands/redact"
+)
+
+// InitHistory opens the persistent history, at history.path from the
+// config or in the data directory, and trims it to history.size entries.
+// It returns the store so that the line editor can be seeded with earlier
+// commands.
+func (s *Session) InitHistory() (*history.Store, error) {
+	path := expandHome(s.settings.String("history.path"))
+	if path == "" {
+		var err error
+		if path, err = history.DefaultPath(); err != nil {
+			return nil, err
+		}
+	}
+	store, err := history.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if size := s.settings.Int("history.size"); size > 0 {
+		if err := store.Trim(size); err != nil {
+			return nil, err
+		}
+	}
+	s.history = store
+	return store, nil
+}
+
+// HistoryStore returns the persistent history, nil if it could not be
+// opened
+func (s *Session) HistoryStore() *history.Store {
+	return s.history
+}
+
+// RecordHistory completes an entry started before the command line ran with
+// its exit status and duration and saves it with secrets redacted
+func (s *Session) RecordHistory(entry history.Entry, err error) {
+	if s.history == nil {
+		return
+	}
+	entry.Command = RedactSecrets(entry.Command)
+	entry.ExitCode = ExitCode(err)
+	entry.Duration = time.Since(entry.Time)
+	if err := s.history.Add(entry); err != nil {
+		fmt.Fprintf(s.Stderr, "CommandRipple: %v\n", err)
+	}
+}
+
+// ExpandHistory applies history expansion such as !!, !$ or ^old^new to a
+// command line and reports whether it changed
+func (s *Session) ExpandHistory(line string) (string, bool, error) {
+	var previous []string
+	if s.history != nil {
+		for _, entry := range s.history.Entries() {
+			previous = append(previous, entry.Command)
+		}
+	}
+	return history.Expand(line, previous)
+}
+
+// SuggestCommand returns the rest of the most recent history entry starting
+// with prefix, preferring commands that ran in the current directory and
+// then those that succeeded. Entries with redacted secrets are left out
+// since they would not run as recorded.
+func (s *Session) SuggestCommand(prefix string) string {
+	if s.history == nil || strings.TrimSpace(prefix) == "" || !s.settings.Bool("completion.autosuggest") {
+		return ""
+	}
+	dir := s.Dir()
+
+	best, bestRank := "", -1
+	entries := s.history.Entries()
+	for i := len(entries) - 1; i >= 0 && bestRank < 3; i-- {
+		command := entries[i].Command
+		if len(command) <= len(prefix) || !strings.HasPrefix(command, prefix) ||
+			strings.ContainsRune(command, '\n') || strings.Contains(command, redact.Mask) {
+			continue
+		}
+		rank := 0
+		if entries[i].Dir == dir {
+			rank += 2
+		}
+		if entries[i].ExitCode == 0 {
+			rank++
+		}
+		if rank > bestRank {
+			best, bestRank = command, rank
+		}
+	}
+	return strings.TrimPrefix(best, prefix)
+}
+
+type historyFilter struct {
+	Since   time.Time
+	Until   time.Time
+	Dir     string
+	Status  *int
+	Failed  bool
+	Session string
+	Limit   int
+}
+
+func (f historyFilter) matches(entry history.Entry) bool {
+	switch {
+	case !f.Since.IsZero() && entry.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && entry.Time.After(f.Until):
+		return false
+	case f.Dir != "" && entry.Dir != f.Dir:
+		return false
+	case f.Status != nil && entry.ExitCode != *f.Status:
+		return false
+	case f.Failed && entry.ExitCode == 0:
+		return false
+	case f.Session != "" && entry.Session != f.Session:
+		return false
+	}
+	return true
+}
+
+// `history` command implementation: lists earlier command lines with their
+// position, filtered by time, directory, exit status or session, e.g.
+// `history --since 2h --failed`, `history --here 20`, `history -d 42`.
+// `history scrub [FILE...]` applies the redaction rules to the stored
+// history and to log files such as commandripple.log.
+func (s *Session) ShowHistory(args []string) error {
+	if s.history == nil {
+		return fmt.Errorf("history is not available")
+	}
+
+	var filter historyFilter
+	verbose := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "-c", "--clear":
+			return s.history.Clear()
+		case "scrub":
+			return s.scrubHistory(args[i+1:])
+		case "-v", "--verbose":
+			verbose = true
+			continue
+		case "--here":
+			filter.Dir = s.Dir()
+			continue
+		case "--failed":
+			filter.Failed = true
+			continue
+		case "--session":
+			filter.Session = s.history.Session()
+			continue
+		}
+
+		if n, err := strconv.Atoi(arg); err == nil && n > 0 {
+			filter.Limit = n
+			continue
+		}
+
+		needsValue := arg == "-d" || arg == "--since" || arg == "--until" || arg == "--dir" || arg == "--status"
+		if !needsValue {
+			return fmt.Errorf("usage: history [N] [-v] [--since T] [--until T] [--dir DIR|--here] [--status N|--failed] [--session] | history -c | history -d N | history scrub [FILE...]")
+		}
+		if i+1 >= len(args) {
+			return fmt.Errorf("missing value for %s", arg)
+		}
+		value := args[i+1]
+		i++
+
+		switch arg {
+		case "-d":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid history position: %s", value)
+			}
+			return s.history.Delete(n)
+		case "--since", "--until":
+			t, err := parseHistoryTime(value)
+			if err != nil {
+				return err
+			}
+			if arg == "--since" {
+				filter.Since = t
+			} else {
+				filter.Until = t
+			}
+		case "--dir":
+			dir, err := filepath.Abs(s.path(value))
+			if err != nil {
+				return err
+			}
+			filter.Dir = dir
+		case "--status":
+			code, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid exit status: %s", value)
+			}
+			filter.Status = &code
+		}
+	}
+
+	type numbered struct {
+		n     int
+		entry history.Entry
+	}
+	var matches []numbered
+	for i, entry := range s.history.Entries() {
+		if filter.matches(entry) {
+			matches = append(matches, numbered{i + 1, entry})
+		}
+	}
+	if filter.Limit > 0 && len(matches) > filter.Limit {
+		matches = matches[len(matches)-filter.Limit:]
+	}
+
+	for _, m := range matches {
+		if !verbose {
+			fmt.Fprintf(s.Stdout, "%5d  %s  %s\n", m.n, m.entry.Time.Format("2006-01-02 15:04:05"), m.entry.Command)
+			continue
+		}
+		fmt.Fprintf(s.Stdout, "%5d  %s  %3d  %8s  %s  %s\n",
+			m.n,
+			m.entry.Time.Format("2006-01-02 15:04:05"),
+			m.entry.ExitCode,
+			m.entry.Duration.Round(time.Millisecond),
+			m.entry.Dir,
+			m.entry.Command)
+	}
+	return nil
+}
+
+// parseHistoryTime accepts a duration meaning that long ago, e.g. 2h or 1d,
+// a date or a date and time
+func parseHistoryTime(value string) (time.Time, error) {
+	if d, err := parseTimeoutDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time: %s (expected a duration such as 2h or a date)", strings.TrimSpace(value))
+}
@@ -0,0 +1,256 @@
+package history
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Expand performs csh-style history expansion on a command line using the
+// earlier command lines, oldest first:
+//
+//	!!          the previous command      !n    command n
+//	!-n         n commands back           !str  the last command starting with str
+//	!?str?      the last command containing str
+//	!$ !^ !*    last, first and all arguments of the previous command
+//	:N :^ :$ :* :N-M  select words of the event, :h and :t keep the head or tail of a path
+//	^old^new    the previous command with old replaced by new
+//
+// It reports whether anything was expanded. Text in single quotes and
+// escaped \! are left alone.
+func Expand(line string, previous []string) (string, bool, error) {
+	if strings.HasPrefix(line, "^") {
+		return quickSubstitute(line, previous)
+	}
+	if !strings.Contains(line, "!") {
+		return line, false, nil
+	}
+
+	var out strings.Builder
+	expanded := false
+	inQuote := false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\'':
+			inQuote = !inQuote
+		case c == '\\' && i+1 < len(line) && line[i+1] == '!' && !inQuote:
+			out.WriteByte('!')
+			i++
+			continue
+		case c == '!' && !inQuote && i+1 < len(line) && !strings.ContainsRune(" \t=(", rune(line[i+1])):
+			text, n, err := expandEvent(line[i+1:], previous)
+			if err != nil {
+				return "", false, err
+			}
+			out.WriteString(text)
+			i += n
+			expanded = true
+			continue
+		}
+		out.WriteByte(c)
+	}
+	return out.String(), expanded, nil
+}
+
+// expandEvent expands the reference following a "!" and returns the text
+// and the number of bytes of spec it used
+func expandEvent(spec string, previous []string) (string, int, error) {
+	var event string
+	var n int
+	var err error
+
+	switch c := spec[0]; {
+	case c == '!':
+		event, err = lookupEvent(previous, len(previous), "!!")
+		n = 1
+	case c == '$' || c == '^' || c == '*':
+		// Shorthands for !!:$, !!:^ and !!:*
+		event, err = lookupEvent(previous, len(previous), "!"+spec[:1])
+		if err != nil {
+			return "", 0, err
+		}
+		words, err := selectWords(event, spec[:1])
+		if err != nil {
+			return "", 0, fmt.Errorf("!%s: %v", spec[:1], err)
+		}
+		text, used, err := applyModifiers(words, spec[1:])
+		return text, 1 + used, err
+	case c == '?':
+		end := strings.IndexByte(spec[1:], '?')
+		search := spec[1:]
+		n = len(spec)
+		if end >= 0 {
+			search = spec[1 : end+1]
+			n = end + 2
+		}
+		event, err = findEvent(previous, "!?"+search, func(command string) bool {
+			return strings.Contains(command, search)
+		})
+	case c == '-' || (c >= '0' && c <= '9'):
+		n = 1
+		for n < len(spec) && spec[n] >= '0' && spec[n] <= '9' {
+			n++
+		}
+		number, convErr := strconv.Atoi(spec[:n])
+		if convErr != nil {
+			return "", 0, fmt.Errorf("!%s: event not found", spec[:n])
+		}
+		position := number
+		if number < 0 {
+			position = len(previous) + number + 1
+		}
+		event, err = lookupEvent(previous, position, "!"+spec[:n])
+	default:
+		n = strings.IndexAny(spec, " \t:")
+		if n < 0 {
+			n = len(spec)
+		}
+		prefix := spec[:n]
+		event, err = findEvent(previous, "!"+prefix, func(command string) bool {
+			return strings.HasPrefix(command, prefix)
+		})
+	}
+	if err != nil {
+		return "", 0, err
+	}
+
+	// Optional word designator and modifiers
+	rest := spec[n:]
+	if !strings.HasPrefix(rest, ":") || len(rest) < 2 {
+		return event, n, nil
+	}
+	if designator := wordDesignator(rest[1:]); designator != "" {
+		words, err := selectWords(event, designator)
+		if err != nil {
+			return "", 0, fmt.Errorf("!%s:%s: %v", spec[:n], designator, err)
+		}
+		text, used, err := applyModifiers(words, rest[1+len(designator):])
+		return text, n + 1 + len(designator) + used, err
+	}
+	text, used, err := applyModifiers(event, rest)
+	return text, n + used, err
+}
+
+// lookupEvent returns command number position, counting from 1
+func lookupEvent(previous []string, position int, spec string) (string, error) {
+	if position < 1 || position > len(previous) {
+		return "", fmt.Errorf("%s: event not found", spec)
+	}
+	return previous[position-1], nil
+}
+
+// findEvent returns the most recent command accepted by match
+func findEvent(previous []string, spec string, match func(string) bool) (string, error) {
+	for i := len(previous) - 1; i >= 0; i-- {
+		if match(previous[i]) {
+			return previous[i], nil
+		}
+	}
+	return "", fmt.Errorf("%s: event not found", spec)
+}
+
+// wordDesignator returns the word designator at the start of s, if any:
+// a number, a range N-M, ^, $ or *
+func wordDesignator(s string) string {
+	if s[0] == '^' || s[0] == '$' || s[0] == '*' {
+		return s[:1]
+	}
+	n := 0
+	for n < len(s) && (s[n] >= '0' && s[n] <= '9' || s[n] == '-' || s[n] == '$') {
+		n++
+	}
+	return s[:n]
+}
+
+// selectWords returns the words of a command chosen by a designator; word 0
+// is the command name
+func selectWords(command, designator string) (string, error) {
+	words := strings.Fields(command)
+	last := len(words) - 1
+
+	switch designator {
+	case "^":
+		designator = "1"
+	case "$":
+		designator = strconv.Itoa(last)
+	case "*":
+		if last < 1 {
+			return "", nil
+		}
+		designator = "1-$"
+	}
+
+	from, to, isRange := strings.Cut(designator, "-")
+	parse := func(s string, fallback int) (int, error) {
+		if s == "" {
+			return fallback, nil
+		}
+		if s == "$" {
+			return last, nil
+		}
+		return strconv.Atoi(s)
+	}
+	start, err := parse(from, 0)
+	if err != nil {
+		return "", fmt.Errorf("bad word specifier")
+	}
+	end := start
+	if isRange {
+		if end, err = parse(to, last); err != nil {
+			return "", fmt.Errorf("bad word specifier")
+		}
+	}
+	if start < 0 || end > last || start > end {
+		return "", fmt.Errorf("bad word specifier")
+	}
+	return strings.Join(words[start:end+1], " "), nil
+}
+
+// applyModifiers applies the :h and :t modifiers at the start of s and
+// returns the number of bytes they used
+func applyModifiers(text, s string) (string, int, error) {
+	used := 0
+	for len(s) >= 2 && s[0] == ':' {
+		switch s[1] {
+		case 'h':
+			if i := strings.LastIndexByte(text, '/'); i > 0 {
+				text = text[:i]
+			} else if i == 0 {
+				text = "/"
+			}
+		case 't':
+			if i := strings.LastIndexByte(text, '/'); i >= 0 {
+				text = text[i+1:]
+			}
+		default:
+			return "", 0, fmt.Errorf(":%c: unrecognized history modifier", s[1])
+		}
+		s = s[2:]
+		used += 2
+	}
+	return text, used, nil
+}
+
+// quickSubstitute handles ^old^new^, which repeats the previous command with
+// the first occurrence of old replaced by new
+func quickSubstitute(line string, previous []string) (string, bool, error) {
+	parts := strings.SplitN(line[1:], "^", 3)
+	if len(parts) < 2 || parts[0] == "" {
+		return "", false, fmt.Errorf("%s: bad substitution", line)
+	}
+	old, replacement := parts[0], parts[1]
+	suffix := ""
+	if len(parts) == 3 {
+		suffix = parts[2]
+	}
+
+	event, err := lookupEvent(previous, len(previous), "^"+old)
+	if err != nil {
+		return "", false, err
+	}
+	if !strings.Contains(event, old) {
+		return "", false, fmt.Errorf("^%s^%s: substitution failed", old, replacement)
+	}
+	return strings.Replace(event, old, replacement, 1) + suffix, true, nil
+}
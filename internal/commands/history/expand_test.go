@@ -0,0 +1,84 @@
+package history
+
+import "testing"
+
+func TestExpand(t *testing.T) {
+	previous := []string{
+		"ls -l /usr/local/bin",
+		"git commit -m fix",
+		"cp a.txt /tmp/backup/a.txt",
+	}
+
+	tests := []struct {
+		line     string
+		want     string
+		expanded bool
+	}{
+		{"echo hi", "echo hi", false},
+		{"!!", "cp a.txt /tmp/backup/a.txt", true},
+		{"sudo !!", "sudo cp a.txt /tmp/backup/a.txt", true},
+		{"!1", "ls -l /usr/local/bin", true},
+		{"!-2", "git commit -m fix", true},
+		{"!git", "git commit -m fix", true},
+		{"!l --color", "ls -l /usr/local/bin --color", true},
+		{"!?commit?", "git commit -m fix", true},
+		{"!?local", "ls -l /usr/local/bin", true},
+		{"cat !$", "cat /tmp/backup/a.txt", true},
+		{"cat !^", "cat a.txt", true},
+		{"echo !*", "echo a.txt /tmp/backup/a.txt", true},
+		{"echo !1:0", "echo ls", true},
+		{"echo !2:1-2", "echo commit -m", true},
+		{"echo !2:2-", "echo -m fix", true},
+		{"echo !2:-1", "echo git commit", true},
+		{"echo !1:$", "echo /usr/local/bin", true},
+		{"cd !$:h", "cd /tmp/backup", true},
+		{"echo !$:t", "echo a.txt", true},
+		{"echo !1:2:h:h", "echo /usr", true},
+		{"echo 'a!!b'", "echo 'a!!b'", false},
+		{`echo \!!`, "echo !!", false},
+		{"echo ! x", "echo ! x", false},
+		{"[ ! -f x ]", "[ ! -f x ]", false},
+		{"echo hi!", "echo hi!", false},
+		{"^a.txt^b.txt", "cp b.txt /tmp/backup/a.txt", true},
+		{"^a.txt^b.txt^ -v", "cp b.txt /tmp/backup/a.txt -v", true},
+		{"^cp^", " a.txt /tmp/backup/a.txt", true},
+	}
+	for _, tt := range tests {
+		got, expanded, err := Expand(tt.line, previous)
+		if err != nil {
+			t.Errorf("Expand(%q) failed: %v", tt.line, err)
+			continue
+		}
+		if got != tt.want || expanded != tt.expanded {
+			t.Errorf("Expand(%q) = %q, %v, want %q, %v", tt.line, got, expanded, tt.want, tt.expanded)
+		}
+	}
+}
+
+func TestExpandInvalid(t *testing.T) {
+	previous := []string{"ls -l", "make test"}
+
+	tests := []string{
+		"!5",
+		"!0",
+		"!-3",
+		"!nothing",
+		"!?nothing?",
+		"!1:5",
+		"!1:2-1",
+		"!!:x",
+		"!$:q",
+		"^missing^x",
+		"^^x",
+		"^",
+	}
+	for _, line := range tests {
+		if got, _, err := Expand(line, previous); err == nil {
+			t.Errorf("Expand(%q) = %q, want an error", line, got)
+		}
+	}
+
+	if _, _, err := Expand("!!", nil); err == nil {
+		t.Error("Expand(\"!!\") without history succeeded")
+	}
+}
@@ -0,0 +1,310 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"commandripple/internal/commands/filelock"
+)
+
+// Entry is one command line in the history
+type Entry struct {
+	Command  string        `json:"command"`
+	Time     time.Time     `json:"time"`
+	Dir      string        `json:"dir"`
+	ExitCode int           `json:"exit_code"`
+	Duration time.Duration `json:"duration"`
+	Hostname string        `json:"hostname"`
+	Session  string        `json:"session"`
+}
+
+// NewEntry starts an entry for a command line about to run in the current
+// directory
+func NewEntry(command string) Entry {
+	dir, _ := os.Getwd()
+	return Entry{Command: command, Time: time.Now(), Dir: dir}
+}
+
+// Store is the persistent history shared by every shell session, kept as
+// one JSON entry per line. Sessions take a lock on a file beside it while
+// they change it, so that none loses what another added.
+type Store struct {
+	path     string
+	session  string
+	hostname string
+
+	mutex   sync.Mutex
+	entries []Entry
+}
+
+// DefaultPath returns the history file in the user's data directory:
+// $XDG_DATA_HOME/commandripple/history.jsonl, ~/.local/share/commandripple
+// or %LocalAppData%\commandripple on Windows
+func DefaultPath() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "commandripple", "history.jsonl"), nil
+	}
+	if runtime.GOOS == "windows" {
+		if dir := os.Getenv("LocalAppData"); dir != "" {
+			return filepath.Join(dir, "commandripple", "history.jsonl"), nil
+		}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the data directory: %v", err)
+	}
+	return filepath.Join(home, ".local", "share", "commandripple", "history.jsonl"), nil
+}
+
+// Open loads the history at path, creating its directory when needed
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %v", err)
+	}
+
+	hostname, _ := os.Hostname()
+	s := &Store{
+		path:     path,
+		session:  strconv.FormatInt(time.Now().UnixNano(), 36) + "-" + strconv.Itoa(os.Getpid()),
+		hostname: hostname,
+	}
+
+	entries, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	s.entries = entries
+	return s, nil
+}
+
+// Session returns the ID of this shell session
+func (s *Store) Session() string {
+	return s.session
+}
+
+// Add records an entry, filling in the hostname and session
+func (s *Store) Add(entry Entry) error {
+	entry.Hostname = s.hostname
+	entry.Session = s.session
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.entries = append(s.entries, entry)
+
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to save history: %v", err)
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// Entries returns every entry, oldest first. Entry n of `history` is at
+// index n-1.
+func (s *Store) Entries() []Entry {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]Entry(nil), s.entries...)
+}
+
+// Clear removes every entry
+func (s *Store) Clear() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	s.entries = nil
+	return s.save(nil)
+}
+
+// Delete removes entry n of Entries, counting from 1. The file may hold
+// entries other sessions added since, so the entry is found by its time,
+// session and command rather than its position.
+func (s *Store) Delete(n int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if n < 1 || n > len(s.entries) {
+		return fmt.Errorf("history position out of range: %d", n)
+	}
+	target := s.entries[n-1]
+
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	entries, err := s.load()
+	if err != nil {
+		return err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if sameEntry(entries[i], target) {
+			entries = append(entries[:i], entries[i+1:]...)
+			if err := s.save(entries); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	// Gone from the file already when another session removed it
+	s.entries = append(s.entries[:n-1:n-1], s.entries[n:]...)
+	return nil
+}
+
+func sameEntry(a, b Entry) bool {
+	return a.Time.Equal(b.Time) && a.Session == b.Session && a.Command == b.Command
+}
+
+// Rewrite replaces every entry with the result of fn, dropping the entries
+// for which it returns false, and returns the number of entries changed or
+// dropped
+func (s *Store) Rewrite(fn func(Entry) (Entry, bool)) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	unlock, err := s.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	// Other sessions may have added entries since the file was loaded
+	entries, err := s.load()
+	if err != nil {
+		return 0, err
+	}
+
+	changed := 0
+	rewritten := entries[:0]
+	for _, entry := range entries {
+		updated, keep := fn(entry)
+		if !keep || updated != entry {
+			changed++
+		}
+		if keep {
+			rewritten = append(rewritten, updated)
+		}
+	}
+	if changed == 0 {
+		s.entries = rewritten
+		return 0, nil
+	}
+	if err := s.save(rewritten); err != nil {
+		return 0, err
+	}
+	s.entries = rewritten
+	return changed, nil
+}
+
+// Trim drops the oldest entries when there are more than max
+func (s *Store) Trim(max int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	entries, err := s.load()
+	if err != nil || len(entries) <= max {
+		return err
+	}
+	entries = entries[len(entries)-max:]
+	if err := s.save(entries); err != nil {
+		return err
+	}
+	s.entries = entries
+	return nil
+}
+
+// lock takes the lock on the history file shared by the sessions, which
+// the returned function releases
+func (s *Store) lock() (func(), error) {
+	f, err := filelock.Lock(s.path + ".lock")
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock history: %v", err)
+	}
+	return func() { f.Close() }, nil
+}
+
+func (s *Store) load() ([]Entry, error) {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %v", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		// Skip lines cut short by a crash instead of losing the history
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// save writes the entries through a temporary file so that a failure never
+// leaves a truncated history. Callers must hold the lock.
+func (s *Store) save(entries []Entry) error {
+	f, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to save history: %v", err)
+	}
+	tmp := f.Name()
+
+	w := bufio.NewWriter(f)
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			f.Close()
+			os.Remove(tmp)
+			return err
+		}
+		w.Write(append(data, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to save history: %v", err)
+	}
+	return nil
+}
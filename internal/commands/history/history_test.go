@@ -0,0 +1,112 @@
+package history
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestDeleteInterleavedSessions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	a, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, step := range []struct {
+		store   *Store
+		command string
+	}{
+		{a, "a1"}, {b, "b1"}, {a, "a2"}, {b, "b2"}, {a, "a3"},
+	} {
+		if err := step.store.Add(NewEntry(step.command)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Session a sees a1 a2 a3, so entry 2 is a2 even though the file holds
+	// b1 in that position
+	if err := a.Delete(2); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := commands(a.Entries()), "a1 a3"; got != want {
+		t.Errorf("entries of the session = %q, want %q", got, want)
+	}
+	entries, err := a.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := commands(entries), "a1 b1 b2 a3"; got != want {
+		t.Errorf("entries in the file = %q, want %q", got, want)
+	}
+
+	if err := a.Delete(3); err == nil {
+		t.Error("Delete(3) of 2 entries succeeded")
+	}
+}
+
+func TestConcurrentSessionsKeepEveryEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	a, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 50
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			if err := a.Add(NewEntry(fmt.Sprint("a", i))); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		// Rewriting while a appends must not drop a's entries
+		for i := 0; i < n; i++ {
+			dir := fmt.Sprint("dir", i)
+			if _, err := b.Rewrite(func(e Entry) (Entry, bool) { e.Dir = dir; return e, true }); err != nil {
+				t.Error(err)
+			}
+			if err := b.Trim(10 * n); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	wg.Wait()
+
+	entries, err := a.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != n {
+		t.Errorf("file holds %d entries, want %d", len(entries), n)
+	}
+	matches, _ := filepath.Glob(path + ".*.tmp")
+	if len(matches) != 0 {
+		t.Errorf("temporary files left behind: %v", matches)
+	}
+}
+
+func commands(entries []Entry) string {
+	var s string
+	for i, e := range entries {
+		if i > 0 {
+			s += " "
+		}
+		s += e.Command
+	}
+	return s
+}
@@ -0,0 +1,67 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// sigContinue is never delivered, jobs cannot be stopped on this platform
+const sigContinue = syscall.Signal(-1)
+
+type terminalState struct{}
+
+// jobControl is never set, there are no process groups to hand the
+// terminal to
+const jobControl = false
+
+// InitJobControl does nothing, job control needs Unix process groups
+func InitJobControl() {}
+
+func setJobProcessGroup(cmd *exec.Cmd, pgid int) {}
+
+// monitor waits for the process to exit, processes cannot be stopped here
+func (j *JobInfo) monitor(p *jobProcess) {
+	err := p.cmd.Wait()
+
+	j.table.mutex.Lock()
+	p.exited = true
+	p.err = err
+	j.updateStatus()
+	j.table.mutex.Unlock()
+}
+
+func giveTerminal(j *JobInfo) {}
+
+func takeTerminal(j *JobInfo) {}
+
+// signalJob terminates every process of the job, other signals cannot be
+// delivered on this platform
+func signalJob(j *JobInfo, sig syscall.Signal) error {
+	if sig == sigContinue {
+		return fmt.Errorf("job control is not supported on this platform")
+	}
+
+	j.table.mutex.Lock()
+	pids := j.pids()
+	j.table.mutex.Unlock()
+
+	for _, pid := range pids {
+		if err := sendSignal(pid, sig); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sendSignal(pid int, sig syscall.Signal) error {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return process.Kill()
+}
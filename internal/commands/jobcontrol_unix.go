@@ -0,0 +1,188 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const sigContinue = syscall.SIGCONT
+
+var (
+	jobControl bool // Set when the shell owns an interactive terminal
+	shellPgid  int
+	ttyFd      int
+	shellTerm  *terminalState
+)
+
+type terminalState struct {
+	termios *unix.Termios
+}
+
+// InitJobControl puts the shell in its own process group and takes the
+// terminal, so that jobs can be moved between foreground and background.
+// It does nothing when standard input is not a terminal.
+func InitJobControl() {
+	fd := int(os.Stdin.Fd())
+	if _, err := unix.IoctlGetTermios(fd, ioctlGetTermios); err != nil {
+		return
+	}
+
+	// Wait until the shell is started in the foreground
+	for {
+		pgrp, err := unix.IoctlGetInt(fd, unix.TIOCGPGRP)
+		if err != nil || pgrp == syscall.Getpgrp() {
+			break
+		}
+		syscall.Kill(-syscall.Getpgrp(), syscall.SIGTTIN)
+	}
+
+	// Catching rather than ignoring the stop signals lets children start
+	// with the default actions. SIGTTOU must be ignored for the shell to
+	// take the terminal back from a job.
+	stopSignals := make(chan os.Signal, 1)
+	signal.Notify(stopSignals, syscall.SIGTSTP, syscall.SIGTTIN)
+	go func() {
+		for range stopSignals {
+		}
+	}()
+	signal.Ignore(syscall.SIGTTOU)
+
+	pid := os.Getpid()
+	if syscall.Getpgrp() != pid {
+		// Fails harmlessly when the shell is already a session leader
+		syscall.Setpgid(pid, pid)
+	}
+	shellPgid = syscall.Getpgrp()
+	ttyFd = fd
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPGRP, shellPgid); err != nil {
+		return
+	}
+	jobControl = true
+}
+
+func setJobProcessGroup(cmd *exec.Cmd, pgid int) {
+	if !jobControl {
+		return
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	cmd.SysProcAttr.Pgid = pgid
+}
+
+// monitor follows a process through stops, continues and its exit
+func (j *JobInfo) monitor(p *jobProcess) {
+	pid := p.cmd.Process.Pid
+	for {
+		var ws syscall.WaitStatus
+		_, err := syscall.Wait4(pid, &ws, syscall.WUNTRACED|syscall.WCONTINUED, nil)
+		if errors.Is(err, syscall.EINTR) {
+			continue
+		}
+		if err == nil && !ws.Stopped() && !ws.Continued() {
+			// The process is already reaped, this waits for the output
+			// exec.Cmd copies to writers that are not files before the
+			// job is reported done
+			p.cmd.Wait()
+		}
+
+		j.table.mutex.Lock()
+		switch {
+		case err != nil:
+			p.exited = true
+			p.err = err
+		case ws.Stopped():
+			p.stopped = true
+		case ws.Continued():
+			p.stopped = false
+		default:
+			p.exited = true
+			p.stopped = false
+			p.err = waitStatusError(ws)
+		}
+		exited := p.exited
+		j.updateStatus()
+		j.table.mutex.Unlock()
+
+		if exited {
+			return
+		}
+	}
+}
+
+func waitStatusError(ws syscall.WaitStatus) error {
+	switch {
+	case ws.Signaled():
+		return &ExitStatus{
+			Code:   128 + int(ws.Signal()),
+			Reason: fmt.Sprintf("signal: %v", ws.Signal()),
+			Signal: ws.Signal(),
+		}
+	case ws.ExitStatus() != 0:
+		return &ExitStatus{Code: ws.ExitStatus()}
+	}
+	return nil
+}
+
+// giveTerminal makes the job the terminal's foreground process group and
+// restores the terminal modes it had when it was stopped
+func giveTerminal(j *JobInfo) {
+	if !j.table.control() {
+		return
+	}
+	if termios, err := unix.IoctlGetTermios(ttyFd, ioctlGetTermios); err == nil {
+		shellTerm = &terminalState{termios: termios}
+	}
+	if j.termState != nil {
+		unix.IoctlSetTermios(ttyFd, ioctlSetTermios, j.termState.termios)
+	}
+	unix.IoctlSetPointerInt(ttyFd, unix.TIOCSPGRP, j.Pgid)
+}
+
+// takeTerminal returns the terminal to the shell, saving the job's terminal
+// modes in case it is resumed later
+func takeTerminal(j *JobInfo) {
+	if !j.table.control() {
+		return
+	}
+	unix.IoctlSetPointerInt(ttyFd, unix.TIOCSPGRP, shellPgid)
+	if termios, err := unix.IoctlGetTermios(ttyFd, ioctlGetTermios); err == nil {
+		j.termState = &terminalState{termios: termios}
+	}
+	if shellTerm != nil {
+		unix.IoctlSetTermios(ttyFd, ioctlSetTermios, shellTerm.termios)
+	}
+}
+
+// signalJob sends a signal to the job's process group, or to each of its
+// processes when they share the shell's process group
+func signalJob(j *JobInfo, sig syscall.Signal) error {
+	if j.Pgid != 0 && j.Pgid != shellPgid && j.table.control() {
+		return syscall.Kill(-j.Pgid, sig)
+	}
+
+	j.table.mutex.Lock()
+	pids := j.pids()
+	j.table.mutex.Unlock()
+
+	for _, pid := range pids {
+		if err := syscall.Kill(pid, sig); err != nil && !errors.Is(err, syscall.ESRCH) {
+			return err
+		}
+	}
+	return nil
+}
+
+func sendSignal(pid int, sig syscall.Signal) error {
+	return syscall.Kill(pid, sig)
+}
@@ -0,0 +1,205 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+// jobOutputLimit is the number of bytes of output kept for each background job
+const jobOutputLimit = 64 * 1024
+
+// jobOutput collects the stdout and stderr of a background job into a ring
+// buffer and an optional log file. While the job is in the foreground its
+// output is also passed through to the terminal.
+type jobOutput struct {
+	mutex    sync.Mutex
+	data     []byte    // The last jobOutputLimit bytes are the buffered output
+	total    int64     // Bytes written since the job started
+	replayed int64     // Bytes already shown on the terminal
+	attached io.Writer // Where the output is passed through to, if set
+	logFile  *os.File
+	closed   bool // Every writer is gone and the log file is closed
+	streams  sync.WaitGroup
+}
+
+// newJobOutput creates the job's output buffer, appending to logPath when set
+func newJobOutput(logPath string) (*jobOutput, error) {
+	o := &jobOutput{}
+	if logPath != "" {
+		logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open job log: %v", err)
+		}
+		o.logFile = logFile
+	}
+	return o, nil
+}
+
+// pipe returns a file for the child to write to and copies everything read
+// from it into the buffer
+func (o *jobOutput) pipe() (*os.File, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	o.streams.Add(1)
+	go func() {
+		defer o.streams.Done()
+		defer r.Close()
+		buf := make([]byte, 4096)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				o.write(buf[:n])
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return w, nil
+}
+
+func (o *jobOutput) write(p []byte) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	o.data = append(o.data, p...)
+	// Trim lazily so that appending stays cheap
+	if len(o.data) > 2*jobOutputLimit {
+		o.data = append([]byte(nil), o.data[len(o.data)-jobOutputLimit:]...)
+	}
+	o.total += int64(len(p))
+
+	if o.logFile != nil {
+		o.logFile.Write(p)
+	}
+	if o.attached != nil {
+		o.attached.Write(p)
+		o.replayed = o.total
+	}
+}
+
+// since returns the buffered output written after offset, which is moved
+// forward when the output it refers to has been dropped from the buffer
+func (o *jobOutput) since(offset int64) ([]byte, int64) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.sinceLocked(offset), o.total
+}
+
+func (o *jobOutput) sinceLocked(offset int64) []byte {
+	buffered := len(o.data)
+	if buffered > jobOutputLimit {
+		buffered = jobOutputLimit
+	}
+	if first := o.total - int64(buffered); offset < first {
+		offset = first
+	}
+	start := len(o.data) - int(o.total-offset)
+	return append([]byte(nil), o.data[start:]...)
+}
+
+// attach replays the output that has not been shown yet to w and passes
+// further output through to it until detach is called
+func (o *jobOutput) attach(w io.Writer) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	w.Write(o.sinceLocked(o.replayed))
+	o.replayed = o.total
+	o.attached = w
+}
+
+func (o *jobOutput) detach() {
+	o.mutex.Lock()
+	o.attached = nil
+	o.mutex.Unlock()
+}
+
+// closeWhenDrained closes the log file once every pipe returned by pipe has
+// been closed by the job and its descendants
+func (o *jobOutput) closeWhenDrained() {
+	go func() {
+		o.streams.Wait()
+
+		o.mutex.Lock()
+		defer o.mutex.Unlock()
+		if o.logFile != nil {
+			o.logFile.Close()
+		}
+		o.closed = true
+	}()
+}
+
+func (o *jobOutput) isClosed() bool {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.closed
+}
+
+// `joblog` command implementation: prints the buffered output of a
+// background job and keeps following it with -f until the job finishes or
+// Ctrl-C is pressed, e.g. `joblog 1 -f`
+func (s *Session) JobLog(args []string) error {
+	spec := ""
+	follow := false
+	for _, arg := range args {
+		if arg == "-f" || arg == "--follow" {
+			follow = true
+			continue
+		}
+		if spec != "" {
+			return fmt.Errorf("usage: joblog [%%job] [-f]")
+		}
+		spec = arg
+	}
+	s.jobs.mutex.Lock()
+	jobInfo, err := s.jobs.lookup(spec)
+	s.jobs.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	if jobInfo.output == nil {
+		return fmt.Errorf("the output of job %d was not captured, only jobs started with bg are", jobInfo.ID)
+	}
+
+	data, offset := jobInfo.output.since(0)
+	s.Stdout.Write(data)
+	if !follow {
+		return nil
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+
+	finished := false
+	for {
+		select {
+		case <-interrupt:
+			return nil
+		case <-ticker.C:
+		}
+
+		data, offset = jobInfo.output.since(offset)
+		s.Stdout.Write(data)
+
+		// Give the output one more tick to drain after the job finished,
+		// descendants may keep the pipes open long after that
+		if finished || jobInfo.output.isClosed() {
+			return nil
+		}
+		s.jobs.mutex.Lock()
+		finished = jobInfo.Status == JobDone
+		s.jobs.mutex.Unlock()
+	}
+}
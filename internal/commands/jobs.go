@@ -0,0 +1,615 @@
+package commands
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"commandripple/internal/commands/processes"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// Job states
+const (
+	JobRunning = "Running"
+	JobStopped = "Stopped"
+	JobDone    = "Completed"
+)
+
+// jobTable holds the jobs of a session
+type jobTable struct {
+	mutex    sync.Mutex
+	jobs     map[int]*JobInfo
+	counter  int   // Unique identifier for jobs
+	order    []int // Job IDs, most recently stopped or backgrounded last
+	changed  *sync.Cond
+	terminal bool // Jobs get the terminal when job control is enabled
+	// exitWarned is set after `exit` warned about remaining jobs, a second
+	// `exit` in a row then quits
+	exitWarned bool
+}
+
+func newJobTable(terminal bool) *jobTable {
+	t := &jobTable{jobs: make(map[int]*JobInfo), terminal: terminal}
+	t.changed = sync.NewCond(&t.mutex)
+	return t
+}
+
+// control reports whether jobs of the table get their own process groups
+// and the terminal
+func (t *jobTable) control() bool {
+	return t.terminal && jobControl
+}
+
+// JobInfo is a pipeline of processes sharing a process group
+type JobInfo struct {
+	ID        int
+	Cmd       *exec.Cmd // Last process of the pipeline
+	Command   string
+	Pgid      int
+	StartTime time.Time
+	EndTime   time.Time
+	Status    string
+	ExitCode  int            // Exit status of the last process once the job is done
+	Signal    syscall.Signal // Signal that killed the last process, if any
+
+	session   *Session // Started the job
+	table     *jobTable
+	processes []*jobProcess
+	termState *terminalState // Terminal modes saved when the job stopped
+	output    *jobOutput     // Captured output of jobs started with bg
+	reported  string         // Last status the user was notified about
+	noHangup  bool           // Not sent SIGHUP when the shell exits
+	launching bool           // Set while the stages of a pipeline are started
+	pending   []*jobProcess  // Started while launching, waited for after
+}
+
+type jobProcess struct {
+	cmd     *exec.Cmd
+	stopped bool
+	exited  bool
+	err     error // Exit error once the process has exited
+}
+
+func (s *Session) newJob(command string) *JobInfo {
+	return &JobInfo{
+		session:   s,
+		table:     s.jobs,
+		Command:   command,
+		StartTime: time.Now(),
+		Status:    JobRunning,
+	}
+}
+
+// start launches a process as part of the job, in the job's process group
+// when job control is enabled
+func (j *JobInfo) start(cmd *exec.Cmd) error {
+	if j.table.control() {
+		setJobProcessGroup(cmd, j.Pgid)
+	}
+	if err := j.session.startProcess(cmd); err != nil {
+		return err
+	}
+
+	p := &jobProcess{cmd: cmd}
+
+	j.table.mutex.Lock()
+	if j.Pgid == 0 {
+		j.Pgid = cmd.Process.Pid
+	}
+	j.Cmd = cmd
+	j.processes = append(j.processes, p)
+	j.table.mutex.Unlock()
+
+	if j.launching {
+		j.pending = append(j.pending, p)
+		return nil
+	}
+	go j.monitor(p)
+	return nil
+}
+
+// launched waits for the processes started while the pipeline was being
+// set up. Until then an exited process is not reaped, which keeps the
+// process group alive for the next stages to join.
+func (j *JobInfo) launched() {
+	j.launching = false
+	for _, p := range j.pending {
+		go j.monitor(p)
+	}
+	j.pending = nil
+}
+
+// updateStatus derives the job state from its processes and wakes up
+// waiters. Callers must hold the table's mutex.
+func (j *JobInfo) updateStatus() {
+	status := JobDone
+	for _, p := range j.processes {
+		if p.stopped {
+			status = JobStopped
+			break
+		}
+		if !p.exited {
+			status = JobRunning
+		}
+	}
+	j.Status = status
+	if status == JobDone && j.EndTime.IsZero() && len(j.processes) > 0 {
+		err := j.processes[len(j.processes)-1].err
+		j.EndTime = time.Now()
+		j.ExitCode = ExitCode(err)
+		j.Signal = syscall.Signal(exitSignal(err))
+	}
+	j.table.changed.Broadcast()
+}
+
+// err returns the result of the job's last process once it is done
+func (j *JobInfo) err() error {
+	if len(j.processes) == 0 {
+		return nil
+	}
+	return j.processes[len(j.processes)-1].err
+}
+
+// describeStatus returns the status shown in notifications and listings,
+// e.g. "Running", "Done" or "Done (exit 2)"
+func (j *JobInfo) describeStatus() string {
+	if j.Status != JobDone {
+		return j.Status
+	}
+	switch {
+	case j.Signal != 0:
+		return fmt.Sprintf("Done (signal: %v)", j.Signal)
+	case j.ExitCode != 0:
+		return fmt.Sprintf("Done (exit %d)", j.ExitCode)
+	}
+	return "Done"
+}
+
+// wait blocks until the job has stopped or finished
+func (j *JobInfo) wait() string {
+	j.table.mutex.Lock()
+	defer j.table.mutex.Unlock()
+
+	for j.Status == JobRunning {
+		j.table.changed.Wait()
+	}
+	return j.Status
+}
+
+func (j *JobInfo) pids() []int {
+	var pids []int
+	for _, p := range j.processes {
+		pids = append(pids, p.cmd.Process.Pid)
+	}
+	return pids
+}
+
+// register adds a job to the job table and makes it the current job.
+// Callers must hold t.mutex.
+func (t *jobTable) register(j *JobInfo) {
+	if j.ID == 0 {
+		t.counter++
+		j.ID = t.counter
+	}
+	t.jobs[j.ID] = j
+	t.markCurrent(j.ID)
+}
+
+// markCurrent moves a job to the end of the order, making it %+.
+// Callers must hold t.mutex.
+func (t *jobTable) markCurrent(id int) {
+	t.removeFromOrder(id)
+	t.order = append(t.order, id)
+}
+
+// remove deletes a job from the job table. Callers must hold t.mutex.
+func (t *jobTable) remove(j *JobInfo) {
+	delete(t.jobs, j.ID)
+	t.removeFromOrder(j.ID)
+}
+
+func (t *jobTable) removeFromOrder(id int) {
+	for i, other := range t.order {
+		if other == id {
+			t.order = append(t.order[:i], t.order[i+1:]...)
+			return
+		}
+	}
+}
+
+// marker returns "+" for the current job, "-" for the previous one.
+// Callers must hold t.mutex.
+func (t *jobTable) marker(id int) string {
+	n := len(t.order)
+	switch {
+	case n > 0 && t.order[n-1] == id:
+		return "+"
+	case n > 1 && t.order[n-2] == id:
+		return "-"
+	}
+	return " "
+}
+
+// lookupJob resolves a job spec: %n or n, %+ or %% for the current job,
+// %- for the previous job, %string for a job whose command starts with
+// string and %?string for one whose command contains it. Callers must hold
+// t.mutex.
+func (t *jobTable) lookup(spec string) (*JobInfo, error) {
+	n := len(t.order)
+	switch spec {
+	case "", "%", "%%", "%+":
+		if n == 0 {
+			return nil, fmt.Errorf("no current job")
+		}
+		return t.jobs[t.order[n-1]], nil
+	case "%-":
+		if n < 2 {
+			return nil, fmt.Errorf("no previous job")
+		}
+		return t.jobs[t.order[n-2]], nil
+	}
+
+	text := strings.TrimPrefix(spec, "%")
+	if id, err := strconv.Atoi(text); err == nil {
+		if job, exists := t.jobs[id]; exists {
+			return job, nil
+		}
+		return nil, fmt.Errorf("no such job: %s", spec)
+	}
+	if !strings.HasPrefix(spec, "%") {
+		return nil, fmt.Errorf("invalid job spec: %s", spec)
+	}
+
+	var match *JobInfo
+	for _, job := range t.jobs {
+		var matched bool
+		if contains, found := strings.CutPrefix(text, "?"); found {
+			matched = strings.Contains(job.Command, contains)
+		} else {
+			matched = strings.HasPrefix(job.Command, text)
+		}
+		if !matched {
+			continue
+		}
+		if match != nil {
+			return nil, fmt.Errorf("ambiguous job spec: %s", spec)
+		}
+		match = job
+	}
+	if match == nil {
+		return nil, fmt.Errorf("no such job: %s", spec)
+	}
+	return match, nil
+}
+
+// sorted returns the jobs ordered by ID. Callers must hold t.mutex.
+func (t *jobTable) sorted() []*JobInfo {
+	jobs := make([]*JobInfo, 0, len(t.jobs))
+	for _, job := range t.jobs {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(a, b int) bool { return jobs[a].ID < jobs[b].ID })
+	return jobs
+}
+
+// waitForeground gives the terminal to a job, continuing it first when
+// resume is set, and waits until it finishes or is stopped, in which case it
+// is kept in the job table
+func (s *Session) waitForeground(j *JobInfo, resume bool) error {
+	giveTerminal(j)
+	if resume {
+		if err := continueJob(j); err != nil {
+			takeTerminal(j)
+			return err
+		}
+	}
+	status := j.wait()
+	takeTerminal(j)
+
+	s.jobs.mutex.Lock()
+	defer s.jobs.mutex.Unlock()
+
+	if status == JobStopped {
+		s.jobs.register(j)
+		j.reported = JobStopped
+		fmt.Fprintf(s.Stdout, "\n[%d]%s  %-22s  %s\n", j.ID, s.jobs.marker(j.ID), j.describeStatus(), j.Command)
+		return nil
+	}
+
+	if j.ID != 0 {
+		s.jobs.remove(j)
+	}
+	return j.err()
+}
+
+// continueJob marks a job as running and sends it SIGCONT
+func continueJob(j *JobInfo) error {
+	j.table.mutex.Lock()
+	for _, p := range j.processes {
+		p.stopped = false
+	}
+	j.reported = ""
+	j.updateStatus()
+	j.table.mutex.Unlock()
+
+	return signalJob(j, sigContinue)
+}
+
+// `jobs` command implementation
+func (s *Session) ListJobs() error {
+	s.jobs.mutex.Lock()
+	defer s.jobs.mutex.Unlock()
+
+	if len(s.jobs.jobs) == 0 {
+		fmt.Fprintln(s.Stdout, "No background jobs running.")
+		return nil
+	}
+
+	table := tablewriter.NewWriter(s.Stdout)
+	table.SetHeader([]string{"ID", "PGID", "Command", "Status", "Started", "Runtime"})
+
+	jobs := s.jobs.sorted()
+	for _, jobInfo := range jobs {
+		started := jobInfo.StartTime.Format("2006-01-02 15:04:05")
+		end := time.Now()
+		if !jobInfo.EndTime.IsZero() {
+			end = jobInfo.EndTime
+		}
+		runtime := end.Sub(jobInfo.StartTime).Round(time.Second).String()
+
+		table.Append([]string{
+			fmt.Sprintf("%d%s", jobInfo.ID, s.jobs.marker(jobInfo.ID)),
+			fmt.Sprintf("%d", jobInfo.Pgid),
+			jobInfo.Command,
+			jobInfo.describeStatus(),
+			started,
+			runtime,
+		})
+	}
+
+	table.Render()
+
+	// The listing reported every state, finished jobs are forgotten now
+	for _, jobInfo := range jobs {
+		jobInfo.reported = jobInfo.Status
+		if jobInfo.Status == JobDone {
+			s.jobs.remove(jobInfo)
+		}
+	}
+	return nil
+}
+
+// NotifyJobs reports background jobs that finished or stopped since the last
+// notification, e.g. "[1]+  Done (exit 2)          make test", and removes
+// the finished ones. It is called before the prompt is shown.
+func (s *Session) NotifyJobs() {
+	s.jobs.mutex.Lock()
+	defer s.jobs.mutex.Unlock()
+
+	for _, jobInfo := range s.jobs.sorted() {
+		if jobInfo.Status == jobInfo.reported || jobInfo.Status == JobRunning {
+			continue
+		}
+		fmt.Fprintf(s.Stdout, "[%d]%s  %-22s  %s\n", jobInfo.ID, s.jobs.marker(jobInfo.ID), jobInfo.describeStatus(), jobInfo.Command)
+		jobInfo.reported = jobInfo.Status
+		if jobInfo.Status == JobDone {
+			s.jobs.remove(jobInfo)
+		}
+	}
+}
+
+// `fg` command implementation
+func (s *Session) BringToForeground(args []string) error {
+	spec := ""
+	if len(args) > 0 {
+		spec = args[0]
+	}
+
+	s.jobs.mutex.Lock()
+	jobInfo, err := s.jobs.lookup(spec)
+	if err != nil {
+		s.jobs.mutex.Unlock()
+		return err
+	}
+	if jobInfo.Status == JobDone {
+		s.jobs.mutex.Unlock()
+		return fmt.Errorf("job %d has already completed", jobInfo.ID)
+	}
+	stopped := jobInfo.Status == JobStopped
+	s.jobs.mutex.Unlock()
+
+	fmt.Fprintln(s.Stdout, jobInfo.Command)
+	if jobInfo.output != nil {
+		// Show what the job printed while it was in the background
+		jobInfo.output.attach(s.Stdout)
+		defer jobInfo.output.detach()
+	}
+	return s.waitForeground(jobInfo, stopped)
+}
+
+// `bg` command implementation. With a job spec, or no arguments, a stopped
+// job is resumed in the background; otherwise the arguments are started as
+// a new background job whose output is captured for `joblog` and written to
+// FILE with --log FILE.
+func (s *Session) SendToBackground(args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "%") {
+		return s.resumeInBackground(args)
+	}
+	if _, err := strconv.Atoi(args[0]); err == nil && len(args) == 1 {
+		return s.resumeInBackground(args)
+	}
+
+	logPath := ""
+	if args[0] == "--log" {
+		if len(args) < 3 {
+			return fmt.Errorf("usage: bg [--log FILE] command [args]")
+		}
+		logPath = s.path(args[1])
+		args = args[2:]
+	}
+
+	job, err := s.startBackgroundJob(args, logPath)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(s.Stdout, "[%d] %d\n", job.ID, job.Pgid)
+	return nil
+}
+
+// startBackgroundJob starts a command as a new job in the job table with its
+// output captured, appending it to logPath when set
+func (s *Session) startBackgroundJob(args []string, logPath string) (*JobInfo, error) {
+	command := s.command(args[0], args[1:]...)
+	command.Stdin = nil
+
+	// Background jobs do not read from the terminal, and their output goes
+	// to a buffer instead of interleaving with the prompt
+	output, err := newJobOutput(logPath)
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := output.pipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := output.pipe()
+	if err != nil {
+		stdout.Close()
+		return nil, err
+	}
+	command.Stdout = stdout
+	command.Stderr = stderr
+
+	// Start the command
+	job := s.newJob(strings.Join(args, " "))
+	job.output = output
+	err = job.start(command)
+	stdout.Close()
+	stderr.Close()
+	output.closeWhenDrained()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start command: %v", err)
+	}
+
+	s.jobs.mutex.Lock()
+	s.jobs.register(job)
+	s.jobs.mutex.Unlock()
+	return job, nil
+}
+
+func (s *Session) resumeInBackground(args []string) error {
+	spec := ""
+	if len(args) > 0 {
+		spec = args[0]
+	}
+
+	s.jobs.mutex.Lock()
+	jobInfo, err := s.jobs.lookup(spec)
+	if err != nil {
+		s.jobs.mutex.Unlock()
+		return err
+	}
+	if jobInfo.Status != JobStopped {
+		s.jobs.mutex.Unlock()
+		return fmt.Errorf("job %d is already running in the background", jobInfo.ID)
+	}
+	s.jobs.markCurrent(jobInfo.ID)
+	s.jobs.mutex.Unlock()
+
+	if err := continueJob(jobInfo); err != nil {
+		return err
+	}
+	fmt.Fprintf(s.Stdout, "[%d]+ %s &\n", jobInfo.ID, jobInfo.Command)
+	return nil
+}
+
+// KillJobs sends a signal to processes or jobs,
+// e.g. `kill -s INT %1`, `kill -9 1234` or `kill -l`
+func (s *Session) KillJobs(args []string) error {
+	if len(args) > 0 && args[0] == "-l" {
+		fmt.Fprintln(s.Stdout, strings.Join(processes.SignalNames(), " "))
+		return nil
+	}
+
+	var sig syscall.Signal
+	explicit := false
+	if len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		name := strings.TrimPrefix(args[0], "-")
+		args = args[1:]
+		if name == "s" || name == "n" {
+			if len(args) == 0 {
+				return fmt.Errorf("usage: kill [-s SIGNAL | -SIGNAL] pid|%%job...")
+			}
+			name = args[0]
+			args = args[1:]
+		}
+		parsed, err := processes.ParseSignal(name)
+		if err != nil {
+			return err
+		}
+		sig = parsed
+		explicit = true
+	}
+
+	if len(args) < 1 {
+		return fmt.Errorf("'kill' requires a PID or job spec")
+	}
+
+	for _, target := range args {
+		if strings.HasPrefix(target, "%") {
+			if !explicit {
+				sig = syscall.SIGTERM
+			}
+			if err := s.killJob(target, sig); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !explicit {
+			if err := processes.KillProcess(s.Stdout, []string{target}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		pid, err := strconv.Atoi(target)
+		if err != nil {
+			return fmt.Errorf("invalid PID: %s", target)
+		}
+		if err := sendSignal(pid, sig); err != nil {
+			return fmt.Errorf("failed to signal process %d: %v", pid, err)
+		}
+	}
+	return nil
+}
+
+func (s *Session) killJob(spec string, sig syscall.Signal) error {
+	s.jobs.mutex.Lock()
+	jobInfo, err := s.jobs.lookup(spec)
+	var stopped bool
+	if err == nil {
+		stopped = jobInfo.Status == JobStopped
+	}
+	s.jobs.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := signalJob(jobInfo, sig); err != nil {
+		return fmt.Errorf("failed to signal job %d: %v", jobInfo.ID, err)
+	}
+	// A stopped job only acts on the signal once it is continued
+	if stopped && sig != syscall.SIGKILL && sig != sigContinue {
+		return continueJob(jobInfo)
+	}
+	return nil
+}
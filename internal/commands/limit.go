@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"commandripple/internal/commands/syntax"
+	"commandripple/internal/commands/ulimit"
+)
+
+// Limit runs a single command with additional resource limits,
+// e.g. `limit --mem 512M --cpu 30s -- make test`
+func (s *Session) Limit(args []string) error {
+	limits := make(ulimit.Limits)
+
+	i := 0
+	for ; i < len(args); i++ {
+		if args[i] == "--" {
+			i++
+			break
+		}
+		if !strings.HasPrefix(args[i], "--") {
+			break
+		}
+		if i+1 >= len(args) {
+			return fmt.Errorf("missing value for %s", args[i])
+		}
+		resource, value, err := ulimit.ParseLimitFlag(args[i], args[i+1])
+		if err != nil {
+			return err
+		}
+		limits[resource] = value
+		i++
+	}
+
+	if i >= len(args) {
+		return fmt.Errorf("usage: limit [--cpu TIME] [--mem SIZE] [--files N] [--procs N] [--core SIZE] [--fsize SIZE] -- command")
+	}
+
+	line := syntax.Join(args[i:])
+	pipeline, err := s.ParsePipeline(line)
+	if err != nil {
+		return err
+	}
+	for _, cmd := range pipeline {
+		if IsBuiltinCommand(cmd.Name) {
+			return fmt.Errorf("limit: %s is a builtin, limits only apply to programs", cmd.Name)
+		}
+	}
+
+	sub := *s
+	sub.limits = s.limits.Merge(limits)
+	return sub.executeCommand(line)
+}
@@ -2,13 +2,14 @@ package ls
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"runtime"
 	"time"
 )
 
 // Ls lists directory contents with detailed file information
-func Ls(args []string) error {
+func Ls(w io.Writer, args []string) error {
 	dir := "."
 	if len(args) > 0 {
 		dir = args[0]
@@ -47,7 +48,7 @@ func Ls(args []string) error {
 			fileInfo = fmt.Sprintf("%s %s %s %8d %s %s", perms, owner, group, size, modTime, name)
 		}
 
-		fmt.Println(fileInfo)
+		fmt.Fprintln(w, fileInfo)
 	}
 
 	return nil
@@ -2,15 +2,17 @@ package ls
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
-	"path/filepath"
 	"runtime"
 	"strings"
 	"time"
+
+	"commandripple/internal/commands/theme"
 )
 
 // LsColor lists directory contents with colors (for file types) and detailed information
-func LsColor(args []string) error {
+func LsColor(w io.Writer, args []string) error {
 	dir := "."
 	if len(args) > 0 {
 		dir = args[0]
@@ -27,15 +29,8 @@ func LsColor(args []string) error {
 		modTime := file.ModTime().Format(time.RFC822)
 		name := file.Name()
 
-		// Determine color based on file type
-		color := "\033[0m" // Default color (reset)
-		if file.IsDir() {
-			color = "\033[1;34m" // Blue for directories
-		} else if mode&0111 != 0 {
-			color = "\033[1;32m" // Green for executable files
-		} else if strings.HasPrefix(strings.ToLower(filepath.Ext(name)), ".") {
-			color = "\033[1;37m" // White for hidden files
-		}
+		// Color based on file type, from LS_COLORS or the theme
+		coloredName := theme.PaintFile(w, name, mode, strings.HasPrefix(name, "."))
 
 		// Create a string that mimics the output of 'ls -l' on Unix
 		var fileInfo string
@@ -45,16 +40,16 @@ func LsColor(args []string) error {
 			if file.IsDir() {
 				fileType = "d"
 			}
-			fileInfo = fmt.Sprintf("%s %10d %s %s%s%s", fileType, size, modTime, color, name, "\033[0m")
+			fileInfo = fmt.Sprintf("%s %10d %s %s", fileType, size, modTime, coloredName)
 		} else {
 			// On Unix-like systems, we'll try to mimic 'ls -l' more closely
 			perms := mode.String()
 			owner := getOwner(file)
 			group := getGroup(file)
-			fileInfo = fmt.Sprintf("%s %s %s %8d %s %s%s%s", perms, owner, group, size, modTime, color, name, "\033[0m")
+			fileInfo = fmt.Sprintf("%s %s %s %8d %s %s", perms, owner, group, size, modTime, coloredName)
 		}
 
-		fmt.Println(fileInfo)
+		fmt.Fprintln(w, fileInfo)
 	}
 
 	return nil
@@ -0,0 +1,387 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// parallelMaxExitCode caps the exit status, which counts the failed jobs
+const parallelMaxExitCode = 101
+
+type parallelOptions struct {
+	Jobs      int    // -j: number of jobs run at once
+	KeepOrder bool   // -k: print output in input order
+	Tag       bool   // --tag: prefix output lines with the job's input
+	JobLog    string // --joblog: file recording every finished job
+	Halt      string // --halt: "now" kills running jobs, "soon" waits for them
+	HaltFails int    // --halt ...,fail=N: failures that trigger the halt
+}
+
+type parallelJob struct {
+	Seq    int
+	Input  string
+	Argv   []string
+	Output bytes.Buffer
+	Done   bool
+}
+
+// parallelRun holds the state shared by the running jobs
+type parallelRun struct {
+	session *Session
+	options parallelOptions
+	stdout  io.Writer
+	stderr  io.Writer
+	jobLog  io.Writer
+
+	mutex    sync.Mutex
+	jobs     []*parallelJob
+	next     int // next job to print when keeping order
+	failures int
+	halted   bool
+	running  map[int]*exec.Cmd // Each in its own process group
+}
+
+// Parallel runs a command for every input line using a bounded pool of jobs,
+// e.g. `cat hosts | parallel -j 8 --tag ping -c 1` or `parallel gzip ::: *.log`
+func (s *Session) Parallel(args []string) error {
+	options := parallelOptions{Jobs: runtime.NumCPU()}
+
+	i := 0
+options:
+	for ; i < len(args) && strings.HasPrefix(args[i], "-"); i++ {
+		switch args[i] {
+		case "-k", "--keep-order":
+			options.KeepOrder = true
+		case "--tag":
+			options.Tag = true
+		case "-j", "--jobs", "--joblog", "--halt":
+			if i+1 >= len(args) {
+				return fmt.Errorf("missing value for %s", args[i])
+			}
+			if err := options.set(args[i], args[i+1]); err != nil {
+				return err
+			}
+			i++
+		case "--":
+			i++
+			break options
+		default:
+			return fmt.Errorf("usage: parallel [-j N] [-k] [--tag] [--joblog FILE] [--halt now|soon,fail=N] command [::: args]")
+		}
+	}
+
+	template := args[i:]
+	var inputs []string
+	for j, word := range template {
+		if word == ":::" {
+			inputs = template[j+1:]
+			template = template[:j]
+			break
+		}
+	}
+	if len(template) == 0 {
+		return fmt.Errorf("'parallel' requires a command")
+	}
+
+	if inputs == nil {
+		data, err := io.ReadAll(s.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read input: %v", err)
+		}
+		inputs = splitInputLines(string(data))
+	}
+
+	run := &parallelRun{
+		session: s,
+		options: options,
+		stdout:  s.Stdout,
+		stderr:  s.Stderr,
+		running: make(map[int]*exec.Cmd),
+	}
+
+	if options.JobLog != "" {
+		logFile, err := os.Create(s.path(options.JobLog))
+		if err != nil {
+			return fmt.Errorf("failed to create joblog: %v", err)
+		}
+		defer logFile.Close()
+		fmt.Fprintln(logFile, "Seq\tHost\tStarttime\tJobRuntime\tSend\tReceive\tExitval\tSignal\tCommand")
+		run.jobLog = logFile
+	}
+
+	for seq, input := range inputs {
+		// Without a {} placeholder the input is appended as the last argument
+		argv, replaced := replaceArgs(template, "{}", input)
+		if !replaced {
+			argv = append(argv, input)
+		}
+		run.jobs = append(run.jobs, &parallelJob{
+			Seq:   seq + 1,
+			Input: input,
+			Argv:  argv,
+		})
+	}
+
+	return run.execute()
+}
+
+func (o *parallelOptions) set(flag, value string) error {
+	switch flag {
+	case "-j", "--jobs":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid number of jobs: %s", value)
+		}
+		o.Jobs = n
+	case "--joblog":
+		o.JobLog = value
+	case "--halt":
+		if value == "never" {
+			o.Halt = ""
+			return nil
+		}
+		when, condition, _ := strings.Cut(value, ",")
+		if when != "now" && when != "soon" {
+			return fmt.Errorf("invalid halt mode: %s (expected now or soon)", when)
+		}
+		o.Halt = when
+		o.HaltFails = 1
+		if condition != "" {
+			count, found := strings.CutPrefix(condition, "fail=")
+			n, err := strconv.Atoi(count)
+			if !found || err != nil || n < 1 {
+				return fmt.Errorf("invalid halt condition: %s", condition)
+			}
+			o.HaltFails = n
+		}
+	}
+	return nil
+}
+
+func (r *parallelRun) execute() error {
+	slots := make(chan struct{}, r.options.Jobs)
+	var wg sync.WaitGroup
+
+	// The jobs have their own process groups, so Ctrl-C is passed on
+	interrupt := make(chan os.Signal, 1)
+	if r.session.interactive {
+		signal.Notify(interrupt, os.Interrupt)
+		defer signal.Stop(interrupt)
+	}
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-interrupt:
+			r.mutex.Lock()
+			r.halted = true
+			r.signalRunning(syscall.SIGINT)
+			r.mutex.Unlock()
+		case <-finished:
+		}
+	}()
+
+	for _, job := range r.jobs {
+		slots <- struct{}{}
+		if r.isHalted() {
+			<-slots
+			break
+		}
+
+		wg.Add(1)
+		go func(job *parallelJob) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			r.runJob(job)
+		}(job)
+	}
+	wg.Wait()
+
+	// Jobs skipped by a halt never finish, flush whatever completed
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for ; r.next < len(r.jobs); r.next++ {
+		if r.jobs[r.next].Done {
+			r.stdout.Write(r.jobs[r.next].Output.Bytes())
+		}
+	}
+
+	if r.failures > 0 {
+		code := r.failures
+		if code > parallelMaxExitCode {
+			code = parallelMaxExitCode
+		}
+		return &ExitStatus{Code: code, Reason: fmt.Sprintf("parallel: %d job(s) failed", r.failures)}
+	}
+	return nil
+}
+
+func (r *parallelRun) runJob(job *parallelJob) {
+	prefix := ""
+	if r.options.Tag {
+		prefix = job.Input + "\t"
+	}
+
+	var stdout *prefixWriter
+	if r.options.KeepOrder {
+		stdout = &prefixWriter{prefix: prefix, out: &job.Output}
+	} else {
+		stdout = &prefixWriter{prefix: prefix, out: r.stdout, mutex: &r.mutex}
+	}
+	stderr := &prefixWriter{prefix: prefix, out: r.stderr, mutex: &r.mutex}
+
+	start := time.Now()
+	var err error
+	if IsBuiltinCommand(job.Argv[0]) {
+		sub := *r.session
+		sub.Stdin, sub.Stdout, sub.Stderr = strings.NewReader(""), stdout, stderr
+		r.session.builtinJobs.Lock()
+		err = sub.ExecuteBuiltin(job.Argv[0], job.Argv[1:])
+		r.session.builtinJobs.Unlock()
+		if err != nil {
+			fmt.Fprintf(stderr, "%v\n", err)
+		}
+	} else {
+		err = r.runExternal(job, stdout, stderr)
+	}
+	stdout.Flush()
+	stderr.Flush()
+
+	r.finish(job, start, stdout.written, err)
+}
+
+func (r *parallelRun) runExternal(job *parallelJob, stdout, stderr io.Writer) error {
+	cmd := r.session.command(job.Argv[0], job.Argv[1:]...)
+	cmd.Stdin = nil
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// Killing the group also stops what the job started, e.g. the
+	// commands of an sh -c pipeline
+	setProcessGroup(cmd)
+
+	r.mutex.Lock()
+	if r.halted {
+		r.mutex.Unlock()
+		return fmt.Errorf("halted")
+	}
+	err := r.session.startProcess(cmd)
+	if err == nil {
+		r.running[job.Seq] = cmd
+	}
+	r.mutex.Unlock()
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return err
+	}
+
+	err = cmd.Wait()
+
+	r.mutex.Lock()
+	delete(r.running, job.Seq)
+	r.mutex.Unlock()
+	return err
+}
+
+// finish records a completed job, prints ordered output that is ready and
+// applies the halt policy
+func (r *parallelRun) finish(job *parallelJob, start time.Time, received int, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	job.Done = true
+	if r.options.KeepOrder {
+		for r.next < len(r.jobs) && r.jobs[r.next].Done {
+			r.stdout.Write(r.jobs[r.next].Output.Bytes())
+			r.next++
+		}
+	}
+
+	if r.jobLog != nil {
+		fmt.Fprintf(r.jobLog, "%d\t:\t%.3f\t%.3f\t0\t%d\t%d\t%d\t%s\n",
+			job.Seq,
+			float64(start.UnixNano())/1e9,
+			time.Since(start).Seconds(),
+			received,
+			ExitCode(err),
+			exitSignal(err),
+			strings.Join(job.Argv, " "))
+	}
+
+	if err == nil {
+		return
+	}
+	r.failures++
+	if r.options.Halt == "" || r.halted || r.failures < r.options.HaltFails {
+		return
+	}
+
+	r.halted = true
+	if r.options.Halt == "now" {
+		r.signalRunning(syscall.SIGKILL)
+	}
+}
+
+// signalRunning signals the process groups of the running jobs. Callers
+// must hold r.mutex.
+func (r *parallelRun) signalRunning(sig syscall.Signal) {
+	for _, cmd := range r.running {
+		signalProcessGroup(processGroupID(cmd), sig)
+	}
+}
+
+func (r *parallelRun) isHalted() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.halted
+}
+
+// prefixWriter writes complete lines with a prefix so that the output of
+// concurrent jobs does not interleave within a line
+type prefixWriter struct {
+	prefix  string
+	out     io.Writer
+	mutex   *sync.Mutex
+	partial []byte
+	written int
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.written += len(p)
+	w.partial = append(w.partial, p...)
+	for {
+		idx := bytes.IndexByte(w.partial, '\n')
+		if idx < 0 {
+			break
+		}
+		w.writeLine(w.partial[:idx+1])
+		w.partial = w.partial[idx+1:]
+	}
+	return len(p), nil
+}
+
+// Flush writes a final line that has no trailing newline
+func (w *prefixWriter) Flush() {
+	if len(w.partial) > 0 {
+		w.writeLine(append(w.partial, '\n'))
+		w.partial = nil
+	}
+}
+
+func (w *prefixWriter) writeLine(line []byte) {
+	if w.mutex != nil {
+		w.mutex.Lock()
+		defer w.mutex.Unlock()
+	}
+	io.WriteString(w.out, w.prefix)
+	w.out.Write(line)
+}
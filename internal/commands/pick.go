@@ -0,0 +1,254 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"commandripple/internal/commands/finder"
+)
+
+const (
+	// pickFileLimit caps the number of paths offered by Ctrl-T
+	pickFileLimit = 10000
+	// previewFileBytes is how much of a file the preview pane reads
+	previewFileBytes = 16 * 1024
+)
+
+var errPickCancelled = &ExitStatus{Code: 130, Reason: "pick: cancelled"}
+
+// `pick` command implementation: reads candidates from stdin, lets the user
+// choose with the fuzzy finder on the terminal and prints the selection,
+// e.g. `git ls-files | pick -m --preview head {} | xargs wc -l`. The preview
+// command is the rest of the line; {} is replaced by the candidate, which is
+// appended when there is no placeholder.
+func (s *Session) Pick(args []string) error {
+	var opts finder.Options
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "-m", "--multi":
+			opts.Multi = true
+		case "--prompt", "--query", "-q":
+			if i+1 >= len(args) {
+				return fmt.Errorf("missing value for %s", arg)
+			}
+			i++
+			if arg == "--prompt" {
+				opts.Prompt = args[i]
+			} else {
+				opts.Query = args[i]
+			}
+		case "--preview":
+			if i+1 >= len(args) {
+				return fmt.Errorf("missing preview command")
+			}
+			opts.Preview = s.previewCommand(args[i+1:])
+			i = len(args)
+		default:
+			return fmt.Errorf("usage: pick [-m] [--prompt P] [--query Q] [--preview command...]")
+		}
+	}
+
+	if isTerminal(s.Stdin) {
+		return fmt.Errorf("pick reads candidates from stdin, e.g. `ls | pick`")
+	}
+	data, err := io.ReadAll(s.Stdin)
+	if err != nil {
+		return err
+	}
+	items := splitInputLines(string(data))
+	if len(items) == 0 {
+		return &ExitStatus{Code: 1, Reason: "pick: no candidates"}
+	}
+
+	selected, err := pickOnTerminal(items, opts)
+	if err == finder.ErrCancelled {
+		return errPickCancelled
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, item := range selected {
+		fmt.Fprintln(s.Stdout, item)
+	}
+	return nil
+}
+
+// pickOnTerminal runs the finder on the controlling terminal, which works
+// while stdin and stdout are redirected
+func pickOnTerminal(items []string, opts finder.Options) ([]string, error) {
+	in, out, err := finder.OpenTTY()
+	if err != nil {
+		return nil, fmt.Errorf("no terminal for the finder: %v", err)
+	}
+	defer in.Close()
+	if out != in {
+		defer out.Close()
+	}
+
+	restore, err := finder.MakeRaw(in)
+	if err != nil {
+		return nil, fmt.Errorf("no terminal for the finder: %v", err)
+	}
+	defer restore()
+	return finder.Run(items, in, out, opts)
+}
+
+// previewCommand returns a preview function running a builtin or external
+// command for the candidate under the cursor
+func (s *Session) previewCommand(template []string) func(string) string {
+	return func(item string) string {
+		argv, replaced := replaceArgs(template, "{}", item)
+		if !replaced {
+			argv = append(argv, item)
+		}
+
+		var buf bytes.Buffer
+		var err error
+		if IsBuiltinCommand(argv[0]) {
+			preview := *s
+			preview.Stdin, preview.Stdout = strings.NewReader(""), &buf
+			err = preview.ExecuteBuiltin(argv[0], argv[1:])
+		} else {
+			cmd := s.command(argv[0], argv[1:]...)
+			cmd.Stdin = nil
+			cmd.Stdout = &buf
+			cmd.Stderr = &buf
+			if err = s.startProcess(cmd); err == nil {
+				err = cmd.Wait()
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(&buf, "\n%v\n", err)
+		}
+		return buf.String()
+	}
+}
+
+// PickHistory lets the user choose a command from the history, most recent
+// first, reading keys from input. The preview shows when and where the
+// command ran and how it ended.
+func (s *Session) PickHistory(input io.Reader, query string) (string, error) {
+	if s.history == nil {
+		return "", finder.ErrCancelled
+	}
+
+	entries := s.history.Entries()
+	seen := make(map[string]bool)
+	var items []string
+	latest := make(map[string]int) // Command to its most recent entry
+	for i := len(entries) - 1; i >= 0; i-- {
+		command := entries[i].Command
+		if seen[command] {
+			continue
+		}
+		seen[command] = true
+		latest[command] = i
+		items = append(items, command)
+	}
+
+	preview := func(item string) string {
+		var b strings.Builder
+		var runs, failures int
+		for _, entry := range entries {
+			if entry.Command == item {
+				runs++
+				if entry.ExitCode != 0 {
+					failures++
+				}
+			}
+		}
+		entry := entries[latest[item]]
+		fmt.Fprintf(&b, "%s\n\n", entry.Command)
+		fmt.Fprintf(&b, "Last run:  %s\n", entry.Time.Format("2006-01-02 15:04:05"))
+		fmt.Fprintf(&b, "Directory: %s\n", entry.Dir)
+		fmt.Fprintf(&b, "Exit:      %d\n", entry.ExitCode)
+		fmt.Fprintf(&b, "Duration:  %s\n", entry.Duration.Round(time.Millisecond))
+		fmt.Fprintf(&b, "Runs:      %d (%d failed)\n", runs, failures)
+		return b.String()
+	}
+
+	selected, err := finder.Run(items, input, os.Stdout, finder.Options{
+		Prompt:  "history> ",
+		Query:   query,
+		Preview: preview,
+	})
+	if err != nil || len(selected) == 0 {
+		return "", finder.ErrCancelled
+	}
+	return selected[0], nil
+}
+
+// PickFiles lets the user choose files and directories below the current
+// directory, reading keys from input. Hidden directories are skipped.
+func (s *Session) PickFiles(input io.Reader, query string) ([]string, error) {
+	var items []string
+	filepath.WalkDir(".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == "." {
+			return nil
+		}
+		if d.IsDir() && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if len(items) >= pickFileLimit {
+			return filepath.SkipAll
+		}
+		if d.IsDir() {
+			path += string(os.PathSeparator)
+		}
+		items = append(items, path)
+		return nil
+	})
+
+	selected, err := finder.Run(items, input, os.Stdout, finder.Options{
+		Prompt:  "files> ",
+		Query:   query,
+		Multi:   true,
+		Preview: previewFile,
+	})
+	if err != nil || len(selected) == 0 {
+		return nil, finder.ErrCancelled
+	}
+	return selected, nil
+}
+
+// previewFile shows the start of a text file or the entries of a directory
+func previewFile(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err.Error()
+	}
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return err.Error()
+		}
+		var b strings.Builder
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() {
+				name += string(os.PathSeparator)
+			}
+			fmt.Fprintln(&b, name)
+		}
+		return b.String()
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err.Error()
+	}
+	defer file.Close()
+	head, _ := io.ReadAll(io.LimitReader(file, previewFileBytes))
+	if bytes.IndexByte(head, 0) >= 0 || !utf8.Valid(head) && len(head) < previewFileBytes {
+		return fmt.Sprintf("%s: binary file, %d bytes", path, info.Size())
+	}
+	return string(head)
+}
@@ -1,80 +1,344 @@
 package commands
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"strings"
+
+	"commandripple/internal/commands/syntax"
 )
 
 type Command struct {
-	Name string
-	Args []string
+	Name      string
+	Args      []string
+	Env       []string   // NAME=value assignments scoped to this command
+	Redirects []Redirect // Applied in order before the command runs
+}
+
+// String formats the command as a command line that parses back to it
+func (c Command) String() string {
+	var words []string
+	for _, assignment := range c.Env {
+		// Only the value is quoted, so that it stays an assignment
+		name, value, _ := strings.Cut(assignment, "=")
+		words = append(words, name+"="+syntax.Quote(value))
+	}
+	if c.Name != "" {
+		words = append(words, syntax.Join(append([]string{c.Name}, c.Args...)))
+	}
+	for _, r := range c.Redirects {
+		words = append(words, r.Op)
+		if r.Target != "" {
+			words = append(words, syntax.Quote(r.Target))
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// ParsePipeline splits a command line into the commands of a pipeline. Each
+// command gets its leading NAME=value assignments, its name and arguments
+// with quotes removed and variables expanded, and its redirections.
+func (s *Session) ParsePipeline(commandLine string) ([]Command, error) {
+	lookup := s.LookupEnv
+	tokens, err := syntax.Tokenize(expandAliases(commandLine, s.aliasTable(), lookup), lookup)
+	if err != nil {
+		return nil, fmt.Errorf("syntax error: %v", err)
+	}
+
+	var pipeline []Command
+	var cmd Command
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		switch token.Kind {
+		case syntax.Pipe:
+			pipeline = appendStage(pipeline, cmd)
+			cmd = Command{}
+		case syntax.Redirect:
+			redirect := Redirect{Op: token.Text}
+			if redirect.Op != "2>&1" {
+				if i+1 >= len(tokens) || tokens[i+1].Kind != syntax.Word {
+					return nil, fmt.Errorf("syntax error: missing file name after %s", token.Text)
+				}
+				i++
+				redirect.Target = tokens[i].Value
+			}
+			cmd.Redirects = append(cmd.Redirects, redirect)
+		case syntax.Word:
+			switch {
+			case token.Value == "" && !isQuoted(token):
+				// An unquoted variable that is empty or unset is dropped
+			case cmd.Name == "" && isAssignment(token.Text):
+				cmd.Env = append(cmd.Env, token.Value)
+			case cmd.Name == "":
+				cmd.Name = token.Value
+			default:
+				cmd.Args = append(cmd.Args, token.Value)
+			}
+		}
+	}
+	return appendStage(pipeline, cmd), nil
+}
+
+// expandAliases replaces aliases at the start of each command with their
+// text. An alias whose text starts with another alias is expanded again,
+// but never twice, so that `alias ls='ls -F'` works. Quoted or escaped
+// names are not expanded.
+func expandAliases(line string, aliases map[string]string, lookup syntax.Lookup) string {
+	pos := 0
+	for len(aliases) > 0 {
+		tokens, err := syntax.Tokenize(line, lookup)
+		if err != nil {
+			return line
+		}
+
+		commandNext := true
+		expanded := false
+		for i, token := range tokens {
+			switch {
+			case token.Kind == syntax.Pipe:
+				commandNext = true
+				continue
+			case token.Kind == syntax.Redirect,
+				i > 0 && tokens[i-1].Kind == syntax.Redirect && tokens[i-1].Text != "2>&1",
+				!commandNext,
+				isAssignment(token.Text):
+				continue
+			}
+			commandNext = false
+			if token.Start < pos || token.Text != token.Value || len(token.Parts) > 1 {
+				continue
+			}
+
+			text := token.Text
+			seen := make(map[string]bool)
+			for {
+				name, rest, _ := strings.Cut(text, " ")
+				value, ok := aliases[name]
+				if !ok || seen[name] {
+					break
+				}
+				seen[name] = true
+				text = strings.TrimRight(value+" "+rest, " ")
+			}
+			if text == token.Text {
+				continue
+			}
+			line = line[:token.Start] + text + line[token.End:]
+			pos = token.Start + len(text)
+			expanded = true
+			break
+		}
+		if !expanded {
+			break
+		}
+	}
+	return line
+}
+
+// appendStage adds a command to a pipeline unless it is empty
+func appendStage(pipeline []Command, cmd Command) []Command {
+	if cmd.Name == "" && len(cmd.Env) == 0 && len(cmd.Redirects) == 0 {
+		return pipeline
+	}
+	return append(pipeline, cmd)
+}
+
+func isQuoted(token syntax.Token) bool {
+	for _, part := range token.Parts {
+		if part.Kind == syntax.SingleQuoted || part.Kind == syntax.DoubleQuoted {
+			return true
+		}
+	}
+	return false
+}
+
+// ExecuteCommandLine executes a command line, which may be a pipeline
+func (s *Session) ExecuteCommandLine(commandLine string) error {
+	pipeline, err := s.ParsePipeline(commandLine)
+	if err != nil {
+		return err
+	}
+
+	// Any command in between means a later `exit` warns again
+	if len(pipeline) == 0 || pipeline[0].Name != "exit" {
+		s.jobs.mutex.Lock()
+		s.jobs.exitWarned = false
+		s.jobs.mutex.Unlock()
+	}
+
+	switch len(pipeline) {
+	case 0:
+		return nil
+	case 1:
+		// If there's no pipe, execute the command normally
+		return s.runCommand(pipeline[0])
+	}
+
+	// Execute the command pipeline
+	return s.ExecutePipeline(pipeline)
 }
 
-// ExecutePipeline executes a series of commands connected by pipes
-func ExecutePipeline(commandsChain []Command) error {
+// runCommand executes a single command with its assignments in effect
+func (s *Session) runCommand(cmd Command) error {
+	if cmd.Name == "" {
+		// A line made only of assignments sets them in the shell, and
+		// redirections alone create or truncate their files
+		return s.withRedirects(cmd.Redirects, func(s *Session) error {
+			if len(cmd.Env) == 0 {
+				return nil
+			}
+			return s.ExportEnv(cmd.Env)
+		})
+	}
+
+	scoped, restore := s.scopeEnv(cmd)
+	defer restore()
+
+	return scoped.withRedirects(cmd.Redirects, func(s *Session) error {
+		if IsBuiltinCommand(cmd.Name) {
+			return s.ExecuteBuiltin(cmd.Name, cmd.Args)
+		}
+		return s.ExecuteExternal(cmd.Name, cmd.Args)
+	})
+}
+
+// ExecutePipeline executes a series of commands connected by pipes as a
+// single job and returns the result of the last command
+func (s *Session) ExecutePipeline(commandsChain []Command) error {
 	var lastOutput io.ReadCloser
+
+	job := s.newJob(pipelineString(commandsChain))
+	job.launching = true
+	lastIsBuiltin := false
+
 	for i, cmd := range commandsChain {
 		var err error
-		if i == 0 {
-			// First command: get the output directly
-			lastOutput, err = executeCommandWithOutput(cmd, nil)
-		} else {
-			// Subsequent commands: get output from the previous command
-			lastOutput, err = executeCommandWithOutput(cmd, lastOutput)
-		}
+		isLast := i == len(commandsChain)-1
+		// The first command reads the session's input, the others the
+		// output of the previous one
+		lastOutput, err = s.executeCommandWithOutput(cmd, lastOutput, isLast, job)
 		if err != nil {
+			// Let the stages that already started finish
+			job.launched()
+			if job.Cmd != nil {
+				s.waitForeground(job, false)
+			}
 			return err
 		}
+		lastIsBuiltin = IsBuiltinCommand(cmd.Name)
 	}
+	job.launched()
 
-	// Consume and display the final output
-	if lastOutput != nil {
-		_, err := io.Copy(os.Stdout, lastOutput)
-		return err
+	if job.Cmd == nil {
+		return nil
+	}
+	err := s.waitForeground(job, false)
+	if lastIsBuiltin {
+		// The result of the pipeline is the builtin's, which succeeded
+		return nil
 	}
-	return nil
+	return err
 }
 
-// executeCommandWithOutput executes a command, possibly using input from a previous command
-func executeCommandWithOutput(cmd Command, input io.Reader) (io.ReadCloser, error) {
-	var command *exec.Cmd
+// executeCommandWithOutput executes a command, possibly using input from a previous command.
+// External commands are started as part of job; the last stage writes to the
+// session's standard output, earlier stages return their output for the next one.
+func (s *Session) executeCommandWithOutput(cmd Command, input io.ReadCloser, isLast bool, job *JobInfo) (io.ReadCloser, error) {
+	if cmd.Name == "" {
+		return nil, fmt.Errorf("missing command in pipeline")
+	}
+
+	scoped, restore := s.scopeEnv(cmd)
+	defer restore()
+	stage := *scoped
 
 	if IsBuiltinCommand(cmd.Name) {
 		// Handle built-in commands
-		if input != nil {
-			return nil, fmt.Errorf("input redirection not supported for built-in commands: %s", cmd.Name)
-		}
-		err := ExecuteBuiltin(cmd.Name, cmd.Args)
+		return stage.runBuiltinStage(cmd, input, !isLast)
+	}
+
+	// The shell owns both ends of the pipe, so exec.Cmd.Wait never closes
+	// output that the next stage is still reading
+	var output, pipeWriter *os.File
+	if !isLast {
+		var err error
+		output, pipeWriter, err = os.Pipe()
 		if err != nil {
 			return nil, err
 		}
-		return nil, nil
 	}
 
-	command = exec.Command(cmd.Name, cmd.Args...)
-
 	if input != nil {
-		command.Stdin = input
+		stage.Stdin = input
+	}
+	if pipeWriter != nil {
+		stage.Stdout = pipeWriter
+	}
+	err := stage.withRedirects(cmd.Redirects, func(stage *Session) error {
+		var command *exec.Cmd
+		if p, ok := s.resolvePlugin(cmd.Name); ok {
+			command = p.Command(cmd.Args, stage.pipelineContext(cmd, isLast), stage.Environ())
+			command.Stdin, command.Stdout, command.Stderr = stage.Stdin, stage.Stdout, stage.Stderr
+		} else {
+			command = stage.command(cmd.Name, cmd.Args...)
+		}
+		return job.start(command)
+	})
+
+	// The child has its own copies of the pipe ends now
+	if pipeWriter != nil {
+		pipeWriter.Close()
+	}
+	if f, ok := input.(*os.File); ok {
+		f.Close()
 	}
 
-	// Get a pipe for the command's standard output
-	output, err := command.StdoutPipe()
 	if err != nil {
+		if output != nil {
+			output.Close()
+		}
 		return nil, err
 	}
+	if output == nil {
+		return nil, nil
+	}
+	return output, nil
+}
 
-	command.Stderr = os.Stderr
-
-	// Start the command
-	if err := command.Start(); err != nil {
-		return nil, err
+// pipelineString rebuilds the command line of a pipeline for job listings
+func pipelineString(commandsChain []Command) string {
+	stages := make([]string, 0, len(commandsChain))
+	for _, cmd := range commandsChain {
+		stages = append(stages, cmd.String())
 	}
+	return strings.Join(stages, " | ")
+}
 
-	go func() {
-		command.Wait()
-	}()
+// runBuiltinStage runs a builtin with its standard input fed from the
+// previous stage. Unless it is the last stage, its output is collected and
+// returned for the next one.
+func (s *Session) runBuiltinStage(cmd Command, input io.ReadCloser, capture bool) (io.ReadCloser, error) {
+	stage := *s
+	if input != nil {
+		stage.Stdin = input
+		// Closing it makes a previous stage that is still writing fail
+		// with EPIPE or SIGPIPE, instead of reading it until it ends on
+		// its own
+		defer input.Close()
+	}
 
-	return output, nil
+	var buf bytes.Buffer
+	if capture {
+		stage.Stdout = &buf
+	}
+	err := stage.withRedirects(cmd.Redirects, func(s *Session) error {
+		return s.ExecuteBuiltin(cmd.Name, cmd.Args)
+	})
+	if !capture {
+		return nil, err
+	}
+	return io.NopCloser(&buf), err
 }
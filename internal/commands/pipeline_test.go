@@ -0,0 +1,104 @@
+package commands
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePipeline(t *testing.T) {
+	t.Setenv("GREETING", "hello world")
+	t.Setenv("EMPTY", "")
+
+	tests := []struct {
+		line string
+		want []Command
+	}{
+		{"", nil},
+		{"  ", nil},
+		{"ls -l", []Command{{Name: "ls", Args: []string{"-l"}}}},
+		{`echo "$GREETING" $GREETING '$GREETING'`, []Command{
+			{Name: "echo", Args: []string{"hello world", "hello world", "$GREETING"}},
+		}},
+		{`echo $EMPTY $UNSET "" "$EMPTY" x`, []Command{
+			{Name: "echo", Args: []string{"", "", "x"}},
+		}},
+		{`$EMPTY ls`, []Command{{Name: "ls"}}},
+		{`A=1 B="two words" env`, []Command{
+			{Name: "env", Env: []string{"A=1", "B=two words"}},
+		}},
+		{"A=1", []Command{{Env: []string{"A=1"}}}},
+		{"echo A=1", []Command{{Name: "echo", Args: []string{"A=1"}}}},
+		{`"A=1" echo`, []Command{{Name: "A=1", Args: []string{"echo"}}}},
+		{"cat < in.txt | sort -r | uniq > out.txt", []Command{
+			{Name: "cat", Redirects: []Redirect{{Op: "<", Target: "in.txt"}}},
+			{Name: "sort", Args: []string{"-r"}},
+			{Name: "uniq", Redirects: []Redirect{{Op: ">", Target: "out.txt"}}},
+		}},
+		{"make >> build.log 2>&1", []Command{
+			{Name: "make", Redirects: []Redirect{{Op: ">>", Target: "build.log"}, {Op: "2>&1"}}},
+		}},
+		{`go test 2> "err file" 2>> e &> all`, []Command{
+			{Name: "go", Args: []string{"test"}, Redirects: []Redirect{
+				{Op: "2>", Target: "err file"}, {Op: "2>>", Target: "e"}, {Op: "&>", Target: "all"},
+			}},
+		}},
+		{"> empty.txt", []Command{{Redirects: []Redirect{{Op: ">", Target: "empty.txt"}}}}},
+		{"echo 'a|b' | wc", []Command{
+			{Name: "echo", Args: []string{"a|b"}},
+			{Name: "wc"},
+		}},
+	}
+	for _, tt := range tests {
+		got, err := Default.ParsePipeline(tt.line)
+		if err != nil {
+			t.Errorf("ParsePipeline(%q) failed: %v", tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParsePipeline(%q) = %+v, want %+v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestParsePipelineInvalid(t *testing.T) {
+	tests := []struct {
+		line string
+		err  string
+	}{
+		{"cat <", "missing file name after <"},
+		{"echo hi > | wc", "missing file name after >"},
+		{"ls 2> 2>&1", "missing file name after 2>"},
+		{`echo "unterminated`, "unterminated quote"},
+		{"echo 'unterminated", "unterminated quote"},
+		{"test -f x || rm -rf build", "unsupported operator ||"},
+		{"make && make install", "unsupported operator &&"},
+	}
+	for _, tt := range tests {
+		_, err := Default.ParsePipeline(tt.line)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("ParsePipeline(%q) = %v, want %q", tt.line, err, tt.err)
+		}
+	}
+}
+
+func TestCommandStringRoundTrip(t *testing.T) {
+	commands := []Command{
+		{Name: "echo", Args: []string{"two words", "it's", "$HOME", "a|b", ""}},
+		{Name: "env", Env: []string{"A=x y", "B="}},
+		{Env: []string{"A=1"}, Redirects: []Redirect{{Op: ">", Target: "f"}}},
+		{Name: "grep", Args: []string{"-c", "x"}, Redirects: []Redirect{
+			{Op: "<", Target: "in file"}, {Op: ">", Target: "out"}, {Op: "2>&1"},
+		}},
+	}
+	for _, cmd := range commands {
+		parsed, err := Default.ParsePipeline(cmd.String())
+		if err != nil {
+			t.Errorf("ParsePipeline(%q) failed: %v", cmd.String(), err)
+			continue
+		}
+		if len(parsed) != 1 || !reflect.DeepEqual(parsed[0], cmd) {
+			t.Errorf("ParsePipeline(%q) = %+v, want %+v", cmd.String(), parsed, cmd)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Registry caches the plugins found in a list of directories. Lookups only
+// read the cache, since the shell looks plugins up on every key press to
+// color the command line; Refresh reads the directories again.
+type Registry struct {
+	scan    sync.Mutex // Held while the directories are read
+	mu      sync.RWMutex
+	key     string
+	updated time.Time
+	plugins map[string]*Plugin
+}
+
+// Lookup returns the plugin for a command name as of the last refresh
+func (r *Registry) Lookup(name string) (*Plugin, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.plugins[name]
+	return p, ok
+}
+
+// List returns every plugin as of the last refresh, sorted by name
+func (r *Registry) List() []*Plugin {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	list := make([]*Plugin, 0, len(r.plugins))
+	for _, p := range r.plugins {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Reload forgets the plugins and their descriptions
+func (r *Registry) Reload() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.key = ""
+	r.plugins = nil
+}
+
+// Refresh reads the directories again when they changed or were read more
+// than a few seconds ago
+func (r *Registry) Refresh(dirs []string) {
+	r.scan.Lock()
+	defer r.scan.Unlock()
+
+	key := strings.Join(dirs, string(os.PathListSeparator))
+	r.mu.RLock()
+	fresh := r.plugins != nil && r.key == key && time.Since(r.updated) < 10*time.Second
+	r.mu.RUnlock()
+	if fresh {
+		return
+	}
+
+	found := Discover(dirs)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Plugins that did not move keep their descriptions
+	for name, p := range found {
+		if old, ok := r.plugins[name]; ok && old.Path == p.Path {
+			found[name] = old
+		}
+	}
+	r.key = key
+	r.updated = time.Now()
+	r.plugins = found
+}
+
+// Discover finds the plugins in dirs. The first directory holding a name
+// wins, like PATH lookup.
+func Discover(dirs []string) map[string]*Plugin {
+	plugins := make(map[string]*Plugin)
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name, ok := commandName(entry.Name())
+			if !ok || plugins[name] != nil {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			if !isExecutable(path) {
+				continue
+			}
+			plugins[name] = &Plugin{Name: name, Path: path}
+		}
+	}
+	return plugins
+}
+
+// commandName returns the command of an executable's file name
+func commandName(file string) (string, bool) {
+	name, ok := strings.CutPrefix(file, Prefix)
+	if !ok {
+		return "", false
+	}
+	if runtime.GOOS == "windows" {
+		ext := strings.ToLower(filepath.Ext(name))
+		if !isWindowsExecutable(ext) {
+			return "", false
+		}
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	return name, name != ""
+}
+
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	return runtime.GOOS == "windows" || info.Mode().Perm()&0111 != 0
+}
+
+func isWindowsExecutable(ext string) bool {
+	pathext := os.Getenv("PATHEXT")
+	if pathext == "" {
+		pathext = ".com;.exe;.bat;.cmd"
+	}
+	for _, e := range strings.Split(strings.ToLower(pathext), ";") {
+		if e != "" && e == ext {
+			return true
+		}
+	}
+	return false
+}
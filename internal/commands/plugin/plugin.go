@@ -0,0 +1,190 @@
+// Package plugin finds the executables named commandripple-NAME in the
+// plugins directory and on PATH, which become the command NAME. A plugin
+// describes itself, and may complete its arguments, through a handshake: it
+// is started with COMMANDRIPPLE_HANDSHAKE=1, reads one JSON request line on
+// standard input and writes one JSON response line to standard output.
+// When run as a command it gets the shell's context as JSON in
+// COMMANDRIPPLE_CONTEXT.
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Names and version of the protocol
+const (
+	Prefix       = "commandripple-"
+	Protocol     = 1
+	HandshakeEnv = "COMMANDRIPPLE_HANDSHAKE"
+	ContextEnv   = "COMMANDRIPPLE_CONTEXT"
+)
+
+// Timeout bounds a handshake, so that a plugin that does not speak the
+// protocol cannot hang the shell
+var Timeout = 2 * time.Second
+
+// Context is the state of the shell a plugin receives. The variables are
+// not part of it, since the plugin is run in the shell's environment, in a
+// handshake as well as when it runs as a command.
+type Context struct {
+	Cwd      string   `json:"cwd"`
+	Version  string   `json:"version"` // Of the shell
+	Config   string   `json:"config"`  // Path of config.toml
+	Terminal Terminal `json:"terminal"`
+}
+
+// Terminal is the size of the shell's terminal and whether standard output
+// goes to it
+type Terminal struct {
+	Columns     int  `json:"columns"`
+	Rows        int  `json:"rows"`
+	Interactive bool `json:"interactive"`
+}
+
+// Flag is an option a plugin accepts
+type Flag struct {
+	Name  string `json:"name"`            // e.g. --force
+	Short string `json:"short,omitempty"` // e.g. -f
+	Arg   string `json:"arg,omitempty"`   // Name of its value, if it takes one
+	Help  string `json:"help,omitempty"`
+}
+
+// Completion says what completes a plugin's arguments. With Dynamic set
+// the plugin is asked for candidates as well.
+type Completion struct {
+	Words   []string `json:"words,omitempty"`
+	Files   bool     `json:"files,omitempty"`
+	Dirs    bool     `json:"dirs,omitempty"`
+	Glob    string   `json:"glob,omitempty"`
+	Dynamic bool     `json:"dynamic,omitempty"`
+}
+
+// Description is a plugin's answer to a describe request
+type Description struct {
+	Help       string     `json:"help,omitempty"`  // One line shown by help
+	Usage      string     `json:"usage,omitempty"` // e.g. deploy [-f] SERVICE
+	Flags      []Flag     `json:"flags,omitempty"`
+	Completion Completion `json:"completion"`
+}
+
+type request struct {
+	Protocol int      `json:"protocol"`
+	Type     string   `json:"type"` // describe or complete
+	Name     string   `json:"name"`
+	Args     []string `json:"args,omitempty"`    // Words before the one being completed
+	Current  string   `json:"current,omitempty"` // The word being completed
+	Context  Context  `json:"context"`
+}
+
+type response struct {
+	Protocol    int      `json:"protocol"`
+	Error       string   `json:"error,omitempty"`
+	Completions []string `json:"completions,omitempty"`
+	Description
+}
+
+// Plugin is an executable found on disk
+type Plugin struct {
+	Name string
+	Path string
+
+	mu    sync.Mutex
+	stamp string // Size and time of the file the description was read from
+	desc  *Description
+	err   error
+}
+
+// Describe asks the plugin, run in env, for its help, flags and completion.
+// The answer is kept until the executable changes.
+func (p *Plugin) Describe(ctx Context, env []string) (*Description, error) {
+	stamp := fileStamp(p.Path)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stamp == stamp && (p.desc != nil || p.err != nil) {
+		return p.desc, p.err
+	}
+
+	var resp response
+	p.err = p.handshake(request{Type: "describe", Context: ctx}, env, &resp)
+	p.desc = nil
+	if p.err == nil {
+		p.desc = &resp.Description
+	}
+	p.stamp = stamp
+	return p.desc, p.err
+}
+
+// Complete asks the plugin, run in env, for the candidates of the word
+// being completed
+func (p *Plugin) Complete(args []string, current string, ctx Context, env []string) ([]string, error) {
+	var resp response
+	err := p.handshake(request{Type: "complete", Args: args, Current: current, Context: ctx}, env, &resp)
+	return resp.Completions, err
+}
+
+// Command returns the plugin run with args in env, with the context added
+func (p *Plugin) Command(args []string, ctx Context, env []string) *exec.Cmd {
+	cmd := exec.Command(p.Path, args...)
+	data, _ := json.Marshal(ctx)
+	cmd.Env = append(env, ContextEnv+"="+string(data))
+	return cmd
+}
+
+// handshake sends one request and reads one response line, with the
+// plugin run in env
+func (p *Plugin) handshake(req request, env []string, resp *response) error {
+	req.Protocol = Protocol
+	req.Name = p.Name
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	timeout, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(timeout, p.Path)
+	cmd.Dir = req.Context.Cwd
+	cmd.Env = append(append([]string(nil), env...), HandshakeEnv+"=1")
+	cmd.Stdin = bytes.NewReader(append(data, '\n'))
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.WaitDelay = Timeout
+	runErr := cmd.Run()
+
+	line, _, _ := strings.Cut(strings.TrimSpace(stdout.String()), "\n")
+	if line == "" {
+		if timeout.Err() != nil {
+			return fmt.Errorf("%s: no answer to the handshake within %v", p.Name, Timeout)
+		}
+		if runErr != nil {
+			return fmt.Errorf("%s: handshake failed: %v", p.Name, runErr)
+		}
+		return fmt.Errorf("%s: no answer to the handshake", p.Name)
+	}
+	if err := json.Unmarshal([]byte(line), resp); err != nil {
+		return fmt.Errorf("%s: invalid handshake answer: %v", p.Name, err)
+	}
+	if resp.Error != "" {
+		return fmt.Errorf("%s: %s", p.Name, resp.Error)
+	}
+	if resp.Protocol != Protocol {
+		return fmt.Errorf("%s: unsupported protocol version %d", p.Name, resp.Protocol)
+	}
+	return nil
+}
+
+func fileStamp(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%d %d", info.Size(), info.ModTime().UnixNano())
+}
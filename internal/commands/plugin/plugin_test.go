@@ -0,0 +1,142 @@
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writePlugin writes a shell script named commandripple-NAME to dir
+func writePlugin(t *testing.T, dir, name, script string, mode os.FileMode) string {
+	t.Helper()
+	path := filepath.Join(dir, Prefix+name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), mode); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func skipWithoutShell(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugins are shell scripts here")
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	skipWithoutShell(t)
+	first, second := t.TempDir(), t.TempDir()
+	a := writePlugin(t, first, "a", "", 0755)
+	writePlugin(t, first, "b", "", 0644)
+	writePlugin(t, second, "a", "", 0755)
+	c := writePlugin(t, second, "c", "", 0755)
+	os.WriteFile(filepath.Join(first, "other"), nil, 0755)
+	os.Mkdir(filepath.Join(first, Prefix+"dir"), 0755)
+
+	found := Discover([]string{"", first, filepath.Join(first, "missing"), second})
+	want := map[string]string{"a": a, "c": c}
+	if len(found) != len(want) {
+		t.Errorf("Discover found %d plugins, want %d", len(found), len(want))
+	}
+	for name, path := range want {
+		if p, ok := found[name]; !ok || p.Path != path {
+			t.Errorf("plugin %s = %+v, want %s", name, p, path)
+		}
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	skipWithoutShell(t)
+	saved := Timeout
+	Timeout = 300 * time.Millisecond
+	defer func() { Timeout = saved }()
+
+	tests := []struct {
+		name   string
+		script string
+		help   string // Of a successful answer
+		err    string // Part of the error otherwise
+	}{
+		{"ok", `echo '{"protocol":1,"help":"Deploys"}'`, "Deploys", ""},
+		{"lines", `echo; echo '{"protocol":1,"help":"First"}'; echo '{"protocol":1,"help":"Second"}'`, "First", ""},
+		{"protocol", `echo '{"protocol":2,"help":"Newer"}'`, "", "unsupported protocol version 2"},
+		{"refused", `echo '{"protocol":1,"error":"not configured"}'`, "", "not configured"},
+		{"invalid", `echo 'Usage: deploy SERVICE'`, "", "invalid handshake answer"},
+		{"silent", `exit 0`, "", "no answer to the handshake"},
+		{"failed", `exit 3`, "", "handshake failed"},
+		{"slow", `exec sleep 10`, "", "no answer to the handshake within"},
+	}
+	for _, tt := range tests {
+		p := &Plugin{Name: tt.name, Path: writePlugin(t, t.TempDir(), tt.name, tt.script, 0755)}
+		start := time.Now()
+		desc, err := p.Describe(Context{Cwd: t.TempDir()}, os.Environ())
+		if elapsed := time.Since(start); elapsed > 3*Timeout {
+			t.Errorf("%s: the handshake took %v", tt.name, elapsed)
+		}
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: Describe = %v, want an error with %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Describe failed: %v", tt.name, err)
+		} else if desc.Help != tt.help {
+			t.Errorf("%s: help = %q, want %q", tt.name, desc.Help, tt.help)
+		}
+	}
+}
+
+func TestHandshakeRequest(t *testing.T) {
+	skipWithoutShell(t)
+	// The plugin answers with what it received: the request line, its
+	// directory and the variables of the shell
+	script := `read -r line
+printf '{"protocol":1,"completions":["%s","%s","%s"]}\n' "$COMMANDRIPPLE_HANDSHAKE" "$(pwd)" "$STAGE"
+printf '%s' "$line" > request.json`
+	dir := t.TempDir()
+	p := &Plugin{Name: "deploy", Path: writePlugin(t, t.TempDir(), "deploy", script, 0755)}
+	got, err := p.Complete([]string{"-f"}, "w", Context{Cwd: dir}, []string{"PATH=" + os.Getenv("PATH"), "STAGE=test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolved, _ := filepath.EvalSymlinks(dir)
+	if len(got) != 3 || got[0] != "1" || (got[1] != dir && got[1] != resolved) || got[2] != "test" {
+		t.Errorf("the plugin saw %q, want 1, %s and test", got, dir)
+	}
+
+	request, _ := os.ReadFile(filepath.Join(dir, "request.json"))
+	for _, want := range []string{`"protocol":1`, `"type":"complete"`, `"name":"deploy"`, `"args":["-f"]`, `"current":"w"`} {
+		if !strings.Contains(string(request), want) {
+			t.Errorf("request %s lacks %s", request, want)
+		}
+	}
+}
+
+func TestDescribeCached(t *testing.T) {
+	skipWithoutShell(t)
+	// Every handshake counts itself, only a changed executable is asked again
+	dir := t.TempDir()
+	script := `echo x >> "` + filepath.Join(dir, "count") + `"; echo '{"protocol":1}'`
+	p := &Plugin{Name: "count", Path: writePlugin(t, dir, "count", script, 0755)}
+	for i := 0; i < 3; i++ {
+		if _, err := p.Describe(Context{Cwd: dir}, os.Environ()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	count := func() int {
+		data, _ := os.ReadFile(filepath.Join(dir, "count"))
+		return strings.Count(string(data), "x")
+	}
+	if n := count(); n != 1 {
+		t.Errorf("three descriptions ran %d handshakes, want 1", n)
+	}
+
+	writePlugin(t, dir, "count", script+"\n# changed", 0755)
+	p.Describe(Context{Cwd: dir}, os.Environ())
+	if n := count(); n != 2 {
+		t.Errorf("after a change %d handshakes ran, want 2", n)
+	}
+}
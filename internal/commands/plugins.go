@@ -0,0 +1,258 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"commandripple/internal/commands/complete"
+	"commandripple/internal/commands/config"
+	"commandripple/internal/commands/plugin"
+	"commandripple/internal/commands/theme"
+
+	"github.com/chzyer/readline"
+)
+
+// pluginDirs returns the plugins directory followed by the PATH
+// directories of the session
+func (s *Session) pluginDirs() []string {
+	dir := s.path(expandHome(s.settings.String("plugins.dir")))
+	if dir == "" {
+		if path, err := config.DefaultPath(); err == nil {
+			dir = filepath.Join(filepath.Dir(path), "plugins")
+		}
+	}
+	return append([]string{dir}, filepath.SplitList(s.Getenv("PATH"))...)
+}
+
+// lookupPlugin returns the plugin run for a command name as of the last
+// refresh, which is cheap enough for every key press. Builtins come first,
+// so a plugin cannot replace one.
+func (s *Session) lookupPlugin(name string) (*plugin.Plugin, bool) {
+	if name == "" || IsBuiltinCommand(name) || strings.ContainsRune(name, os.PathSeparator) {
+		return nil, false
+	}
+	return s.plugins.Lookup(name)
+}
+
+// resolvePlugin is lookupPlugin for running a command, which first reads
+// the plugin directories again if they are out of date
+func (s *Session) resolvePlugin(name string) (*plugin.Plugin, bool) {
+	s.plugins.Refresh(s.pluginDirs())
+	return s.lookupPlugin(name)
+}
+
+// RefreshPlugins reads the plugin directories again in the background, so
+// that the lookups while a line is typed see new plugins. The shell calls
+// it before every prompt.
+func (s *Session) RefreshPlugins() {
+	dirs := s.pluginDirs()
+	go s.plugins.Refresh(dirs)
+}
+
+func (s *Session) pluginNames() []string {
+	var names []string
+	for _, p := range s.plugins.List() {
+		if !IsBuiltinCommand(p.Name) {
+			names = append(names, p.Name)
+		}
+	}
+	return names
+}
+
+// pluginContext returns the state of the shell handed to plugins
+func (s *Session) pluginContext() plugin.Context {
+	configPath := s.settings.Path()
+	if configPath == "" {
+		configPath, _ = config.DefaultPath()
+	}
+
+	terminal := plugin.Terminal{Columns: 80, Rows: 24}
+	for _, stream := range []any{s.Stdout, s.Stdin} {
+		if f, ok := stream.(*os.File); ok && isTerminal(f) {
+			if columns, rows, err := readline.GetSize(int(f.Fd())); err == nil && columns > 0 {
+				terminal.Columns, terminal.Rows = columns, rows
+				break
+			}
+		}
+	}
+	terminal.Interactive = isTerminal(s.Stdout)
+
+	return plugin.Context{
+		Cwd:      s.Dir(),
+		Version:  Version,
+		Config:   configPath,
+		Terminal: terminal,
+	}
+}
+
+// pipelineContext is pluginContext for a stage of a pipeline, whose output
+// only reaches the terminal when it is the last stage and not redirected
+func (s *Session) pipelineContext(cmd Command, isLast bool) plugin.Context {
+	ctx := s.pluginContext()
+	for _, r := range cmd.Redirects {
+		if r.Op == ">" || r.Op == ">>" || r.Op == "&>" {
+			isLast = false
+		}
+	}
+	ctx.Terminal.Interactive = ctx.Terminal.Interactive && isLast
+	return ctx
+}
+
+// externalOrPlugin returns the process that runs a command that is not a
+// builtin
+func (s *Session) externalOrPlugin(name string, args []string) *exec.Cmd {
+	if p, ok := s.resolvePlugin(name); ok {
+		return p.Command(args, s.pluginContext(), s.Environ())
+	}
+	return externalCommand(s, name, args)
+}
+
+// pluginCandidates completes the arguments of a plugin from what it
+// declared in the handshake, asking it when its completion is dynamic
+func (s *Session) pluginCandidates(p *plugin.Plugin, ctx complete.Context) []string {
+	desc, err := p.Describe(s.pluginContext(), s.Environ())
+	if err != nil {
+		return complete.Files(ctx.Current, false, "")
+	}
+	spec := &complete.Spec{
+		Words: desc.Completion.Words,
+		Files: desc.Completion.Files,
+		Dirs:  desc.Completion.Dirs,
+		Glob:  desc.Completion.Glob,
+	}
+	for _, flag := range desc.Flags {
+		for _, name := range []string{flag.Name, flag.Short} {
+			if name != "" {
+				spec.Options = append(spec.Options, name)
+			}
+		}
+	}
+	candidates := spec.Candidates(ctx, nil)
+	if desc.Completion.Dynamic && (len(spec.Options) == 0 || !strings.HasPrefix(ctx.Current, "-")) {
+		words, _ := p.Complete(ctx.Words[1:], ctx.Current, s.pluginContext(), s.Environ())
+		candidates = append(candidates, words...)
+	}
+	return candidates
+}
+
+// printPluginHelp adds the plugins to the output of help
+func (s *Session) printPluginHelp() {
+	s.plugins.Refresh(s.pluginDirs())
+	list := s.plugins.List()
+	if len(list) == 0 {
+		return
+	}
+	s.printColor(theme.Heading, "\nPlugins:")
+	for _, p := range list {
+		if IsBuiltinCommand(p.Name) {
+			continue
+		}
+		usage, help := p.Name, ""
+		if desc, err := p.Describe(s.pluginContext(), s.Environ()); err == nil {
+			help = desc.Help
+			if desc.Usage != "" {
+				usage = desc.Usage
+			}
+		}
+		s.printColor(theme.Command, fmt.Sprintf("  %-18s", usage))
+		fmt.Fprintln(s.Stdout, help)
+	}
+}
+
+// `plugin` command implementation: `plugin list` shows the plugins with
+// where they were found, `plugin info NAME` shows what a plugin declared in
+// its handshake and `plugin reload` looks for plugins again.
+func (s *Session) Plugin(args []string) error {
+	usage := fmt.Errorf("usage: plugin [list|info NAME|reload]")
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch args[0] {
+	case "list":
+		if len(args) != 1 {
+			return usage
+		}
+		s.plugins.Refresh(s.pluginDirs())
+		for _, p := range s.plugins.List() {
+			s.printColorInline(theme.Accent, fmt.Sprintf("%-16s", p.Name))
+			switch desc, err := p.Describe(s.pluginContext(), s.Environ()); {
+			case IsBuiltinCommand(p.Name):
+				fmt.Fprintf(s.Stdout, " %s (hidden by the builtin)\n", p.Path)
+			case err != nil:
+				fmt.Fprintf(s.Stdout, " %s (%v)\n", p.Path, err)
+			case desc.Help != "":
+				fmt.Fprintf(s.Stdout, " %s - %s\n", p.Path, desc.Help)
+			default:
+				fmt.Fprintf(s.Stdout, " %s\n", p.Path)
+			}
+		}
+	case "info":
+		if len(args) != 2 {
+			return usage
+		}
+		p, ok := s.resolvePlugin(args[1])
+		if !ok {
+			return &ExitStatus{Code: 1, Reason: fmt.Sprintf("plugin: %s is not a plugin", args[1])}
+		}
+		desc, err := p.Describe(s.pluginContext(), s.Environ())
+		if err != nil {
+			return fmt.Errorf("plugin: %v", err)
+		}
+		s.printPluginField("path", p.Path)
+		s.printPluginField("usage", desc.Usage)
+		s.printPluginField("help", desc.Help)
+		for _, flag := range desc.Flags {
+			names := flag.Name
+			if flag.Short != "" {
+				names = flag.Short + ", " + names
+			}
+			if flag.Arg != "" {
+				names += " " + flag.Arg
+			}
+			s.printPluginField("flag", fmt.Sprintf("%-24s %s", names, flag.Help))
+		}
+		s.printPluginField("completion", describeCompletion(desc.Completion))
+	case "reload":
+		if len(args) != 1 {
+			return usage
+		}
+		s.plugins.Reload()
+		s.plugins.Refresh(s.pluginDirs())
+		fmt.Fprintf(s.Stdout, "%d plugins found\n", len(s.pluginNames()))
+	default:
+		return usage
+	}
+	return nil
+}
+
+func (s *Session) printPluginField(name, value string) {
+	if value == "" {
+		return
+	}
+	s.printColorInline(theme.Accent, fmt.Sprintf("%-11s", name))
+	fmt.Fprintln(s.Stdout, value)
+}
+
+func describeCompletion(c plugin.Completion) string {
+	var parts []string
+	if len(c.Words) > 0 {
+		parts = append(parts, strings.Join(c.Words, " "))
+	}
+	switch {
+	case c.Dirs:
+		parts = append(parts, "directories")
+	case c.Glob != "":
+		parts = append(parts, "files matching "+strconv.Quote(c.Glob))
+	case c.Files:
+		parts = append(parts, "files")
+	}
+	if c.Dynamic {
+		parts = append(parts, "asks the plugin")
+	}
+	return strings.Join(parts, ", ")
+}
@@ -2,6 +2,7 @@ package processes
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"runtime"
 	"strconv"
@@ -9,7 +10,7 @@ import (
 )
 
 // KillProcess terminates a process by its PID
-func KillProcess(args []string) error {
+func KillProcess(w io.Writer, args []string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("'kill' requires a PID")
 	}
@@ -43,6 +44,6 @@ func KillProcess(args []string) error {
 		return fmt.Errorf("failed to kill process: %v", killErr)
 	}
 
-	fmt.Printf("Process with PID %d has been terminated\n", pid)
+	fmt.Fprintf(w, "Process with PID %d has been terminated\n", pid)
 	return nil
 }
@@ -2,6 +2,7 @@ package processes
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"runtime"
@@ -11,27 +12,27 @@ import (
 )
 
 // KillAll terminates all processes with the given name
-func KillAll(args []string) error {
+func KillAll(w io.Writer, args []string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("usage: killall [name]")
 	}
 	processName := args[0]
 
 	if runtime.GOOS == "windows" {
-		return killAllWindows(processName)
+		return killAllWindows(w, processName)
 	} else {
-		return killAllUnix(processName)
+		return killAllUnix(w, processName)
 	}
 }
 
-func killAllWindows(processName string) error {
+func killAllWindows(w io.Writer, processName string) error {
 	cmd := exec.Command("taskkill", "/F", "/IM", processName)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdout = w
+	cmd.Stderr = w
 	return cmd.Run()
 }
 
-func killAllUnix(processName string) error {
+func killAllUnix(w io.Writer, processName string) error {
 	// First, try using the 'killall' command if it exists
 	killallCmd := exec.Command("killall", processName)
 	if err := killallCmd.Run(); err == nil {
@@ -39,7 +40,7 @@ func killAllUnix(processName string) error {
 	}
 
 	// If 'killall' doesn't exist or fails, fall back to manual process killing
-	processes, err := getProcessesByName(processName)
+	processes, err := getProcessesByName(w, processName)
 	if err != nil {
 		return fmt.Errorf("error finding processes: %v", err)
 	}
@@ -47,16 +48,16 @@ func killAllUnix(processName string) error {
 	for _, pid := range processes {
 		process, err := os.FindProcess(pid)
 		if err != nil {
-			fmt.Printf("Warning: Could not find process %d: %v\n", pid, err)
+			fmt.Fprintf(w, "Warning: Could not find process %d: %v\n", pid, err)
 			continue
 		}
 
 		err = process.Signal(syscall.SIGTERM)
 		if err != nil {
-			fmt.Printf("Warning: Could not send SIGTERM to process %d: %v\n", pid, err)
+			fmt.Fprintf(w, "Warning: Could not send SIGTERM to process %d: %v\n", pid, err)
 			err = process.Signal(syscall.SIGKILL)
 			if err != nil {
-				fmt.Printf("Error: Could not send SIGKILL to process %d: %v\n", pid, err)
+				fmt.Fprintf(w, "Error: Could not send SIGKILL to process %d: %v\n", pid, err)
 			}
 		}
 	}
@@ -64,7 +65,7 @@ func killAllUnix(processName string) error {
 	return nil
 }
 
-func getProcessesByName(name string) ([]int, error) {
+func getProcessesByName(w io.Writer, name string) ([]int, error) {
 	cmd := exec.Command("pgrep", name)
 	output, err := cmd.Output()
 	if err != nil {
@@ -76,7 +77,7 @@ func getProcessesByName(name string) ([]int, error) {
 		if line != "" {
 			pid, err := strconv.Atoi(line)
 			if err != nil {
-				fmt.Printf("Warning: Could not parse PID '%s': %v\n", line, err)
+				fmt.Fprintf(w, "Warning: Could not parse PID '%s': %v\n", line, err)
 				continue
 			}
 			pids = append(pids, pid)
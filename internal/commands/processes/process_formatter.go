@@ -2,25 +2,17 @@ package processes
 
 import (
 	"fmt"
+	"io"
 	"runtime"
 	"strconv"
 	"strings"
 
-	"github.com/olekukonko/tablewriter"
-)
+	"commandripple/internal/commands/theme"
 
-const (
-	ColorReset  = "\033[0m"
-	ColorRed    = "\033[31m"
-	ColorGreen  = "\033[32m"
-	ColorYellow = "\033[33m"
-	ColorBlue   = "\033[34m"
-	ColorPurple = "\033[35m"
-	ColorCyan   = "\033[36m"
-	ColorWhite  = "\033[37m"
+	"github.com/olekukonko/tablewriter"
 )
 
-func FormatProcessList(output string) (string, error) {
+func FormatProcessList(w io.Writer, output string) (string, error) {
 	lines := strings.Split(output, "\n")
 	if len(lines) < 2 {
 		return "", fmt.Errorf("unexpected output format")
@@ -31,13 +23,17 @@ func FormatProcessList(output string) (string, error) {
 	table.SetHeader([]string{"PID", "PPID", "CPU%", "MEM%", "COMMAND"})
 	table.SetBorder(false)
 	table.SetColumnSeparator("")
-	table.SetHeaderColor(
-		tablewriter.Colors{tablewriter.FgHiCyanColor},
-		tablewriter.Colors{tablewriter.FgHiCyanColor},
-		tablewriter.Colors{tablewriter.FgHiCyanColor},
-		tablewriter.Colors{tablewriter.FgHiCyanColor},
-		tablewriter.Colors{tablewriter.FgHiCyanColor},
-	)
+
+	// The table is printed to w, which may not want colors
+	header := tablewriter.Colors(theme.Codes(w, theme.TableHeader))
+	table.SetHeaderColor(header, header, header, header, header)
+	columns := []tablewriter.Colors{
+		theme.Codes(w, theme.PID),
+		theme.Codes(w, theme.PPID),
+		theme.Codes(w, theme.CPU),
+		theme.Codes(w, theme.Memory),
+		theme.Codes(w, theme.ProcessName),
+	}
 
 	for _, line := range lines[1:] {
 		fields := strings.Fields(line)
@@ -47,13 +43,7 @@ func FormatProcessList(output string) (string, error) {
 
 		formattedFields := formatProcessLine(fields)
 		if len(formattedFields) == 5 {
-			table.Rich(formattedFields, []tablewriter.Colors{
-				{tablewriter.FgYellowColor},
-				{tablewriter.FgGreenColor},
-				{tablewriter.FgRedColor},
-				{tablewriter.FgMagentaColor},
-				{tablewriter.FgWhiteColor},
-			})
+			table.Rich(formattedFields, columns)
 		}
 	}
 
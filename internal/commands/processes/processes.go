@@ -2,11 +2,12 @@ package processes
 
 import (
 	"fmt"
+	"io"
 	"os/exec"
 	"runtime"
 )
 
-func ListProcesses() error {
+func ListProcesses(w io.Writer) error {
 	var cmd *exec.Cmd
 
 	switch runtime.GOOS {
@@ -23,11 +24,11 @@ func ListProcesses() error {
 		return fmt.Errorf("error listing processes: %v", err)
 	}
 
-	formattedOutput, err := FormatProcessList(string(output))
+	formattedOutput, err := FormatProcessList(w, string(output))
 	if err != nil {
 		return fmt.Errorf("error formatting process list: %v", err)
 	}
 
-	fmt.Println(formattedOutput)
+	fmt.Fprintln(w, formattedOutput)
 	return nil
 }
@@ -0,0 +1,25 @@
+package processes
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ParseSignal parses a signal given by number or by name, with or without
+// the SIG prefix, e.g. 9, KILL or SIGKILL
+func ParseSignal(name string) (syscall.Signal, error) {
+	if n, err := strconv.Atoi(name); err == nil {
+		if n <= 0 {
+			return 0, fmt.Errorf("invalid signal: %s", name)
+		}
+		return syscall.Signal(n), nil
+	}
+
+	upper := strings.TrimPrefix(strings.ToUpper(name), "SIG")
+	if sig, ok := lookupSignal(upper); ok {
+		return sig, nil
+	}
+	return 0, fmt.Errorf("unknown signal: %s", name)
+}
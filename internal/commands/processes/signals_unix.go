@@ -0,0 +1,28 @@
+//go:build !windows
+// +build !windows
+
+package processes
+
+import (
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+func lookupSignal(name string) (syscall.Signal, bool) {
+	sig := unix.SignalNum("SIG" + name)
+	return sig, sig != 0
+}
+
+// SignalNames lists the names of the signals supported by the platform,
+// without the SIG prefix
+func SignalNames() []string {
+	var names []string
+	for sig := syscall.Signal(1); sig < 32; sig++ {
+		if name := unix.SignalName(sig); name != "" {
+			names = append(names, strings.TrimPrefix(name, "SIG"))
+		}
+	}
+	return names
+}
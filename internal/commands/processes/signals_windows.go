@@ -0,0 +1,26 @@
+//go:build windows
+// +build windows
+
+package processes
+
+import (
+	"syscall"
+)
+
+var windowsSignals = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"KILL": syscall.SIGKILL,
+	"TERM": syscall.SIGTERM,
+}
+
+func lookupSignal(name string) (syscall.Signal, bool) {
+	sig, ok := windowsSignals[name]
+	return sig, ok
+}
+
+// SignalNames lists the names of the signals supported by the platform,
+// without the SIG prefix
+func SignalNames() []string {
+	return []string{"HUP", "INT", "KILL", "TERM"}
+}
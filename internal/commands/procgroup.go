@@ -0,0 +1,68 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"sync"
+	"syscall"
+)
+
+// processGroup tracks the processes spawned while it is active so that they
+// can be signalled together, e.g. when a timeout expires. Each tracked
+// process is started in its own process group, which the programs it starts
+// in turn belong to as well. Its context is cancelled at the same time, for
+// the builtins that run inside the shell.
+type processGroup struct {
+	ctx       context.Context
+	parent    *processGroup // Also tracks the processes of this group
+	mutex     sync.Mutex
+	pids      []int
+	signalled bool // Processes started later are refused
+}
+
+// commandContext returns the context builtins that loop or wait check, which
+// is cancelled when the timeout they run under expires
+func (s *Session) commandContext() context.Context {
+	if s.group != nil {
+		return s.group.ctx
+	}
+	return context.Background()
+}
+
+// start starts a process in the group and the groups enclosing it. Holding
+// their locks until it is registered means a signal cannot miss it.
+func (g *processGroup) start(cmd *exec.Cmd) error {
+	var chain []*processGroup
+	for group := g; group != nil; group = group.parent {
+		chain = append(chain, group)
+	}
+	// Outermost first, the order every start locks them in
+	for i := len(chain) - 1; i >= 0; i-- {
+		chain[i].mutex.Lock()
+		defer chain[i].mutex.Unlock()
+	}
+	for _, group := range chain {
+		if group.signalled {
+			return fmt.Errorf("not started: %v", context.Cause(group.ctx))
+		}
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	pgid := processGroupID(cmd)
+	for _, group := range chain {
+		group.pids = append(group.pids, pgid)
+	}
+	return nil
+}
+
+// signal sends sig to every tracked process group
+func (g *processGroup) signal(sig syscall.Signal) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.signalled = true
+	for _, pid := range g.pids {
+		signalProcessGroup(pid, sig)
+	}
+}
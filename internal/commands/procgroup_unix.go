@@ -0,0 +1,28 @@
+//go:build !windows
+// +build !windows
+
+package commands
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+func signalProcessGroup(pgid int, sig syscall.Signal) error {
+	return syscall.Kill(-pgid, sig)
+}
+
+// processGroupID returns the process group a started command was placed in
+func processGroupID(cmd *exec.Cmd) int {
+	if cmd.SysProcAttr != nil && cmd.SysProcAttr.Pgid != 0 {
+		return cmd.SysProcAttr.Pgid
+	}
+	return cmd.Process.Pid
+}
@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"time"
+
+	"commandripple/internal/commands/prompt"
+)
+
+// DefaultPrompt is used when neither PROMPT nor PS1 is set
+const DefaultPrompt = "{bold}{blue}{cwd}{reset}{git: {magenta}(%s){reset}}{jobs: {yellow}[%s jobs]{reset}}{status: {red}✘%s{reset}} CommandRipple> "
+
+// CommandFinished records the result of a command line for the prompt
+func (s *Session) CommandFinished(err error, duration time.Duration) {
+	s.lastCommand.exitCode = ExitCode(err)
+	s.lastCommand.duration = duration
+}
+
+// Prompt renders the prompt template in PROMPT, or PS1, or prompt.template
+// from the config
+func (s *Session) Prompt() string {
+	template := s.Getenv("PROMPT")
+	if template == "" {
+		template = s.Getenv("PS1")
+	}
+	if template == "" {
+		template = s.settings.String("prompt.template")
+	}
+	if template == "" {
+		template = DefaultPrompt
+	}
+	return prompt.Render(template, s.promptState())
+}
+
+// RightPrompt renders the template in RPROMPT, or prompt.right, shown at
+// the right edge of the line being edited
+func (s *Session) RightPrompt() string {
+	template := s.Getenv("RPROMPT")
+	if template == "" {
+		template = s.settings.String("prompt.right")
+	}
+	if template == "" {
+		return ""
+	}
+	return prompt.Render(template, s.promptState())
+}
+
+// TransientPrompt renders the template in TRANSIENT_PROMPT, or
+// prompt.transient, which replaces the prompt of a line once it has been
+// entered. It reports false when no transient prompt is set.
+func (s *Session) TransientPrompt() (string, bool) {
+	template, ok := s.LookupEnv("TRANSIENT_PROMPT")
+	if !ok && s.settings.IsSet("prompt.transient") {
+		template, ok = s.settings.String("prompt.transient"), true
+	}
+	if !ok {
+		return "", false
+	}
+	return prompt.Render(template, s.promptState()), true
+}
+
+func (s *Session) promptState() prompt.State {
+	return prompt.State{
+		ExitCode: s.lastCommand.exitCode,
+		Duration: s.lastCommand.duration,
+		Jobs:     s.activeJobCount(),
+		Now:      time.Now(),
+	}
+}
+
+// activeJobCount returns the number of jobs that are running or stopped
+func (s *Session) activeJobCount() int {
+	s.jobs.mutex.Lock()
+	defer s.jobs.mutex.Unlock()
+	count := 0
+	for _, job := range s.jobs.jobs {
+		if job.Status != JobDone {
+			count++
+		}
+	}
+	return count
+}
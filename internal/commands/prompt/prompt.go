@@ -0,0 +1,285 @@
+// Package prompt expands prompt templates. A template mixes text with
+// bash-style escapes such as \u, \w or \$ and segments in braces such as
+// {cwd}, {git} or {status}. A segment may carry a format, {git: (%s)},
+// whose text only appears when the segment has a value, and styles such as
+// {red} or {bold} color what follows until {reset}.
+package prompt
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"commandripple/internal/commands/gitstatus"
+)
+
+// State is the shell state shown by the segments
+type State struct {
+	ExitCode int           // Exit status of the last command line
+	Duration time.Duration // How long the last command line took
+	Jobs     int           // Jobs that are running or stopped
+	Now      time.Time
+}
+
+const (
+	// MinDuration is the shortest command duration shown by {duration}
+	MinDuration = 2 * time.Second
+
+	// maxCwdWidth is the width above which {cwd} abbreviates parent
+	// directories
+	maxCwdWidth = 30
+)
+
+// Styles usable as segments, e.g. {bold}{blue}{cwd}{reset}
+var styles = map[string]string{
+	"reset":   "\033[0m",
+	"bold":    "\033[1m",
+	"dim":     "\033[2m",
+	"black":   "\033[30m",
+	"red":     "\033[31m",
+	"green":   "\033[32m",
+	"yellow":  "\033[33m",
+	"blue":    "\033[34m",
+	"magenta": "\033[35m",
+	"cyan":    "\033[36m",
+	"white":   "\033[37m",
+}
+
+// segments return the value of a segment, empty when it has none
+var segments = map[string]func(State) string{
+	"user":     func(State) string { return userName() },
+	"host":     func(State) string { return shortHost() },
+	"cwd":      func(State) string { return ShortenPath(workingDir(), maxCwdWidth) },
+	"dir":      func(State) string { return filepath.Base(ShortenPath(workingDir(), 0)) },
+	"status":   statusSegment,
+	"duration": durationSegment,
+	"jobs":     jobsSegment,
+	"time":     func(s State) string { return s.Now.Format("15:04:05") },
+	"git":      gitSegment,
+	"stash":    stashSegment,
+	"ssh":      sshSegment,
+}
+
+// Render expands a template. Unknown escapes and segments are kept as
+// written so that mistakes are visible.
+func Render(template string, state State) string {
+	if state.Now.IsZero() {
+		state.Now = time.Now()
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(template); i++ {
+		c := template[i]
+		switch {
+		case c == '\\' && i+1 < len(template):
+			i++
+			b.WriteString(escape(template[i], state))
+		case c == '{':
+			end := closingBrace(template, i)
+			if end < 0 {
+				b.WriteString(template[i:])
+				return b.String()
+			}
+			b.WriteString(segment(template[i+1:end], state))
+			i = end
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func segment(spec string, state State) string {
+	name, format, hasFormat := strings.Cut(spec, ":")
+	if style, ok := styles[name]; ok && !hasFormat {
+		return style
+	}
+	fn, ok := segments[name]
+	if !ok {
+		return "{" + spec + "}"
+	}
+	value := fn(state)
+	if value == "" || !hasFormat {
+		return value
+	}
+	// The format may hold styles, {status:{red}%s{reset}}
+	return strings.ReplaceAll(Render(format, state), "%s", value)
+}
+
+// closingBrace returns the index of the brace closing the one at start,
+// allowing nested segments in formats, or -1
+func closingBrace(template string, start int) int {
+	depth := 0
+	for i := start; i < len(template); i++ {
+		switch template[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// escape expands the bash prompt escape \c
+func escape(c byte, state State) string {
+	switch c {
+	case 'u':
+		return userName()
+	case 'h':
+		return shortHost()
+	case 'H':
+		host, _ := os.Hostname()
+		return host
+	case 'w':
+		return ShortenPath(workingDir(), 0)
+	case 'W':
+		return filepath.Base(ShortenPath(workingDir(), 0))
+	case 't':
+		return state.Now.Format("15:04:05")
+	case 'd':
+		return state.Now.Format("Mon Jan 02")
+	case 'j':
+		return fmt.Sprint(state.Jobs)
+	case '?':
+		return fmt.Sprint(state.ExitCode)
+	case 'n':
+		return "\n"
+	case 'e':
+		return "\033"
+	case '$':
+		if isRoot() {
+			return "#"
+		}
+		return "$"
+	case '\\':
+		return "\\"
+	case '[', ']':
+		// Markers for non-printing text in bash, not needed here
+		return ""
+	}
+	return "\\" + string(c)
+}
+
+func statusSegment(state State) string {
+	if state.ExitCode == 0 {
+		return ""
+	}
+	return fmt.Sprint(state.ExitCode)
+}
+
+func durationSegment(state State) string {
+	if state.Duration < MinDuration {
+		return ""
+	}
+	if state.Duration < time.Minute {
+		return state.Duration.Round(100 * time.Millisecond).String()
+	}
+	return state.Duration.Round(time.Second).String()
+}
+
+func jobsSegment(state State) string {
+	if state.Jobs == 0 {
+		return ""
+	}
+	return fmt.Sprint(state.Jobs)
+}
+
+func gitSegment(State) string {
+	status, err := gitstatus.Get(workingDir())
+	if status == nil || err != nil {
+		return ""
+	}
+	return status.Short()
+}
+
+func stashSegment(State) string {
+	status, err := gitstatus.Get(workingDir())
+	if status == nil || err != nil || status.Stashes == 0 {
+		return ""
+	}
+	return fmt.Sprint(status.Stashes)
+}
+
+func sshSegment(State) string {
+	for _, name := range []string{"SSH_CONNECTION", "SSH_CLIENT", "SSH_TTY"} {
+		if os.Getenv(name) != "" {
+			return "ssh"
+		}
+	}
+	return ""
+}
+
+func userName() string {
+	if u, err := user.Current(); err == nil {
+		// DOMAIN\user on Windows
+		name := u.Username
+		if i := strings.LastIndexByte(name, '\\'); i >= 0 {
+			name = name[i+1:]
+		}
+		return name
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return os.Getenv("USERNAME")
+}
+
+func shortHost() string {
+	host, _ := os.Hostname()
+	host, _, _ = strings.Cut(host, ".")
+	return host
+}
+
+func isRoot() bool {
+	return runtime.GOOS != "windows" && os.Geteuid() == 0
+}
+
+func workingDir() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "unknown"
+	}
+	return dir
+}
+
+// ShortenPath replaces the home directory with ~ and, while the path is
+// wider than max, abbreviates parent directories to their first letter
+// from the left, e.g. ~/s/g/commandripple. A max of 0 only replaces home.
+func ShortenPath(path string, max int) string {
+	if home, err := os.UserHomeDir(); err == nil && home != "" {
+		if path == home {
+			path = "~"
+		} else if strings.HasPrefix(path, home+string(os.PathSeparator)) {
+			path = "~" + path[len(home):]
+		}
+	}
+	if max <= 0 || utf8.RuneCountInString(path) <= max {
+		return path
+	}
+
+	parts := strings.Split(path, string(os.PathSeparator))
+	for i := 0; i < len(parts)-1 && utf8.RuneCountInString(strings.Join(parts, string(os.PathSeparator))) > max; i++ {
+		part := parts[i]
+		if part == "" || part == "~" {
+			continue
+		}
+		// Keep the dot of hidden directories so that they stay recognizable
+		n := 1
+		if strings.HasPrefix(part, ".") {
+			n = 2
+		}
+		if runes := []rune(part); len(runes) > n {
+			parts[i] = string(runes[:n])
+		}
+	}
+	return strings.Join(parts, string(os.PathSeparator))
+}
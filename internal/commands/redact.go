@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"commandripple/internal/commands/history"
+	"commandripple/internal/commands/redact"
+)
+
+var (
+	redactorOnce sync.Once
+	redactor     *redact.Redactor
+)
+
+// secretRedactor returns the redaction rules, loading the user's rules on
+// first use. Broken user rules are reported and the defaults used instead.
+func secretRedactor() *redact.Redactor {
+	redactorOnce.Do(func() {
+		redactor = &redact.Redactor{Rules: redact.DefaultRules}
+		path, err := redact.RulesPath()
+		if err != nil {
+			return
+		}
+		loaded, err := redact.Load(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "CommandRipple: %v\n", err)
+			return
+		}
+		redactor = loaded
+	})
+	return redactor
+}
+
+// RedactSecrets masks tokens, passwords and other secrets in a line
+func RedactSecrets(line string) string {
+	redacted, _ := secretRedactor().Redact(line)
+	return redacted
+}
+
+// scrubHistory applies the redaction rules to the stored history and to
+// the given files, rewriting them in place
+func (s *Session) scrubHistory(files []string) error {
+	r := secretRedactor()
+	changed, err := s.history.Rewrite(func(entry history.Entry) (history.Entry, bool) {
+		entry.Command, _ = r.Redact(entry.Command)
+		return entry, true
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(s.Stdout, "Scrubbed %d history entries\n", changed)
+
+	for _, name := range files {
+		file := s.path(name)
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		lines := strings.SplitAfter(string(data), "\n")
+		changed := 0
+		for i, line := range lines {
+			if redacted, found := r.Redact(line); found {
+				lines[i] = redacted
+				changed++
+			}
+		}
+		if changed > 0 {
+			info, err := os.Stat(file)
+			if err != nil {
+				return err
+			}
+			tmp := file + ".tmp"
+			if err := os.WriteFile(tmp, []byte(strings.Join(lines, "")), info.Mode().Perm()); err != nil {
+				return err
+			}
+			if err := os.Rename(tmp, file); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(s.Stdout, "Scrubbed %d lines in %s\n", changed, name)
+	}
+	return nil
+}
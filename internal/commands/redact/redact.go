@@ -0,0 +1,129 @@
+// Package redact masks secrets such as API tokens and passwords in command
+// lines before they are written to the history or a log file.
+package redact
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// Mask replaces every secret
+const Mask = "[REDACTED]"
+
+// Rule finds secrets in a line. When the pattern has a group named secret
+// only that group is masked, otherwise the whole match is.
+type Rule struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+// DefaultRules cover assignments to variables that usually hold secrets,
+// password and token options, credentials in URLs and the token formats of
+// common services. PASS and PWD only count as a whole name or after an
+// underscore, so that BYPASS, PWD and OLDPWD are kept.
+var DefaultRules = []Rule{
+	{"secret assignment", regexp.MustCompile(`(?i)\b(?:[A-Z0-9_]*(?:_KEY|_TOKEN|_SECRET|PASSWORD|PASSWD|_PWD)|(?:[A-Z0-9_]*_)?PASS)=(?P<secret>[^\s'"]+|'[^']*'|"[^"]*")`)},
+	{"secret option", regexp.MustCompile(`(?i)(?:^|\s)--?(?:password|passwd|token|secret|api-key|apikey)(?:=|\s+)(?P<secret>[^\s'"]+|'[^']*'|"[^"]*")`)},
+	// The MySQL clients take the password attached to -p, which elsewhere
+	// is an ordinary flag, as in mkdir -p or ssh -p22
+	{"mysql password", regexp.MustCompile(`\b(?:mysql|mariadb)[\w-]*\s(?:[^|]*?\s)?-p(?P<secret>[^\s'"]+|'[^']*'|"[^"]*")`)},
+	{"authorization header", regexp.MustCompile(`(?i)authorization:\s*(?:bearer|basic|token)\s+(?P<secret>[A-Za-z0-9._~+/=-]+)`)},
+	{"url credentials", regexp.MustCompile(`://[^/\s:@]+:(?P<secret>[^/\s@]+)@`)},
+	{"aws access key", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"github token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{"gitlab token", regexp.MustCompile(`\bglpat-[A-Za-z0-9_-]{20,}\b`)},
+	{"slack token", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}\b`)},
+	{"api key", regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}\b`)},
+	{"google api key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{"jwt", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}\b`)},
+}
+
+// Redactor applies a set of rules
+type Redactor struct {
+	Rules []Rule
+}
+
+// RulesPath returns the file with additional rules:
+// $XDG_CONFIG_HOME/commandripple/redact, ~/.config/commandripple/redact or
+// %AppData%\commandripple\redact on Windows
+func RulesPath() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "commandripple", "redact"), nil
+	}
+	if runtime.GOOS == "windows" {
+		if dir := os.Getenv("AppData"); dir != "" {
+			return filepath.Join(dir, "commandripple", "redact"), nil
+		}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the config directory: %v", err)
+	}
+	return filepath.Join(home, ".config", "commandripple", "redact"), nil
+}
+
+// Load returns a redactor with the default rules followed by the rules in
+// the file at path, one regular expression per line. Blank lines and lines
+// starting with # are ignored. A missing file is not an error.
+func Load(path string) (*Redactor, error) {
+	r := &Redactor{Rules: append([]Rule(nil), DefaultRules...)}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return r, fmt.Errorf("failed to read redaction rules: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pattern, err := regexp.Compile(line)
+		if err != nil {
+			return r, fmt.Errorf("%s:%d: invalid redaction rule: %v", path, n, err)
+		}
+		r.Rules = append(r.Rules, Rule{Name: fmt.Sprintf("%s:%d", filepath.Base(path), n), Pattern: pattern})
+	}
+	return r, scanner.Err()
+}
+
+// Redact masks the secrets in line and reports whether there were any
+func (r *Redactor) Redact(line string) (string, bool) {
+	found := false
+	for _, rule := range r.Rules {
+		group := rule.Pattern.SubexpIndex("secret")
+		matches := rule.Pattern.FindAllStringSubmatchIndex(line, -1)
+		if len(matches) == 0 {
+			continue
+		}
+
+		var b strings.Builder
+		last := 0
+		for _, m := range matches {
+			start, end := m[0], m[1]
+			if group > 0 {
+				start, end = m[2*group], m[2*group+1]
+			}
+			if start < 0 || line[start:end] == Mask {
+				continue
+			}
+			b.WriteString(line[last:start])
+			b.WriteString(Mask)
+			last = end
+			found = true
+		}
+		b.WriteString(line[last:])
+		line = b.String()
+	}
+	return line, found
+}
@@ -0,0 +1,61 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+)
+
+// Redirect sends a standard stream of a command to or from a file
+type Redirect struct {
+	Op     string // <, >, >>, 2>, 2>>, &> or 2>&1
+	Target string // The file, empty for 2>&1
+}
+
+// withRedirects runs fn in a copy of the session whose streams are
+// redirected. The files are opened in order, so that `> out 2>&1` sends both
+// streams to out while `2>&1 > out` does not.
+func (s *Session) withRedirects(redirects []Redirect, fn func(*Session) error) error {
+	if len(redirects) == 0 {
+		return fn(s)
+	}
+
+	sub := *s
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	for _, redirect := range redirects {
+		if redirect.Op == "2>&1" {
+			sub.Stderr = sub.Stdout
+			continue
+		}
+
+		flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		switch redirect.Op {
+		case "<":
+			flag = os.O_RDONLY
+		case ">>", "2>>":
+			flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		}
+		f, err := os.OpenFile(s.path(redirect.Target), flag, 0644)
+		if err != nil {
+			return fmt.Errorf("cannot redirect: %v", err)
+		}
+		files = append(files, f)
+
+		switch redirect.Op {
+		case "<":
+			sub.Stdin = f
+		case ">", ">>":
+			sub.Stdout = f
+		case "2>", "2>>":
+			sub.Stderr = f
+		case "&>":
+			sub.Stdout = f
+			sub.Stderr = f
+		}
+	}
+	return fn(&sub)
+}
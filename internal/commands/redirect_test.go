@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"commandripple/internal/commands/syntax"
+)
+
+func TestWithRedirects(t *testing.T) {
+	tests := []struct {
+		redirects string // With OUT, ERR and IN standing for files
+		out, err  string // What the files hold afterwards
+		terminal  string // What reached the original standard output
+	}{
+		{"", "", "", "out\nerr\n"},
+		{"> OUT", "out\n", "", "err\n"},
+		{">> OUT", "old\nout\n", "", "err\n"},
+		{"2> ERR", "", "err\n", "out\n"},
+		{"2>> ERR", "", "old\nerr\n", "out\n"},
+		{"&> OUT", "out\nerr\n", "", ""},
+		{"> OUT 2>&1", "out\nerr\n", "", ""},
+		{"2>&1 > OUT", "out\n", "", "err\n"},
+		{"> OUT 2> ERR", "out\n", "err\n", ""},
+		{"< IN", "", "", "out\nerr\ninput\n"},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		files := map[string]string{
+			"OUT": filepath.Join(dir, "out"),
+			"ERR": filepath.Join(dir, "err"),
+			"IN":  filepath.Join(dir, "in"),
+		}
+		line := "cmd " + tt.redirects
+		for name, path := range files {
+			line = strings.ReplaceAll(line, name, syntax.Quote(path))
+		}
+		os.WriteFile(files["OUT"], []byte("old\n"), 0644)
+		os.WriteFile(files["ERR"], []byte("old\n"), 0644)
+		os.WriteFile(files["IN"], []byte("input\n"), 0644)
+
+		// Both streams start out on the terminal
+		var terminal strings.Builder
+		session := NewSession(dir, nil)
+		session.Stdin = strings.NewReader("")
+		session.Stdout, session.Stderr = &terminal, &terminal
+		pipeline, err := session.ParsePipeline(line)
+		if err != nil {
+			t.Fatalf("ParsePipeline(%q) failed: %v", line, err)
+		}
+		err = session.withRedirects(pipeline[0].Redirects, func(c *Session) error {
+			fmt.Fprintln(c.Stdout, "out")
+			fmt.Fprintln(c.Stderr, "err")
+			input, _ := io.ReadAll(c.Stdin)
+			fmt.Fprint(c.Stdout, string(input))
+			return nil
+		})
+		if err != nil {
+			t.Errorf("%q: %v", tt.redirects, err)
+			continue
+		}
+
+		read := func(name string) string {
+			data, _ := os.ReadFile(files[name])
+			if string(data) == "old\n" {
+				return ""
+			}
+			return string(data)
+		}
+		if got := read("OUT"); got != tt.out {
+			t.Errorf("%q: the output file holds %q, want %q", tt.redirects, got, tt.out)
+		}
+		if got := read("ERR"); got != tt.err {
+			t.Errorf("%q: the error file holds %q, want %q", tt.redirects, got, tt.err)
+		}
+		if got := terminal.String(); got != tt.terminal {
+			t.Errorf("%q: the terminal got %q, want %q", tt.redirects, got, tt.terminal)
+		}
+	}
+}
+
+func TestWithRedirectsMissingInput(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+	ran := false
+	err := NewSession(t.TempDir(), nil).withRedirects([]Redirect{{Op: "<", Target: missing}}, func(*Session) error {
+		ran = true
+		return nil
+	})
+	if err == nil || ran {
+		t.Errorf("withRedirects from a missing file = %v, ran = %v, want an error before running", err, ran)
+	}
+}
@@ -1,10 +1,10 @@
 package commands
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net"
-	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
@@ -14,7 +14,7 @@ import (
 )
 
 // RemoteExecute executes a command on a remote machine via SSH
-func RemoteExecute(args []string) error {
+func (s *Session) RemoteExecute(args []string) error {
 	if len(args) < 4 {
 		return fmt.Errorf("usage: remote_execute [user] [host] [port] [command]")
 	}
@@ -23,14 +23,8 @@ func RemoteExecute(args []string) error {
 	port := args[2]
 	command := strings.Join(args[3:], " ") // Allow multi-word commands
 
-	// Setup SSH client configuration
-	config, err := getSSHConfig(user)
-	if err != nil {
-		return fmt.Errorf("failed to configure SSH client: %v", err)
-	}
-
 	// Connect to the SSH server
-	client, err := ssh.Dial("tcp", fmt.Sprintf("%s:%s", host, port), config)
+	client, err := s.dialSSH(user, host, port)
 	if err != nil {
 		return fmt.Errorf("unable to connect: %v", err)
 	}
@@ -49,12 +43,41 @@ func RemoteExecute(args []string) error {
 		return fmt.Errorf("command execution failed: %v\nOutput: %s", err, string(output))
 	}
 
-	fmt.Println(string(output))
+	fmt.Fprintln(s.Stdout, string(output))
 	return nil
 }
 
-func getSSHConfig(user string) (*ssh.ClientConfig, error) {
-	authMethods, err := getAuthMethods()
+// dialSSH connects to an SSH server. The connection is closed when the
+// command's context is done, which is how `timeout` stops a transfer or a
+// remote command, as there is no local process to signal.
+func (s *Session) dialSSH(user, host, port string) (*ssh.Client, error) {
+	config, err := s.getSSHConfig(user)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure SSH client: %v", err)
+	}
+
+	ctx := s.commandContext()
+	address := net.JoinHostPort(host, port)
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	c, chans, reqs, err := ssh.NewClientConn(conn, address, config)
+	if err != nil {
+		stop()
+		conn.Close()
+		if ctxErr := context.Cause(ctx); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, err
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+func (s *Session) getSSHConfig(user string) (*ssh.ClientConfig, error) {
+	authMethods, err := s.getAuthMethods()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get authentication methods: %v", err)
 	}
@@ -66,22 +89,22 @@ func getSSHConfig(user string) (*ssh.ClientConfig, error) {
 	}, nil
 }
 
-func getAuthMethods() ([]ssh.AuthMethod, error) {
+func (s *Session) getAuthMethods() ([]ssh.AuthMethod, error) {
 	var authMethods []ssh.AuthMethod
 
 	// Try SSH key authentication first
-	if sshKeyAuth, err := getSSHKeyAuth(); err == nil {
+	if sshKeyAuth, err := s.getSSHKeyAuth(); err == nil {
 		authMethods = append(authMethods, sshKeyAuth)
 	} else {
-		fmt.Printf("SSH key authentication failed: %v\n", err)
+		fmt.Fprintf(s.Stdout, "SSH key authentication failed: %v\n", err)
 	}
 
 	// Try SSH Agent if key auth failed
 	if len(authMethods) == 0 {
-		if sshAgentAuth, err := getSSHAgentAuth(); err == nil {
+		if sshAgentAuth, err := s.getSSHAgentAuth(); err == nil {
 			authMethods = append(authMethods, sshAgentAuth)
 		} else {
-			fmt.Printf("SSH agent authentication failed: %v\n", err)
+			fmt.Fprintf(s.Stdout, "SSH agent authentication failed: %v\n", err)
 		}
 	}
 
@@ -92,12 +115,12 @@ func getAuthMethods() ([]ssh.AuthMethod, error) {
 	return authMethods, nil
 }
 
-func getSSHAgentAuth() (ssh.AuthMethod, error) {
+func (s *Session) getSSHAgentAuth() (ssh.AuthMethod, error) {
 	if runtime.GOOS == "windows" {
 		return nil, fmt.Errorf("SSH Agent auth not implemented for Windows")
 	}
 
-	socket := os.Getenv("SSH_AUTH_SOCK")
+	socket := s.Getenv("SSH_AUTH_SOCK")
 	if socket == "" {
 		return nil, fmt.Errorf("SSH_AUTH_SOCK not set")
 	}
@@ -111,8 +134,8 @@ func getSSHAgentAuth() (ssh.AuthMethod, error) {
 	return ssh.PublicKeysCallback(agentClient.Signers), nil
 }
 
-func getSSHKeyAuth() (ssh.AuthMethod, error) {
-	home, err := os.UserHomeDir()
+func (s *Session) getSSHKeyAuth() (ssh.AuthMethod, error) {
+	home, err := s.homeDir()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user home directory: %v", err)
 	}
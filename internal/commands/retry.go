@@ -0,0 +1,139 @@
+package commands
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"commandripple/internal/commands/syntax"
+)
+
+type retryOptions struct {
+	Attempts int
+	Delay    time.Duration
+	MaxDelay time.Duration
+	Backoff  string
+	OnExit   map[int]bool
+}
+
+// Retry runs a command until it succeeds or the attempts are exhausted,
+// e.g. `retry --attempts 5 --backoff exp --max-delay 30s --on-exit 1,2 -- curl ...`
+func (s *Session) Retry(args []string) error {
+	options := retryOptions{
+		Attempts: 3,
+		Delay:    time.Second,
+		MaxDelay: 30 * time.Second,
+		Backoff:  "exp",
+	}
+
+	i := 0
+	for ; i < len(args); i++ {
+		if args[i] == "--" {
+			i++
+			break
+		}
+		if !strings.HasPrefix(args[i], "--") {
+			break
+		}
+		if i+1 >= len(args) {
+			return fmt.Errorf("missing value for %s", args[i])
+		}
+		if err := options.set(args[i], args[i+1]); err != nil {
+			return err
+		}
+		i++
+	}
+
+	if i >= len(args) {
+		return fmt.Errorf("usage: retry [--attempts N] [--delay D] [--backoff exp|linear|const] [--max-delay D] [--on-exit CODES] -- command")
+	}
+	commandLine := syntax.Join(args[i:])
+	ctx := s.commandContext()
+
+	var err error
+	for attempt := 1; attempt <= options.Attempts; attempt++ {
+		err = s.executeCommand(commandLine)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+
+		code := ExitCode(err)
+		if options.OnExit != nil && !options.OnExit[code] {
+			return err
+		}
+		if attempt == options.Attempts {
+			break
+		}
+
+		delay := options.backoffDelay(attempt)
+		fmt.Fprintf(s.Stderr, "retry: attempt %d/%d failed (exit %d), retrying in %v\n",
+			attempt, options.Attempts, code, delay.Round(time.Millisecond))
+		if err := sleepContext(ctx, delay); err != nil {
+			return err
+		}
+	}
+
+	return fmt.Errorf("command failed after %d attempts: %w", options.Attempts, err)
+}
+
+func (o *retryOptions) set(flag, value string) error {
+	var err error
+	switch flag {
+	case "--attempts":
+		o.Attempts, err = strconv.Atoi(value)
+		if err != nil || o.Attempts < 1 {
+			return fmt.Errorf("invalid number of attempts: %s", value)
+		}
+	case "--delay":
+		o.Delay, err = parseTimeoutDuration(value)
+	case "--max-delay":
+		o.MaxDelay, err = parseTimeoutDuration(value)
+	case "--backoff":
+		switch value {
+		case "exp", "linear", "const":
+			o.Backoff = value
+		default:
+			return fmt.Errorf("invalid backoff: %s (expected exp, linear or const)", value)
+		}
+	case "--on-exit":
+		o.OnExit = make(map[int]bool)
+		for _, field := range strings.Split(value, ",") {
+			code, convErr := strconv.Atoi(strings.TrimSpace(field))
+			if convErr != nil {
+				return fmt.Errorf("invalid exit code: %s", field)
+			}
+			o.OnExit[code] = true
+		}
+	default:
+		return fmt.Errorf("unknown option: %s", flag)
+	}
+	return err
+}
+
+// backoffDelay returns the jittered delay before the next attempt, between
+// half and all of the computed backoff
+func (o *retryOptions) backoffDelay(attempt int) time.Duration {
+	delay := o.Delay
+	switch o.Backoff {
+	case "exp":
+		for n := 1; n < attempt && delay < o.MaxDelay; n++ {
+			delay *= 2
+		}
+	case "linear":
+		delay *= time.Duration(attempt)
+	}
+	if delay > o.MaxDelay {
+		delay = o.MaxDelay
+	}
+
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
@@ -0,0 +1,298 @@
+package ripplefile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FileName is the name of the file tasks are read from
+const FileName = "Ripplefile"
+
+// Task is a named recipe from a Ripplefile:
+//
+//	[build]
+//	desc = Build the shell
+//	deps = generate
+//	env = CGO_ENABLED=0
+//	dir = cmd/commandripple
+//	inputs = **/*.go go.mod
+//	outputs = commandripple
+//	run = go build -o commandripple .
+type Task struct {
+	Name    string
+	Desc    string
+	Deps    []string
+	Env     []string // NAME=value assignments
+	Dir     string   // Working directory, relative to the Ripplefile
+	Inputs  []string // Glob patterns, ** matches any number of directories
+	Outputs []string
+	Run     []string // Command lines, run in order
+}
+
+// File is a parsed Ripplefile
+type File struct {
+	Path  string
+	Dir   string // Directory containing the Ripplefile
+	Tasks map[string]*Task
+}
+
+// Find looks for a Ripplefile in dir and its parent directories
+func Find(dir string) (string, error) {
+	for {
+		path := filepath.Join(dir, FileName)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("no %s found", FileName)
+		}
+		dir = parent
+	}
+}
+
+// Load reads and parses a Ripplefile
+func Load(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	file, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	file.Path = abs
+	file.Dir = filepath.Dir(abs)
+	return file, nil
+}
+
+// Parse parses the Ripplefile format: [name] starts a task, followed by
+// key = value lines. run and env may be repeated, lists are separated by
+// spaces and lines starting with # are comments.
+func Parse(r io.Reader) (*File, error) {
+	file := &File{Tasks: make(map[string]*Task)}
+	var task *Task
+
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: unterminated task name", lineNumber)
+			}
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if name == "" || strings.ContainsAny(name, " \t") {
+				return nil, fmt.Errorf("line %d: invalid task name %q", lineNumber, name)
+			}
+			if _, exists := file.Tasks[name]; exists {
+				return nil, fmt.Errorf("line %d: task %s is defined twice", lineNumber, name)
+			}
+			task = &Task{Name: name}
+			file.Tasks[name] = task
+			continue
+		}
+
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			return nil, fmt.Errorf("line %d: expected key = value", lineNumber)
+		}
+		if task == nil {
+			return nil, fmt.Errorf("line %d: %s outside of a task", lineNumber, strings.TrimSpace(key))
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "desc":
+			task.Desc = value
+		case "deps":
+			task.Deps = append(task.Deps, strings.Fields(value)...)
+		case "env":
+			for _, assignment := range strings.Fields(value) {
+				if !strings.Contains(assignment, "=") {
+					return nil, fmt.Errorf("line %d: invalid environment assignment %q", lineNumber, assignment)
+				}
+				task.Env = append(task.Env, assignment)
+			}
+		case "dir":
+			task.Dir = value
+		case "inputs":
+			task.Inputs = append(task.Inputs, strings.Fields(value)...)
+		case "outputs":
+			task.Outputs = append(task.Outputs, strings.Fields(value)...)
+		case "run":
+			task.Run = append(task.Run, value)
+		default:
+			return nil, fmt.Errorf("line %d: unknown key %q", lineNumber, key)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, task := range file.Tasks {
+		for _, dep := range task.Deps {
+			if _, exists := file.Tasks[dep]; !exists {
+				return nil, fmt.Errorf("task %s depends on unknown task %s", task.Name, dep)
+			}
+		}
+	}
+	return file, nil
+}
+
+// Names returns the task names in alphabetical order
+func (f *File) Names() []string {
+	names := make([]string, 0, len(f.Tasks))
+	for name := range f.Tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Plan returns the tasks needed to run the targets, dependencies first, and
+// fails on unknown tasks and dependency cycles
+func (f *File) Plan(targets []string) ([]*Task, error) {
+	var plan []*Task
+	state := make(map[string]int) // 1 while visiting, 2 when done
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		task, exists := f.Tasks[name]
+		if !exists {
+			return fmt.Errorf("no such task: %s", name)
+		}
+		switch state[name] {
+		case 1:
+			return fmt.Errorf("dependency cycle: %s -> %s", strings.Join(path, " -> "), name)
+		case 2:
+			return nil
+		}
+		state[name] = 1
+		for _, dep := range task.Deps {
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = 2
+		plan = append(plan, task)
+		return nil
+	}
+
+	for _, target := range targets {
+		if err := visit(target, nil); err != nil {
+			return nil, err
+		}
+	}
+	return plan, nil
+}
+
+// UpToDate reports whether every output of the task exists and is newer than
+// all of its inputs. Tasks without outputs are never up to date.
+func (t *Task) UpToDate(dir string) (bool, error) {
+	if len(t.Outputs) == 0 {
+		return false, nil
+	}
+
+	outputs, err := expand(dir, t.Outputs)
+	if err != nil {
+		return false, err
+	}
+	if len(outputs) == 0 {
+		return false, nil
+	}
+	var oldestOutput time.Time
+	for _, output := range outputs {
+		info, err := os.Stat(output)
+		if err != nil {
+			return false, nil
+		}
+		if oldestOutput.IsZero() || info.ModTime().Before(oldestOutput) {
+			oldestOutput = info.ModTime()
+		}
+	}
+
+	inputs, err := expand(dir, t.Inputs)
+	if err != nil {
+		return false, err
+	}
+	for _, input := range inputs {
+		info, err := os.Stat(input)
+		if err != nil {
+			continue
+		}
+		if info.ModTime().After(oldestOutput) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// expand resolves glob patterns relative to dir. Patterns without glob
+// characters are kept even when the file does not exist, so that missing
+// outputs are noticed.
+func expand(dir string, patterns []string) ([]string, error) {
+	var paths []string
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+		if !strings.ContainsAny(pattern, "*?[") {
+			paths = append(paths, pattern)
+			continue
+		}
+
+		root, rest, recursive := strings.Cut(filepath.ToSlash(pattern), "**/")
+		if !recursive {
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+			}
+			paths = append(paths, matches...)
+			continue
+		}
+
+		root = filepath.FromSlash(strings.TrimSuffix(root, "/"))
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return nil
+			}
+			rel, _ := filepath.Rel(root, path)
+			rel = filepath.ToSlash(rel)
+			// ** matches any number of directories, including none
+			for {
+				if matched, _ := filepath.Match(rest, rel); matched {
+					paths = append(paths, path)
+					return nil
+				}
+				_, next, found := strings.Cut(rel, "/")
+				if !found {
+					return nil
+				}
+				rel = next
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
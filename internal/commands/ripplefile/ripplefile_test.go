@@ -0,0 +1,204 @@
+package ripplefile
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want map[string]*Task
+	}{
+		{"empty", "", map[string]*Task{}},
+		{"comments", "# nothing here\n\n   # indented\n", map[string]*Task{}},
+		{"empty task", "[clean]", map[string]*Task{
+			"clean": {Name: "clean"},
+		}},
+		{"every key", `
+[build]
+desc = Build the shell
+deps = generate
+env = CGO_ENABLED=0 GOOS=linux
+dir = cmd/commandripple
+inputs = **/*.go go.mod
+outputs = commandripple
+run = go build -o commandripple .
+
+[generate]
+run = go generate ./...
+`, map[string]*Task{
+			"build": {
+				Name:    "build",
+				Desc:    "Build the shell",
+				Deps:    []string{"generate"},
+				Env:     []string{"CGO_ENABLED=0", "GOOS=linux"},
+				Dir:     "cmd/commandripple",
+				Inputs:  []string{"**/*.go", "go.mod"},
+				Outputs: []string{"commandripple"},
+				Run:     []string{"go build -o commandripple ."},
+			},
+			"generate": {Name: "generate", Run: []string{"go generate ./..."}},
+		}},
+		{"repeated keys", `
+[test]
+run = go vet ./...
+run = go test -run 'A=B' ./...
+env = A=1
+env = B=x=y
+deps = a
+deps = b
+[a]
+[b]
+`, map[string]*Task{
+			"test": {
+				Name: "test",
+				Deps: []string{"a", "b"},
+				Env:  []string{"A=1", "B=x=y"},
+				Run:  []string{"go vet ./...", "go test -run 'A=B' ./..."},
+			},
+			"a": {Name: "a"},
+			"b": {Name: "b"},
+		}},
+		{"spacing", "  [ lint ]  \n\tdesc=Check   the code \n", map[string]*Task{
+			"lint": {Name: "lint", Desc: "Check   the code"},
+		}},
+	}
+	for _, tt := range tests {
+		file, err := Parse(strings.NewReader(tt.data))
+		if err != nil {
+			t.Errorf("%s: Parse failed: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(file.Tasks, tt.want) {
+			t.Errorf("%s: Parse = %+v, want %+v", tt.name, file.Tasks, tt.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		data string
+		err  string
+	}{
+		{"[build", "line 1: unterminated task name"},
+		{"[]", "line 1: invalid task name"},
+		{"[two words]", "line 1: invalid task name"},
+		{"[a]\n[a]", "line 2: task a is defined twice"},
+		{"run = make", "line 1: run outside of a task"},
+		{"[a]\nrun make", "line 2: expected key = value"},
+		{"[a]\ncommand = make", `line 2: unknown key "command"`},
+		{"[a]\nenv = A=1 B", `line 2: invalid environment assignment "B"`},
+		{"[a]\ndeps = missing", "task a depends on unknown task missing"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(strings.NewReader(tt.data))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Parse(%q) = %v, want %q", tt.data, err, tt.err)
+		}
+	}
+}
+
+func TestPlan(t *testing.T) {
+	file, err := Parse(strings.NewReader(`
+[all]
+deps = build test
+[build]
+deps = generate
+[test]
+deps = build
+[generate]
+[loop]
+deps = again
+[again]
+deps = loop
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		targets []string
+		want    string
+		err     string
+	}{
+		{[]string{"generate"}, "generate", ""},
+		{[]string{"all"}, "generate build test all", ""},
+		{[]string{"test", "build"}, "generate build test", ""},
+		{[]string{"nothing"}, "", "no such task: nothing"},
+		{[]string{"loop"}, "", "dependency cycle: loop -> again -> loop"},
+	}
+	for _, tt := range tests {
+		plan, err := file.Plan(tt.targets)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("Plan(%v) = %v, want %q", tt.targets, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Plan(%v) failed: %v", tt.targets, err)
+			continue
+		}
+		var names []string
+		for _, task := range plan {
+			names = append(names, task.Name)
+		}
+		if got := strings.Join(names, " "); got != tt.want {
+			t.Errorf("Plan(%v) = %s, want %s", tt.targets, got, tt.want)
+		}
+	}
+}
+
+func TestUpToDate(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-time.Hour)
+	write := func(name string, modified time.Time) {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("main.go", old)
+	write("pkg/deep/lib.go", old)
+	write("out/app", time.Now())
+
+	tests := []struct {
+		task Task
+		want bool
+	}{
+		{Task{Inputs: []string{"**/*.go"}}, false},
+		{Task{Inputs: []string{"**/*.go"}, Outputs: []string{"out/app"}}, true},
+		{Task{Inputs: []string{"*.go"}, Outputs: []string{"out/*"}}, true},
+		{Task{Inputs: []string{"**/*.go"}, Outputs: []string{"out/app", "out/missing"}}, false},
+		{Task{Inputs: []string{"**/*.go"}, Outputs: []string{"out/*.none"}}, false},
+	}
+	for _, tt := range tests {
+		got, err := tt.task.UpToDate(dir)
+		if err != nil {
+			t.Errorf("UpToDate(%v -> %v) failed: %v", tt.task.Inputs, tt.task.Outputs, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("UpToDate(%v -> %v) = %v, want %v", tt.task.Inputs, tt.task.Outputs, got, tt.want)
+		}
+	}
+
+	// A changed input makes the outputs stale
+	write("pkg/deep/lib.go", time.Now().Add(time.Minute))
+	task := Task{Inputs: []string{"**/*.go"}, Outputs: []string{"out/app"}}
+	if got, _ := task.UpToDate(dir); got {
+		t.Error("UpToDate after an input changed = true, want false")
+	}
+}
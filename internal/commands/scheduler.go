@@ -0,0 +1,332 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"commandripple/internal/commands/cron"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// scheduledTask is a command run later by the scheduler, once for `at` and
+// repeatedly for `every` and `cron`. Every run is a background job.
+type scheduledTask struct {
+	ID       int
+	Kind     string // "at", "every" or "cron"
+	Spec     string // The schedule as the user wrote it
+	Args     []string
+	Next     time.Time
+	Runs     int
+	interval time.Duration  // every
+	cron     *cron.Schedule // cron
+	session  *Session       // Starts the runs
+}
+
+// scheduler holds the scheduled tasks of a session
+type scheduler struct {
+	mutex   sync.Mutex
+	tasks   map[int]*scheduledTask
+	counter int
+	wake    chan struct{} // Tells the scheduler the tasks changed
+	once    sync.Once
+}
+
+func newScheduler() *scheduler {
+	return &scheduler{tasks: make(map[int]*scheduledTask), wake: make(chan struct{}, 1)}
+}
+
+// `at` command implementation: runs a command once at a time of day, a date
+// or after a delay, e.g. `at 14:30 backup.sh`, `at +10m make deploy` or
+// `at 2026-01-01T00:00 ./rotate-logs`
+func (s *Session) At(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: at TIME command [args]")
+	}
+	next, err := parseAtTime(args[0], time.Now())
+	if err != nil {
+		return err
+	}
+	return s.scheduleTask(&scheduledTask{Kind: "at", Spec: args[0], Args: args[1:], Next: next})
+}
+
+// `every` command implementation: runs a command repeatedly, the first time
+// after one interval or at the next HH:MM with --at,
+// e.g. `every 1h --at 09:00 ./report.sh`
+func (s *Session) Every(args []string) error {
+	usage := fmt.Errorf("usage: every INTERVAL [--at HH:MM] command [args]")
+	if len(args) < 2 {
+		return usage
+	}
+	interval, err := parseTimeoutDuration(args[0])
+	if err != nil {
+		return err
+	}
+	if interval < time.Second {
+		return fmt.Errorf("interval must be at least one second")
+	}
+
+	task := &scheduledTask{Kind: "every", Spec: "every " + args[0], interval: interval}
+	args = args[1:]
+	task.Next = time.Now().Add(interval)
+	if args[0] == "--at" {
+		if len(args) < 3 {
+			return usage
+		}
+		first, err := nextClockTime(args[1], time.Now())
+		if err != nil {
+			return err
+		}
+		task.Next = first
+		task.Spec += " at " + args[1]
+		args = args[2:]
+	}
+	task.Args = args
+	return s.scheduleTask(task)
+}
+
+// `cron` command implementation: runs a command on a cron schedule,
+// e.g. `cron "*/5 * * * *" ./sync.sh` or `cron @hourly ./cleanup.sh`
+func (s *Session) Cron(args []string) error {
+	expr, rest := splitCronExpression(args)
+	if expr == "" || len(rest) == 0 {
+		return fmt.Errorf("usage: cron \"MIN HOUR DOM MON DOW\" command [args]")
+	}
+	parsed, err := cron.Parse(expr)
+	if err != nil {
+		return err
+	}
+	next := parsed.Next(time.Now())
+	if next.IsZero() {
+		return fmt.Errorf("cron expression %q never matches", expr)
+	}
+	return s.scheduleTask(&scheduledTask{Kind: "cron", Spec: expr, Args: rest, Next: next, cron: parsed})
+}
+
+// `schedule` command implementation: `schedule list` and `schedule rm ID`
+func (s *Session) Schedule(args []string) error {
+	if len(args) == 0 || args[0] == "list" || args[0] == "ls" {
+		return s.listSchedule()
+	}
+	if args[0] != "rm" || len(args) != 2 {
+		return fmt.Errorf("usage: schedule [list] | schedule rm ID")
+	}
+
+	id, err := strconv.Atoi(args[1])
+	if err != nil {
+		return fmt.Errorf("invalid schedule ID: %s", args[1])
+	}
+	s.schedule.mutex.Lock()
+	defer s.schedule.mutex.Unlock()
+	if _, exists := s.schedule.tasks[id]; !exists {
+		return fmt.Errorf("no such scheduled command: %d", id)
+	}
+	delete(s.schedule.tasks, id)
+	s.schedule.wakeUp()
+	return nil
+}
+
+func (s *Session) listSchedule() error {
+	s.schedule.mutex.Lock()
+	defer s.schedule.mutex.Unlock()
+
+	if len(s.schedule.tasks) == 0 {
+		fmt.Fprintln(s.Stdout, "No scheduled commands.")
+		return nil
+	}
+
+	tasks := make([]*scheduledTask, 0, len(s.schedule.tasks))
+	for _, task := range s.schedule.tasks {
+		tasks = append(tasks, task)
+	}
+	sort.Slice(tasks, func(a, b int) bool { return tasks[a].ID < tasks[b].ID })
+
+	table := tablewriter.NewWriter(s.Stdout)
+	table.SetHeader([]string{"ID", "Type", "Schedule", "Next Run", "Runs", "Command"})
+	for _, task := range tasks {
+		table.Append([]string{
+			strconv.Itoa(task.ID),
+			task.Kind,
+			task.Spec,
+			task.Next.Format("2006-01-02 15:04:05"),
+			strconv.Itoa(task.Runs),
+			strings.Join(task.Args, " "),
+		})
+	}
+	table.Render()
+	return nil
+}
+
+// scheduleTask adds a task and makes sure the scheduler is running
+func (s *Session) scheduleTask(task *scheduledTask) error {
+	// The runs would go on after the script that scheduled them and the
+	// context it ran under, only the interactive shell lasts as long
+	if !s.interactive {
+		return fmt.Errorf("%s: commands can only be scheduled in the interactive shell", task.Kind)
+	}
+	// Runs happen while the prompt is active, builtins would race with the
+	// command being typed
+	if IsBuiltinCommand(task.Args[0]) {
+		return fmt.Errorf("only external commands can be scheduled: %s is a builtin", task.Args[0])
+	}
+
+	// The runs outlive the command that scheduled them, and whatever it
+	// runs under
+	session := *s
+	session.group = nil
+	task.session = &session
+
+	sched := s.schedule
+	sched.mutex.Lock()
+	sched.counter++
+	task.ID = sched.counter
+	sched.tasks[task.ID] = task
+	sched.mutex.Unlock()
+
+	sched.once.Do(func() { go sched.run() })
+	sched.wakeUp()
+
+	fmt.Fprintf(s.Stdout, "[schedule %d] next run at %s\n", task.ID, task.Next.Format("2006-01-02 15:04:05"))
+	return nil
+}
+
+func (sched *scheduler) wakeUp() {
+	select {
+	case sched.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run sleeps until the next task is due, starts every due task as a
+// background job and computes its next run
+func (sched *scheduler) run() {
+	timer := time.NewTimer(time.Hour)
+	for {
+		sched.mutex.Lock()
+		now := time.Now()
+		var due []*scheduledTask
+		var next time.Time
+		for id, task := range sched.tasks {
+			if !task.Next.After(now) {
+				due = append(due, task)
+				task.Runs++
+				if !task.advance(now) {
+					delete(sched.tasks, id)
+					continue
+				}
+			}
+			if next.IsZero() || task.Next.Before(next) {
+				next = task.Next
+			}
+		}
+		sched.mutex.Unlock()
+
+		sort.Slice(due, func(a, b int) bool { return due[a].ID < due[b].ID })
+		for _, task := range due {
+			if _, err := task.session.startBackgroundJob(task.Args, ""); err != nil {
+				fmt.Fprintf(task.session.Stderr, "\nschedule %d: %v\n", task.ID, err)
+			}
+		}
+
+		wait := time.Hour
+		if !next.IsZero() {
+			wait = time.Until(next)
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+
+		select {
+		case <-timer.C:
+		case <-sched.wake:
+		}
+	}
+}
+
+// advance moves a task to its next run after now and reports whether it
+// has one
+func (t *scheduledTask) advance(now time.Time) bool {
+	switch t.Kind {
+	case "every":
+		// Skip runs that were missed, e.g. while the machine was asleep
+		for !t.Next.After(now) {
+			t.Next = t.Next.Add(t.interval)
+		}
+		return true
+	case "cron":
+		t.Next = t.cron.Next(now)
+		return !t.Next.IsZero()
+	}
+	return false
+}
+
+// parseAtTime parses the time of an `at` command: HH:MM[:SS] for the next
+// time the clock shows it, +DURATION or now+DURATION for a delay, or a date
+// and time such as 2026-01-01T09:00
+func parseAtTime(spec string, now time.Time) (time.Time, error) {
+	if delay, found := strings.CutPrefix(strings.TrimPrefix(spec, "now"), "+"); found {
+		d, err := parseTimeoutDuration(delay)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return now.Add(d), nil
+	}
+	if strings.Contains(spec, ":") && !strings.Contains(spec, "-") {
+		return nextClockTime(spec, now)
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, spec, now.Location()); err == nil {
+			if !t.After(now) {
+				return time.Time{}, fmt.Errorf("time is in the past: %s", spec)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time: %s (expected HH:MM, +DURATION or YYYY-MM-DDTHH:MM)", spec)
+}
+
+// nextClockTime returns the next time the clock shows HH:MM or HH:MM:SS,
+// today or tomorrow
+func nextClockTime(spec string, now time.Time) (time.Time, error) {
+	var clock time.Time
+	var err error
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if clock, err = time.Parse(layout, spec); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time of day: %s", spec)
+	}
+
+	t := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), clock.Second(), 0, now.Location())
+	if !t.After(now) {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// splitCronExpression separates the cron expression from the command. The
+// expression is either one quoted word, an @shorthand or the first five
+// words.
+func splitCronExpression(args []string) (string, []string) {
+	if len(args) == 0 {
+		return "", nil
+	}
+	if strings.HasPrefix(args[0], "@") || strings.Contains(args[0], " ") {
+		return args[0], args[1:]
+	}
+
+	if len(args) < 5 {
+		return "", nil
+	}
+	return strings.Join(args[:5], " "), args[5:]
+}
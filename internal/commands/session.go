@@ -0,0 +1,461 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"commandripple/internal/commands/config"
+	"commandripple/internal/commands/history"
+	"commandripple/internal/commands/plugin"
+	"commandripple/internal/commands/ulimit"
+
+	"github.com/chzyer/readline"
+)
+
+// Session is a shell: the standard streams its commands use and the state
+// they share, such as the working directory, variables, aliases and jobs.
+// Copies of a Session share that state, which is how a command gets its own
+// streams for a redirection or a pipeline stage.
+type Session struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	*state
+	env    []string      // The environment with the assignments of the running command
+	group  *processGroup // Processes started now belong to it, if set
+	limits ulimit.Limits // Set by `limit` for the command it runs
+}
+
+type state struct {
+	// interactive is set for the shell on the terminal, which works in the
+	// process's directory and environment, owns the terminal for job
+	// control and exits the process on `exit`
+	interactive bool
+
+	mutex   sync.Mutex // Guards dir, vars, aliases and status
+	dir     string
+	vars    []string
+	aliases map[string]string
+	status  int // Exit status of the last command line Run ran
+
+	startTime time.Time
+	ulimits   *ulimit.Table // Limits set with `ulimit`
+	jobs      *jobTable
+	schedule  *scheduler
+	history   *history.Store   // Nil until InitHistory succeeds
+	plugins   *plugin.Registry // Shared with the subshells, scanned again when their PATH differs
+
+	// builtinJobs serializes the builtin jobs of `parallel`, which share
+	// the session's state
+	builtinJobs sync.Mutex
+
+	// settings is the loaded config file, holding the defaults until
+	// LoadConfig is called
+	settings *config.Config
+	// configAliases are the aliases the config file defined, so that a
+	// reload can remove the ones deleted from it
+	configAliases   map[string]string
+	configListeners []func()
+
+	// lastCommand is the result shown by the status and duration segments
+	// of the prompt
+	lastCommand struct {
+		exitCode int
+		duration time.Duration
+	}
+}
+
+// Default is the interactive shell
+var Default = newSession(&state{interactive: true})
+
+// NewSession returns a shell that starts in dir with env, given as in
+// os.Environ, and keeps its own working directory and environment, so that
+// several can run in one process. It uses the process's standard streams
+// until they are changed.
+func NewSession(dir string, env []string) *Session {
+	return newSession(&state{
+		dir:  dir,
+		vars: append([]string(nil), env...),
+	})
+}
+
+func newSession(st *state) *Session {
+	st.aliases = make(map[string]string)
+	st.startTime = time.Now()
+	st.ulimits = ulimit.NewTable(nil)
+	st.plugins = &plugin.Registry{}
+	st.jobs = newJobTable(st.interactive)
+	st.schedule = newScheduler()
+	st.settings = config.Empty("")
+	st.configAliases = make(map[string]string)
+	return &Session{Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr, state: st}
+}
+
+// subshell returns a session that starts in dir with env and a copy of the
+// aliases, for commands that must not change this one, such as the lines
+// of a task
+func (s *Session) subshell(dir string, env []string) *Session {
+	sub := NewSession(dir, env)
+	sub.Stdin, sub.Stdout, sub.Stderr = s.Stdin, s.Stdout, s.Stderr
+	sub.group, sub.limits = s.group, s.limits
+	s.mutex.Lock()
+	for name, command := range s.aliases {
+		sub.aliases[name] = command
+	}
+	s.mutex.Unlock()
+	sub.ulimits = ulimit.NewTable(s.ulimits.Current())
+	sub.history = s.history
+	sub.plugins = s.plugins
+	sub.settings = s.settings
+	return sub
+}
+
+// exitError stops a script run by a session other than Default with the
+// status given to `exit`
+type exitError struct {
+	code int
+}
+
+func (e *exitError) Error() string {
+	return fmt.Sprintf("exit %d", e.code)
+}
+
+// Run runs a script, one command line per line, and returns the exit status
+// of its last command or the status given to exit. Commands that fail are
+// reported on Stderr and the script goes on, like source does. The error is
+// only set when ctx is done, which also kills the process groups of the
+// programs still running.
+func (s *Session) Run(ctx context.Context, script string) (int, error) {
+	run := *s
+	if run.Stdin == nil {
+		run.Stdin = strings.NewReader("")
+	}
+	if run.Stdout == nil {
+		run.Stdout = io.Discard
+	}
+	if run.Stderr == nil {
+		run.Stderr = io.Discard
+	}
+	// exec.CommandContext would only kill the process it started, not the
+	// programs that one started in turn
+	run.group = &processGroup{ctx: ctx, parent: s.group}
+	stop := context.AfterFunc(ctx, func() { run.group.signal(syscall.SIGKILL) })
+	defer stop()
+
+	status, err := run.runScript(ctx, script)
+	var exit *exitError
+	if errors.As(err, &exit) {
+		return status, nil
+	}
+	return status, err
+}
+
+// runScript is Run in the current process group, returning an exitError
+// when the script called exit, so that it also ends the scripts sourcing it
+func (s *Session) runScript(ctx context.Context, script string) (int, error) {
+	for _, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return s.Status(), err
+		}
+
+		err := s.ExecuteCommandLine(line)
+		var exit *exitError
+		if errors.As(err, &exit) {
+			s.setStatus(exit.code)
+			return exit.code, exit
+		}
+		status := ExitCode(err)
+		s.setStatus(status)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return status, ctxErr
+		}
+		if err != nil && !isExitStatus(err) {
+			fmt.Fprintf(s.Stderr, "CommandRipple: %v\n", err)
+		}
+	}
+	return s.Status(), nil
+}
+
+// isExitStatus reports whether an error only carries a status, which a
+// program that failed already explained itself
+func isExitStatus(err error) bool {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return true
+	}
+	var status *ExitStatus
+	return errors.As(err, &status) && status.Reason == ""
+}
+
+// Status returns the exit status of the last command line Run ran
+func (s *Session) Status() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.status
+}
+
+func (s *Session) setStatus(status int) {
+	s.mutex.Lock()
+	s.status = status
+	s.mutex.Unlock()
+}
+
+// Dir returns the working directory
+func (s *Session) Dir() string {
+	if s.interactive {
+		dir, _ := os.Getwd()
+		return dir
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.dir
+}
+
+// SetDir changes the working directory, without setting PWD as cd does
+func (s *Session) SetDir(dir string) error {
+	dir = s.path(dir)
+	if s.interactive {
+		return os.Chdir(dir)
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s: not a directory", dir)
+	}
+	s.mutex.Lock()
+	s.dir = filepath.Clean(dir)
+	s.mutex.Unlock()
+	return nil
+}
+
+// Environ returns the environment of the commands the session runs, which
+// includes the assignments of the running command
+func (s *Session) Environ() []string {
+	if s.env != nil {
+		return append([]string(nil), s.env...)
+	}
+	if s.interactive {
+		return os.Environ()
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string(nil), s.vars...)
+}
+
+// SetEnviron replaces the variables with env, given as in os.Environ
+func (s *Session) SetEnviron(env []string) {
+	if s.interactive {
+		os.Clearenv()
+		for _, entry := range env {
+			name, value, _ := strings.Cut(entry, "=")
+			os.Setenv(name, value)
+		}
+		return
+	}
+	s.mutex.Lock()
+	s.vars = append([]string(nil), env...)
+	s.mutex.Unlock()
+}
+
+// LookupEnv returns the value of a variable as commands see it
+func (s *Session) LookupEnv(name string) (string, bool) {
+	if s.env != nil {
+		return lookupEnv(s.env, name)
+	}
+	if s.interactive {
+		return os.LookupEnv(name)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return lookupEnv(s.vars, name)
+}
+
+// Getenv returns the value of a variable, empty when it is not set
+func (s *Session) Getenv(name string) string {
+	value, _ := s.LookupEnv(name)
+	return value
+}
+
+// Setenv sets a variable
+func (s *Session) Setenv(name, value string) error {
+	if s.interactive {
+		return os.Setenv(name, value)
+	}
+	s.mutex.Lock()
+	s.vars = mergeEnv(s.vars, []string{name + "=" + value})
+	s.mutex.Unlock()
+	return nil
+}
+
+// Unsetenv removes a variable
+func (s *Session) Unsetenv(name string) error {
+	if s.interactive {
+		return os.Unsetenv(name)
+	}
+	s.mutex.Lock()
+	s.vars = unsetEnv(s.vars, name)
+	s.mutex.Unlock()
+	return nil
+}
+
+// Alias defines an alias, or removes it when command is empty
+func (s *Session) Alias(name, command string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if command == "" {
+		delete(s.aliases, name)
+		return
+	}
+	s.aliases[name] = command
+}
+
+// lookupAlias returns the text of an alias
+func (s *Session) lookupAlias(name string) (string, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	command, ok := s.aliases[name]
+	return command, ok
+}
+
+// aliasTable returns a copy of the aliases
+func (s *Session) aliasTable() map[string]string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	table := make(map[string]string, len(s.aliases))
+	for name, command := range s.aliases {
+		table[name] = command
+	}
+	return table
+}
+
+// path resolves a file name given to a command. The interactive shell
+// leaves it to the process, other sessions resolve ~ and relative names
+// against their own HOME and working directory.
+func (s *Session) path(name string) string {
+	if s.interactive || name == "" {
+		return name
+	}
+	if name == "~" || strings.HasPrefix(name, "~/") || strings.HasPrefix(name, "~"+string(os.PathSeparator)) {
+		if home, err := s.homeDir(); err == nil {
+			name = filepath.Join(home, name[1:])
+		}
+	}
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(s.Dir(), name)
+}
+
+// homeDir returns the home directory, from the HOME of sessions other than
+// Default
+func (s *Session) homeDir() (string, error) {
+	if !s.interactive {
+		if home := s.Getenv("HOME"); home != "" {
+			return home, nil
+		}
+	}
+	return os.UserHomeDir()
+}
+
+// command returns a command running a program in the directory and
+// environment of the session, connected to its streams
+func (s *Session) command(name string, args ...string) *exec.Cmd {
+	cmd := exec.Command(name, args...)
+	if !s.interactive {
+		// exec.Command looked on the PATH of the process
+		cmd.Err = nil
+		if path, err := s.lookPath(name); err != nil {
+			cmd.Err = err
+		} else {
+			cmd.Path = path
+		}
+	}
+	s.prepare(cmd)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = s.Stdin, s.Stdout, s.Stderr
+	return cmd
+}
+
+// prepare sets the directory and environment of a command that does not
+// have its own
+func (s *Session) prepare(cmd *exec.Cmd) {
+	if cmd.Dir == "" && !s.interactive {
+		cmd.Dir = s.Dir()
+	}
+	if cmd.Env == nil && (s.env != nil || !s.interactive) {
+		cmd.Env = s.Environ()
+	}
+}
+
+// lookPath finds a program on the PATH of the session, since exec.LookPath
+// uses the PATH of the process
+func (s *Session) lookPath(name string) (string, error) {
+	if strings.ContainsRune(name, '/') || strings.ContainsRune(name, os.PathSeparator) {
+		path := s.path(name)
+		if isExecutableFile(path) {
+			return path, nil
+		}
+		return "", fmt.Errorf("%s: not an executable", name)
+	}
+
+	extensions := []string{""}
+	if runtime.GOOS == "windows" {
+		pathext := s.Getenv("PATHEXT")
+		if pathext == "" {
+			pathext = ".com;.exe;.bat;.cmd"
+		}
+		extensions = strings.Split(strings.ToLower(pathext), ";")
+	}
+	for _, dir := range filepath.SplitList(s.Getenv("PATH")) {
+		if dir == "" {
+			continue
+		}
+		dir = s.path(dir)
+		for _, ext := range extensions {
+			path := filepath.Join(dir, name+ext)
+			if isExecutableFile(path) {
+				return path, nil
+			}
+		}
+	}
+	return "", &ExitStatus{Code: 127, Reason: fmt.Sprintf("%s: command not found", name)}
+}
+
+func isExecutableFile(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	return runtime.GOOS == "windows" || info.Mode().Perm()&0111 != 0
+}
+
+// isTerminal reports whether a stream is a terminal
+func isTerminal(stream any) bool {
+	f, ok := stream.(*os.File)
+	return ok && f != nil && readline.IsTerminal(int(f.Fd()))
+}
+
+func lookupEnv(env []string, name string) (string, bool) {
+	for i := len(env) - 1; i >= 0; i-- {
+		if value, ok := strings.CutPrefix(env[i], name+"="); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
@@ -2,11 +2,12 @@ package stat
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"time"
 )
 
-func Stat(args []string) error {
+func Stat(w io.Writer, args []string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("usage: stat [file]")
 	}
@@ -17,12 +18,12 @@ func Stat(args []string) error {
 		return err
 	}
 
-	fmt.Printf("  File: %s\n", filePath)
-	fmt.Printf("  Size: %d bytes\n", fileInfo.Size())
-	fmt.Printf("  Mode: %s\n", fileInfo.Mode())
-	fmt.Printf("  Modified: %s\n", fileInfo.ModTime().Format(time.RFC1123))
+	fmt.Fprintf(w, "  File: %s\n", filePath)
+	fmt.Fprintf(w, "  Size: %d bytes\n", fileInfo.Size())
+	fmt.Fprintf(w, "  Mode: %s\n", fileInfo.Mode())
+	fmt.Fprintf(w, "  Modified: %s\n", fileInfo.ModTime().Format(time.RFC1123))
 
-	printDetailedStats(filePath, fileInfo)
+	printDetailedStats(w, filePath, fileInfo)
 
 	return nil
 }
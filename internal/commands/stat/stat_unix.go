@@ -5,17 +5,18 @@ package stat
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"syscall"
 )
 
-func printDetailedStats(filePath string, fileInfo os.FileInfo) {
+func printDetailedStats(w io.Writer, filePath string, fileInfo os.FileInfo) {
 	stat, ok := fileInfo.Sys().(*syscall.Stat_t)
 	if !ok {
 		return
 	}
-	fmt.Printf("  Inode: %d\n", stat.Ino)
-	fmt.Printf("  Links: %d\n", stat.Nlink)
-	fmt.Printf("  UID: %d\n", stat.Uid)
-	fmt.Printf("  GID: %d\n", stat.Gid)
+	fmt.Fprintf(w, "  Inode: %d\n", stat.Ino)
+	fmt.Fprintf(w, "  Links: %d\n", stat.Nlink)
+	fmt.Fprintf(w, "  UID: %d\n", stat.Uid)
+	fmt.Fprintf(w, "  GID: %d\n", stat.Gid)
 }
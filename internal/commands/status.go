@@ -0,0 +1,19 @@
+package commands
+
+import (
+	"commandripple/internal/commands/exitstatus"
+)
+
+// ExitStatus is returned by commands that finish with a specific exit code
+type ExitStatus = exitstatus.ExitStatus
+
+// ExitCode returns the exit status of a command from the error it returned.
+// Processes killed by a signal report 128 plus the signal number.
+func ExitCode(err error) int {
+	return exitstatus.Code(err)
+}
+
+// exitSignal returns the number of the signal that killed a process, or 0
+func exitSignal(err error) int {
+	return exitstatus.Signal(err)
+}
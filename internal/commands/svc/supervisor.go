@@ -0,0 +1,212 @@
+package svc
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"commandripple/internal/commands/exitstatus"
+)
+
+// Arguments the shell is re-executed with to detach and supervise a service
+const (
+	detachFlag    = "--svc-detach"
+	superviseFlag = "--svc-supervise"
+)
+
+// maxRestartDelay caps the delay between restarts of a failing service
+const maxRestartDelay = time.Minute
+
+// hostsSupervisor is set once RunInternal ran, which means the program can
+// be re-executed to supervise a service
+var hostsSupervisor bool
+
+// RunInternal runs the hidden modes the shell re-executes itself in to
+// supervise services. It reports whether args selected one of them and the
+// exit status to use.
+func RunInternal(args []string) (int, bool) {
+	hostsSupervisor = true
+	if len(args) != 2 {
+		return 0, false
+	}
+	switch args[0] {
+	case detachFlag:
+		// The intermediate process exits right away so the supervisor is
+		// adopted by init and belongs to no shell
+		if err := startSupervisor(args[1]); err != nil {
+			fmt.Fprintf(os.Stderr, "svc: %v\n", err)
+			return 1, true
+		}
+		return 0, true
+	case superviseFlag:
+		if err := supervise(args[1]); err != nil {
+			fmt.Fprintf(os.Stderr, "svc: %v\n", err)
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// startSupervisor starts the supervisor of a service in a new session
+func startSupervisor(name string) error {
+	exe, err := executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(exe, superviseFlag, name)
+	cmd.SysProcAttr = detachAttr()
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// supervisor runs a service's command, restarting it according to its
+// policy, and keeps its state file up to date
+type supervisor struct {
+	state   *State
+	log     *os.File
+	signals chan os.Signal
+}
+
+func supervise(name string) error {
+	// The lock shows the service is supervised until this process exits,
+	// however it ends
+	lock, err := lockSupervisor(name)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+
+	state, err := loadState(name)
+	if err != nil {
+		return err
+	}
+	log, err := os.OpenFile(state.LogPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer log.Close()
+
+	s := &supervisor{state: state, log: log, signals: make(chan os.Signal, 1)}
+	signal.Ignore(syscall.SIGHUP)
+	signal.Notify(s.signals, stopSignals...)
+
+	state.SupervisorPid = os.Getpid()
+	consecutive := 0
+	for {
+		started := time.Now()
+		code, stopped, err := s.run()
+		if err != nil {
+			s.logf("failed to start: %v", err)
+			return s.finish(StatusFailed, 127)
+		}
+		if stopped {
+			s.logf("stopped")
+			return s.finish(StatusStopped, code)
+		}
+
+		if !s.shouldRestart(code) {
+			s.logf("exited with status %d", code)
+			if code != 0 {
+				return s.finish(StatusFailed, code)
+			}
+			return s.finish(StatusExited, code)
+		}
+
+		// Back off while the command keeps failing right after starting
+		if time.Since(started) > maxRestartDelay {
+			consecutive = 0
+		}
+		delay := time.Second << consecutive
+		if delay > maxRestartDelay {
+			delay = maxRestartDelay
+		} else {
+			consecutive++
+		}
+
+		state.Restarts++
+		state.ExitCode = code
+		state.Pid = 0
+		state.Status = StatusRestarting
+		saveState(state)
+		s.logf("exited with status %d, restarting in %v", code, delay)
+
+		select {
+		case <-s.signals:
+			s.logf("stopped")
+			return s.finish(StatusStopped, code)
+		case <-time.After(delay):
+		}
+	}
+}
+
+// run starts the command and waits for it, stopping it when the supervisor
+// is asked to stop
+func (s *supervisor) run() (code int, stopped bool, err error) {
+	cmd := exec.Command(s.state.Command[0], s.state.Command[1:]...)
+	cmd.Dir = s.state.Dir
+	cmd.Stdout = s.log
+	cmd.Stderr = s.log
+	setChildAttr(cmd)
+	if err := cmd.Start(); err != nil {
+		return 0, false, err
+	}
+
+	s.state.Pid = cmd.Process.Pid
+	s.state.Status = StatusRunning
+	saveState(s.state)
+	s.logf("started %v (pid %d)", s.state.Command, cmd.Process.Pid)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	var kill <-chan time.Time
+	for {
+		select {
+		case err := <-done:
+			return exitstatus.Code(err), stopped, nil
+		case <-s.signals:
+			if !stopped {
+				stopped = true
+				terminate(cmd.Process.Pid)
+				kill = time.After(stopTimeout)
+			}
+		case <-kill:
+			s.logf("did not stop within %v, killing it", stopTimeout)
+			killProcess(cmd.Process.Pid)
+		}
+	}
+}
+
+func (s *supervisor) shouldRestart(code int) bool {
+	switch s.state.Restart {
+	case RestartAlways:
+	case RestartOnFailure:
+		if code == 0 {
+			return false
+		}
+	default:
+		return false
+	}
+	return s.state.MaxRestarts == 0 || s.state.Restarts < s.state.MaxRestarts
+}
+
+func (s *supervisor) finish(status string, code int) error {
+	s.state.Status = status
+	s.state.ExitCode = code
+	s.state.Pid = 0
+	s.state.EndTime = time.Now()
+	return saveState(s.state)
+}
+
+// logf writes a supervisor message between the lines of the service output
+func (s *supervisor) logf(format string, args ...interface{}) {
+	fmt.Fprintf(s.log, "[svc %s] %s\n", time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf(format, args...))
+}
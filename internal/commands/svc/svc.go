@@ -0,0 +1,531 @@
+package svc
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"commandripple/internal/commands/filelock"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// Restart policies
+const (
+	RestartNever     = "no"
+	RestartOnFailure = "on-failure"
+	RestartAlways    = "always"
+)
+
+// Service states
+const (
+	StatusStarting   = "starting"
+	StatusRunning    = "running"
+	StatusRestarting = "restarting"
+	StatusStopped    = "stopped"
+	StatusExited     = "exited"
+	StatusFailed     = "failed"
+	StatusDead       = "dead" // The supervisor is gone without recording why
+)
+
+// stopTimeout is how long a service has to exit before it is killed
+const stopTimeout = 10 * time.Second
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// State is what is persisted about a service in its state directory
+type State struct {
+	Name          string    `json:"name"`
+	Command       []string  `json:"command"`
+	Dir           string    `json:"dir"`
+	Restart       string    `json:"restart"`
+	MaxRestarts   int       `json:"max_restarts,omitempty"`
+	LogPath       string    `json:"log_path"`
+	SupervisorPid int       `json:"supervisor_pid"`
+	Pid           int       `json:"pid"`
+	Status        string    `json:"status"`
+	StartTime     time.Time `json:"start_time"`
+	EndTime       time.Time `json:"end_time,omitempty"`
+	Restarts      int       `json:"restarts"`
+	ExitCode      int       `json:"exit_code"`
+}
+
+// Svc implements the `svc` command, which manages supervised processes that
+// keep running after the shell exits, e.g.
+// `svc start migrate --restart on-failure -- ./migrate.sh`. Services start in
+// dir and messages are written to w.
+func Svc(w io.Writer, dir string, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: svc start|list|logs|stop|restart|rm [name] ...")
+	}
+
+	switch args[0] {
+	case "start", "restart":
+		if !hostsSupervisor {
+			// A program embedding the shell would run its own main instead
+			return fmt.Errorf("svc %s: services can only be started by the commandripple shell", args[0])
+		}
+	}
+
+	switch args[0] {
+	case "start":
+		return start(w, dir, args[1:])
+	case "list", "ls":
+		return list(w)
+	case "logs":
+		return logs(w, args[1:])
+	case "stop":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: svc stop NAME")
+		}
+		return stop(w, args[1])
+	case "restart":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: svc restart NAME")
+		}
+		return restart(w, args[1])
+	case "rm":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: svc rm NAME")
+		}
+		return remove(args[1])
+	default:
+		return fmt.Errorf("unknown svc command: %s", args[0])
+	}
+}
+
+func start(w io.Writer, dir string, args []string) error {
+	usage := fmt.Errorf("usage: svc start NAME [--restart no|on-failure|always] [--max-restarts N] -- command [args]")
+	if len(args) == 0 {
+		return usage
+	}
+
+	state := &State{Name: args[0], Restart: RestartNever}
+	if !validName.MatchString(state.Name) {
+		return fmt.Errorf("invalid service name: %s", state.Name)
+	}
+
+	i := 1
+	for ; i < len(args); i++ {
+		if args[i] == "--" {
+			i++
+			break
+		}
+		if !strings.HasPrefix(args[i], "--") {
+			break
+		}
+		if i+1 >= len(args) {
+			return fmt.Errorf("missing value for %s", args[i])
+		}
+		switch args[i] {
+		case "--restart":
+			switch args[i+1] {
+			case RestartNever, RestartOnFailure, RestartAlways:
+				state.Restart = args[i+1]
+			default:
+				return fmt.Errorf("invalid restart policy: %s (expected no, on-failure or always)", args[i+1])
+			}
+		case "--max-restarts":
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n < 0 {
+				return fmt.Errorf("invalid number of restarts: %s", args[i+1])
+			}
+			state.MaxRestarts = n
+		default:
+			return usage
+		}
+		i++
+	}
+	if i >= len(args) {
+		return usage
+	}
+	state.Command = args[i:]
+
+	state.Dir = dir
+
+	if existing, err := loadState(state.Name); err == nil && isActive(existing) {
+		return fmt.Errorf("service %s is already running (pid %d)", state.Name, existing.Pid)
+	}
+	return launch(w, state)
+}
+
+// launch records the initial state and starts the supervisor, detached from
+// the shell's session
+func launch(w io.Writer, state *State) error {
+	serviceDir, err := serviceDir(state.Name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(serviceDir, 0700); err != nil {
+		return fmt.Errorf("failed to create state directory: %v", err)
+	}
+
+	state.LogPath = filepath.Join(serviceDir, "output.log")
+	state.Status = StatusStarting
+	state.StartTime = time.Now()
+	state.EndTime = time.Time{}
+	state.SupervisorPid = 0
+	state.Pid = 0
+	state.Restarts = 0
+	state.ExitCode = 0
+	if err := saveState(state); err != nil {
+		return err
+	}
+
+	if err := detach(state.Name); err != nil {
+		return fmt.Errorf("failed to start supervisor: %v", err)
+	}
+
+	// Wait briefly for the supervisor to report the process it started. A
+	// service that is restarting under its policy is still starting.
+	restarting := false
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		current, err := loadState(state.Name)
+		if err != nil {
+			continue
+		}
+		switch {
+		case current.Pid != 0:
+			fmt.Fprintf(w, "Started service %s (pid %d), logs in %s\n", state.Name, current.Pid, current.LogPath)
+			return nil
+		case current.Status == StatusRestarting:
+			restarting = true
+		case current.Status != StatusStarting:
+			return fmt.Errorf("service %s failed to start, see `svc logs %s`", state.Name, state.Name)
+		}
+	}
+	if restarting {
+		fmt.Fprintf(w, "Service %s exited and is restarting, see `svc logs %s`\n", state.Name, state.Name)
+		return nil
+	}
+	fmt.Fprintf(w, "Starting service %s, logs in %s\n", state.Name, state.LogPath)
+	return nil
+}
+
+func list(w io.Writer) error {
+	states, err := loadAll()
+	if err != nil {
+		return err
+	}
+	if len(states) == 0 {
+		fmt.Fprintln(w, "No services.")
+		return nil
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Name", "Status", "PID", "Restarts", "Started", "Uptime", "Command"})
+	for _, state := range states {
+		pid, uptime := "-", "-"
+		if isActive(state) {
+			pid = strconv.Itoa(state.Pid)
+			uptime = time.Since(state.StartTime).Round(time.Second).String()
+		}
+		table.Append([]string{
+			state.Name,
+			describe(state),
+			pid,
+			strconv.Itoa(state.Restarts),
+			state.StartTime.Format("2006-01-02 15:04:05"),
+			uptime,
+			strings.Join(state.Command, " "),
+		})
+	}
+	table.Render()
+	return nil
+}
+
+// describe returns the status of a service for listings, noticing
+// supervisors that died without updating the state
+func describe(state *State) string {
+	switch state.Status {
+	case StatusStarting, StatusRunning, StatusRestarting:
+		if !supervised(state.Name) {
+			return StatusDead
+		}
+		return state.Status
+	case StatusExited, StatusFailed:
+		return fmt.Sprintf("%s (exit %d)", state.Status, state.ExitCode)
+	}
+	return state.Status
+}
+
+// isActive reports whether the supervisor of a service is still running
+func isActive(state *State) bool {
+	switch state.Status {
+	case StatusStarting, StatusRunning, StatusRestarting:
+		return supervised(state.Name)
+	}
+	return false
+}
+
+// lockSupervisor takes the lock a supervisor holds while it runs
+func lockSupervisor(name string) (*os.File, error) {
+	dir, err := serviceDir(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := filelock.TryLock(filepath.Join(dir, "supervisor.lock"))
+	if err == filelock.ErrLocked {
+		return nil, fmt.Errorf("service %s is already supervised", name)
+	}
+	return f, err
+}
+
+// supervised reports whether the supervisor of a service is running. Its
+// lock is released when it exits, so unlike its recorded PID this cannot
+// mistake another process for it after a reboot.
+func supervised(name string) bool {
+	dir, err := serviceDir(name)
+	if err != nil {
+		return false
+	}
+	f, err := filelock.TryLock(filepath.Join(dir, "supervisor.lock"))
+	if err == nil {
+		f.Close()
+	}
+	return err == filelock.ErrLocked
+}
+
+// logs prints the end of a service's log: `svc logs NAME [-n LINES] [-f]`
+func logs(w io.Writer, args []string) error {
+	usage := fmt.Errorf("usage: svc logs NAME [-n LINES] [-f]")
+	if len(args) == 0 {
+		return usage
+	}
+	name := args[0]
+	lines := 50
+	follow := false
+	for i := 1; i < len(args); i++ {
+		switch args[i] {
+		case "-f", "--follow":
+			follow = true
+		case "-n":
+			if i+1 >= len(args) {
+				return usage
+			}
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n < 0 {
+				return fmt.Errorf("invalid number of lines: %s", args[i+1])
+			}
+			lines = n
+			i++
+		default:
+			return usage
+		}
+	}
+
+	state, err := loadState(name)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(state.LogPath)
+	if err != nil {
+		return fmt.Errorf("failed to open log: %v", err)
+	}
+	defer file.Close()
+
+	var tail []string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		tail = append(tail, scanner.Text())
+		if lines > 0 && len(tail) > lines {
+			tail = tail[1:]
+		}
+	}
+	for _, line := range tail {
+		fmt.Fprintln(w, line)
+	}
+	if !follow {
+		return nil
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	offset, err := file.Seek(0, 2)
+	if err != nil {
+		return err
+	}
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+	buf := make([]byte, 32*1024)
+	for {
+		select {
+		case <-interrupt:
+			return nil
+		case <-ticker.C:
+		}
+		for {
+			n, _ := file.ReadAt(buf, offset)
+			if n == 0 {
+				break
+			}
+			w.Write(buf[:n])
+			offset += int64(n)
+		}
+	}
+}
+
+func stop(w io.Writer, name string) error {
+	state, err := loadState(name)
+	if err != nil {
+		return err
+	}
+	if !isActive(state) {
+		return fmt.Errorf("service %s is not running", name)
+	}
+
+	if err := stopSupervisor(state); err != nil {
+		return fmt.Errorf("failed to stop service %s: %v", name, err)
+	}
+
+	// The supervisor stops the process and records the final state
+	deadline := time.Now().Add(stopTimeout + 5*time.Second)
+	for supervised(name) {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("service %s did not stop", name)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	fmt.Fprintf(w, "Stopped service %s\n", name)
+	return nil
+}
+
+func restart(w io.Writer, name string) error {
+	state, err := loadState(name)
+	if err != nil {
+		return err
+	}
+	if isActive(state) {
+		if err := stop(w, name); err != nil {
+			return err
+		}
+	}
+	return launch(w, state)
+}
+
+func remove(name string) error {
+	state, err := loadState(name)
+	if err != nil {
+		return err
+	}
+	if isActive(state) {
+		return fmt.Errorf("service %s is running, stop it first", name)
+	}
+	dir, err := serviceDir(name)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+// StateDir returns the directory holding the state of every service:
+// $XDG_STATE_HOME/commandripple/svc or ~/.local/state/commandripple/svc
+func StateDir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "commandripple", "svc"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the state directory: %v", err)
+	}
+	return filepath.Join(home, ".local", "state", "commandripple", "svc"), nil
+}
+
+func serviceDir(name string) (string, error) {
+	if !validName.MatchString(name) {
+		return "", fmt.Errorf("invalid service name: %s", name)
+	}
+	dir, err := StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+func loadState(name string) (*State, error) {
+	dir, err := serviceDir(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "state.json"))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no such service: %s", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	state := &State{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("corrupt state for service %s: %v", name, err)
+	}
+	return state, nil
+}
+
+// saveState writes the state through a temporary file so that readers never
+// see a partial write
+func saveState(state *State) error {
+	dir, err := serviceDir(state.Name)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, "state.json.tmp")
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to save state: %v", err)
+	}
+	return os.Rename(tmp, filepath.Join(dir, "state.json"))
+}
+
+func loadAll() ([]*State, error) {
+	dir, err := StateDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var states []*State
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if state, err := loadState(entry.Name()); err == nil {
+			states = append(states, state)
+		}
+	}
+	sort.Slice(states, func(a, b int) bool { return states[a].Name < states[b].Name })
+	return states, nil
+}
+
+// executable returns the path of the running shell, which is re-executed to
+// run the supervisor
+func executable() (string, error) {
+	path, err := os.Executable()
+	if err != nil {
+		return exec.LookPath(os.Args[0])
+	}
+	return path, nil
+}
@@ -0,0 +1,50 @@
+//go:build !windows
+// +build !windows
+
+package svc
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+var stopSignals = []os.Signal{syscall.SIGTERM, syscall.SIGINT}
+
+// detach starts the supervisor through an intermediate process, so that it
+// runs in its own session and is not a child of the shell
+func detach(name string) error {
+	exe, err := executable()
+	if err != nil {
+		return err
+	}
+	return exec.Command(exe, detachFlag, name).Run()
+}
+
+func detachAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
+
+// setChildAttr puts the service in its own process group so that stopping it
+// reaches the processes it started too
+func setChildAttr(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func terminate(pid int) {
+	syscall.Kill(-pid, syscall.SIGTERM)
+}
+
+func killProcess(pid int) {
+	syscall.Kill(-pid, syscall.SIGKILL)
+}
+
+// stopSupervisor asks the supervisor to stop the service and record it
+func stopSupervisor(state *State) error {
+	// A PID of 0 would signal the shell's own process group
+	if state.SupervisorPid <= 0 {
+		return fmt.Errorf("the supervisor is still starting")
+	}
+	return syscall.Kill(state.SupervisorPid, syscall.SIGTERM)
+}
@@ -0,0 +1,49 @@
+//go:build windows
+// +build windows
+
+package svc
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+const detachedProcess = 0x00000008
+
+var stopSignals = []os.Signal{os.Interrupt}
+
+// detach starts the supervisor directly, Windows processes do not belong to
+// the process that started them
+func detach(name string) error {
+	return startSupervisor(name)
+}
+
+func detachAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP | detachedProcess}
+}
+
+func setChildAttr(cmd *exec.Cmd) {}
+
+func terminate(pid int) {
+	killProcess(pid)
+}
+
+func killProcess(pid int) {
+	if process, err := os.FindProcess(pid); err == nil {
+		process.Kill()
+	}
+}
+
+// stopSupervisor kills the supervisor and then the service, since Windows
+// cannot ask the supervisor to do it, and records the final state
+func stopSupervisor(state *State) error {
+	killProcess(state.SupervisorPid)
+	killProcess(state.Pid)
+
+	state.Status = StatusStopped
+	state.Pid = 0
+	state.EndTime = time.Now()
+	return saveState(state)
+}
@@ -0,0 +1,301 @@
+// Package syntax splits command lines into words, pipes and redirections.
+// The same tokenizer is used to run commands and to highlight the line
+// being edited, so what is colored is what will run.
+package syntax
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrUnterminated is returned for a line ending inside quotes
+var ErrUnterminated = errors.New("unterminated quote")
+
+// UnsupportedError is returned for an operator of other shells that this
+// one does not run, such as || or &&, rather than reading it as a pipe or a
+// word
+type UnsupportedError struct {
+	Op string
+}
+
+func (e *UnsupportedError) Error() string {
+	return "unsupported operator " + e.Op
+}
+
+// Kind is the type of a token
+type Kind int
+
+const (
+	Word        Kind = iota
+	Pipe             // |
+	Redirect         // <, >, >>, 2>, 2>>, &> or 2>&1
+	Unsupported      // || or &&
+)
+
+// PartKind is the type of a piece of a word
+type PartKind int
+
+const (
+	Literal PartKind = iota
+	SingleQuoted
+	DoubleQuoted
+	Variable // $NAME or ${NAME}, unless single quoted
+	Escape   // A backslash and the character it escapes
+)
+
+// Part is a piece of a word, as byte offsets into the line
+type Part struct {
+	Kind       PartKind
+	Start, End int
+}
+
+// Token is a word or an operator, with its position in the line
+type Token struct {
+	Kind       Kind
+	Start, End int    // Byte offsets of the token in the line
+	Text       string // The token as written
+	Value      string // Words: quotes and escapes removed, variables expanded
+	Parts      []Part // Words: the quoted, escaped and variable parts
+}
+
+// Lookup returns the value of a variable
+type Lookup func(name string) (string, bool)
+
+// Tokenize splits a line into tokens. Variables are expanded with lookup;
+// a nil lookup expands them to nothing. For a line ending inside quotes the
+// tokens read so far are returned together with ErrUnterminated. A line
+// with || or && is read to the end and returned with an UnsupportedError.
+func Tokenize(line string, lookup Lookup) ([]Token, error) {
+	var tokens []Token
+	var unsupported error
+	i := 0
+	for {
+		for i < len(line) && isSpace(line[i]) {
+			i++
+		}
+		if i >= len(line) {
+			return tokens, unsupported
+		}
+
+		if op := unsupportedAt(line, i); op != "" {
+			tokens = append(tokens, Token{Kind: Unsupported, Start: i, End: i + len(op), Text: op})
+			if unsupported == nil {
+				unsupported = &UnsupportedError{Op: op}
+			}
+			i += len(op)
+			continue
+		}
+		if line[i] == '|' {
+			tokens = append(tokens, Token{Kind: Pipe, Start: i, End: i + 1, Text: "|"})
+			i++
+			continue
+		}
+		if op := redirectAt(line, i); op != "" {
+			tokens = append(tokens, Token{Kind: Redirect, Start: i, End: i + len(op), Text: op})
+			i += len(op)
+			continue
+		}
+
+		token, err := readWord(line, i, lookup)
+		tokens = append(tokens, token)
+		if err != nil {
+			return tokens, err
+		}
+		i = token.End
+	}
+}
+
+// redirectOperators are tried in order, longest first
+var redirectOperators = []string{"2>&1", "2>>", "2>", "&>", ">>", ">", "<"}
+
+func unsupportedAt(line string, i int) string {
+	for _, op := range []string{"||", "&&"} {
+		if strings.HasPrefix(line[i:], op) {
+			return op
+		}
+	}
+	return ""
+}
+
+func redirectAt(line string, i int) string {
+	for _, op := range redirectOperators {
+		if strings.HasPrefix(line[i:], op) {
+			return op
+		}
+	}
+	return ""
+}
+
+func readWord(line string, start int, lookup Lookup) (Token, error) {
+	token := Token{Kind: Word, Start: start}
+	var value strings.Builder
+	i := start
+
+	for i < len(line) {
+		c := line[i]
+		if isSpace(c) || c == '|' || c == '<' || c == '>' || unsupportedAt(line, i) != "" {
+			break
+		}
+		// 2> and &> only start a redirection at the beginning of a word
+		if (c == '&' || c == '2') && i == start && redirectAt(line, i) != "" {
+			break
+		}
+
+		switch c {
+		case '\\':
+			if i+1 >= len(line) {
+				value.WriteByte(c)
+				i++
+				continue
+			}
+			_, size := utf8.DecodeRuneInString(line[i+1:])
+			token.Parts = append(token.Parts, Part{Kind: Escape, Start: i, End: i + 1 + size})
+			value.WriteString(line[i+1 : i+1+size])
+			i += 1 + size
+		case '\'':
+			end := strings.IndexByte(line[i+1:], '\'')
+			if end < 0 {
+				token.Parts = append(token.Parts, Part{Kind: SingleQuoted, Start: i, End: len(line)})
+				value.WriteString(line[i+1:])
+				return finish(line, token, value, len(line)), ErrUnterminated
+			}
+			end += i + 1
+			token.Parts = append(token.Parts, Part{Kind: SingleQuoted, Start: i, End: end + 1})
+			value.WriteString(line[i+1 : end])
+			i = end + 1
+		case '"':
+			quoteStart := i
+			i++
+			closed := false
+			for i < len(line) {
+				switch line[i] {
+				case '"':
+					closed = true
+				case '\\':
+					// Inside double quotes a backslash only escapes these
+					if i+1 < len(line) && strings.IndexByte("\"\\$`", line[i+1]) >= 0 {
+						value.WriteByte(line[i+1])
+						i += 2
+						continue
+					}
+				case '$':
+					if n, expanded := variableAt(line, i, lookup); n > 0 {
+						token.Parts = append(token.Parts, Part{Kind: Variable, Start: i, End: i + n})
+						value.WriteString(expanded)
+						i += n
+						continue
+					}
+				}
+				if closed {
+					break
+				}
+				value.WriteByte(line[i])
+				i++
+			}
+			if !closed {
+				token.Parts = append(token.Parts, Part{Kind: DoubleQuoted, Start: quoteStart, End: len(line)})
+				return finish(line, token, value, len(line)), ErrUnterminated
+			}
+			i++
+			token.Parts = append(token.Parts, Part{Kind: DoubleQuoted, Start: quoteStart, End: i})
+		case '$':
+			if n, expanded := variableAt(line, i, lookup); n > 0 {
+				token.Parts = append(token.Parts, Part{Kind: Variable, Start: i, End: i + n})
+				value.WriteString(expanded)
+				i += n
+				continue
+			}
+			value.WriteByte(c)
+			i++
+		default:
+			value.WriteByte(c)
+			i++
+		}
+	}
+	return finish(line, token, value, i), nil
+}
+
+func finish(line string, token Token, value strings.Builder, end int) Token {
+	token.End = end
+	token.Text = line[token.Start:end]
+	token.Value = value.String()
+	return token
+}
+
+// variableAt returns the length of the $NAME or ${NAME} reference at i and
+// its value, or 0 when there is none
+func variableAt(line string, i int, lookup Lookup) (int, string) {
+	name := ""
+	n := 0
+	if strings.HasPrefix(line[i:], "${") {
+		end := strings.IndexByte(line[i+2:], '}')
+		if end < 0 {
+			return 0, ""
+		}
+		name = line[i+2 : i+2+end]
+		n = end + 3
+		if !isName(name) {
+			return 0, ""
+		}
+	} else {
+		j := i + 1
+		for j < len(line) && isNameChar(line[j], j == i+1) {
+			j++
+		}
+		name = line[i+1 : j]
+		n = j - i
+		if name == "" {
+			return 0, ""
+		}
+	}
+
+	if lookup == nil {
+		return n, ""
+	}
+	value, _ := lookup(name)
+	return n, value
+}
+
+func isName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isNameChar(s[i], i == 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func isNameChar(c byte, first bool) bool {
+	if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
+		return true
+	}
+	return !first && c >= '0' && c <= '9'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// Quote returns word in a form that Tokenize reads back unchanged
+func Quote(word string) string {
+	if word == "" {
+		return "''"
+	}
+	if !strings.ContainsAny(word, " \t\n\r|<>&'\"\\$") {
+		return word
+	}
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
+
+// Join quotes the words where needed and joins them into a command line
+func Join(words []string) string {
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = Quote(word)
+	}
+	return strings.Join(quoted, " ")
+}
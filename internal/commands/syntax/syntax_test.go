@@ -0,0 +1,181 @@
+package syntax
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var variables = map[string]string{
+	"HOME":  "/home/me",
+	"EMPTY": "",
+	"SPACE": "a b",
+	"_x1":   "underscore",
+}
+
+func lookup(name string) (string, bool) {
+	value, ok := variables[name]
+	return value, ok
+}
+
+// describe writes tokens as Value for words and [Text] for operators
+func describe(tokens []Token) string {
+	var parts []string
+	for _, token := range tokens {
+		if token.Kind == Word {
+			parts = append(parts, "<"+token.Value+">")
+		} else {
+			parts = append(parts, "["+token.Text+"]")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"", ""},
+		{"   \t ", ""},
+		{"echo hello  world", "<echo> <hello> <world>"},
+		{`echo 'single $HOME "x"'`, `<echo> <single $HOME "x">`},
+		{`echo "double $HOME 'x'"`, `<echo> <double /home/me 'x'>`},
+		{`echo "a \" \\ \$HOME \n"`, `<echo> <a " \ $HOME \n>`},
+		{`echo a\ b \'c\' \$HOME`, `<echo> <a b> <'c'> <$HOME>`},
+		{`echo trailing\`, `<echo> <trailing\>`},
+		{"echo $HOME/bin ${HOME}x $_x1", "<echo> </home/me/bin> </home/mex> <underscore>"},
+		{"echo $UNSET. $EMPTY", "<echo> <.> <>"},
+		{`echo $SPACE "$SPACE"`, "<echo> <a b> <a b>"},
+		{"echo $ $1 ${} ${bad-name} a$", "<echo> <$> <$1> <${}> <${bad-name}> <a$>"},
+		{`x"y"'z'`, "<xyz>"},
+		{"a|b", "<a> [|] <b>"},
+		{"ls | grep x | wc -l", "<ls> [|] <grep> <x> [|] <wc> <-l>"},
+		{"cmd < in > out >> log", "<cmd> [<] <in> [>] <out> [>>] <log>"},
+		{"cmd 2> err 2>> errlog &> all 2>&1", "<cmd> [2>] <err> [2>>] <errlog> [&>] <all> [2>&1]"},
+		{"cmd >out 2>&1", "<cmd> [>] <out> [2>&1]"},
+		{"echo a2> b", "<echo> <a2> [>] <b>"},
+		{"echo 2 x&y", "<echo> <2> <x&y>"},
+		{`echo "a|b" 'c>d' e\|f`, "<echo> <a|b> <c>d> <e|f>"},
+		{"echo é ü\\ñ", "<echo> <é> <üñ>"},
+	}
+	for _, tt := range tests {
+		tokens, err := Tokenize(tt.line, lookup)
+		if err != nil {
+			t.Errorf("Tokenize(%q) failed: %v", tt.line, err)
+			continue
+		}
+		if got := describe(tokens); got != tt.want {
+			t.Errorf("Tokenize(%q) = %s, want %s", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestTokenizeUnterminated(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{`echo 'open`, "<echo> <open>"},
+		{`echo "open $HOME`, "<echo> <open /home/me>"},
+		{`ls | grep "x`, "<ls> [|] <grep> <x>"},
+	}
+	for _, tt := range tests {
+		tokens, err := Tokenize(tt.line, lookup)
+		if err != ErrUnterminated {
+			t.Errorf("Tokenize(%q) error = %v, want ErrUnterminated", tt.line, err)
+		}
+		if got := describe(tokens); got != tt.want {
+			t.Errorf("Tokenize(%q) = %s, want %s", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	line := `cat "$HOME"/a\ b 2>&1|x`
+	tokens, err := Tokenize(line, lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range tokens {
+		if line[token.Start:token.End] != token.Text {
+			t.Errorf("token %q is at %d-%d, which holds %q", token.Text, token.Start, token.End, line[token.Start:token.End])
+		}
+	}
+
+	word := tokens[1]
+	want := []Part{
+		{Kind: Variable, Start: 5, End: 10},
+		{Kind: DoubleQuoted, Start: 4, End: 11},
+		{Kind: Escape, Start: 13, End: 15},
+	}
+	if !reflect.DeepEqual(word.Parts, want) {
+		t.Errorf("parts of %q = %+v, want %+v", word.Text, word.Parts, want)
+	}
+}
+
+func TestTokenizeUnsupported(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+		op   string
+	}{
+		{"test -f x || rm -rf build", "<test> <-f> <x> [||] <rm> <-rf> <build>", "||"},
+		{"make && make install", "<make> [&&] <make> <install>", "&&"},
+		{"a&&b||c", "<a> [&&] <b> [||] <c>", "&&"},
+		{"ls | wc ||", "<ls> [|] <wc> [||]", "||"},
+	}
+	for _, tt := range tests {
+		tokens, err := Tokenize(tt.line, lookup)
+		if e, ok := err.(*UnsupportedError); !ok || e.Op != tt.op {
+			t.Errorf("Tokenize(%q) error = %v, want unsupported operator %s", tt.line, err, tt.op)
+		}
+		if got := describe(tokens); got != tt.want {
+			t.Errorf("Tokenize(%q) = %s, want %s", tt.line, got, tt.want)
+		}
+	}
+
+	// Quoted or escaped, they are plain text
+	for _, line := range []string{`echo "a || b" 'c && d'`, `echo a\|\| b\&\&`} {
+		if _, err := Tokenize(line, lookup); err != nil {
+			t.Errorf("Tokenize(%q) failed: %v", line, err)
+		}
+	}
+}
+
+func TestTokenizeNilLookup(t *testing.T) {
+	tokens, err := Tokenize(`echo $HOME "${HOME}x"`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := describe(tokens), "<echo> <> <x>"; got != want {
+		t.Errorf("Tokenize without lookup = %s, want %s", got, want)
+	}
+}
+
+func TestQuoteRoundTrip(t *testing.T) {
+	words := []string{
+		"", "plain", "with space", "tab\there", "new\nline", "it's", `"double"`,
+		`back\slash`, "$HOME", "${HOME}", "a|b", "<in", ">out", "2>&1", "&>", "2",
+		"x&y", "'''", "é",
+	}
+	line := Join(words)
+	tokens, err := Tokenize(line, lookup)
+	if err != nil {
+		t.Fatalf("Tokenize(Join(words)) failed: %v", err)
+	}
+	var got []string
+	for _, token := range tokens {
+		if token.Kind != Word {
+			t.Fatalf("Join(words) = %s, which holds the operator %s", line, token.Text)
+		}
+		got = append(got, token.Value)
+	}
+	if !reflect.DeepEqual(got, words) {
+		t.Errorf("Tokenize(%s) = %q, want %q", line, got, words)
+	}
+
+	if got := Quote("plain-word_1.txt"); got != "plain-word_1.txt" {
+		t.Errorf("Quote of a plain word = %s, want it unchanged", got)
+	}
+}
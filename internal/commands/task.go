@@ -0,0 +1,237 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"commandripple/internal/commands/ripplefile"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+type taskOptions struct {
+	File  string // -f: Ripplefile to read instead of searching for one
+	Jobs  int    // -j: tasks run at once
+	Force bool   // --force: run tasks even when they are up to date
+	List  bool   // --list
+}
+
+// Task runs tasks from the nearest Ripplefile together with their
+// dependencies, e.g. `task build test`, `task -j 4 ci` or `task --list`
+func (s *Session) Task(args []string) error {
+	options := taskOptions{Jobs: runtime.NumCPU()}
+
+	var targets []string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-l", "--list":
+			options.List = true
+		case "--force":
+			options.Force = true
+		case "-f", "--file", "-j", "--jobs":
+			if i+1 >= len(args) {
+				return fmt.Errorf("missing value for %s", args[i])
+			}
+			if args[i] == "-f" || args[i] == "--file" {
+				options.File = args[i+1]
+			} else {
+				n, err := strconv.Atoi(args[i+1])
+				if err != nil || n < 1 {
+					return fmt.Errorf("invalid number of jobs: %s", args[i+1])
+				}
+				options.Jobs = n
+			}
+			i++
+		default:
+			if strings.HasPrefix(args[i], "-") {
+				return fmt.Errorf("usage: task [-f FILE] [-j N] [--force] [--list] [task...]")
+			}
+			targets = append(targets, args[i])
+		}
+	}
+
+	file, err := s.loadRipplefile(options.File)
+	if err != nil {
+		return err
+	}
+
+	if options.List {
+		return s.listTasks(file)
+	}
+	if len(targets) == 0 {
+		if _, exists := file.Tasks["default"]; !exists {
+			s.listTasks(file)
+			return fmt.Errorf("no task given and no default task defined")
+		}
+		targets = []string{"default"}
+	}
+
+	plan, err := file.Plan(targets)
+	if err != nil {
+		return err
+	}
+	run := &taskRun{
+		session: s,
+		file:    file,
+		options: options,
+		done:    make(map[string]bool),
+		started: make(map[string]bool),
+	}
+	return run.execute(plan)
+}
+
+// TaskNames returns the tasks of the Ripplefile found from the current
+// directory, for completion
+func TaskNames() []string {
+	file, err := Default.loadRipplefile("")
+	if err != nil {
+		return nil
+	}
+	return file.Names()
+}
+
+func (s *Session) loadRipplefile(path string) (*ripplefile.File, error) {
+	if path == "" {
+		var err error
+		if path, err = ripplefile.Find(s.Dir()); err != nil {
+			return nil, err
+		}
+	}
+	return ripplefile.Load(s.path(path))
+}
+
+func (s *Session) listTasks(file *ripplefile.File) error {
+	table := tablewriter.NewWriter(s.Stdout)
+	table.SetHeader([]string{"Task", "Depends On", "Description"})
+	for _, name := range file.Names() {
+		task := file.Tasks[name]
+		table.Append([]string{name, strings.Join(task.Deps, " "), task.Desc})
+	}
+	table.Render()
+	return nil
+}
+
+// taskRun executes a plan, starting every task whose dependencies are done
+// as long as fewer than options.Jobs tasks are running
+type taskRun struct {
+	session *Session
+	file    *ripplefile.File
+	options taskOptions
+
+	mutex    sync.Mutex
+	done     map[string]bool
+	started  map[string]bool
+	parallel bool // More than one task may run at once, so output is prefixed
+}
+
+type taskResult struct {
+	task *ripplefile.Task
+	err  error
+}
+
+func (r *taskRun) execute(plan []*ripplefile.Task) error {
+	r.parallel = r.options.Jobs > 1 && len(plan) > 1
+	results := make(chan taskResult)
+	running := 0
+	var firstErr error
+
+	for {
+		// Start what is ready unless a task failed
+		for _, task := range plan {
+			if firstErr != nil || running >= r.options.Jobs {
+				break
+			}
+			if r.started[task.Name] || !r.ready(task) {
+				continue
+			}
+			r.started[task.Name] = true
+			running++
+			go func(task *ripplefile.Task) {
+				results <- taskResult{task: task, err: r.runTask(task)}
+			}(task)
+		}
+
+		if running == 0 {
+			return firstErr
+		}
+
+		result := <-results
+		running--
+		if result.err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("task %s failed: %w", result.task.Name, result.err)
+			}
+			continue
+		}
+		r.done[result.task.Name] = true
+	}
+}
+
+func (r *taskRun) ready(task *ripplefile.Task) bool {
+	for _, dep := range task.Deps {
+		if !r.done[dep] {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *taskRun) runTask(task *ripplefile.Task) error {
+	dir := r.file.Dir
+	if task.Dir != "" {
+		if filepath.IsAbs(task.Dir) {
+			dir = task.Dir
+		} else {
+			dir = filepath.Join(r.file.Dir, task.Dir)
+		}
+	}
+
+	if !r.options.Force {
+		upToDate, err := task.UpToDate(dir)
+		if err != nil {
+			return err
+		}
+		if upToDate {
+			fmt.Fprintf(r.session.Stderr, "task: %s is up to date\n", task.Name)
+			return nil
+		}
+	}
+
+	fmt.Fprintf(r.session.Stderr, "task: %s\n", task.Name)
+
+	// Tasks running side by side get their output prefixed line by line,
+	// a lone task talks to the terminal directly
+	stdout, stderr := r.session.Stdout, r.session.Stderr
+	if r.parallel {
+		prefix := "[" + task.Name + "] "
+		stdoutWriter := &prefixWriter{prefix: prefix, out: stdout, mutex: &r.mutex}
+		stderrWriter := &prefixWriter{prefix: prefix, out: stderr, mutex: &r.mutex}
+		defer stdoutWriter.Flush()
+		defer stderrWriter.Flush()
+		stdout, stderr = stdoutWriter, stderrWriter
+	}
+
+	for _, line := range task.Run {
+		if err := r.runLine(task, dir, line, stdout, stderr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runLine runs one command line of a task in a shell of its own, which
+// starts in the task directory and environment, so that tasks running side
+// by side do not share them
+func (r *taskRun) runLine(task *ripplefile.Task, dir, line string, stdout, stderr io.Writer) error {
+	sub := r.session.subshell(dir, mergeEnv(r.session.Environ(), task.Env))
+	sub.Stdout, sub.Stderr = stdout, stderr
+	if r.parallel {
+		sub.Stdin = strings.NewReader("")
+	}
+	return sub.ExecuteCommandLine(line)
+}
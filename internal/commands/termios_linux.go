@@ -0,0 +1,13 @@
+//go:build linux
+// +build linux
+
+package commands
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+const (
+	ioctlGetTermios = unix.TCGETS
+	ioctlSetTermios = unix.TCSETS
+)
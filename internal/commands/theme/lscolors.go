@@ -0,0 +1,166 @@
+package theme
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// lsColors is LS_COLORS read into its file type keys, such as di, and its
+// *.ext patterns
+type lsColors struct {
+	source   string
+	types    map[string][]int
+	patterns []lsPattern
+}
+
+type lsPattern struct {
+	suffix string
+	codes  []int
+}
+
+var (
+	lsMu     sync.Mutex
+	lsParsed *lsColors
+)
+
+// currentLSColors parses LS_COLORS again when it changed
+func currentLSColors() *lsColors {
+	source := os.Getenv("LS_COLORS")
+	lsMu.Lock()
+	defer lsMu.Unlock()
+	if lsParsed == nil || lsParsed.source != source {
+		lsParsed = parseLSColors(source)
+	}
+	return lsParsed
+}
+
+// parseLSColors reads entries such as di=01;34:*.tar=01;31, skipping the
+// ones it does not understand like dircolors does
+func parseLSColors(source string) *lsColors {
+	c := &lsColors{source: source, types: make(map[string][]int)}
+	for _, entry := range strings.Split(source, ":") {
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok || key == "" {
+			continue
+		}
+		var codes []int
+		valid := true
+		for _, part := range strings.Split(value, ";") {
+			if part == "" {
+				continue
+			}
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				valid = false
+				break
+			}
+			codes = append(codes, n)
+		}
+		// ln=target colors links like their target, which is left to the
+		// symlink role
+		if !valid {
+			continue
+		}
+		if suffix, ok := strings.CutPrefix(key, "*"); ok {
+			c.patterns = append(c.patterns, lsPattern{suffix, codes})
+		} else {
+			c.types[key] = codes
+		}
+	}
+	return c
+}
+
+// lookup returns the codes LS_COLORS gives a file, and whether it has an
+// entry for it
+func (c *lsColors) lookup(name string, mode os.FileMode) ([]int, bool) {
+	key := ""
+	switch {
+	case mode&os.ModeDir != 0:
+		key = "di"
+		switch {
+		case mode&os.ModeSticky != 0 && mode&0002 != 0:
+			key = "tw"
+		case mode&0002 != 0:
+			key = "ow"
+		case mode&os.ModeSticky != 0:
+			key = "st"
+		}
+	case mode&os.ModeSymlink != 0:
+		key = "ln"
+	case mode&os.ModeNamedPipe != 0:
+		key = "pi"
+	case mode&os.ModeSocket != 0:
+		key = "so"
+	case mode&os.ModeDevice != 0 && mode&os.ModeCharDevice != 0:
+		key = "cd"
+	case mode&os.ModeDevice != 0:
+		key = "bd"
+	case mode&os.ModeSetuid != 0:
+		key = "su"
+	case mode&os.ModeSetgid != 0:
+		key = "sg"
+	case mode&0111 != 0:
+		key = "ex"
+	}
+	// Special directories fall back to di, and so on
+	for _, k := range []string{key, fallback[key]} {
+		if codes, ok := c.types[k]; ok && k != "" {
+			return codes, true
+		}
+	}
+	if key != "" && key != "su" && key != "sg" && key != "ex" {
+		return nil, false
+	}
+
+	// Patterns apply to regular files, the last matching one wins
+	for i := len(c.patterns) - 1; i >= 0; i-- {
+		p := c.patterns[i]
+		if strings.HasSuffix(name, p.suffix) || strings.HasSuffix(strings.ToLower(name), strings.ToLower(p.suffix)) {
+			return p.codes, true
+		}
+	}
+	if codes, ok := c.types["fi"]; ok {
+		return codes, true
+	}
+	return nil, false
+}
+
+var fallback = map[string]string{
+	"tw": "di",
+	"ow": "di",
+	"st": "di",
+	"su": "ex",
+	"sg": "ex",
+}
+
+// File returns the escape sequence for a file name in a listing written to
+// w. LS_COLORS decides when it has an entry for the file, the directory,
+// executable, symlink and hidden roles otherwise. Hidden files are only
+// styled when hidden is true, for listings that tell them apart.
+func File(w io.Writer, name string, mode os.FileMode, hidden bool) string {
+	if !Enabled(w) {
+		return ""
+	}
+	if codes, ok := currentLSColors().lookup(name, mode); ok {
+		return sequence(filter(codes))
+	}
+	switch {
+	case mode&os.ModeDir != 0:
+		return Interactive(Directory)
+	case mode&os.ModeSymlink != 0:
+		return Interactive(Symlink)
+	case mode&0111 != 0:
+		return Interactive(Executable)
+	case hidden:
+		return Interactive(Hidden)
+	}
+	return ""
+}
+
+// PaintFile wraps a file name in its style, see File
+func PaintFile(w io.Writer, name string, mode os.FileMode, hidden bool) string {
+	return wrap(File(w, name, mode, hidden), name)
+}
@@ -0,0 +1,406 @@
+// Package theme names the colors of the shell's output by role, such as
+// directory or diff-add, so that every command styles the same things the
+// same way and the colors can be changed in one place. Colors are only
+// written to terminals: NO_COLOR turns them off, keeping bold and the other
+// attributes, and CLICOLOR_FORCE writes them even to files and pipes.
+package theme
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/chzyer/readline"
+)
+
+// Reset ends a style
+const Reset = "\033[0m"
+
+// Roles
+const (
+	Directory  = "directory"
+	Executable = "executable"
+	Symlink    = "symlink"
+	Hidden     = "hidden"
+	Error      = "error"
+	Warning    = "warning"
+	Success    = "success"
+	Accent     = "accent"
+	Heading    = "heading"
+	DiffAdd    = "diff-add"
+	DiffRemove = "diff-remove"
+	TreeLines  = "tree-lines"
+
+	TableHeader = "table-header"
+	PID         = "pid"
+	PPID        = "ppid"
+	CPU         = "cpu"
+	Memory      = "memory"
+	ProcessName = "process"
+
+	Command    = "command"
+	Unknown    = "unknown"
+	String     = "string"
+	Escape     = "escape"
+	Variable   = "variable"
+	Pipe       = "pipe"
+	Redirect   = "redirect"
+	Suggestion = "suggestion"
+
+	FinderMatch    = "finder-match"
+	FinderCursor   = "finder-cursor"
+	FinderSelected = "finder-selected"
+	FinderCurrent  = "finder-current"
+	FinderInfo     = "finder-info"
+)
+
+// Role is a named style with its default
+type Role struct {
+	Name    string
+	Default string
+	Doc     string
+}
+
+// Roles lists every role in the order they are shown
+var Roles = []Role{
+	{Directory, "bold blue", "Directories in ls, lsc, tree and cd"},
+	{Executable, "bold green", "Executable files"},
+	{Symlink, "cyan", "Symbolic links"},
+	{Hidden, "yellow", "Hidden files in tree"},
+	{Error, "red", "Error messages"},
+	{Warning, "yellow", "Warnings"},
+	{Success, "green", "Results such as uptime"},
+	{Accent, "cyan", "Names in listings, help and echo"},
+	{Heading, "white", "Section headings of help"},
+	{DiffAdd, "green", "Added lines in diff"},
+	{DiffRemove, "red", "Removed lines in diff"},
+	{TreeLines, "blue, green, yellow, cyan, red, magenta", "Branch lines of tree, one color per level separated by commas"},
+	{TableHeader, "bright-cyan", "Table headers in ps"},
+	{PID, "yellow", "Process IDs in ps"},
+	{PPID, "green", "Parent process IDs in ps"},
+	{CPU, "red", "CPU usage in ps"},
+	{Memory, "magenta", "Memory usage in ps"},
+	{ProcessName, "white", "Commands in ps"},
+	{Command, "green", "Commands that are found, while typing"},
+	{Unknown, "red", "Commands that are not found, while typing"},
+	{String, "yellow", "Quoted strings, while typing"},
+	{Escape, "magenta", "Escaped characters, while typing"},
+	{Variable, "cyan", "Variables and assignments, while typing"},
+	{Pipe, "bold blue", "Pipes, while typing"},
+	{Redirect, "bold magenta", "Redirections, while typing"},
+	{Suggestion, "dim", "Autosuggestions"},
+	{FinderMatch, "bold green", "Matched characters in the finder"},
+	{FinderCursor, "bold red", "Cursor of the finder"},
+	{FinderSelected, "bold magenta", "Selection marks of the finder"},
+	{FinderCurrent, "bold", "Candidate under the finder's cursor"},
+	{FinderInfo, "dim", "Counts and borders of the finder"},
+}
+
+var (
+	mu     sync.RWMutex
+	styles = defaults()
+)
+
+func defaults() map[string][][]int {
+	m := make(map[string][][]int, len(Roles))
+	for _, r := range Roles {
+		m[r.Name], _ = parseList(r.Default)
+	}
+	return m
+}
+
+// Lookup returns a role by name
+func Lookup(name string) (Role, bool) {
+	for _, r := range Roles {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return Role{}, false
+}
+
+// Set changes the style of a role, see Parse for the format of spec
+func Set(role, spec string) error {
+	if _, ok := Lookup(role); !ok {
+		return fmt.Errorf("unknown role %s", role)
+	}
+	list, err := parseList(spec)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	styles[role] = list
+	mu.Unlock()
+	return nil
+}
+
+// Check reports whether spec is a valid style or list of styles
+func Check(spec string) error {
+	_, err := parseList(spec)
+	return err
+}
+
+// Enabled reports whether output written to w gets colors: CLICOLOR_FORCE
+// other than 0 turns them on, otherwise w has to be a terminal
+func Enabled(w io.Writer) bool {
+	if force := os.Getenv("CLICOLOR_FORCE"); force != "" && force != "0" {
+		return true
+	}
+	f, ok := w.(*os.File)
+	return ok && f != nil && readline.IsTerminal(int(f.Fd()))
+}
+
+// Color returns the escape sequence of role for output written to w, or ""
+// when w gets no colors
+func Color(w io.Writer, role string) string {
+	if !Enabled(w) {
+		return ""
+	}
+	return Interactive(role)
+}
+
+// Paint wraps text in the style of role for output written to w
+func Paint(w io.Writer, role, text string) string {
+	return wrap(Color(w, role), text)
+}
+
+// Interactive returns the escape sequence of role for what the shell draws
+// on the terminal itself, such as the line being edited, without checking
+// where standard output goes
+func Interactive(role string) string {
+	return Level(role, 0)
+}
+
+// Level is Interactive for the n-th style of a role holding a list,
+// counting around
+func Level(role string, n int) string {
+	mu.RLock()
+	list := styles[role]
+	mu.RUnlock()
+	if len(list) == 0 {
+		return ""
+	}
+	return sequence(filter(list[n%len(list)]))
+}
+
+// Codes returns the SGR parameters of role for output written to w, for
+// writers such as tablewriter that build the sequence themselves
+func Codes(w io.Writer, role string) []int {
+	if !Enabled(w) {
+		return nil
+	}
+	mu.RLock()
+	list := styles[role]
+	mu.RUnlock()
+	if len(list) == 0 {
+		return nil
+	}
+	return filter(list[0])
+}
+
+func wrap(color, text string) string {
+	if color == "" {
+		return text
+	}
+	return color + text + Reset
+}
+
+func sequence(codes []int) string {
+	if len(codes) == 0 {
+		return ""
+	}
+	parts := make([]string, len(codes))
+	for i, code := range codes {
+		parts[i] = strconv.Itoa(code)
+	}
+	return "\033[" + strings.Join(parts, ";") + "m"
+}
+
+// filter applies NO_COLOR, which keeps only attributes, and turns true
+// colors into the nearest of 256 on terminals that do not announce them in
+// COLORTERM
+func filter(codes []int) []int {
+	noColor := os.Getenv("NO_COLOR") != ""
+	colorterm := os.Getenv("COLORTERM")
+	trueColor := colorterm == "truecolor" || colorterm == "24bit"
+	out := make([]int, 0, len(codes))
+	for i := 0; i < len(codes); i++ {
+		code := codes[i]
+		switch {
+		case (code == 38 || code == 48) && i+2 < len(codes) && codes[i+1] == 5:
+			if !noColor {
+				out = append(out, codes[i:i+3]...)
+			}
+			i += 2
+		case (code == 38 || code == 48) && i+4 < len(codes) && codes[i+1] == 2:
+			switch {
+			case noColor:
+			case trueColor:
+				out = append(out, codes[i:i+5]...)
+			default:
+				out = append(out, code, 5, nearest256(codes[i+2], codes[i+3], codes[i+4]))
+			}
+			i += 4
+		case code >= 30 && code <= 49, code >= 90 && code <= 107:
+			if !noColor {
+				out = append(out, code)
+			}
+		default:
+			out = append(out, code)
+		}
+	}
+	return out
+}
+
+// nearest256 returns the color of the 6x6x6 cube or the gray ramp of the
+// 256 color palette closest to r, g, b
+func nearest256(r, g, b int) int {
+	level := func(v int) int {
+		if v < 48 {
+			return 0
+		}
+		if v < 115 {
+			return 1
+		}
+		return (v - 35) / 40
+	}
+	value := func(l int) int {
+		if l == 0 {
+			return 0
+		}
+		return 55 + l*40
+	}
+	lr, lg, lb := level(r), level(g), level(b)
+	cube := 16 + 36*lr + 6*lg + lb
+	cr, cg, cb := value(lr), value(lg), value(lb)
+
+	avg := (r + g + b) / 3
+	grayLevel := 23
+	if avg < 238 {
+		grayLevel = max(0, (avg-3)/10)
+	}
+	gray := 8 + grayLevel*10
+
+	dist := func(x, y, z int) int {
+		return (x-r)*(x-r) + (y-g)*(y-g) + (z-b)*(z-b)
+	}
+	if dist(gray, gray, gray) < dist(cr, cg, cb) {
+		return 232 + grayLevel
+	}
+	return cube
+}
+
+// Attributes and colors usable in styles
+var (
+	attributes = map[string]int{
+		"bold":      1,
+		"dim":       2,
+		"italic":    3,
+		"underline": 4,
+		"blink":     5,
+		"reverse":   7,
+	}
+	colors = map[string]int{
+		"black":   0,
+		"red":     1,
+		"green":   2,
+		"yellow":  3,
+		"blue":    4,
+		"magenta": 5,
+		"cyan":    6,
+		"white":   7,
+	}
+)
+
+// Parse reads a style: words separated by spaces, each an attribute (bold,
+// dim, italic, underline, blink, reverse), a color name, bright- and a
+// color name, default, a number from 0 to 255 of the 256 color palette, a
+// #rrggbb true color or SGR parameters such as 01;34. A color after "on"
+// is the background. none is no style.
+func Parse(spec string) ([]int, error) {
+	var codes []int
+	background := false
+	for _, word := range strings.Fields(strings.ToLower(spec)) {
+		base, offset := 30, 0
+		if background {
+			base = 40
+		}
+		if word == "none" {
+			continue
+		}
+		if word == "on" {
+			if background {
+				return nil, fmt.Errorf("on must be followed by a color")
+			}
+			background = true
+			continue
+		}
+		if code, ok := attributes[word]; ok && !background {
+			codes = append(codes, code)
+			continue
+		}
+
+		name, bright := strings.CutPrefix(word, "bright-")
+		if bright {
+			offset = 60
+		}
+		switch code, isName := colors[name]; {
+		case isName:
+			codes = append(codes, base+offset+code)
+		case word == "default":
+			codes = append(codes, base+9)
+		case strings.HasPrefix(word, "#"):
+			r, g, b, err := parseHex(word[1:])
+			if err != nil {
+				return nil, err
+			}
+			codes = append(codes, base+8, 2, r, g, b)
+		case strings.Contains(word, ";") && !background:
+			for _, part := range strings.Split(word, ";") {
+				n, err := strconv.Atoi(part)
+				if err != nil || n < 0 || n > 255 {
+					return nil, fmt.Errorf("invalid SGR parameters %q", word)
+				}
+				codes = append(codes, n)
+			}
+		default:
+			n, err := strconv.Atoi(word)
+			if err != nil || n < 0 || n > 255 || bright {
+				return nil, fmt.Errorf("unknown color %q, use a name such as red or bright-red, a number from 0 to 255 or #rrggbb", word)
+			}
+			codes = append(codes, base+8, 5, n)
+		}
+		background = false
+	}
+	if background {
+		return nil, fmt.Errorf("on must be followed by a color")
+	}
+	return codes, nil
+}
+
+// parseList reads styles separated by commas
+func parseList(spec string) ([][]int, error) {
+	var list [][]int
+	for _, part := range strings.Split(spec, ",") {
+		codes, err := Parse(part)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, codes)
+	}
+	return list, nil
+}
+
+func parseHex(s string) (int, int, int, error) {
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil || len(s) != 6 {
+		return 0, 0, 0, fmt.Errorf("invalid color #%s, use #rrggbb", s)
+	}
+	return int(v >> 16), int(v >> 8 & 0xff), int(v & 0xff), nil
+}
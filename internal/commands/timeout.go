@@ -0,0 +1,138 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"commandripple/internal/commands/processes"
+	"commandripple/internal/commands/syntax"
+)
+
+// timeoutExitCode is the exit status of a command killed by `timeout`
+const timeoutExitCode = 124
+
+// timeoutGrace is how long `timeout` waits for a command to finish after the
+// last signal before it returns anyway
+const timeoutGrace = time.Second
+
+// Timeout runs a command and signals its process groups when the duration
+// expires, e.g. `timeout -s INT -k 5s 1m make test`
+func (s *Session) Timeout(args []string) error {
+	sig := syscall.SIGTERM
+	var killAfter time.Duration
+
+	i := 0
+	for ; i < len(args) && strings.HasPrefix(args[i], "-"); i++ {
+		if i+1 >= len(args) {
+			return fmt.Errorf("missing value for %s", args[i])
+		}
+		switch args[i] {
+		case "-s", "--signal":
+			parsed, err := processes.ParseSignal(args[i+1])
+			if err != nil {
+				return err
+			}
+			sig = parsed
+		case "-k", "--kill-after":
+			d, err := parseTimeoutDuration(args[i+1])
+			if err != nil {
+				return err
+			}
+			killAfter = d
+		default:
+			return fmt.Errorf("unknown option: %s", args[i])
+		}
+		i++
+	}
+
+	if len(args)-i < 2 {
+		return fmt.Errorf("usage: timeout [-s SIGNAL] [-k KILL_AFTER] DURATION command")
+	}
+
+	duration, err := parseTimeoutDuration(args[i])
+	if err != nil {
+		return err
+	}
+	commandLine := syntax.Join(args[i+1:])
+
+	parent := s.commandContext()
+	ctx, cancel := context.WithCancelCause(parent)
+	defer cancel(nil)
+	group := &processGroup{ctx: ctx, parent: s.group}
+
+	sub := *s
+	sub.group = group
+	done := make(chan error, 1)
+	go func() {
+		done <- sub.executeCommand(commandLine)
+	}()
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+	case <-parent.Done():
+		// An enclosing timeout expired
+	}
+
+	timedOut := &ExitStatus{
+		Code:   timeoutExitCode,
+		Reason: fmt.Sprintf("command timed out after %v: %s", duration, commandLine),
+	}
+
+	// Builtins stop when the context is cancelled, processes on the signal
+	cancel(timedOut)
+	group.signal(sig)
+	if killAfter > 0 {
+		select {
+		case <-done:
+			return timedOut
+		case <-time.After(killAfter):
+			group.signal(syscall.SIGKILL)
+		}
+	}
+	// A builtin blocked reading its input never sees the context, it is
+	// left behind rather than holding the shell
+	select {
+	case <-done:
+	case <-time.After(timeoutGrace):
+	}
+	return timedOut
+}
+
+// sleepContext waits for d unless ctx is done first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+}
+
+// parseTimeoutDuration accepts plain seconds as well as Go durations and a
+// d suffix for days, e.g. 10, 1.5, 30s, 2m or 1d
+func parseTimeoutDuration(s string) (time.Duration, error) {
+	if seconds, err := strconv.ParseFloat(s, 64); err == nil && seconds >= 0 {
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	if days, found := strings.CutSuffix(s, "d"); found {
+		if n, err := strconv.ParseFloat(days, 64); err == nil && n >= 0 {
+			return time.Duration(n * 24 * float64(time.Hour)), nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration: %s", s)
+	}
+	return d, nil
+}
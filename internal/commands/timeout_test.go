@@ -0,0 +1,24 @@
+package commands
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestTimeoutBlockedBuiltin(t *testing.T) {
+	// tr reads its input, which never ends nor checks the context
+	input, writer := io.Pipe()
+	defer writer.Close()
+	s := NewSession(t.TempDir(), nil)
+	s.Stdin, s.Stdout, s.Stderr = input, io.Discard, io.Discard
+
+	start := time.Now()
+	err := s.Timeout([]string{"0.1", "tr", "a", "b"})
+	if code := ExitCode(err); code != timeoutExitCode {
+		t.Errorf("timeout of a blocked builtin = %v (status %d), want status %d", err, code, timeoutExitCode)
+	}
+	if elapsed := time.Since(start); elapsed > timeoutGrace+2*time.Second {
+		t.Errorf("timeout returned after %v", elapsed)
+	}
+}
@@ -5,20 +5,10 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
-)
 
-const (
-	ColorReset   = "\033[0m"
-	ColorBlue    = "\033[34m"
-	ColorGreen   = "\033[32m"
-	ColorYellow  = "\033[33m"
-	ColorCyan    = "\033[36m"
-	ColorRed     = "\033[31m"
-	ColorMagenta = "\033[35m"
+	"commandripple/internal/commands/theme"
 )
 
-var LineColors = []string{ColorBlue, ColorGreen, ColorYellow, ColorCyan, ColorRed, ColorMagenta}
-
 type TreeStats struct {
 	Directories int
 	Files       int
@@ -28,7 +18,7 @@ type TreeOptions struct {
 	ShowHidden bool
 }
 
-func Tree(args []string) error {
+func (s *Session) Tree(args []string) error {
 	options := TreeOptions{}
 	root := "."
 
@@ -42,18 +32,18 @@ func Tree(args []string) error {
 		}
 	}
 
-	fmt.Printf("Starting tree from root: %s\n", root)
+	fmt.Fprintf(s.Stdout, "Starting tree from root: %s\n", root)
 
 	stats := &TreeStats{}
-	err := printTree(root, "", stats, options, 0)
+	err := s.printTree(s.path(root), "", stats, options, 0)
 	if err != nil {
 		return fmt.Errorf("error in printTree: %v", err)
 	}
-	fmt.Printf("\n%d directories, %d files\n", stats.Directories, stats.Files)
+	fmt.Fprintf(s.Stdout, "\n%d directories, %d files\n", stats.Directories, stats.Files)
 	return nil
 }
 
-func printTree(path string, prefix string, stats *TreeStats, options TreeOptions, depth int) error {
+func (s *Session) printTree(path string, prefix string, stats *TreeStats, options TreeOptions, depth int) error {
 	fileInfo, err := os.Stat(path)
 	if err != nil {
 		return fmt.Errorf("error getting file info for %s: %v", path, err)
@@ -63,14 +53,16 @@ func printTree(path string, prefix string, stats *TreeStats, options TreeOptions
 		return nil
 	}
 
-	lineColor := LineColors[depth%len(LineColors)]
-	coloredPrefix := colorizePrefix(prefix, lineColor)
+	coloredPrefix := prefix
+	if theme.Enabled(s.Stdout) {
+		coloredPrefix = colorizePrefix(prefix, theme.Level(theme.TreeLines, depth))
+	}
 
+	name := fileInfo.Name()
+	fmt.Fprintf(s.Stdout, "%s%s\n", coloredPrefix, theme.PaintFile(s.Stdout, name, fileInfo.Mode(), isHidden(name)))
 	if fileInfo.IsDir() {
-		fmt.Printf("%s%s%s%s\n", coloredPrefix, ColorBlue, fileInfo.Name(), ColorReset)
 		stats.Directories++
 	} else {
-		fmt.Printf("%s%s%s%s\n", coloredPrefix, getFileColor(fileInfo), fileInfo.Name(), ColorReset)
 		stats.Files++
 	}
 
@@ -92,9 +84,9 @@ func printTree(path string, prefix string, stats *TreeStats, options TreeOptions
 		if i == len(entries)-1 {
 			newPrefix = prefix + "└── "
 		}
-		err := printTree(filepath.Join(path, entry.Name()), newPrefix, stats, options, depth+1)
+		err := s.printTree(filepath.Join(path, entry.Name()), newPrefix, stats, options, depth+1)
 		if err != nil {
-			fmt.Printf("Error processing %s: %v\n", entry.Name(), err)
+			fmt.Fprintf(s.Stdout, "Error processing %s: %v\n", entry.Name(), err)
 		}
 	}
 
@@ -102,14 +94,17 @@ func printTree(path string, prefix string, stats *TreeStats, options TreeOptions
 }
 
 func colorizePrefix(prefix string, color string) string {
+	if color == "" {
+		return prefix
+	}
 	parts := strings.Split(prefix, "──")
 	if len(parts) > 1 {
 		coloredParts := make([]string, len(parts))
 		for i, part := range parts {
 			if i == len(parts)-1 {
-				coloredParts[i] = color + part + ColorReset
+				coloredParts[i] = color + part + theme.Reset
 			} else {
-				coloredParts[i] = color + part + "──" + ColorReset
+				coloredParts[i] = color + part + "──" + theme.Reset
 			}
 		}
 		return strings.Join(coloredParts, "")
@@ -117,19 +112,6 @@ func colorizePrefix(prefix string, color string) string {
 	return prefix
 }
 
-func getFileColor(fileInfo os.FileInfo) string {
-	switch {
-	case fileInfo.Mode()&os.ModeSymlink != 0:
-		return ColorCyan
-	case fileInfo.Mode()&0111 != 0:
-		return ColorGreen
-	case isHidden(fileInfo.Name()):
-		return ColorYellow
-	default:
-		return ColorReset
-	}
-}
-
 func isHidden(name string) bool {
 	return strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
 }
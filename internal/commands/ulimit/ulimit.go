@@ -0,0 +1,338 @@
+package ulimit
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Resource identifies a per-process resource limit.
+type Resource int
+
+const (
+	CPU Resource = iota
+	AddressSpace
+	OpenFiles
+	Processes
+	CoreSize
+	FileSize
+)
+
+// Unlimited removes any limit on a resource.
+const Unlimited = ^uint64(0)
+
+// Limits maps resources to their limit. CPU is in seconds, AddressSpace,
+// CoreSize and FileSize are in bytes, OpenFiles and Processes are counts.
+type Limits map[Resource]uint64
+
+type resourceInfo struct {
+	resource    Resource
+	flag        byte
+	name        string
+	description string
+	unit        string
+	scale       uint64
+}
+
+// resources lists the supported limits in the order `ulimit -a` prints them.
+// The scale converts the unit shown to the user into the stored value.
+var resources = []resourceInfo{
+	{CoreSize, 'c', "core", "core file size", "kbytes", 1024},
+	{FileSize, 'f', "fsize", "file size", "kbytes", 1024},
+	{OpenFiles, 'n', "files", "open files", "count", 1},
+	{CPU, 't', "cpu", "cpu time", "seconds", 1},
+	{Processes, 'u', "procs", "max user processes", "count", 1},
+	{AddressSpace, 'v', "mem", "virtual memory", "kbytes", 1024},
+}
+
+// Table holds the limits set with the `ulimit` builtin of one shell
+type Table struct {
+	mutex  sync.Mutex
+	limits Limits
+}
+
+// NewTable returns a table starting with limits
+func NewTable(limits Limits) *Table {
+	return &Table{limits: limits.Merge(nil)}
+}
+
+// Current returns a copy of the limits set with the `ulimit` builtin.
+func (t *Table) Current() Limits {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.limits.Merge(nil)
+}
+
+// Merge returns a new set of limits where values from other override l.
+func (l Limits) Merge(other Limits) Limits {
+	merged := make(Limits, len(l)+len(other))
+	for r, v := range l {
+		merged[r] = v
+	}
+	for r, v := range other {
+		merged[r] = v
+	}
+	return merged
+}
+
+// Ulimit shows or sets the resource limits applied to spawned commands
+func (t *Table) Ulimit(w io.Writer, args []string) error {
+	if len(args) == 0 || (len(args) == 1 && args[0] == "-a") {
+		t.printLimits(w)
+		return nil
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for i := 0; i < len(args); i++ {
+		info, ok := lookupFlag(args[i])
+		if !ok {
+			return fmt.Errorf("usage: ulimit [-a] [-c|-f|-n|-t|-u|-v [value|unlimited]]")
+		}
+
+		// Without a value the flag prints the current limit
+		if i+1 >= len(args) || strings.HasPrefix(args[i+1], "-") {
+			fmt.Fprintln(w, formatLimit(info, t.effectiveLimit(info.resource)))
+			continue
+		}
+
+		i++
+		value, err := parseUlimitValue(info, args[i])
+		if err != nil {
+			return err
+		}
+		if value == Unlimited {
+			delete(t.limits, info.resource)
+		} else {
+			t.limits[info.resource] = value
+		}
+	}
+	return nil
+}
+
+// execFlag selects the hidden mode the shell re-executes itself in to set
+// the limits of a command before running it
+const execFlag = "--ulimit-exec"
+
+// selfExec is set once RunInternal ran, which means the program handles
+// its exec mode
+var selfExec bool
+
+// Start starts cmd with limits, calling start to do so, e.g. cmd.Start. The
+// command never runs without them, nor does anything it starts. A program
+// that calls RunInternal first thing in main re-executes itself in its exec
+// mode, which sets the limits and then executes the command in its place.
+// Other programs, such as those embedding the shell, start the command
+// stopped at its first instruction and set the limits of the new process,
+// which keeps setuid programs from gaining their privileges.
+func Start(cmd *exec.Cmd, limits Limits, start func() error) error {
+	if len(limits) == 0 || cmd.Err != nil {
+		return start()
+	}
+	if !supported {
+		return fmt.Errorf("resource limits are not supported on this platform")
+	}
+	if !selfExec {
+		return startStopped(cmd, limits, start)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to set resource limits: %v", err)
+	}
+	cmd.Args = append([]string{exe, execFlag, limits.encode(), cmd.Path}, cmd.Args...)
+	cmd.Path = exe
+	return start()
+}
+
+// RunInternal runs the exec mode of Start. It reports whether args selected
+// it and the exit status to use when the command could not be executed.
+func RunInternal(args []string) (int, bool) {
+	selfExec = true
+	if len(args) < 4 || args[0] != execFlag {
+		return 0, false
+	}
+	limits, err := decode(args[1])
+	if err == nil {
+		err = setLimits(0, limits)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "CommandRipple: %v\n", err)
+		return 126, true
+	}
+	err = execute(args[2], args[3:])
+	fmt.Fprintf(os.Stderr, "CommandRipple: %s: %v\n", args[3], err)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 127, true
+	}
+	return 126, true
+}
+
+// encode writes limits as c=1024,n=64 with the flags of ulimit
+func (l Limits) encode() string {
+	var parts []string
+	for _, info := range resources {
+		if v, ok := l[info.resource]; ok {
+			parts = append(parts, fmt.Sprintf("%c=%d", info.flag, v))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func decode(s string) (Limits, error) {
+	limits := make(Limits)
+	for _, part := range strings.Split(s, ",") {
+		flag, value, _ := strings.Cut(part, "=")
+		info, ok := lookupFlag("-" + flag)
+		v, err := strconv.ParseUint(value, 10, 64)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("invalid resource limits: %s", s)
+		}
+		limits[info.resource] = v
+	}
+	return limits, nil
+}
+
+// ParseLimitFlag parses a `limit` builtin option such as --mem 512M or
+// --cpu 30s into a resource and value.
+func ParseLimitFlag(flag, value string) (Resource, uint64, error) {
+	name := strings.TrimPrefix(flag, "--")
+	for _, info := range resources {
+		if info.name != name {
+			continue
+		}
+		var v uint64
+		var err error
+		switch info.unit {
+		case "seconds":
+			v, err = ParseSeconds(value)
+		case "kbytes":
+			v, err = ParseSize(value)
+		default:
+			v, err = parseCount(value)
+		}
+		return info.resource, v, err
+	}
+	return 0, 0, fmt.Errorf("unknown limit: %s", flag)
+}
+
+// ParseSize parses a byte size such as 4096, 512K, 512M or 2G.
+func ParseSize(s string) (uint64, error) {
+	if s == "unlimited" {
+		return Unlimited, nil
+	}
+
+	multiplier := uint64(1)
+	trimmed := strings.TrimSuffix(strings.ToUpper(s), "B")
+	if trimmed != "" {
+		switch trimmed[len(trimmed)-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		case 'T':
+			multiplier = 1 << 40
+		}
+		if multiplier > 1 {
+			trimmed = trimmed[:len(trimmed)-1]
+		}
+	}
+
+	n, err := strconv.ParseUint(trimmed, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size: %s", s)
+	}
+	return n * multiplier, nil
+}
+
+// ParseSeconds parses a CPU time given either as plain seconds or as a
+// duration such as 30s or 2m.
+func ParseSeconds(s string) (uint64, error) {
+	if s == "unlimited" {
+		return Unlimited, nil
+	}
+	if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return n, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < time.Second {
+		return 0, fmt.Errorf("invalid cpu time: %s", s)
+	}
+	return uint64(d / time.Second), nil
+}
+
+func parseCount(s string) (uint64, error) {
+	if s == "unlimited" {
+		return Unlimited, nil
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid count: %s", s)
+	}
+	return n, nil
+}
+
+func parseUlimitValue(info resourceInfo, s string) (uint64, error) {
+	if s == "unlimited" {
+		return Unlimited, nil
+	}
+	// Plain numbers use the unit shown by `ulimit -a`, suffixed sizes are bytes
+	if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return n * info.scale, nil
+	}
+	switch info.unit {
+	case "kbytes":
+		return ParseSize(s)
+	case "seconds":
+		return ParseSeconds(s)
+	}
+	return 0, fmt.Errorf("invalid value for -%c: %s", info.flag, s)
+}
+
+func lookupFlag(arg string) (resourceInfo, bool) {
+	if len(arg) != 2 || arg[0] != '-' {
+		return resourceInfo{}, false
+	}
+	for _, info := range resources {
+		if info.flag == arg[1] {
+			return info, true
+		}
+	}
+	return resourceInfo{}, false
+}
+
+// effectiveLimit returns the configured limit, falling back to the limit
+// inherited by the shell itself. Callers must hold the mutex.
+func (t *Table) effectiveLimit(r Resource) uint64 {
+	if v, ok := t.limits[r]; ok {
+		return v
+	}
+	return inheritedLimit(r)
+}
+
+func (t *Table) printLimits(w io.Writer) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for _, info := range resources {
+		fmt.Fprintln(w, formatLimit(info, t.effectiveLimit(info.resource)))
+	}
+}
+
+func formatLimit(info resourceInfo, value uint64) string {
+	label := fmt.Sprintf("(%s, -%c)", info.unit, info.flag)
+	if value == Unlimited {
+		return fmt.Sprintf("%-24s%-16sunlimited", info.description, label)
+	}
+	return fmt.Sprintf("%-24s%-16s%d", info.description, label, value/info.scale)
+}
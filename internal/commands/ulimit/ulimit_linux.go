@@ -0,0 +1,126 @@
+//go:build linux
+// +build linux
+
+package ulimit
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+var rlimitResources = map[Resource]int{
+	CPU:          unix.RLIMIT_CPU,
+	AddressSpace: unix.RLIMIT_AS,
+	OpenFiles:    unix.RLIMIT_NOFILE,
+	Processes:    unix.RLIMIT_NPROC,
+	CoreSize:     unix.RLIMIT_CORE,
+	FileSize:     unix.RLIMIT_FSIZE,
+}
+
+// supported reports whether Start can set limits on this platform
+const supported = true
+
+// setLimits sets the soft and hard limits of a process, 0 for the calling
+// one before it executes the command they are for
+func setLimits(pid int, limits Limits) error {
+	for r, value := range limits {
+		resource := rlimitResources[r]
+
+		var old unix.Rlimit
+		if err := unix.Prlimit(pid, resource, nil, &old); err != nil {
+			return fmt.Errorf("failed to read resource limit: %v", err)
+		}
+
+		// An unprivileged process cannot raise its hard limit
+		if value > old.Max {
+			value = old.Max
+		}
+		limit := unix.Rlimit{Cur: value, Max: value}
+		if err := unix.Prlimit(pid, resource, &limit, nil); err != nil {
+			return fmt.Errorf("failed to set resource limit: %v", err)
+		}
+	}
+	return nil
+}
+
+// startStopped starts cmd traced, so that the kernel stops it once it
+// executed the program, sets the limits of the new process and lets it go
+func startStopped(cmd *exec.Cmd, limits Limits, start func() error) error {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Ptrace = true
+	// Only the thread that started a traced process may let it go
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	if err := start(); err != nil {
+		return err
+	}
+	pid := cmd.Process.Pid
+	err := awaitExec(pid)
+	if err == nil {
+		err = setLimits(pid, limits)
+	}
+	if detachErr := unix.PtraceDetach(pid); err == nil {
+		err = detachErr
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return fmt.Errorf("failed to set resource limits: %v", err)
+	}
+	return nil
+}
+
+// awaitExec waits for a traced process to stop after executing its program.
+// A process that exited instead, e.g. because it was killed, is left for
+// cmd.Wait to collect.
+func awaitExec(pid int) error {
+	for {
+		var info unix.Siginfo
+		err := unix.Waitid(unix.P_PID, pid, &info, unix.WEXITED|unix.WSTOPPED|unix.WNOWAIT, nil)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if info.Code != cldTrapped && info.Code != cldStopped {
+			return fmt.Errorf("process %d exited before it ran", pid)
+		}
+		var status unix.WaitStatus
+		_, err = unix.Wait4(pid, &status, 0, nil)
+		if err == unix.EINTR {
+			continue
+		}
+		return err
+	}
+}
+
+// Codes of waitid for a stopped process
+const (
+	cldTrapped = 4
+	cldStopped = 5
+)
+
+// execute replaces the process with the command
+func execute(path string, argv []string) error {
+	return unix.Exec(path, argv, os.Environ())
+}
+
+func inheritedLimit(r Resource) uint64 {
+	var limit unix.Rlimit
+	if err := unix.Getrlimit(rlimitResources[r], &limit); err != nil {
+		return Unlimited
+	}
+	if limit.Cur == unix.RLIM_INFINITY {
+		return Unlimited
+	}
+	return limit.Cur
+}
@@ -0,0 +1,28 @@
+//go:build !linux
+// +build !linux
+
+package ulimit
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// supported is false, limits are only set on Linux
+const supported = false
+
+func setLimits(pid int, limits Limits) error {
+	return fmt.Errorf("resource limits are not supported on this platform")
+}
+
+func startStopped(cmd *exec.Cmd, limits Limits, start func() error) error {
+	return fmt.Errorf("resource limits are not supported on this platform")
+}
+
+func execute(path string, argv []string) error {
+	return fmt.Errorf("resource limits are not supported on this platform")
+}
+
+func inheritedLimit(r Resource) uint64 {
+	return Unlimited
+}
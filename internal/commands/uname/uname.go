@@ -2,6 +2,7 @@ package uname
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"runtime"
 )
@@ -14,7 +15,7 @@ type SystemInfo struct {
 	Version      string
 }
 
-func Uname(args []string) error {
+func Uname(w io.Writer, args []string) error {
 	info := SystemInfo{
 		OS:           runtime.GOOS,
 		Architecture: runtime.GOARCH,
@@ -32,21 +33,21 @@ func Uname(args []string) error {
 	}
 
 	if len(args) > 0 && args[0] == "-a" {
-		printDetailedSystemInfo(sysInfo)
+		printDetailedSystemInfo(w, sysInfo)
 	} else {
-		printBasicSystemInfo(sysInfo)
+		printBasicSystemInfo(w, sysInfo)
 	}
 	return nil
 }
 
-func printBasicSystemInfo(info SystemInfo) {
-	fmt.Printf("%s %s %s\n", info.OS, info.Kernel, info.Architecture)
+func printBasicSystemInfo(w io.Writer, info SystemInfo) {
+	fmt.Fprintf(w, "%s %s %s\n", info.OS, info.Kernel, info.Architecture)
 }
 
-func printDetailedSystemInfo(info SystemInfo) {
-	fmt.Printf("Operating System: %s\n", info.OS)
-	fmt.Printf("Kernel: %s\n", info.Kernel)
-	fmt.Printf("Architecture: %s\n", info.Architecture)
-	fmt.Printf("Hostname: %s\n", info.Hostname)
-	fmt.Printf("Version: %s\n", info.Version)
+func printDetailedSystemInfo(w io.Writer, info SystemInfo) {
+	fmt.Fprintf(w, "Operating System: %s\n", info.OS)
+	fmt.Fprintf(w, "Kernel: %s\n", info.Kernel)
+	fmt.Fprintf(w, "Architecture: %s\n", info.Architecture)
+	fmt.Fprintf(w, "Hostname: %s\n", info.Hostname)
+	fmt.Fprintf(w, "Version: %s\n", info.Version)
 }
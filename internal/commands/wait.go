@@ -0,0 +1,146 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+)
+
+// errWaitInterrupted is returned when Ctrl-C stops `wait`
+var errWaitInterrupted = &ExitStatus{Code: 130, Reason: "wait: interrupted"}
+
+// Wait waits for background jobs and returns the exit status of the last one
+// waited for: `wait` waits for every job, `wait %1 1234` for the given jobs
+// or processes and `wait -n` for whichever job finishes next
+func (s *Session) Wait(args []string) error {
+	if len(args) > 0 && args[0] == "-n" {
+		return s.waitInterruptible(s.waitNext)
+	}
+
+	var jobs []*JobInfo
+	s.jobs.mutex.Lock()
+	if len(args) == 0 {
+		jobs = s.jobs.sorted()
+	}
+	for _, target := range args {
+		var jobInfo *JobInfo
+		var err error
+		if strings.HasPrefix(target, "%") {
+			jobInfo, err = s.jobs.lookup(target)
+		} else {
+			jobInfo, err = s.jobs.byPid(target)
+		}
+		if err != nil {
+			s.jobs.mutex.Unlock()
+			return err
+		}
+		jobs = append(jobs, jobInfo)
+	}
+	s.jobs.mutex.Unlock()
+
+	var result error
+	for _, jobInfo := range jobs {
+		err := s.waitInterruptible(func(cancelled *bool) error { return s.waitJob(jobInfo, cancelled) })
+		if err == errWaitInterrupted {
+			return err
+		}
+		result = err
+	}
+	return result
+}
+
+// waitJob blocks until a job is done or stopped and removes a finished job
+// from the job table, since its status has now been reported
+func (s *Session) waitJob(j *JobInfo, cancelled *bool) error {
+	s.jobs.mutex.Lock()
+	defer s.jobs.mutex.Unlock()
+
+	for j.Status == JobRunning {
+		if *cancelled {
+			return errWaitInterrupted
+		}
+		s.jobs.changed.Wait()
+	}
+	if j.Status == JobStopped {
+		j.reported = JobStopped
+		return fmt.Errorf("job %d is stopped", j.ID)
+	}
+	s.jobs.remove(j)
+	return j.err()
+}
+
+// waitNext blocks until any job finishes, preferring jobs that finished
+// before `wait -n` was called, and returns its status
+func (s *Session) waitNext(cancelled *bool) error {
+	s.jobs.mutex.Lock()
+	defer s.jobs.mutex.Unlock()
+
+	for {
+		var next *JobInfo
+		running := false
+		for _, jobInfo := range s.jobs.sorted() {
+			switch {
+			case jobInfo.Status == JobRunning:
+				running = true
+			case jobInfo.Status == JobDone && (next == nil || jobInfo.EndTime.Before(next.EndTime)):
+				next = jobInfo
+			}
+		}
+		if next != nil {
+			s.jobs.remove(next)
+			return next.err()
+		}
+		if !running {
+			return &ExitStatus{Code: 127, Reason: "wait: no running jobs"}
+		}
+		if *cancelled {
+			return errWaitInterrupted
+		}
+		s.jobs.changed.Wait()
+	}
+}
+
+// byPid finds the job a process belongs to. Callers must hold t.mutex.
+func (t *jobTable) byPid(target string) (*JobInfo, error) {
+	pid, err := strconv.Atoi(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PID or job spec: %s", target)
+	}
+	for _, jobInfo := range t.jobs {
+		for _, other := range jobInfo.pids() {
+			if other == pid {
+				return jobInfo, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("pid %d is not a child of this shell", pid)
+}
+
+// waitInterruptible runs a wait function, which must check cancelled every
+// time it wakes up, and cancels it when Ctrl-C is pressed or the timeout it
+// runs under expires. The jobs keep running in the background.
+func (s *Session) waitInterruptible(fn func(cancelled *bool) error) error {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	cancelled := false
+	done := make(chan error, 1)
+	go func() {
+		done <- fn(&cancelled)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-interrupt:
+	case <-s.commandContext().Done():
+	}
+	s.jobs.mutex.Lock()
+	cancelled = true
+	s.jobs.changed.Broadcast()
+	s.jobs.mutex.Unlock()
+	return <-done
+}
@@ -1,12 +1,14 @@
 package commands
 
 import (
+	"context"
 	"fmt"
-	"strings"
 	"time"
+
+	"commandripple/internal/commands/syntax"
 )
 
-func Watch(args []string) error {
+func (s *Session) Watch(args []string) error {
 	if len(args) < 2 {
 		return fmt.Errorf("usage: watch [interval] [command]")
 	}
@@ -16,18 +18,24 @@ func Watch(args []string) error {
 		return fmt.Errorf("invalid interval: %v", err)
 	}
 
-	command := strings.Join(args[1:], " ")
+	command := syntax.Join(args[1:])
+	ctx := s.commandContext()
 
 	for {
-		fmt.Printf("\033[2J") // Clear screen
-		fmt.Printf("\033[H")  // Move cursor to top-left
-		fmt.Printf("Every %v: %s\n\n", interval, command)
+		fmt.Fprintf(s.Stdout, "\033[2J") // Clear screen
+		fmt.Fprintf(s.Stdout, "\033[H")  // Move cursor to top-left
+		fmt.Fprintf(s.Stdout, "Every %v: %s\n\n", interval, command)
 
-		err := executeCommand(command)
+		err := s.executeCommand(command)
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
 		if err != nil {
-			fmt.Printf("Error: %v\n", err)
+			fmt.Fprintf(s.Stdout, "Error: %v\n", err)
 		}
 
-		time.Sleep(interval)
+		if err := sleepContext(ctx, interval); err != nil {
+			return err
+		}
 	}
 }
@@ -2,20 +2,22 @@ package which
 
 import (
 	"fmt"
+	"io"
 )
 
-// Which locates a command in the PATH
-func Which(args []string) error {
+// Which locates a command in path, a list of directories like PATH, for a
+// shell in dir
+func Which(w io.Writer, args []string, dir, path string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("usage: which [command]")
 	}
 
 	command := args[0]
-	path, err := findExecutable(command)
+	found, err := Lookup(command, dir, path)
 	if err != nil {
 		return fmt.Errorf("command not found: %s", command)
 	}
 
-	fmt.Println(path)
+	fmt.Fprintln(w, found)
 	return nil
 }
@@ -7,9 +7,18 @@ import (
 	"strings"
 )
 
-func findExecutable(command string) (string, error) {
+// Find returns the path of the executable a command name resolves to
+func Find(command string) (string, error) {
+	return Lookup(command, ".", os.Getenv("PATH"))
+}
+
+// Lookup is Find for a shell in dir with pathList as its PATH
+func Lookup(command, dir, pathList string) (string, error) {
 	// If the command contains a path separator, check if it's an absolute path
 	if strings.ContainsRune(command, os.PathSeparator) {
+		if !filepath.IsAbs(command) {
+			command = filepath.Join(dir, command)
+		}
 		path, err := filepath.Abs(command)
 		if err == nil {
 			if isExecutable(path) {
@@ -20,17 +29,17 @@ func findExecutable(command string) (string, error) {
 	}
 
 	// Check in the current directory first
-	path := checkCurrentDir(command)
+	path := checkInDir(command, dir)
 	if path != "" {
 		return path, nil
 	}
 
 	// Check in PATH
-	pathEnv := os.Getenv("PATH")
-	pathDirs := filepath.SplitList(pathEnv)
-
-	for _, dir := range pathDirs {
-		path := checkInDir(command, dir)
+	for _, entry := range filepath.SplitList(pathList) {
+		if !filepath.IsAbs(entry) {
+			entry = filepath.Join(dir, entry)
+		}
+		path := checkInDir(command, entry)
 		if path != "" {
 			return path, nil
 		}
@@ -16,14 +16,6 @@ func isExecutable(path string) bool {
 	return !info.IsDir() && info.Mode()&0111 != 0
 }
 
-func checkCurrentDir(command string) string {
-	path := filepath.Join(".", command)
-	if isExecutable(path) {
-		return path
-	}
-	return ""
-}
-
 func checkInDir(command, dir string) string {
 	path := filepath.Join(dir, command)
 	if isExecutable(path) {
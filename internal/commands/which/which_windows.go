@@ -17,10 +17,6 @@ func isExecutable(path string) bool {
 	return !info.IsDir()
 }
 
-func checkCurrentDir(command string) string {
-	return findWindowsExecutable(command, ".")
-}
-
 func checkInDir(command, dir string) string {
 	return findWindowsExecutable(command, dir)
 }
@@ -0,0 +1,206 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// xargsExitCode is returned when any invocation of the command failed
+const xargsExitCode = 123
+
+type xargsOptions struct {
+	MaxArgs    int    // -n: arguments per command
+	MaxLines   int    // -L: input lines per command
+	Replace    string // -I: replace this string with each input line
+	Delimiter  string // -0 / -d: split input on this delimiter instead of blanks
+	NoRunEmpty bool   // -r: do not run the command when there is no input
+}
+
+// Xargs builds and runs commands from standard input,
+// e.g. `find . -name '*.tmp' | xargs -n 10 rm`
+func (s *Session) Xargs(args []string) error {
+	var options xargsOptions
+
+	i := 0
+	for ; i < len(args) && len(args[i]) > 1 && strings.HasPrefix(args[i], "-"); i++ {
+		if args[i] == "--" {
+			i++
+			break
+		}
+		flag, value := args[i][:2], args[i][2:]
+		needsValue := flag == "-n" || flag == "-L" || flag == "-I" || flag == "-d"
+		if needsValue && value == "" {
+			if i+1 >= len(args) {
+				return fmt.Errorf("missing value for %s", flag)
+			}
+			i++
+			value = args[i]
+		}
+
+		switch flag {
+		case "-n", "-L":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid value for %s: %s", flag, value)
+			}
+			if flag == "-n" {
+				options.MaxArgs = n
+			} else {
+				options.MaxLines = n
+			}
+		case "-I":
+			options.Replace = value
+		case "-0":
+			options.Delimiter = "\x00"
+		case "-d":
+			options.Delimiter = unescapeDelimiter(value)
+		case "-r":
+			options.NoRunEmpty = true
+		default:
+			return fmt.Errorf("usage: xargs [-0] [-d DELIM] [-n N] [-L N] [-I REPLACE] [-r] [command [args]]")
+		}
+	}
+
+	template := args[i:]
+	if len(template) == 0 {
+		template = []string{"echo"}
+	}
+
+	input, err := io.ReadAll(s.Stdin)
+	if err != nil {
+		return fmt.Errorf("failed to read input: %v", err)
+	}
+
+	batches := options.batches(string(input))
+	if len(batches) == 0 {
+		if options.NoRunEmpty || options.Replace != "" {
+			return nil
+		}
+		batches = [][]string{nil}
+	}
+
+	failed := false
+	for _, batch := range batches {
+		var argv []string
+		if options.Replace != "" {
+			argv, _ = replaceArgs(template, options.Replace, batch[0])
+		} else {
+			argv = append(append([]string(nil), template...), batch...)
+		}
+
+		if err := s.runCommand(Command{Name: argv[0], Args: argv[1:]}); err != nil {
+			fmt.Fprintf(s.Stderr, "xargs: %s: %v\n", argv[0], err)
+			failed = true
+		}
+	}
+
+	if failed {
+		return &ExitStatus{Code: xargsExitCode, Reason: "xargs: one or more commands failed"}
+	}
+	return nil
+}
+
+// batches splits the input into the argument lists of each invocation
+func (o xargsOptions) batches(input string) [][]string {
+	var batches [][]string
+
+	switch {
+	case o.Replace != "":
+		for _, line := range splitInputLines(input) {
+			batches = append(batches, []string{strings.TrimLeft(line, " \t")})
+		}
+		return batches
+	case o.MaxLines > 0:
+		var batch []string
+		lines := 0
+		for _, line := range splitInputLines(input) {
+			fields := strings.Fields(line)
+			if len(fields) == 0 {
+				continue
+			}
+			batch = append(batch, fields...)
+			lines++
+			if lines == o.MaxLines {
+				batches = append(batches, batch)
+				batch, lines = nil, 0
+			}
+		}
+		if len(batch) > 0 {
+			batches = append(batches, batch)
+		}
+		return batches
+	}
+
+	var items []string
+	if o.Delimiter != "" {
+		for _, item := range strings.Split(input, o.Delimiter) {
+			if item != "" {
+				items = append(items, item)
+			}
+		}
+		// A trailing newline is not part of the last item
+		if n := len(items); n > 0 && o.Delimiter != "\n" {
+			items[n-1] = strings.TrimSuffix(items[n-1], "\n")
+			if items[n-1] == "" {
+				items = items[:n-1]
+			}
+		}
+	} else {
+		items = strings.Fields(input)
+	}
+
+	if len(items) == 0 {
+		return nil
+	}
+	if o.MaxArgs == 0 {
+		return [][]string{items}
+	}
+	for len(items) > 0 {
+		n := o.MaxArgs
+		if n > len(items) {
+			n = len(items)
+		}
+		batches = append(batches, items[:n])
+		items = items[n:]
+	}
+	return batches
+}
+
+// replaceArgs substitutes value for every occurrence of placeholder in the
+// command words and reports whether any word contained it
+func replaceArgs(template []string, placeholder, value string) (argv []string, replaced bool) {
+	argv = make([]string, len(template))
+	for i, word := range template {
+		if strings.Contains(word, placeholder) {
+			replaced = true
+		}
+		argv[i] = strings.ReplaceAll(word, placeholder, value)
+	}
+	return argv, replaced
+}
+
+// splitInputLines splits input into non-empty lines
+func splitInputLines(input string) []string {
+	var lines []string
+	for _, line := range strings.Split(input, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func unescapeDelimiter(delim string) string {
+	switch delim {
+	case `\n`:
+		return "\n"
+	case `\t`:
+		return "\t"
+	case `\0`:
+		return "\x00"
+	}
+	return delim
+}
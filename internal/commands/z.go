@@ -0,0 +1,131 @@
+package commands
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"commandripple/internal/commands/finder"
+	"commandripple/internal/commands/frecency"
+)
+
+// dirStore is the frecency database, opened on first use
+var dirStore *frecency.Store
+
+func openDirStore() (*frecency.Store, error) {
+	if dirStore != nil {
+		return dirStore, nil
+	}
+	path, err := frecency.DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	store, err := frecency.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	dirStore = store
+	return store, nil
+}
+
+// RecordDirectory adds a visit to dir to the frecency database
+func (s *Session) RecordDirectory(dir string) {
+	store, err := openDirStore()
+	if err == nil {
+		err = store.Add(dir, time.Now())
+	}
+	if err != nil {
+		fmt.Fprintf(s.Stderr, "CommandRipple: %v\n", err)
+	}
+}
+
+// `z` command implementation: jumps to the most frecent directory matching
+// every term, e.g. `z api` or `z src api`. With -l the matches are listed
+// with their scores, -x removes a directory (the current one by default)
+// and -i always chooses interactively. When the best match does not clearly
+// beat the second one the finder is opened to choose between them.
+func (s *Session) Z(args []string) error {
+	list, interactive := false, false
+	var terms []string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-l", "--list":
+			list = true
+		case "-i", "--interactive":
+			interactive = true
+		case "-x", "--remove":
+			return s.zRemove(args[i+1:])
+		case "--":
+			terms = append(terms, args[i+1:]...)
+			i = len(args)
+		default:
+			terms = append(terms, args[i])
+		}
+	}
+
+	store, err := openDirStore()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	matches, err := store.Query(terms, now)
+	if err != nil {
+		return err
+	}
+
+	if list || len(terms) == 0 && !interactive {
+		// Lowest score first so that the best match ends up next to the prompt
+		for i := len(matches) - 1; i >= 0; i-- {
+			fmt.Fprintf(s.Stdout, "%10.1f  %s\n", matches[i].Score(now), matches[i].Path)
+		}
+		return nil
+	}
+	if len(matches) == 0 {
+		return fmt.Errorf("z: no directory matches %s", strings.Join(terms, " "))
+	}
+
+	target := matches[0].Path
+	ambiguous := len(matches) > 1 && matches[0].Score(now) < 2*matches[1].Score(now)
+	if (interactive || ambiguous) && isTerminal(s.Stdin) {
+		paths := make([]string, len(matches))
+		for i, m := range matches {
+			paths[i] = m.Path
+		}
+		selected, err := pickOnTerminal(paths, finder.Options{Prompt: "z> ", Preview: previewFile})
+		if err == finder.ErrCancelled {
+			return &ExitStatus{Code: 130, Reason: "z: cancelled"}
+		}
+		if err != nil {
+			return err
+		}
+		target = selected[0]
+	}
+	return s.ChangeDirectory([]string{target})
+}
+
+// zRemove forgets the given directories, or the current one
+func (s *Session) zRemove(args []string) error {
+	if len(args) == 0 {
+		args = []string{s.Dir()}
+	}
+
+	store, err := openDirStore()
+	if err != nil {
+		return err
+	}
+	for _, arg := range args {
+		dir, err := filepath.Abs(s.path(arg))
+		if err != nil {
+			return err
+		}
+		removed, err := store.Remove(dir)
+		if err != nil {
+			return err
+		}
+		if !removed {
+			return fmt.Errorf("z: %s is not in the database", dir)
+		}
+	}
+	return nil
+}
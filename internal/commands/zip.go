@@ -9,18 +9,18 @@ import (
 	"strings"
 )
 
-func Compress(args []string) error {
+func (s *Session) Compress(args []string) error {
 	if len(args) < 2 {
 		return fmt.Errorf("usage: compress [source] [destination.zip]")
 	}
-	return zipSource(args[0], args[1])
+	return zipSource(s.path(args[0]), s.path(args[1]))
 }
 
-func Decompress(args []string) error {
+func (s *Session) Decompress(args []string) error {
 	if len(args) < 2 {
 		return fmt.Errorf("usage: decompress [source.zip] [destination]")
 	}
-	return unzip(args[0], args[1])
+	return unzip(s.path(args[0]), s.path(args[1]))
 }
 
 func zipSource(source, target string) error {
@@ -0,0 +1,126 @@
+// Package shell embeds CommandRipple in other programs. A Shell is one
+// session with its own working directory, environment, aliases and
+// standard streams, so several can run in one process without touching the
+// process's own directory or environment:
+//
+//	sh := shell.New()
+//	sh.Dir = "/srv/app"
+//	sh.Stdout = &out
+//	status, err := sh.Run(ctx, "export MODE=test\ngo test ./... | tail -n 1")
+//
+// Scripts use the syntax and the builtins of the interactive shell: one
+// command line per line, with quotes, $VARIABLES, NAME=value assignments,
+// aliases, pipes and redirections. Builtins write to the Shell's streams;
+// other commands run as programs found on the session's PATH, in a process
+// group that is killed when the context of Run is done. Commands cannot be
+// scheduled with at, every or cron, which would outlive Run, and services
+// cannot be started with svc, their supervisor is a new process of the
+// commandripple program.
+package shell
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+
+	"commandripple/internal/commands"
+)
+
+// Shell is an embedded session. Its fields may be changed between calls to
+// Run; cd and export change Dir and Env while a script runs.
+type Shell struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+	Dir    string   // Working directory
+	Env    []string // NAME=value, as in os.Environ
+
+	mu      sync.Mutex
+	session *commands.Session
+}
+
+// New returns a session that starts in the process's working directory and
+// environment and uses its standard streams
+func New() *Shell {
+	dir, _ := os.Getwd()
+	return &Shell{
+		Stdin:   os.Stdin,
+		Stdout:  os.Stdout,
+		Stderr:  os.Stderr,
+		Dir:     dir,
+		Env:     os.Environ(),
+		session: commands.NewSession(dir, os.Environ()),
+	}
+}
+
+// Run runs a script and returns the exit status of its last command, or
+// the status given to exit. Commands that fail are reported on Stderr and
+// the script goes on, like source does. The error is only set when ctx is
+// done, which also kills the process groups of the programs still running.
+func (s *Shell) Run(ctx context.Context, script string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session := s.sync()
+	session.Stdin, session.Stdout, session.Stderr = s.Stdin, s.Stdout, s.Stderr
+	defer func() {
+		s.Dir = session.Dir()
+		s.Env = session.Environ()
+	}()
+	return session.Run(ctx, script)
+}
+
+// sync hands Dir and Env, which may have been changed since the last call,
+// to the session
+func (s *Shell) sync() *commands.Session {
+	if s.session == nil {
+		s.session = commands.NewSession(s.Dir, s.Env)
+	}
+	if s.Dir != "" && s.Dir != s.session.Dir() {
+		// A directory that is gone shows up as a failure of the commands
+		// run in it, as it would after it was removed while running
+		s.session.SetDir(s.Dir)
+	}
+	s.session.SetEnviron(s.Env)
+	return s.session
+}
+
+// Status returns the exit status of the last command
+func (s *Shell) Status() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sync().Status()
+}
+
+// Getenv returns a variable of the session. Like the other methods it
+// waits for a script that is running.
+func (s *Shell) Getenv(name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sync().Getenv(name)
+}
+
+// Setenv sets a variable of the session
+func (s *Shell) Setenv(name, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session := s.sync()
+	session.Setenv(name, value)
+	s.Env = session.Environ()
+}
+
+// Unsetenv removes a variable of the session
+func (s *Shell) Unsetenv(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session := s.sync()
+	session.Unsetenv(name)
+	s.Env = session.Environ()
+}
+
+// Alias defines an alias, or removes it when command is empty
+func (s *Shell) Alias(name, command string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sync().Alias(name, command)
+}
@@ -0,0 +1,198 @@
+package shell
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func newShell(t *testing.T) (*Shell, *strings.Builder) {
+	t.Helper()
+	var out strings.Builder
+	sh := New()
+	sh.Dir = t.TempDir()
+	sh.Stdin = strings.NewReader("")
+	sh.Stdout, sh.Stderr = &out, &out
+	return sh, &out
+}
+
+func TestSessionsAreSeparate(t *testing.T) {
+	shells := make([]*Shell, 2)
+	outputs := make([]*strings.Builder, 2)
+	for i := range shells {
+		shells[i], outputs[i] = newShell(t)
+	}
+	cwd, _ := os.Getwd()
+
+	var wg sync.WaitGroup
+	for i, sh := range shells {
+		wg.Add(1)
+		go func(i int, sh *Shell) {
+			defer wg.Done()
+			script := "mkdir sub\ncd sub\nexport N=" + string(rune('a'+i)) + "\necho $N > n.txt\ncat n.txt\npwd"
+			if status, err := sh.Run(context.Background(), script); status != 0 || err != nil {
+				t.Errorf("shell %d: Run = %d, %v", i, status, err)
+			}
+		}(i, sh)
+	}
+	wg.Wait()
+
+	for i, sh := range shells {
+		want := string(rune('a' + i))
+		if got := sh.Getenv("N"); got != want {
+			t.Errorf("shell %d: N = %q, want %q", i, got, want)
+		}
+		if filepath.Base(sh.Dir) != "sub" {
+			t.Errorf("shell %d: Dir = %s, want the sub directory", i, sh.Dir)
+		}
+		if out := outputs[i].String(); !strings.Contains(out, want+"\n") || !strings.Contains(out, sh.Dir) {
+			t.Errorf("shell %d wrote %q, want %s and its directory", i, out, want)
+		}
+	}
+	if now, _ := os.Getwd(); now != cwd {
+		t.Errorf("the process moved to %s", now)
+	}
+	if _, ok := os.LookupEnv("N"); ok {
+		t.Error("N was set in the process's environment")
+	}
+
+	// Limits set with ulimit belong to the session as well
+	limit := func(sh *Shell) string {
+		var out strings.Builder
+		sh.Stdout = &out
+		sh.Run(context.Background(), "ulimit -n")
+		return out.String()
+	}
+	before := limit(shells[1])
+	shells[0].Run(context.Background(), "ulimit -n 40")
+	if got := limit(shells[0]); !strings.HasSuffix(got, " 40\n") {
+		t.Errorf("ulimit -n after setting 40 = %q", got)
+	}
+	if got := limit(shells[1]); got != before {
+		t.Errorf("ulimit -n of the other shell = %q, want %q as before", got, before)
+	}
+}
+
+func TestExitStatus(t *testing.T) {
+	sh, _ := newShell(t)
+	status, err := sh.Run(context.Background(), "false\nexit 3\necho unreachable")
+	if status != 3 || err != nil {
+		t.Errorf("Run = %d, %v, want 3", status, err)
+	}
+	if status, _ := sh.Run(context.Background(), "false"); status != 1 {
+		t.Errorf("false = %d, want 1", status)
+	}
+}
+
+func TestCancelKillsProcessGroup(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+	sh, _ := newShell(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := sh.Run(ctx, "sh -c 'sleep 100 & wait'")
+	if err != context.DeadlineExceeded {
+		t.Errorf("Run = %v, want context.DeadlineExceeded", err)
+	}
+	// The sleep holds the output pipe, so Run only returns early when the
+	// kill reached it and not just sh
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Run returned after %v", elapsed)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("resource limits are only set on Linux")
+	}
+	// The test binary does not handle the shell's own modes, as in any
+	// program embedding it
+	sh, out := newShell(t)
+	script := "limit --files 64 -- sh -c 'ulimit -n'\nulimit -n 32\nsh -c 'ulimit -n'"
+	if status, err := sh.Run(context.Background(), script); status != 0 || err != nil {
+		t.Fatalf("Run = %d, %v, output %q", status, err, out)
+	}
+	if got, want := out.String(), "64\n32\n"; got != want {
+		t.Errorf("limits seen by the commands = %q, want %q", got, want)
+	}
+}
+
+func TestServicesRefused(t *testing.T) {
+	// Starting the supervisor would run the test binary's main
+	sh, out := newShell(t)
+	status, err := sh.Run(context.Background(), "svc start web -- sleep 100")
+	if status == 0 || err != nil || !strings.Contains(out.String(), "only be started by the commandripple shell") {
+		t.Errorf("svc start = %d, %v, output %q, want a refusal", status, err, out)
+	}
+}
+
+func TestSchedulingRefused(t *testing.T) {
+	// The runs would outlive Run and the context it was given
+	for _, line := range []string{"at +1s touch at", "every 1s touch every", "cron @hourly touch cron"} {
+		sh, out := newShell(t)
+		status, err := sh.Run(context.Background(), line+"\nschedule list")
+		if status != 0 || err != nil || !strings.Contains(out.String(), "can only be scheduled in the interactive shell") ||
+			!strings.Contains(out.String(), "No scheduled commands.") {
+			t.Errorf("%s = %d, %v, output %q, want a refusal", line, status, err, out)
+		}
+	}
+}
+
+func TestPluginsOnSessionPath(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+	// The plugin is only on the session's PATH and describes itself with a
+	// variable of the session
+	dir := t.TempDir()
+	script := `#!/bin/sh
+if [ -n "$COMMANDRIPPLE_HANDSHAKE" ]; then
+	echo "{\"protocol\":1,\"help\":\"Greets $GREETING\"}"
+else
+	echo "hello $GREETING"
+fi
+`
+	if err := os.WriteFile(filepath.Join(dir, "commandripple-greet"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	sh, out := newShell(t)
+	sh.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	sh.Setenv("GREETING", "embedders")
+	if status, err := sh.Run(context.Background(), "greet\nplugin info greet"); status != 0 || err != nil {
+		t.Fatalf("Run = %d, %v, output %q", status, err, out)
+	}
+	for _, want := range []string{"hello embedders\n", "Greets embedders\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output %q lacks %q", out, want)
+		}
+	}
+}
+
+func TestNohup(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("reads /proc")
+	}
+	// Output that does not go to a terminal stays where it goes
+	sh, out := newShell(t)
+	if status, err := sh.Run(context.Background(), "nohup grep SigIgn /proc/self/status\nwait"); status != 0 || err != nil {
+		t.Fatalf("Run = %d, %v, output %q", status, err, out)
+	}
+	if _, err := os.Stat(filepath.Join(sh.Dir, "nohup.out")); err == nil {
+		t.Error("nohup wrote nohup.out although the output is no terminal")
+	}
+	_, mask, _ := strings.Cut(out.String(), "SigIgn:")
+	ignored, err := strconv.ParseUint(strings.TrimSpace(strings.SplitN(mask, "\n", 2)[0]), 16, 64)
+	if err != nil || ignored&(1<<(syscall.SIGHUP-1)) == 0 {
+		t.Errorf("nohup ran %q, want SIGHUP ignored", out)
+	}
+}